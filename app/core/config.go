@@ -8,6 +8,7 @@ import (
 	"github.com/BurntSushi/toml"
 
 	"github.com/breeew/brew-api/app/core/srv"
+	"github.com/breeew/brew-api/app/store/qdrant"
 )
 
 func MustLoadBaseConfig(path string) CoreConfig {
@@ -57,6 +58,7 @@ type CoreConfig struct {
 	Addr     string   `toml:"addr"`
 	Log      Log      `toml:"log"`
 	Postgres PGConfig `toml:"postgres"`
+	VectorDB VectorDB `toml:"vector_db"`
 	Site     Site     `toml:"site"`
 
 	AI srv.AIConfig `toml:"ai"`
@@ -99,6 +101,7 @@ func (c *CoreConfig) FromENV() {
 	c.Addr = os.Getenv("BREW_API_SERVICE_ADDRESS")
 	c.Log.FromENV()
 	c.Postgres.FromENV()
+	c.VectorDB.FromENV()
 	c.AI.FromENV()
 }
 
@@ -114,6 +117,21 @@ func (c PGConfig) FormatDSN() string {
 	return c.DSN
 }
 
+const (
+	VECTOR_DB_DRIVER_PGVECTOR = "pgvector"
+	VECTOR_DB_DRIVER_QDRANT   = qdrant.NAME
+)
+
+type VectorDB struct {
+	Driver string        `toml:"driver"` // default: pgvector, 与 postgres 共用连接
+	Qdrant qdrant.Config `toml:"qdrant"`
+}
+
+func (v *VectorDB) FromENV() {
+	v.Driver = os.Getenv("BREW_API_VECTOR_DB_DRIVER")
+	v.Qdrant.FromENV()
+}
+
 type Log struct {
 	Level string `toml:"level"`
 	Path  string `toml:"path"`
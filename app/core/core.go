@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,7 +15,9 @@ import (
 
 	"github.com/breeew/brew-api/app/core/srv"
 	"github.com/breeew/brew-api/app/store"
+	"github.com/breeew/brew-api/app/store/qdrant"
 	"github.com/breeew/brew-api/app/store/sqlstore"
+	"github.com/breeew/brew-api/pkg/types"
 )
 
 type Core struct {
@@ -61,7 +64,7 @@ func MustSetupCore(cfg CoreConfig) *Core {
 	// setup store
 	setupMysqlStore(core)
 
-	core.srv = srv.SetupSrvs(srv.ApplyAI(cfg.AI), // ai provider select
+	core.srv = srv.SetupSrvs(srv.ApplyAI(cfg.AI, core.httpClient), // ai provider select
 		// web socket
 		srv.ApplyTower())
 
@@ -114,6 +117,31 @@ func (s *Core) Metrics() *Metrics {
 
 func setupMysqlStore(core *Core) {
 	core.stores = sqlstore.MustSetup(core.cfg.Postgres)
+	setupVectorStore(core)
+}
+
+// setupVectorStore 根据配置替换默认的 pgvector 向量存储
+func setupVectorStore(core *Core) {
+	switch strings.ToLower(core.cfg.VectorDB.Driver) {
+	case "", VECTOR_DB_DRIVER_PGVECTOR:
+		return
+	case VECTOR_DB_DRIVER_QDRANT:
+		s := qdrant.NewVectorStore(core.cfg.VectorDB.Qdrant)
+		s.SetKnowledgeResolver(func(ctx context.Context, spaceID string, filter *types.KnowledgeFilter) ([]string, error) {
+			return core.stores().KnowledgeStore().ListKnowledgeIDs(ctx, types.GetKnowledgeOptions{
+				SpaceID: spaceID,
+				Filter:  filter,
+			}, 0, 0)
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		if err := s.Install(ctx); err != nil {
+			panic(err)
+		}
+		core.stores().SetVectorStore(s)
+	default:
+		panic("Setup vector db driver not found: " + core.cfg.VectorDB.Driver)
+	}
 }
 
 func (s *Core) Store() *sqlstore.Provider {
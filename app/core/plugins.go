@@ -22,8 +22,13 @@ type Plugins interface {
 	AIChatLogic(agentType string, receiver types.Receiver) AIChatLogic
 	EncryptData(data []byte) ([]byte, error)
 	DecryptData(data []byte) ([]byte, error)
+	EncryptKeywords(tokens []string) []string
+	// HashContent 返回明文内容的带密钥哈希，内容为空时返回空字符串
+	// 判断重复内容时需要先经过 dedup.Normalize 归一化
+	HashContent(text string) string
 	DeleteSpace(ctx context.Context, spaceID string) error
-	Rerank(query string, knowledges []*types.Knowledge) ([]*types.Knowledge, *ai.Usage, error)
+	// Rerank 返回重排后的知识点及重排模型给出的得分，未经过重排模型时得分为空
+	Rerank(query string, knowledges []*types.Knowledge) ([]*types.Knowledge, []ai.RankDocItem, *ai.Usage, error)
 	AppendKnowledgeContentToDocs(docs []*types.PassageInfo, knowledges []*types.Knowledge) ([]*types.PassageInfo, error)
 	Cache() Cache
 }
@@ -3,13 +3,22 @@ package srv
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"net/http"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/samber/lo"
 
 	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/ai/anthropic"
 	"github.com/breeew/brew-api/pkg/ai/azure_openai"
+	"github.com/breeew/brew-api/pkg/ai/cohere"
 	"github.com/breeew/brew-api/pkg/ai/deepseek"
+	"github.com/breeew/brew-api/pkg/ai/gemini"
 	"github.com/breeew/brew-api/pkg/ai/jina"
+	"github.com/breeew/brew-api/pkg/ai/local"
 	"github.com/breeew/brew-api/pkg/ai/ollama"
 	"github.com/breeew/brew-api/pkg/ai/openai"
 	"github.com/breeew/brew-api/pkg/ai/qwen"
@@ -19,7 +28,6 @@ import (
 type ChatAI interface {
 	Summarize(ctx context.Context, doc *string) (ai.SummarizeResult, error)
 	Chunk(ctx context.Context, doc *string) (ai.ChunkResult, error)
-	MsgIsOverLimit(msgs []*types.MessageContext) bool
 	NewQuery(ctx context.Context, msgs []*types.MessageContext) *ai.QueryOptions
 	Lang() string
 }
@@ -52,17 +60,26 @@ type AIDriver interface {
 	ReaderAI
 	VisionAI
 	RerankAI
+	EmbeddingWith(driver string) (EmbeddingAI, bool)
+	ChatModel() string
+	MsgIsOverLimit(msgs []*types.MessageContext) bool
 }
 
 type AIConfig struct {
-	Gemini   Gemini      `toml:"gemini"`
-	Openai   Openai      `toml:"openai"`
-	QWen     QWen        `toml:"qwen"`
-	DeepSeek DeepSeek    `toml:"deepseek"`
-	Jina     Jina        `toml:"jina"`
-	Azure    AzureOpenai `toml:"azure_openai"`
-	Ollama   Ollama      `toml:"ollama"`
-	Agent    AgentDriver `toml:"agent"`
+	Gemini    Gemini      `toml:"gemini"`
+	Anthropic Anthropic   `toml:"anthropic"`
+	Openai    Openai      `toml:"openai"`
+	QWen      QWen        `toml:"qwen"`
+	DeepSeek  DeepSeek    `toml:"deepseek"`
+	Jina      Jina        `toml:"jina"`
+	Azure     AzureOpenai `toml:"azure_openai"`
+	Ollama    Ollama      `toml:"ollama"`
+	Agent     AgentDriver `toml:"agent"`
+	Local     LocalReader `toml:"local"`
+	Cohere    Cohere      `toml:"cohere"`
+	Rerank    Rerank      `toml:"rerank"`
+	Context   RAGContext  `toml:"context"`
+	Window    ChatWindow  `toml:"window"`
 	// Usage list
 	// embedding.query
 	// embedding.document
@@ -70,7 +87,69 @@ type AIConfig struct {
 	// summarize
 	// enhance_query
 	// reader
-	Usage map[string]string `toml:"usage"`
+	// rerank
+	// 每个 usage 可以配置多个驱动作为备用链，如 query = ["openai", "azure_openai"]
+	// embedding 与 rerank 只使用第一个驱动，不同模型的向量不能混用
+	Usage    map[string]UsageDrivers `toml:"usage"`
+	Failover AIFailover              `toml:"failover"`
+}
+
+// Rerank 检索结果的重排，usage.rerank 指定重排驱动，未指定时保持检索顺序，请求失败时使用本地的词法重排
+type Rerank struct {
+	TopK     int     `toml:"top_k"`     // 重排后最多保留的知识点数量，0 表示不限制
+	MinScore float64 `toml:"min_score"` // 得分低于该值的知识点不会提供给模型，不同驱动的得分范围不同，0 表示不限制
+}
+
+const (
+	DEFAULT_RAG_CONTEXT_TOKENS    = 6000
+	DEFAULT_RAG_CONTEXT_NEIGHBORS = 1
+)
+
+// RAGContext 放入对话上下文的知识内容的 token 预算
+type RAGContext struct {
+	MaxTokens int            `toml:"max_tokens"` // 默认预算，默认 6000
+	Models    map[string]int `toml:"models"`     // 按对话模型覆盖默认预算，<0 表示不限制
+	Neighbors *int           `toml:"neighbors"`  // 每个命中片段向两侧补充的相邻片段数，默认 1
+}
+
+// Budget 返回对话模型可用于知识内容的 token 数，<=0 表示不限制
+func (c RAGContext) Budget(model string) int {
+	if v, ok := c.Models[model]; ok {
+		return v
+	}
+	if c.MaxTokens != 0 {
+		return c.MaxTokens
+	}
+	return DEFAULT_RAG_CONTEXT_TOKENS
+}
+
+func (c RAGContext) NeighborChunks() int {
+	if c.Neighbors == nil {
+		return DEFAULT_RAG_CONTEXT_NEIGHBORS
+	}
+	return *c.Neighbors
+}
+
+const DEFAULT_RESERVED_COMPLETION_TOKENS = 2048
+
+// ChatWindow 对话模型的上下文窗口，请求内容(提示词+知识内容+历史消息)超出窗口减去为回复预留的 token 数时先总结历史消息
+type ChatWindow struct {
+	Models             map[string]int `toml:"models"`              // 按对话模型覆盖内置的上下文窗口大小
+	ReservedCompletion int            `toml:"reserved_completion"` // 为模型回复预留的 token 数，默认 2048
+}
+
+// Limit 返回对话模型请求内容可使用的 token 数
+func (c ChatWindow) Limit(model string) int {
+	window, ok := c.Models[model]
+	if !ok || window <= 0 {
+		window = ai.ContextWindow(model)
+	}
+	reserved := c.ReservedCompletion
+	if reserved <= 0 {
+		reserved = DEFAULT_RESERVED_COMPLETION_TOKENS
+	}
+	// 窗口较小的模型至少保留一半给请求内容
+	return max(window-reserved, window/2)
 }
 
 type AgentDriver struct {
@@ -88,7 +167,7 @@ type Jina struct {
 
 func (cfg *Jina) Install(root *AI) {
 	var oai any
-	oai = jina.New(cfg.Token, cfg.Models)
+	oai = jina.New(cfg.Token, cfg.ApiEndpoint, cfg.Models)
 
 	installAI(root, jina.NAME, oai)
 }
@@ -96,23 +175,76 @@ func (cfg *Jina) Install(root *AI) {
 func (c *Jina) FromENV() {
 	c.Token = os.Getenv("BREW_API_AI_JINA_TOKEN")
 	c.ReaderEndpoint = os.Getenv("BREW_API_AI_JINA_READER_ENDPOINT")
+	c.ApiEndpoint = os.Getenv("BREW_API_AI_JINA_API_ENDPOINT")
+}
+
+// Cohere cohere 或兼容 cohere rerank 接口的服务，只提供 rerank，未配置 token 和 endpoint 时不安装
+type Cohere struct {
+	Token       string `toml:"token"`
+	Endpoint    string `toml:"endpoint"` // 完整的 rerank 接口地址，默认 https://api.cohere.com/v2/rerank
+	RerankModel string `toml:"rerank_model"`
+}
+
+func (cfg *Cohere) Install(root *AI) {
+	if cfg.Token == "" && cfg.Endpoint == "" {
+		return
+	}
+	var oai any
+	oai = cohere.New(cfg.Token, cfg.Endpoint, cfg.RerankModel)
+
+	installAI(root, cohere.NAME, oai)
+}
+
+func (c *Cohere) FromENV() {
+	c.Token = os.Getenv("BREW_API_AI_COHERE_TOKEN")
+	c.Endpoint = os.Getenv("BREW_API_AI_COHERE_ENDPOINT")
+}
+
+// LocalReader 本地 reader，直接抓取网页转换为 markdown，无需访问 jina 等外部服务
+type LocalReader struct {
+	UserAgent string `toml:"user_agent"`
+	Timeout   int    `toml:"timeout"` // 抓取网页的超时时间(秒)，默认 30 秒
+}
+
+func (cfg *LocalReader) Install(root *AI, client *http.Client) {
+	c := http.Client{}
+	if client != nil {
+		c = *client
+	}
+	c.Timeout = time.Second * 30
+	if cfg.Timeout > 0 {
+		c.Timeout = time.Second * time.Duration(cfg.Timeout)
+	}
+
+	var oai any
+	oai = local.New(&c, cfg.UserAgent)
+
+	installAI(root, local.NAME, oai)
+}
+
+func (c *LocalReader) FromENV() {
+	c.UserAgent = os.Getenv("BREW_API_AI_LOCAL_USER_AGENT")
 }
 
 func (c *AIConfig) FromENV() {
-	c.Usage = make(map[string]string)
-	c.Usage["embedding.query"] = os.Getenv("BREW_API_AI_USAGE_E_QUERY")
-	c.Usage["embedding.document"] = os.Getenv("BREW_API_AI_USAGE_E_DOCUMENT")
-	c.Usage["query"] = os.Getenv("BREW_API_AI_USAGE_QUERY")
-	c.Usage["summarize"] = os.Getenv("BREW_API_AI_USAGE_SUMMARIZE")
-	c.Usage["enhance_query"] = os.Getenv("BREW_API_AI_USAGE_ENHANCE_QUERY")
-	c.Usage["reader"] = os.Getenv("BREW_API_AI_USAGE_READER")
+	c.Usage = make(map[string]UsageDrivers)
+	c.Usage["embedding.query"] = ParseUsageDrivers(os.Getenv("BREW_API_AI_USAGE_E_QUERY"))
+	c.Usage["embedding.document"] = ParseUsageDrivers(os.Getenv("BREW_API_AI_USAGE_E_DOCUMENT"))
+	c.Usage["query"] = ParseUsageDrivers(os.Getenv("BREW_API_AI_USAGE_QUERY"))
+	c.Usage["summarize"] = ParseUsageDrivers(os.Getenv("BREW_API_AI_USAGE_SUMMARIZE"))
+	c.Usage["enhance_query"] = ParseUsageDrivers(os.Getenv("BREW_API_AI_USAGE_ENHANCE_QUERY"))
+	c.Usage["reader"] = ParseUsageDrivers(os.Getenv("BREW_API_AI_USAGE_READER"))
+	c.Usage["rerank"] = ParseUsageDrivers(os.Getenv("BREW_API_AI_USAGE_RERANK"))
 
 	c.Gemini.FromENV()
+	c.Anthropic.FromENV()
 	c.Openai.FromENV()
 	c.Azure.FromENV()
 	c.QWen.FromENV()
 	c.Jina.FromENV()
 	c.DeepSeek.FromENV()
+	c.Local.FromENV()
+	c.Cohere.FromENV()
 }
 
 func (c *DeepSeek) FromENV() {
@@ -122,6 +254,12 @@ func (c *DeepSeek) FromENV() {
 
 func (c *Gemini) FromENV() {
 	c.Token = os.Getenv("BREW_API_AI_GEMINI_TOKEN")
+	c.Endpoint = os.Getenv("BREW_API_AI_GEMINI_ENDPOINT")
+}
+
+func (c *Anthropic) FromENV() {
+	c.Token = os.Getenv("BREW_API_AI_ANTHROPIC_TOKEN")
+	c.Endpoint = os.Getenv("BREW_API_AI_ANTHROPIC_ENDPOINT")
 }
 
 func (c *Openai) FromENV() {
@@ -139,8 +277,47 @@ func (c *QWen) FromENV() {
 	c.Endpoint = os.Getenv("BREW_API_AI_ALI_ENDPOINT")
 }
 
+// Gemini 未配置 token 时不安装
 type Gemini struct {
-	Token string `toml:"token"`
+	Token          string `toml:"token"`
+	Endpoint       string `toml:"endpoint"`
+	EmbeddingModel string `toml:"embedding_model"`
+	ChatModel      string `toml:"chat_model"`
+}
+
+func (cfg *Gemini) Install(root *AI) {
+	if cfg.Token == "" {
+		return
+	}
+	d, err := gemini.New(cfg.Token, cfg.Endpoint, ai.ModelName{
+		ChatModel:      cfg.ChatModel,
+		EmbeddingModel: cfg.EmbeddingModel,
+	})
+	if err != nil {
+		slog.Error("Failed to install gemini driver", slog.String("error", err.Error()))
+		return
+	}
+
+	installAI(root, gemini.NAME, d)
+}
+
+// Anthropic anthropic 或兼容 messages 接口的服务，不提供 embedding，未配置 token 时不安装
+type Anthropic struct {
+	Token     string `toml:"token"`
+	Endpoint  string `toml:"endpoint"`
+	ChatModel string `toml:"chat_model"`
+}
+
+func (cfg *Anthropic) Install(root *AI) {
+	if cfg.Token == "" {
+		return
+	}
+	var oai any
+	oai = anthropic.New(cfg.Token, cfg.Endpoint, ai.ModelName{
+		ChatModel: cfg.ChatModel,
+	})
+
+	installAI(root, anthropic.NAME, oai)
 }
 
 type DeepSeek struct {
@@ -249,6 +426,9 @@ type AI struct {
 	readerDefault  ReaderAI
 	visionDefault  VisionAI
 	rerankDefault  RerankAI
+
+	window ChatWindow
+	order  []string // 驱动的安装顺序，用于确定默认驱动
 }
 
 func (s *AI) NewQuery(ctx context.Context, query []*types.MessageContext) *ai.QueryOptions {
@@ -265,13 +445,43 @@ func (s *AI) NewVisionQuery(ctx context.Context, query []*types.MessageContext)
 	return s.chatDefault.NewQuery(ctx, query)
 }
 
+// Rerank 使用 usage.rerank 指定的驱动重排，请求失败时降级为默认的本地词法重排
+// Rerank 未指定 usage.rerank 时返回 ERROR_UNSUPPORTED_FEATURE，由调用方保持检索顺序
+// 指定的驱动请求失败时使用本地的词法重排兜底
 func (s *AI) Rerank(ctx context.Context, query string, docs []*ai.RerankDoc) ([]ai.RankDocItem, *ai.Usage, error) {
-	if d := s.rerankUsage["rerank"]; d != nil {
-		return d.Rerank(ctx, query, docs)
+	d := s.rerankUsage["rerank"]
+	if d == nil {
+		return nil, nil, ERROR_UNSUPPORTED_FEATURE
+	}
+	res, usage, err := d.Rerank(ctx, query, docs)
+	if err == nil || s.rerankDefault == nil || d == s.rerankDefault {
+		return res, usage, err
 	}
+	slog.Error("Failed to rerank, fallback to default reranker", slog.String("error", err.Error()))
 	return s.rerankDefault.Rerank(ctx, query, docs)
 }
 
+// queryDriver 返回 usage.query 当前使用的驱动，备用链返回其中未被熔断的第一个驱动
+func (s *AI) queryDriver() ChatAI {
+	d := s.chatUsage["query"]
+	if d == nil {
+		d = s.chatDefault
+	}
+	if c, ok := d.(*chatChain); ok {
+		return c.active()
+	}
+	return d
+}
+
+// ChatModel 返回 usage.query 当前使用的驱动的对话模型，驱动未提供模型名称时返回空字符串
+func (s *AI) ChatModel() string {
+	d := s.queryDriver()
+	if m, ok := d.(interface{ ChatModel() string }); ok {
+		return m.ChatModel()
+	}
+	return ""
+}
+
 func (s *AI) Lang() string {
 	if d := s.chatUsage["query"]; d != nil {
 		return d.Lang()
@@ -293,6 +503,15 @@ func (s *AI) EmbeddingForDocument(ctx context.Context, title string, content []s
 	return s.embedDefault.EmbeddingForQuery(ctx, content)
 }
 
+// EmbeddingWith 返回指定名称的 embedding 驱动，driver 为空时按照 usage 配置选择
+func (s *AI) EmbeddingWith(driver string) (EmbeddingAI, bool) {
+	if driver == "" {
+		return s, true
+	}
+	d, ok := s.embedDrivers[strings.ToLower(driver)]
+	return d, ok
+}
+
 func (s *AI) Summarize(ctx context.Context, doc *string) (ai.SummarizeResult, error) {
 	if d := s.chatUsage["summarize"]; d != nil {
 		return d.Summarize(ctx, doc)
@@ -314,9 +533,19 @@ func (s *AI) NewEnhance(ctx context.Context) *ai.EnhanceOptions {
 	return s.enhanceDefault.NewEnhance(ctx)
 }
 
+// MsgIsOverLimit 判断对话消息是否超出 usage.query 对应模型可用的上下文窗口
+// 驱动实现了 ai.MessageTokenizer 时使用驱动的分词方式，否则按 tiktoken 计算
 func (s *AI) MsgIsOverLimit(msgs []*types.MessageContext) bool {
-	// TODO
-	return false
+	d := s.queryDriver()
+	model := s.ChatModel()
+
+	var tokens int
+	if t, ok := d.(ai.MessageTokenizer); ok {
+		tokens = t.CountMessageTokens(msgs)
+	} else {
+		tokens = ai.NumMessageTokens(msgs, ai.NewTokenCounter(model))
+	}
+	return tokens > s.window.Limit(model)
 }
 
 var (
@@ -335,7 +564,38 @@ func (s *AI) Reader(ctx context.Context, endpoint string) (*ai.ReaderResult, err
 	return s.readerDefault.Reader(ctx, endpoint)
 }
 
+// setUsageDriver 设置 usage 对应的驱动，配置了多个驱动时使用 chain 组成备用链，chain 为空时只使用第一个驱动
+func setUsageDriver[D any](usage map[string]D, key string, names UsageDrivers, drivers map[string]D, chain func(p []ai.Provider[D]) D) {
+	providers := usageProviders(key, names, drivers)
+	switch {
+	case len(providers) == 0:
+		return
+	case len(providers) > 1 && chain != nil:
+		usage[key] = chain(providers)
+	default:
+		if len(providers) > 1 {
+			slog.Warn("AI usage does not support fallback drivers, only the first one is used", slog.String("usage", key), slog.String("driver", providers[0].Name))
+		}
+		usage[key] = providers[0].Driver
+	}
+}
+
+// defaultDriver 返回 preferred 中第一个支持该功能的驱动，都不支持时按 order 顺序选择
+func defaultDriver[D any](drivers map[string]D, preferred UsageDrivers, order []string) D {
+	for _, name := range append(preferred, order...) {
+		if d, ok := drivers[name]; ok {
+			return d
+		}
+	}
+	var d D
+	return d
+}
+
 func installAI(a *AI, name string, driver any) {
+	if !lo.Contains(a.order, name) {
+		a.order = append(a.order, name)
+	}
+
 	if d, ok := driver.(ChatAI); ok {
 		a.chatDrivers[name] = d
 	}
@@ -361,7 +621,8 @@ func installAI(a *AI, name string, driver any) {
 	}
 }
 
-func SetupAI(cfg AIConfig) (*AI, error) {
+// SetupAI client 为 core 的 http client，用于本地 reader 抓取网页
+func SetupAI(cfg AIConfig, client *http.Client) (*AI, error) {
 	a := &AI{
 		chatDrivers:    make(map[string]ChatAI),
 		chatUsage:      make(map[string]ChatAI),
@@ -375,6 +636,7 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 		visionUsage:    make(map[string]VisionAI),
 		rerankDrivers:  make(map[string]RerankAI),
 		rerankUsage:    make(map[string]RerankAI),
+		window:         cfg.Window,
 	}
 
 	cfg.Openai.Install(a)
@@ -382,56 +644,54 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 	cfg.QWen.Install(a)
 	cfg.Jina.Install(a)
 	cfg.DeepSeek.Install(a)
+	cfg.Gemini.Install(a)
+	cfg.Anthropic.Install(a)
 	cfg.Ollama.Install(a)
-	// TODO: Gemini install
+	cfg.Local.Install(a, client)
+	cfg.Cohere.Install(a)
 
-	for k, v := range cfg.Usage {
-		v = strings.ToLower(v)
+	policy := cfg.Failover.Policy()
+	for k, names := range cfg.Usage {
 		switch k {
 		case "reader":
-			a.readerUsage[k] = a.readerDrivers[v]
+			setUsageDriver(a.readerUsage, k, names, a.readerDrivers, func(p []ai.Provider[ReaderAI]) ReaderAI {
+				return &readerChain{providers: p, policy: policy}
+			})
 		case "embedding.document", "embedding.query":
-			a.embedUsage[k] = a.embedDrivers[v]
+			setUsageDriver(a.embedUsage, k, names, a.embedDrivers, nil)
 		case "enhance_query":
-			a.enhanceUsage[k] = a.enhanceDrivers[v]
+			setUsageDriver(a.enhanceUsage, k, names, a.enhanceDrivers, func(p []ai.Provider[EnhanceAI]) EnhanceAI {
+				return &enhanceChain{providers: p, policy: policy}
+			})
 		case "vision":
-			a.visionUsage[k] = a.visionDrivers[v]
+			setUsageDriver(a.visionUsage, k, names, a.visionDrivers, func(p []ai.Provider[VisionAI]) VisionAI {
+				return &visionChain{providers: p, policy: policy}
+			})
 		case "rerank":
-			a.rerankUsage[k] = a.rerankDrivers[v]
+			setUsageDriver(a.rerankUsage, k, names, a.rerankDrivers, nil)
 		default:
-			a.chatUsage[k] = a.chatDrivers[v]
+			setUsageDriver(a.chatUsage, k, names, a.chatDrivers, func(p []ai.Provider[ChatAI]) ChatAI {
+				return &chatChain{providers: p, policy: policy}
+			})
 		}
 	}
 
-	for _, v := range a.chatDrivers {
-		a.chatDefault = v
-		break
-	}
-
-	for _, v := range a.embedDrivers {
-		a.embedDefault = v
-		break
-	}
-
-	for _, v := range a.enhanceDrivers {
-		a.enhanceDefault = v
-		break
-	}
-
-	for _, v := range a.readerDrivers {
-		a.readerDefault = v
-		break
+	// 默认驱动优先使用 usage.query 中的驱动，其次按安装顺序选择，保证每次启动的选择一致
+	preferred := append(UsageDrivers{}, cfg.Usage["query"]...)
+	a.chatDefault = defaultDriver(a.chatDrivers, preferred, a.order)
+	a.enhanceDefault = defaultDriver(a.enhanceDrivers, preferred, a.order)
+	a.visionDefault = defaultDriver(a.visionDrivers, preferred, a.order)
+	a.embedDefault = defaultDriver(a.embedDrivers, append(append(UsageDrivers{}, cfg.Usage["embedding.query"]...), cfg.Usage["embedding.document"]...), a.order)
+
+	// 本地 reader 仅在没有其他 reader 时作为默认，可通过 usage.reader = "local" 指定使用
+	a.readerDefault = defaultDriver(a.readerDrivers, nil, lo.Without(a.order, local.NAME))
+	if a.readerDefault == nil {
+		a.readerDefault = a.readerDrivers[local.NAME]
 	}
 
-	for _, v := range a.visionDrivers {
-		a.visionDefault = v
-		break
-	}
-
-	for _, v := range a.rerankDrivers {
-		a.rerankDefault = v
-		break
-	}
+	// 重排驱动需要通过 usage.rerank 指定，未指定时保持检索顺序
+	// 本地的词法重排只在指定的驱动请求失败时兜底，也可以通过 usage.rerank = "local" 指定使用
+	a.rerankDefault = a.rerankDrivers[local.NAME]
 
 	if a.chatDefault == nil || a.embedDefault == nil {
 		panic("AI driver of chat and embedding must be set")
@@ -442,8 +702,8 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 
 type ApplyFunc func(s *Srv)
 
-func ApplyAI(cfg AIConfig) ApplyFunc {
+func ApplyAI(cfg AIConfig, client *http.Client) ApplyFunc {
 	return func(s *Srv) {
-		s.ai, _ = SetupAI(cfg)
+		s.ai, _ = SetupAI(cfg, client)
 	}
 }
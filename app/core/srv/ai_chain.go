@@ -0,0 +1,179 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// UsageDrivers usage 对应的驱动，按顺序作为备用链，配置中可以是单个驱动名称或驱动名称数组
+type UsageDrivers []string
+
+func (u *UsageDrivers) UnmarshalTOML(v any) error {
+	switch val := v.(type) {
+	case string:
+		*u = ParseUsageDrivers(val)
+	case []any:
+		*u = nil
+		for _, item := range val {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				*u = append(*u, strings.ToLower(strings.TrimSpace(s)))
+			}
+		}
+	}
+	return nil
+}
+
+// ParseUsageDrivers 解析以逗号分隔的驱动名称，用于环境变量配置
+func ParseUsageDrivers(s string) UsageDrivers {
+	var res UsageDrivers
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// AIFailover 备用链的熔断及超时配置
+type AIFailover struct {
+	Threshold int `toml:"threshold"` // 驱动连续失败多少次后熔断，默认 3
+	Cooldown  int `toml:"cooldown"`  // 熔断后跳过该驱动的时间(秒)，默认 30
+	Timeout   int `toml:"timeout"`   // 单个驱动在该时间(秒)内没有返回(流式请求为开始输出)时切换到下一个驱动，0 表示不限制
+}
+
+func (c AIFailover) Policy() *ai.FailoverPolicy {
+	return &ai.FailoverPolicy{
+		Breaker: ai.NewCircuitBreaker(c.Threshold, time.Duration(c.Cooldown)*time.Second),
+		Timeout: time.Duration(c.Timeout) * time.Second,
+	}
+}
+
+// chatChain 对话类 usage 的备用链，请求超时、5xx 或限流时按顺序切换到下一个驱动
+type chatChain struct {
+	providers []ai.Provider[ChatAI]
+	policy    *ai.FailoverPolicy
+}
+
+// active 返回当前会被优先使用的驱动，用于获取模型名称、语言等信息
+func (c *chatChain) active() ChatAI {
+	for _, p := range c.providers {
+		if !c.policy.Breaker.IsOpen(p.Name) {
+			return p.Driver
+		}
+	}
+	return c.providers[0].Driver
+}
+
+func (c *chatChain) Lang() string {
+	return c.active().Lang()
+}
+
+func (c *chatChain) Summarize(ctx context.Context, doc *string) (ai.SummarizeResult, error) {
+	return ai.Failover(ctx, c.policy, c.providers, func(ctx context.Context, d ChatAI) (ai.SummarizeResult, error) {
+		return d.Summarize(ctx, doc)
+	})
+}
+
+func (c *chatChain) Chunk(ctx context.Context, doc *string) (ai.ChunkResult, error) {
+	return ai.Failover(ctx, c.policy, c.providers, func(ctx context.Context, d ChatAI) (ai.ChunkResult, error) {
+		return d.Chunk(ctx, doc)
+	})
+}
+
+func (c *chatChain) NewQuery(ctx context.Context, msgs []*types.MessageContext) *ai.QueryOptions {
+	return ai.NewQueryOptions(ctx, c, msgs)
+}
+
+// Query 消息已由 QueryOptions 加上了系统提示词，各驱动的 QueryOptions 不会重复添加
+func (c *chatChain) Query(ctx context.Context, msgs []*types.MessageContext) (ai.GenerateResponse, error) {
+	return ai.Failover(ctx, c.policy, c.providers, func(ctx context.Context, d ChatAI) (ai.GenerateResponse, error) {
+		return d.NewQuery(ctx, msgs).Query()
+	})
+}
+
+// QueryStream 只在建立流式请求时切换驱动，已开始输出后的错误不再切换
+func (c *chatChain) QueryStream(ctx context.Context, msgs []*types.MessageContext) (ai.Stream, error) {
+	return ai.Failover(ctx, c.policy, c.providers, func(ctx context.Context, d ChatAI) (ai.Stream, error) {
+		return d.NewQuery(ctx, msgs).QueryStream()
+	})
+}
+
+type visionChain struct {
+	providers []ai.Provider[VisionAI]
+	policy    *ai.FailoverPolicy
+}
+
+func (c *visionChain) NewVisionQuery(ctx context.Context, msgs []*types.MessageContext) *ai.QueryOptions {
+	return ai.NewQueryOptions(ctx, c, msgs)
+}
+
+func (c *visionChain) Lang() string {
+	if l, ok := c.providers[0].Driver.(ai.Lang); ok {
+		return l.Lang()
+	}
+	return ai.MODEL_BASE_LANGUAGE_EN
+}
+
+func (c *visionChain) Query(ctx context.Context, msgs []*types.MessageContext) (ai.GenerateResponse, error) {
+	return ai.Failover(ctx, c.policy, c.providers, func(ctx context.Context, d VisionAI) (ai.GenerateResponse, error) {
+		return d.NewVisionQuery(ctx, msgs).Query()
+	})
+}
+
+func (c *visionChain) QueryStream(ctx context.Context, msgs []*types.MessageContext) (ai.Stream, error) {
+	return ai.Failover(ctx, c.policy, c.providers, func(ctx context.Context, d VisionAI) (ai.Stream, error) {
+		return d.NewVisionQuery(ctx, msgs).QueryStream()
+	})
+}
+
+type enhanceChain struct {
+	providers []ai.Provider[EnhanceAI]
+	policy    *ai.FailoverPolicy
+}
+
+func (c *enhanceChain) NewEnhance(ctx context.Context) *ai.EnhanceOptions {
+	return ai.NewEnhance(ctx, c)
+}
+
+func (c *enhanceChain) Lang() string {
+	if l, ok := c.providers[0].Driver.(ai.Lang); ok {
+		return l.Lang()
+	}
+	return ai.MODEL_BASE_LANGUAGE_EN
+}
+
+func (c *enhanceChain) EnhanceQuery(ctx context.Context, prompt, query string) (ai.EnhanceQueryResult, error) {
+	return ai.Failover(ctx, c.policy, c.providers, func(ctx context.Context, d EnhanceAI) (ai.EnhanceQueryResult, error) {
+		return d.NewEnhance(ctx).WithPrompt(prompt).EnhanceQuery(query)
+	})
+}
+
+type readerChain struct {
+	providers []ai.Provider[ReaderAI]
+	policy    *ai.FailoverPolicy
+}
+
+func (c *readerChain) Reader(ctx context.Context, endpoint string) (*ai.ReaderResult, error) {
+	return ai.Failover(ctx, c.policy, c.providers, func(ctx context.Context, d ReaderAI) (*ai.ReaderResult, error) {
+		return d.Reader(ctx, endpoint)
+	})
+}
+
+// usageProviders 按配置顺序返回已安装的驱动，未安装的驱动会被忽略
+func usageProviders[D any](usage string, names UsageDrivers, drivers map[string]D) []ai.Provider[D] {
+	var res []ai.Provider[D]
+	for _, name := range names {
+		d, ok := drivers[name]
+		if !ok {
+			slog.Warn("AI driver of usage not found or not support this usage", slog.String("usage", usage), slog.String("driver", name))
+			continue
+		}
+		res = append(res, ai.Provider[D]{Name: name, Driver: d})
+	}
+	return res
+}
@@ -224,7 +224,7 @@ func requestAI(ctx context.Context, core *core.Core, isStream bool, sessionConte
 			// slog.Debug("got ai response", slog.Any("msg", msg), slog.Bool("status", ok))
 			if !ok || msg.FinishReason != "" {
 				done(int32(len(sended)))
-				if msg.FinishReason != "" && msg.FinishReason != "stop" {
+				if msg.FinishReason != "" && msg.FinishReason != ai.FINISH_REASON_STOP {
 					slog.Error("AI srv unexpected exit", slog.String("error", msg.FinishReason), slog.String("id", msg.ID))
 					return errors.New("requestAI.Srv.AI.Query", i18n.ERROR_INTERNAL, fmt.Errorf("%s", msg.FinishReason))
 				}
@@ -358,18 +358,27 @@ func (s *QueryReceiveHandler) GetDoneFunc(callback func(receiveMsg *types.ChatMe
 	}
 }
 
-// RequestAssistant 向智能助理发起请求
-// reqMsgInfo 用户请求的内容
-// recvMsgInfo 用于承载ai回复的内容，会预先在数据库中为ai响应的数据创建出对应的记录
-func (s *NormalAssistant) RequestAssistant(ctx context.Context, docs types.RAGDocs, reqMsg *types.ChatMessage) error {
+// buildRAGPrompt 生成请求对话模型时使用的系统提示词，没有检索到参考内容时使用基础提示词
+func buildRAGPrompt(core *core.Core, docs types.RAGDocs) string {
+	return ragPrompt(core.Prompt(), docs, core.Srv().AI())
+}
+
+func ragPrompt(p core.Prompt, docs types.RAGDocs, lang ai.Lang) string {
 	// TODO: Get space prompt
 	var prompt string
 	if len(docs.Refs) == 0 {
-		prompt = s.core.Prompt().Base
+		prompt = p.Base
 	} else {
-		prompt = s.core.Prompt().Query
+		prompt = p.Query
 	}
-	prompt = ai.BuildRAGPrompt(prompt, ai.NewDocs(docs.Docs), s.core.Srv().AI())
+	return ai.BuildRAGPrompt(prompt, ai.NewDocs(docs.Docs), lang)
+}
+
+// RequestAssistant 向智能助理发起请求
+// reqMsgInfo 用户请求的内容
+// recvMsgInfo 用于承载ai回复的内容，会预先在数据库中为ai响应的数据创建出对应的记录
+func (s *NormalAssistant) RequestAssistant(ctx context.Context, docs types.RAGDocs, reqMsg *types.ChatMessage) error {
+	prompt := buildRAGPrompt(s.core, docs)
 
 	var (
 		sessionContext *SessionContext
@@ -409,7 +418,11 @@ func (s *NormalAssistant) RequestAssistant(ctx context.Context, docs types.RAGDo
 				if len(docs.Refs) == 0 {
 					return
 				}
-				if err := createChatSessionKnowledgePin(s.core, recvMsgInfo, &docs); err != nil {
+				citations, err := recordMessageCitations(s.core, recvMsgInfo, docs.Sources)
+				if err != nil {
+					slog.Error("Failed to record message citations", slog.String("message_id", recvMsgInfo.ID), slog.String("error", err.Error()))
+				}
+				if err := createChatSessionKnowledgePin(s.core, recvMsgInfo, &docs, citations); err != nil {
 					slog.Error("Failed to create chat session knowledge pins", slog.String("session_id", recvMsgInfo.SessionID), slog.String("error", err.Error()))
 				}
 			default:
@@ -616,22 +629,63 @@ func (s *JournalAssistant) RequestAssistant(ctx context.Context, docs types.RAGD
 	return nil
 }
 
-// createChatSessionKnowledgePin Create this chat session prompt pin docs
-func createChatSessionKnowledgePin(core *core.Core, recvMsgInfo *types.ChatMessage, docs *types.RAGDocs) error {
+// recordMessageCitations 解析ai回复中的引用标记，记录每个引用对应的知识点、片段及其在回复中的位置
+func recordMessageCitations(core *core.Core, recvMsgInfo *types.ChatMessage, sources []types.CitationSource) (types.Citations, error) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*6)
 	defer cancel()
 	msg, err := core.Store().ChatMessageStore().GetOne(ctx, recvMsgInfo.ID)
 	if err != nil && err != sql.ErrNoRows {
-		return err
+		return nil, err
 	}
 	if msg == nil {
-		return nil
+		return nil, nil
 	}
 
+	if msg.IsEncrypt == types.MESSAGE_IS_ENCRYPT {
+		deData, err := core.DecryptData([]byte(msg.Message))
+		if err != nil {
+			return nil, err
+		}
+		msg.Message = string(deData)
+	}
+
+	citations := ai.ResolveCitations(msg.Message, sources)
+	if len(citations) == 0 {
+		return nil, nil
+	}
+	if err = core.Store().ChatMessageExtStore().UpdateCitations(ctx, msg.ID, citations); err != nil {
+		return nil, err
+	}
+	return citations, nil
+}
+
+// createChatSessionKnowledgePin Create this chat session prompt pin docs
+// 回复中有引用标记时 pin 被引用的知识点，否则退化为按回复中出现的知识点 id 匹配
+func createChatSessionKnowledgePin(core *core.Core, recvMsgInfo *types.ChatMessage, docs *types.RAGDocs, citations types.Citations) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*6)
+	defer cancel()
+
 	var pinDocs []string
-	for _, v := range docs.Refs {
-		if strings.Contains(msg.Message, v.KnowledgeID) {
-			pinDocs = append(pinDocs, v.KnowledgeID)
+	if len(citations) > 0 {
+		pinDocs = lo.Uniq(lo.Map(citations, func(item types.Citation, _ int) string {
+			return item.KnowledgeID
+		}))
+	} else {
+		msg, err := core.Store().ChatMessageStore().GetOne(ctx, recvMsgInfo.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if msg == nil {
+			return nil
+		}
+
+		for _, v := range docs.Refs {
+			if strings.Contains(msg.Message, v.KnowledgeID) {
+				pinDocs = append(pinDocs, v.KnowledgeID)
+			}
 		}
 	}
 
@@ -835,8 +889,8 @@ ReGen:
 		summaryMessageID = msgList[contextIndex-summaryMessageCutRange].ID
 	}
 
-	// 计算token是否超出限额，超出20条记录自动做一次总结
-	if len(msgList) > 20 || core.Srv().AI().MsgIsOverLimit(reqMsg) {
+	// 提示词(含知识内容) + 总结 + 历史消息超出模型上下文窗口(已扣除为回复预留的 token)时做一次总结
+	if core.Srv().AI().MsgIsOverLimit(reqMsg) {
 		if len(reqMsg) <= 3 || reGen {
 			// 表明当前prompt + 总结 + 用户一段对话已经超出 max token
 			slog.Warn("the current context token is insufficient", slog.String("session_id", reqMsgWithDocs.SessionID), slog.String("msg_id", reqMsgWithDocs.ID))
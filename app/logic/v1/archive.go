@@ -0,0 +1,1034 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/app/core/srv"
+	"github.com/breeew/brew-api/pkg/archive"
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/links"
+	"github.com/breeew/brew-api/pkg/search"
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+// ARCHIVE_BATCH_SIZE 导出时分页读取、导入时批量写入的记录数
+const ARCHIVE_BATCH_SIZE = 100
+
+// ArchiveLogic 空间的整体导出与导入，归档格式见 pkg/archive
+type ArchiveLogic struct {
+	ctx  context.Context
+	core *core.Core
+	UserInfo
+}
+
+func NewArchiveLogic(ctx context.Context, core *core.Core) *ArchiveLogic {
+	l := &ArchiveLogic{
+		ctx:      ctx,
+		core:     core,
+		UserInfo: SetupUserInfo(ctx, core),
+	}
+
+	return l
+}
+
+// ExportSpace 将空间的资源、知识点(含切片、向量、链接)、日记、会话及知识点引用的文件写入归档
+// 日记与会话属于个人数据，只导出当前用户的记录
+// 加密的内容解密后以明文导出
+func (l *ArchiveLogic) ExportSpace(spaceID string, w io.Writer) error {
+	userID := l.GetUserInfo().User
+	if userID == "" {
+		return errors.New("ArchiveLogic.ExportSpace.UserID", i18n.ERROR_PERMISSION_DENIED, nil).Code(http.StatusForbidden)
+	}
+
+	space, err := l.core.Store().SpaceStore().GetSpace(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("ArchiveLogic.ExportSpace.SpaceStore.GetSpace", i18n.ERROR_INTERNAL, err)
+	}
+	if space == nil {
+		return errors.New("ArchiveLogic.ExportSpace.SpaceStore.GetSpace.nil", i18n.ERROR_NOT_FOUND, nil).Code(http.StatusNotFound)
+	}
+
+	// 迁移进度仅对当前安装有效
+	settings := space.Settings
+	settings.Embedding.Migration = nil
+
+	aw := archive.NewWriter(w, archive.Space{
+		ID:          space.SpaceID,
+		Title:       space.Title,
+		Description: space.Description,
+		Settings:    settings,
+	})
+
+	var (
+		knowledgeIDs []string
+		sessionIDs   []string
+		files        []string
+	)
+	steps := []struct {
+		table string
+		fn    func(write func(v any) error) error
+	}{
+		{archive.TABLE_RESOURCE, func(write func(v any) error) error {
+			return l.exportResources(spaceID, write)
+		}},
+		{archive.TABLE_KNOWLEDGE, func(write func(v any) error) error {
+			return l.exportKnowledges(spaceID, write, &knowledgeIDs, &files)
+		}},
+		{archive.TABLE_CHUNK, func(write func(v any) error) error {
+			return l.exportChunks(spaceID, knowledgeIDs, write)
+		}},
+		{archive.TABLE_VECTOR, func(write func(v any) error) error {
+			return l.exportVectors(spaceID, knowledgeIDs, write)
+		}},
+		{archive.TABLE_LINK, func(write func(v any) error) error {
+			return l.exportLinks(spaceID, write)
+		}},
+		{archive.TABLE_JOURNAL, func(write func(v any) error) error {
+			return l.exportJournals(spaceID, userID, write)
+		}},
+		{archive.TABLE_CHAT_SESSION, func(write func(v any) error) error {
+			return l.exportChatSessions(spaceID, userID, write, &sessionIDs)
+		}},
+		{archive.TABLE_CHAT_MESSAGE, func(write func(v any) error) error {
+			return l.exportChatMessages(spaceID, sessionIDs, write)
+		}},
+	}
+	for _, step := range steps {
+		if err = aw.WriteTable(step.table, step.fn); err != nil {
+			return errors.Trace("ArchiveLogic.ExportSpace."+step.table, err)
+		}
+	}
+
+	if err = l.exportFiles(spaceID, files, aw); err != nil {
+		return errors.Trace("ArchiveLogic.ExportSpace.files", err)
+	}
+
+	if err = aw.Close(); err != nil {
+		return errors.New("ArchiveLogic.ExportSpace.Close", i18n.ERROR_INTERNAL, err)
+	}
+	return nil
+}
+
+func (l *ArchiveLogic) exportResources(spaceID string, write func(v any) error) error {
+	for page := uint64(1); ; page++ {
+		list, err := l.core.Store().ResourceStore().ListResources(l.ctx, spaceID, page, ARCHIVE_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("ArchiveLogic.exportResources.ResourceStore.ListResources", i18n.ERROR_INTERNAL, err)
+		}
+		for _, v := range list {
+			err = write(archive.Resource{
+				ID:          v.ID,
+				Title:       v.Title,
+				Description: v.Description,
+				Cycle:       v.Cycle,
+				Tag:         v.Tag,
+				CreatedAt:   v.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(list) < ARCHIVE_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+func (l *ArchiveLogic) exportKnowledges(spaceID string, write func(v any) error, ids, files *[]string) error {
+	exported := make(map[string]bool)
+	for page := uint64(1); ; page++ {
+		list, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, types.GetKnowledgeOptions{SpaceID: spaceID}, page, ARCHIVE_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("ArchiveLogic.exportKnowledges.KnowledgeStore.ListKnowledges", i18n.ERROR_INTERNAL, err)
+		}
+		for _, v := range list {
+			content, err := l.core.DecryptData(v.Content)
+			if err != nil {
+				return errors.New("ArchiveLogic.exportKnowledges.DecryptData", i18n.ERROR_INTERNAL, err)
+			}
+
+			*ids = append(*ids, v.ID)
+			for _, file := range knowledgeFiles(v.Kind, v.Source, v.ContentType, content) {
+				if !exported[file] {
+					exported[file] = true
+					*files = append(*files, file)
+				}
+			}
+
+			err = write(archive.Knowledge{
+				ID:          v.ID,
+				Kind:        v.Kind,
+				Resource:    v.Resource,
+				Title:       v.Title,
+				Tags:        v.Tags,
+				Content:     string(content),
+				ContentType: v.ContentType,
+				Source:      v.Source,
+				Summary:     v.Summary,
+				MaybeDate:   v.MaybeDate,
+				Stage:       v.Stage,
+				CreatedAt:   v.CreatedAt,
+				UpdatedAt:   v.UpdatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(list) < ARCHIVE_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+func (l *ArchiveLogic) exportChunks(spaceID string, knowledgeIDs []string, write func(v any) error) error {
+	for _, knowledgeID := range knowledgeIDs {
+		list, err := l.core.Store().KnowledgeChunkStore().List(l.ctx, spaceID, knowledgeID)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("ArchiveLogic.exportChunks.KnowledgeChunkStore.List", i18n.ERROR_INTERNAL, err)
+		}
+		for _, v := range list {
+			chunk, err := l.core.DecryptData([]byte(v.Chunk))
+			if err != nil {
+				return errors.New("ArchiveLogic.exportChunks.DecryptData", i18n.ERROR_INTERNAL, err)
+			}
+			err = write(archive.Chunk{
+				ID:             v.ID,
+				KnowledgeID:    v.KnowledgeID,
+				Chunk:          string(chunk),
+				Seq:            v.Seq,
+				OriginalLength: v.OriginalLength,
+				CreatedAt:      v.CreatedAt,
+				UpdatedAt:      v.UpdatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *ArchiveLogic) exportVectors(spaceID string, knowledgeIDs []string, write func(v any) error) error {
+	for _, knowledgeID := range knowledgeIDs {
+		for page := uint64(1); ; page++ {
+			list, err := l.core.Store().VectorStore().ListVectors(l.ctx, types.GetVectorsOptions{
+				SpaceID:     spaceID,
+				KnowledgeID: knowledgeID,
+			}, page, ARCHIVE_BATCH_SIZE)
+			if err != nil && err != sql.ErrNoRows {
+				return errors.New("ArchiveLogic.exportVectors.VectorStore.ListVectors", i18n.ERROR_INTERNAL, err)
+			}
+			for _, v := range list {
+				err = write(archive.Vector{
+					ID:             v.ID,
+					KnowledgeID:    v.KnowledgeID,
+					Embedding:      v.Embedding,
+					Model:          v.Model,
+					OriginalLength: v.OriginalLength,
+					CreatedAt:      v.CreatedAt,
+					UpdatedAt:      v.UpdatedAt,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if len(list) < ARCHIVE_BATCH_SIZE {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (l *ArchiveLogic) exportLinks(spaceID string, write func(v any) error) error {
+	for page := uint64(1); ; page++ {
+		list, err := l.core.Store().KnowledgeLinkStore().ListLinks(l.ctx, types.GetKnowledgeLinksOptions{SpaceID: spaceID}, page, ARCHIVE_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("ArchiveLogic.exportLinks.KnowledgeLinkStore.ListLinks", i18n.ERROR_INTERNAL, err)
+		}
+		if err = decryptLinkText(l.core, list); err != nil {
+			return errors.New("ArchiveLogic.exportLinks.DecryptData", i18n.ERROR_INTERNAL, err)
+		}
+		for _, v := range list {
+			err = write(archive.Link{
+				FromID:    v.FromID,
+				ToID:      v.ToID,
+				Text:      v.Text,
+				CreatedAt: v.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(list) < ARCHIVE_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+func (l *ArchiveLogic) exportJournals(spaceID, userID string, write func(v any) error) error {
+	for page := uint64(1); ; page++ {
+		list, err := l.core.Store().JournalStore().List(l.ctx, spaceID, userID, page, ARCHIVE_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("ArchiveLogic.exportJournals.JournalStore.List", i18n.ERROR_INTERNAL, err)
+		}
+		for _, v := range list {
+			content, err := l.core.DecryptData(v.Content)
+			if err != nil {
+				return errors.New("ArchiveLogic.exportJournals.DecryptData", i18n.ERROR_INTERNAL, err)
+			}
+			err = write(archive.Journal{
+				UserID:    v.UserID,
+				Date:      v.Date,
+				Content:   string(content),
+				CreatedAt: v.CreatedAt,
+				UpdatedAt: v.UpdatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(list) < ARCHIVE_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+func (l *ArchiveLogic) exportChatSessions(spaceID, userID string, write func(v any) error, ids *[]string) error {
+	for page := uint64(1); ; page++ {
+		list, err := l.core.Store().ChatSessionStore().List(l.ctx, spaceID, userID, page, ARCHIVE_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("ArchiveLogic.exportChatSessions.ChatSessionStore.List", i18n.ERROR_INTERNAL, err)
+		}
+		for _, v := range list {
+			*ids = append(*ids, v.ID)
+			err = write(archive.ChatSession{
+				ID:               v.ID,
+				UserID:           v.UserID,
+				Title:            v.Title,
+				Type:             v.Type,
+				Status:           v.Status,
+				CreatedAt:        v.CreatedAt,
+				LatestAccessTime: v.LatestAccessTime,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(list) < ARCHIVE_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+// exportChatMessages 按会话导出消息，同一会话的消息按发送顺序排列
+func (l *ArchiveLogic) exportChatMessages(spaceID string, sessionIDs []string, write func(v any) error) error {
+	for _, sessionID := range sessionIDs {
+		var messages []*types.ChatMessage
+		for page := uint64(1); ; page++ {
+			list, err := l.core.Store().ChatMessageStore().ListSessionMessage(l.ctx, spaceID, sessionID, "", page, ARCHIVE_BATCH_SIZE)
+			if err != nil && err != sql.ErrNoRows {
+				return errors.New("ArchiveLogic.exportChatMessages.ChatMessageStore.ListSessionMessage", i18n.ERROR_INTERNAL, err)
+			}
+			messages = append(messages, list...)
+			if len(list) < ARCHIVE_BATCH_SIZE {
+				break
+			}
+		}
+		slices.Reverse(messages)
+
+		exts := make(map[string]types.ChatMessageExt)
+		for msgs := range slices.Chunk(messages, ARCHIVE_BATCH_SIZE) {
+			list, err := l.core.Store().ChatMessageExtStore().ListChatMessageExts(l.ctx, lo.Map(msgs, func(item *types.ChatMessage, _ int) string {
+				return item.ID
+			}))
+			if err != nil && err != sql.ErrNoRows {
+				return errors.New("ArchiveLogic.exportChatMessages.ChatMessageExtStore.ListChatMessageExts", i18n.ERROR_INTERNAL, err)
+			}
+			for _, v := range list {
+				exts[v.MessageID] = v
+			}
+		}
+
+		for _, v := range messages {
+			if v.IsEncrypt == types.MESSAGE_IS_ENCRYPT {
+				message, err := l.core.DecryptData([]byte(v.Message))
+				if err != nil {
+					return errors.New("ArchiveLogic.exportChatMessages.DecryptData", i18n.ERROR_INTERNAL, err)
+				}
+				v.Message = string(message)
+			}
+			err := write(archive.ChatMessage{
+				ID:        v.ID,
+				SessionID: v.SessionID,
+				Role:      v.Role,
+				Message:   v.Message,
+				MsgType:   v.MsgType,
+				SendTime:  v.SendTime,
+				Complete:  v.Complete,
+				Sequence:  v.Sequence,
+				MsgBlock:  v.MsgBlock,
+				RelDocs:   exts[v.ID].RelDocs,
+				Citations: exts[v.ID].Citations,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportFiles 导出知识点引用的文件，下载失败的文件仅记录日志，知识点中保留原始链接
+func (l *ArchiveLogic) exportFiles(spaceID string, files []string, aw *archive.Writer) error {
+	var list []archive.File
+	for _, file := range files {
+		info, err := l.core.Store().FileManagementStore().GetByID(l.ctx, spaceID, file)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("ArchiveLogic.exportFiles.FileManagementStore.GetByID", i18n.ERROR_INTERNAL, err)
+		}
+		if info == nil {
+			// 不是本空间上传的文件
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(l.ctx, time.Minute)
+		object, err := l.core.FileStorage().DownloadFile(ctx, file)
+		cancel()
+		if err != nil {
+			slog.Warn("Failed to download file for space export", slog.String("space_id", spaceID), slog.String("file", file), slog.String("error", err.Error()))
+			continue
+		}
+
+		blob := strings.TrimPrefix(file, "/")
+		if err = aw.WriteBlob(blob, object.File); err != nil {
+			return errors.New("ArchiveLogic.exportFiles.WriteBlob", i18n.ERROR_INTERNAL, err)
+		}
+		list = append(list, archive.File{
+			Path:       file,
+			FileSize:   info.FileSize,
+			ObjectType: info.ObjectType,
+			Kind:       info.Kind,
+			Blob:       blob,
+		})
+	}
+
+	err := aw.WriteTable(archive.TABLE_FILE, func(write func(v any) error) error {
+		for _, v := range list {
+			if err := write(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.New("ArchiveLogic.exportFiles.WriteTable", i18n.ERROR_INTERNAL, err)
+	}
+	return nil
+}
+
+// knowledgeFiles 返回知识点引用的文件路径，包括文件类知识点的原始文件以及 blocks 内容中的图片、视频
+func knowledgeFiles(kind types.KnowledgeKind, source string, contentType types.KnowledgeContentType, content types.KnowledgeContent) []string {
+	var files []string
+	if kind == types.KNOWLEDGE_KIND_FILE && source != "" {
+		files = append(files, source)
+	}
+	if contentType != types.KNOWLEDGE_CONTENT_TYPE_BLOCKS {
+		return files
+	}
+
+	urls, err := filterKnowledgeFiles(content)
+	if err != nil {
+		return files
+	}
+	for _, v := range urls {
+		files = append(files, filePathFromURL(v))
+	}
+	return files
+}
+
+func filePathFromURL(v string) string {
+	parsed, err := url.Parse(v)
+	if err != nil {
+		return v
+	}
+	return parsed.RequestURI()
+}
+
+// ImportSpace 从归档创建一个新的空间，当前用户为空间管理员，导入的记录均归属于当前用户
+// 所有 id 重新生成，内容使用当前安装的密钥重新加密
+// reembed 为 true 时不导入向量，由 KnowledgeProcess 使用当前的 embedding 模型重新生成
+func (l *ArchiveLogic) ImportSpace(r io.ReaderAt, size int64, reembed bool) (string, error) {
+	reader, err := archive.NewReader(r, size)
+	if err != nil {
+		return "", errors.New("ArchiveLogic.ImportSpace.NewReader", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	im := &spaceImporter{
+		ArchiveLogic: l,
+		reader:       reader,
+		reembed:      reembed,
+		spaceID:      utils.GenRandomID(),
+		userID:       l.GetUserInfo().User,
+		knowledges:   make(map[string]importedKnowledge),
+		chunks:       make(map[string]string),
+		sessions:     make(map[string]string),
+		files:        make(map[string]string),
+		hasVectors:   make(map[string]bool),
+	}
+
+	if err = im.scanVectors(); err != nil {
+		return "", errors.Trace("ArchiveLogic.ImportSpace", err)
+	}
+	if err = im.scanKnowledges(); err != nil {
+		return "", errors.Trace("ArchiveLogic.ImportSpace", err)
+	}
+	// 文件地址需要写入知识点内容，因此在事务之前保存，失败时删除已保存的文件
+	if err = im.importFiles(); err != nil {
+		im.deleteFiles()
+		return "", errors.Trace("ArchiveLogic.ImportSpace", err)
+	}
+
+	err = l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		steps := []func(ctx context.Context) error{
+			im.createSpace,
+			im.importResources,
+			im.importKnowledges,
+			im.importLinks,
+			im.importChunks,
+			im.importJournals,
+			im.importChatSessions,
+			im.importChatMessages,
+			// 向量存储可能不在数据库事务中，放在最后写入
+			im.importVectors,
+		}
+		for _, step := range steps {
+			if err := step(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		im.deleteFiles()
+		if stderrors.Is(err, archive.ErrInvalidArchive) {
+			return "", errors.New("ArchiveLogic.ImportSpace.Archive", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+		}
+		return "", errors.Trace("ArchiveLogic.ImportSpace", err)
+	}
+
+	slog.Info("Space imported", slog.String("space_id", im.spaceID), slog.String("from", reader.Manifest().Space.ID),
+		slog.String("user_id", im.userID), slog.Any("counts", reader.Manifest().Counts), slog.Bool("reembed", reembed))
+	return im.spaceID, nil
+}
+
+type importedKnowledge struct {
+	ID       string
+	Resource string
+	Stage    types.KnowledgeStage
+}
+
+// spaceImporter 记录导入过程中原始 id 与新 id 的对应关系
+type spaceImporter struct {
+	*ArchiveLogic
+	reader  *archive.Reader
+	reembed bool
+	spaceID string
+	userID  string
+
+	knowledges map[string]importedKnowledge
+	chunks     map[string]string
+	sessions   map[string]string
+	files      map[string]string // 原始文件路径 -> 新的文件路径
+	hasVectors map[string]bool   // 归档中包含向量的知识点
+}
+
+// batch 将记录按 ARCHIVE_BATCH_SIZE 分批写入
+type batch[T any] struct {
+	list  []T
+	flush func(list []T) error
+}
+
+func (b *batch[T]) add(item T) error {
+	b.list = append(b.list, item)
+	if len(b.list) < ARCHIVE_BATCH_SIZE {
+		return nil
+	}
+	return b.close()
+}
+
+func (b *batch[T]) close() error {
+	if len(b.list) == 0 {
+		return nil
+	}
+	err := b.flush(b.list)
+	b.list = nil
+	return err
+}
+
+func (im *spaceImporter) scanVectors() error {
+	if im.reembed {
+		return nil
+	}
+	return archive.Each(im.reader, archive.TABLE_VECTOR, func(item archive.Vector) error {
+		im.hasVectors[item.KnowledgeID] = true
+		return nil
+	})
+}
+
+// importFiles 将归档中的文件保存到当前安装的文件存储
+func (im *spaceImporter) importFiles() error {
+	return archive.Each(im.reader, archive.TABLE_FILE, func(item archive.File) error {
+		data, err := im.reader.Blob(item.Blob)
+		if err != nil {
+			slog.Warn("Missing file in space archive", slog.String("file", item.Path), slog.String("error", err.Error()))
+			return nil
+		}
+
+		filePath := genUserFilePath(im.spaceID, importObjectType(item.ObjectType))
+		fileName := path.Base(item.Path)
+		if err = im.core.FileStorage().SaveFile(filePath, fileName, data); err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.FileStorage.SaveFile", i18n.ERROR_INTERNAL, err)
+		}
+		im.files[item.Path] = path.Join(filePath, fileName)
+		return nil
+	})
+}
+
+// deleteFiles 导入失败时删除已保存的文件，避免文件存储中残留无人引用的文件
+func (im *spaceImporter) deleteFiles() {
+	for _, v := range im.files {
+		if err := im.core.FileStorage().DeleteFile(v); err != nil {
+			slog.Error("Failed to delete imported space file", slog.String("space_id", im.spaceID), slog.String("file", v), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// importObjectType object_type 会作为存储路径的一部分，不合法时归入知识点文件
+func importObjectType(v string) string {
+	if !isValidObjectType(v) {
+		return "knowledge"
+	}
+	return v
+}
+
+func (im *spaceImporter) createSpace(ctx context.Context) error {
+	manifest := im.reader.Manifest()
+	err := im.core.Store().SpaceStore().Create(ctx, types.Space{
+		SpaceID:     im.spaceID,
+		Title:       manifest.Space.Title,
+		Description: manifest.Space.Description,
+		CreatedAt:   time.Now().Unix(),
+	})
+	if err != nil {
+		return errors.New("ArchiveLogic.ImportSpace.SpaceStore.Create", i18n.ERROR_INTERNAL, err)
+	}
+
+	settings := manifest.Space.Settings
+	settings.Embedding.Migration = nil
+	if err = im.core.Store().SpaceStore().UpdateSettings(ctx, im.spaceID, settings); err != nil {
+		return errors.New("ArchiveLogic.ImportSpace.SpaceStore.UpdateSettings", i18n.ERROR_INTERNAL, err)
+	}
+
+	err = im.core.Store().UserSpaceStore().Create(ctx, types.UserSpace{
+		UserID:    im.userID,
+		SpaceID:   im.spaceID,
+		Role:      srv.RoleAdmin,
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return errors.New("ArchiveLogic.ImportSpace.UserSpaceStore.Create", i18n.ERROR_INTERNAL, err)
+	}
+
+	return archive.Each(im.reader, archive.TABLE_FILE, func(item archive.File) error {
+		newPath, ok := im.files[item.Path]
+		if !ok {
+			return nil
+		}
+		err := im.core.Store().FileManagementStore().Create(ctx, types.FileManagement{
+			SpaceID:    im.spaceID,
+			UserID:     im.userID,
+			File:       newPath,
+			FileSize:   item.FileSize,
+			ObjectType: importObjectType(item.ObjectType),
+			Kind:       item.Kind,
+			Status:     types.FILE_UPLOAD_STATUS_UPLOADED,
+			CreatedAt:  time.Now().Unix(),
+		})
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.FileManagementStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+}
+
+func (im *spaceImporter) importResources(ctx context.Context) error {
+	return archive.Each(im.reader, archive.TABLE_RESOURCE, func(item archive.Resource) error {
+		err := im.core.Store().ResourceStore().Create(ctx, types.Resource{
+			ID:          item.ID,
+			Title:       item.Title,
+			UserID:      im.userID,
+			SpaceID:     im.spaceID,
+			Description: item.Description,
+			Cycle:       item.Cycle,
+			Tag:         item.Tag,
+			CreatedAt:   item.CreatedAt,
+		})
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.ResourceStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+}
+
+// importStage 导入后知识点所处的阶段
+// 未处理完成的知识点重新处理，已有切片的知识点在缺少向量或需要重新向量化时只重新生成向量
+func (im *spaceImporter) importStage(item archive.Knowledge) types.KnowledgeStage {
+	switch item.Stage {
+	case types.KNOWLEDGE_STAGE_DONE:
+		if im.reembed || !im.hasVectors[item.ID] {
+			return types.KNOWLEDGE_STAGE_EMBEDDING
+		}
+		return types.KNOWLEDGE_STAGE_DONE
+	case types.KNOWLEDGE_STAGE_EMBEDDING:
+		return types.KNOWLEDGE_STAGE_EMBEDDING
+	default:
+		return types.KNOWLEDGE_STAGE_SUMMARIZE
+	}
+}
+
+// scanKnowledges 预先为知识点分配新的 id，知识点内容中可能链接到排在后面的知识点
+func (im *spaceImporter) scanKnowledges() error {
+	return archive.Each(im.reader, archive.TABLE_KNOWLEDGE, func(item archive.Knowledge) error {
+		im.knowledges[item.ID] = importedKnowledge{
+			ID:       utils.GenRandomID(),
+			Resource: item.Resource,
+			Stage:    im.importStage(item),
+		}
+		return nil
+	})
+}
+
+func (im *spaceImporter) importKnowledges(ctx context.Context) error {
+	b := &batch[*types.Knowledge]{flush: func(list []*types.Knowledge) error {
+		if err := im.core.Store().KnowledgeStore().BatchCreate(ctx, list); err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.KnowledgeStore.BatchCreate", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	}}
+
+	err := archive.Each(im.reader, archive.TABLE_KNOWLEDGE, func(item archive.Knowledge) error {
+		content := im.rewriteFileURLs(item.ContentType, item.Content)
+		content = links.ReplaceIDs(content, func(id string) string {
+			return im.knowledges[id].ID
+		})
+		encryptContent, err := im.core.EncryptData([]byte(content))
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.EncryptData", i18n.ERROR_INTERNAL, err)
+		}
+
+		source := item.Source
+		if newPath, ok := im.files[source]; ok && item.Kind == types.KNOWLEDGE_KIND_FILE {
+			source = newPath
+		}
+
+		knowledge := im.knowledges[item.ID]
+
+		return b.add(&types.Knowledge{
+			ID:          knowledge.ID,
+			SpaceID:     im.spaceID,
+			Kind:        item.Kind,
+			Resource:    item.Resource,
+			Title:       item.Title,
+			Tags:        pq.StringArray(item.Tags),
+			Content:     encryptContent,
+			ContentType: item.ContentType,
+			Source:      source,
+			UserID:      im.userID,
+			Summary:     item.Summary,
+			MaybeDate:   item.MaybeDate,
+			Stage:       knowledge.Stage,
+			CreatedAt:   item.CreatedAt,
+			UpdatedAt:   item.UpdatedAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return b.close()
+}
+
+// rewriteFileURLs 将 blocks 内容中引用的文件地址替换为导入后的地址
+func (im *spaceImporter) rewriteFileURLs(contentType types.KnowledgeContentType, content string) string {
+	if contentType != types.KNOWLEDGE_CONTENT_TYPE_BLOCKS || len(im.files) == 0 {
+		return content
+	}
+	urls, err := filterKnowledgeFiles(types.KnowledgeContent(content))
+	if err != nil {
+		return content
+	}
+
+	domain := strings.TrimSuffix(im.core.FileStorage().GetStaticDomain(), "/")
+	for _, v := range urls {
+		if newPath, ok := im.files[filePathFromURL(v)]; ok {
+			content = strings.ReplaceAll(content, v, domain+newPath)
+		}
+	}
+	return content
+}
+
+func (im *spaceImporter) importLinks(ctx context.Context) error {
+	b := &batch[types.KnowledgeLink]{flush: func(list []types.KnowledgeLink) error {
+		if err := im.core.Store().KnowledgeLinkStore().BatchCreate(ctx, list); err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.KnowledgeLinkStore.BatchCreate", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	}}
+
+	err := archive.Each(im.reader, archive.TABLE_LINK, func(item archive.Link) error {
+		from, ok := im.knowledges[item.FromID]
+		if !ok {
+			return nil
+		}
+		to, ok := im.knowledges[item.ToID]
+		if !ok {
+			return nil
+		}
+		if item.Text != "" {
+			text, err := im.core.EncryptData([]byte(item.Text))
+			if err != nil {
+				return errors.New("ArchiveLogic.ImportSpace.EncryptData", i18n.ERROR_INTERNAL, err)
+			}
+			item.Text = string(text)
+		}
+		return b.add(types.KnowledgeLink{
+			SpaceID:   im.spaceID,
+			FromID:    from.ID,
+			ToID:      to.ID,
+			Text:      item.Text,
+			CreatedAt: item.CreatedAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return b.close()
+}
+
+func (im *spaceImporter) importChunks(ctx context.Context) error {
+	b := &batch[*types.KnowledgeChunk]{flush: func(list []*types.KnowledgeChunk) error {
+		if err := im.core.Store().KnowledgeChunkStore().BatchCreate(ctx, list); err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.KnowledgeChunkStore.BatchCreate", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	}}
+
+	err := archive.Each(im.reader, archive.TABLE_CHUNK, func(item archive.Chunk) error {
+		knowledge, ok := im.knowledges[item.KnowledgeID]
+		if !ok || knowledge.Stage == types.KNOWLEDGE_STAGE_SUMMARIZE {
+			// 重新处理的知识点会重新切片
+			return nil
+		}
+
+		// 全文索引需要基于明文生成
+		keywords := im.core.EncryptKeywords(search.Tokenize(item.Chunk))
+		chunk, err := im.core.EncryptData([]byte(item.Chunk))
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.EncryptData", i18n.ERROR_INTERNAL, err)
+		}
+
+		id := utils.GenRandomID()
+		im.chunks[item.ID] = id
+		return b.add(&types.KnowledgeChunk{
+			ID:             id,
+			KnowledgeID:    knowledge.ID,
+			SpaceID:        im.spaceID,
+			UserID:         im.userID,
+			Chunk:          string(chunk),
+			Seq:            item.Seq,
+			Keywords:       keywords,
+			OriginalLength: item.OriginalLength,
+			CreatedAt:      item.CreatedAt,
+			UpdatedAt:      item.UpdatedAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return b.close()
+}
+
+func (im *spaceImporter) importVectors(ctx context.Context) error {
+	if im.reembed {
+		return nil
+	}
+
+	b := &batch[types.Vector]{flush: func(list []types.Vector) error {
+		if err := im.core.Store().VectorStore().BatchCreate(ctx, list); err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.VectorStore.BatchCreate", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	}}
+
+	err := archive.Each(im.reader, archive.TABLE_VECTOR, func(item archive.Vector) error {
+		knowledge, ok := im.knowledges[item.KnowledgeID]
+		if !ok || knowledge.Stage != types.KNOWLEDGE_STAGE_DONE {
+			return nil
+		}
+		id, ok := im.chunks[item.ID]
+		if !ok {
+			return nil
+		}
+		return b.add(types.Vector{
+			ID:             id,
+			KnowledgeID:    knowledge.ID,
+			SpaceID:        im.spaceID,
+			Resource:       knowledge.Resource,
+			UserID:         im.userID,
+			Embedding:      item.Embedding,
+			Model:          item.Model,
+			Dimension:      len(item.Embedding),
+			OriginalLength: item.OriginalLength,
+			CreatedAt:      item.CreatedAt,
+			UpdatedAt:      item.UpdatedAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return b.close()
+}
+
+// importJournals 日记按日期唯一，多个用户同一天的日记只保留第一篇
+func (im *spaceImporter) importJournals(ctx context.Context) error {
+	return archive.Each(im.reader, archive.TABLE_JOURNAL, func(item archive.Journal) error {
+		exist, err := im.core.Store().JournalStore().Exist(ctx, im.spaceID, im.userID, item.Date)
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.JournalStore.Exist", i18n.ERROR_INTERNAL, err)
+		}
+		if exist {
+			return nil
+		}
+
+		content, err := im.core.EncryptData([]byte(item.Content))
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.EncryptData", i18n.ERROR_INTERNAL, err)
+		}
+		err = im.core.Store().JournalStore().Create(ctx, types.Journal{
+			ID:        utils.GenUniqID(),
+			SpaceID:   im.spaceID,
+			UserID:    im.userID,
+			Date:      item.Date,
+			Content:   content,
+			CreatedAt: item.CreatedAt,
+			UpdatedAt: item.UpdatedAt,
+		})
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.JournalStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+}
+
+func (im *spaceImporter) importChatSessions(ctx context.Context) error {
+	return archive.Each(im.reader, archive.TABLE_CHAT_SESSION, func(item archive.ChatSession) error {
+		id := utils.GenSpecIDStr()
+		im.sessions[item.ID] = id
+		err := im.core.Store().ChatSessionStore().Create(ctx, types.ChatSession{
+			ID:               id,
+			SpaceID:          im.spaceID,
+			UserID:           im.userID,
+			Title:            item.Title,
+			Type:             item.Type,
+			Status:           item.Status,
+			CreatedAt:        item.CreatedAt,
+			LatestAccessTime: item.LatestAccessTime,
+		})
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.ChatSessionStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+}
+
+// importChatMessages 消息按发送顺序导入，新生成的 id 保持递增，与消息列表的排序一致
+func (im *spaceImporter) importChatMessages(ctx context.Context) error {
+	return archive.Each(im.reader, archive.TABLE_CHAT_MESSAGE, func(item archive.ChatMessage) error {
+		sessionID, ok := im.sessions[item.SessionID]
+		if !ok {
+			return nil
+		}
+
+		message, err := im.core.EncryptData([]byte(item.Message))
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.EncryptData", i18n.ERROR_INTERNAL, err)
+		}
+
+		id := utils.GenSpecIDStr()
+		err = im.core.Store().ChatMessageStore().Create(ctx, &types.ChatMessage{
+			ID:        id,
+			SpaceID:   im.spaceID,
+			SessionID: sessionID,
+			UserID:    im.userID,
+			Role:      item.Role,
+			Message:   string(message),
+			MsgType:   item.MsgType,
+			IsEncrypt: types.MESSAGE_IS_ENCRYPT,
+			SendTime:  item.SendTime,
+			Complete:  item.Complete,
+			Sequence:  item.Sequence,
+			MsgBlock:  item.MsgBlock,
+		})
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.ChatMessageStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+
+		var relDocs []string
+		for _, v := range item.RelDocs {
+			if knowledge, ok := im.knowledges[v]; ok {
+				relDocs = append(relDocs, knowledge.ID)
+			}
+		}
+		var citations types.Citations
+		for _, v := range item.Citations {
+			knowledge, ok := im.knowledges[v.KnowledgeID]
+			if !ok {
+				continue
+			}
+			v.KnowledgeID = knowledge.ID
+			// 片段未导入时(如重新生成片段)只保留知识点
+			v.ChunkID = im.chunks[v.ChunkID]
+			citations = append(citations, v)
+		}
+		if len(relDocs) == 0 && len(citations) == 0 {
+			return nil
+		}
+		err = im.core.Store().ChatMessageExtStore().Create(ctx, types.ChatMessageExt{
+			MessageID: id,
+			SessionID: sessionID,
+			SpaceID:   im.spaceID,
+			RelDocs:   relDocs,
+			Citations: citations,
+		})
+		if err != nil {
+			return errors.New("ArchiveLogic.ImportSpace.ChatMessageExtStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+}
@@ -181,7 +181,7 @@ func (l *ChatLogic) NewUserMessage(chatSession *types.ChatSession, msgArgs types
 	case types.AGENT_TYPE_NORMAL:
 		// else rag handler
 		go safe.Run(func() {
-			docs, usages, err := NewKnowledgeLogic(l.ctx, l.core).GetQueryRelevanceKnowledges(chatSession.SpaceID, l.GetUserInfo().User, msg.Message, resourceQuery)
+			docs, usages, err := NewKnowledgeLogic(l.ctx, l.core).GetQueryRelevanceKnowledges(chatSession.SpaceID, l.GetUserInfo().User, msg.Message, resourceQuery, nil)
 			if len(usages) > 0 {
 				for _, v := range usages {
 					process.NewRecordChatUsageRequest(v.Usage.Model, v.Subject, msgArgs.ID, v.Usage.Usage)
@@ -369,11 +369,12 @@ func RAGSessionHandle(core *core.Core, receiver types.Receiver, userMessage *typ
 	}
 
 	ext := types.ChatMessageExt{
-		SpaceID:   userMessage.SpaceID,
-		SessionID: userMessage.SessionID,
-		RelDocs:   relDocs,
-		CreatedAt: time.Now().Unix(),
-		UpdatedAt: time.Now().Unix(),
+		SpaceID:        userMessage.SpaceID,
+		SessionID:      userMessage.SessionID,
+		RelDocs:        relDocs,
+		ContextPacking: docs.Packing,
+		CreatedAt:      time.Now().Unix(),
+		UpdatedAt:      time.Now().Unix(),
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
@@ -40,6 +40,8 @@ type ChatMessageExt struct {
 	GenerationStatus types.GenerationStatusType `json:"generation_status"`
 	RelDocs          []RelDoc                   `json:"rel_docs"` // relevance docs
 	Marks            map[string]string          `json:"marks"`
+	ContextPacking   *types.ContextPacking      `json:"context_packing,omitempty"` // 知识内容按 token 预算裁剪的记录
+	Citations        types.Citations            `json:"citations"`                 // 回答中引用的知识点、片段及引用标记在回答中的位置
 }
 
 func (l *HistoryLogic) GetMessageExt(spaceID, sessionID, messageID string) (*ChatMessageExt, error) {
@@ -59,6 +61,8 @@ func (l *HistoryLogic) GetMessageExt(spaceID, sessionID, messageID string) (*Cha
 
 	result.Evaluate = data.Evaluate
 	result.GenerationStatus = data.GenerationStatus
+	result.ContextPacking = data.ContextPacking
+	result.Citations = data.Citations
 
 	if len(data.RelDocs) > 0 {
 		docs, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, types.GetKnowledgeOptions{
@@ -92,6 +96,7 @@ type MessageExt struct {
 	RelDocs          []RelDoc           `json:"rel_docs"`
 	Evaluate         types.EvaluateType `json:"evaluate"`
 	IsEvaluateEnable bool               `json:"is_evaluate_enable"`
+	Citations        types.Citations    `json:"citations"`
 }
 
 func (l *HistoryLogic) GetHistoryMessage(spaceID, sessionID, afterMsgID string, page, pageSize uint64) ([]*MessageDetail, int64, error) {
@@ -174,6 +179,7 @@ func (l *HistoryLogic) GetHistoryMessage(spaceID, sessionID, afterMsgID string,
 				Evaluate:         v.Ext.Evaluate,
 				IsEvaluateEnable: v.Ext.IsEvaluateEnable,
 				RelDocs:          relDocs,
+				Citations:        v.Ext.Citations,
 			},
 		}
 	})
@@ -194,6 +200,7 @@ func chatMsgAndExtToMessageDetail(msg *types.ChatMessage, ext *types.ChatMessage
 		data.Ext = &types.MessageExt{
 			Evaluate:         ext.Evaluate,
 			RelDocs:          ext.RelDocs,
+			Citations:        ext.Citations,
 			IsEvaluateEnable: lo.If(msg.Role == types.USER_ROLE_ASSISTANT, true).Else(false),
 		}
 	}
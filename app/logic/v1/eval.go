@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/rageval"
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+type EvalOptions struct {
+	SpaceID string
+	// UserID 只检索该用户的知识，为空时检索空间中的全部知识
+	UserID   string
+	Ks       []int
+	Generate bool // 为有参考答案的问题生成回答并计算回答指标
+}
+
+// EvalLogic 使用数据集离线评估空间的检索与生成效果，评估过程不会写入对话记录
+type EvalLogic struct {
+	ctx  context.Context
+	core *core.Core
+	UserInfo
+}
+
+func NewEvalLogic(ctx context.Context, core *core.Core) *EvalLogic {
+	return &EvalLogic{
+		ctx:      ctx,
+		core:     core,
+		UserInfo: SetupUserInfo(ctx, core),
+	}
+}
+
+func (l *EvalLogic) Run(cases []rageval.Case, opts EvalOptions) (rageval.Report, error) {
+	if _, err := l.core.Store().SpaceStore().GetSpace(l.ctx, opts.SpaceID); err != nil {
+		if err == sql.ErrNoRows {
+			return rageval.Report{}, errors.New("EvalLogic.Run.SpaceStore.GetSpace", i18n.ERROR_NOT_FOUND, err)
+		}
+		return rageval.Report{}, errors.New("EvalLogic.Run.SpaceStore.GetSpace", i18n.ERROR_INTERNAL, err)
+	}
+	if len(opts.Ks) == 0 {
+		opts.Ks = rageval.DefaultKs
+	}
+
+	results := make([]rageval.CaseResult, 0, len(cases))
+	for i, c := range cases {
+		res := l.evalCase(c, opts)
+		if res.Error != "" {
+			slog.Error("Failed to evaluate case", slog.String("case", c.ID), slog.String("error", res.Error))
+		}
+		slog.Info("evaluated case", slog.Int("index", i+1), slog.Int("total", len(cases)), slog.String("case", c.ID))
+		results = append(results, res)
+	}
+	return rageval.Summarize(opts.Ks, results), nil
+}
+
+func (l *EvalLogic) evalCase(c rageval.Case, opts EvalOptions) rageval.CaseResult {
+	res := rageval.CaseResult{
+		ID:          c.ID,
+		Question:    c.Question,
+		ExpectedIDs: c.ExpectedIDs,
+		Retrieved:   []string{},
+	}
+
+	docs, _, err := NewKnowledgeLogic(l.ctx, l.core).GetQueryRelevanceKnowledges(opts.SpaceID, opts.UserID, c.Question, nil, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	// 重排后提供给模型的顺序
+	for _, v := range docs.Docs {
+		res.Retrieved = append(res.Retrieved, v.ID)
+	}
+	res.Score(opts.Ks)
+
+	if !opts.Generate || c.ReferenceAnswer == "" {
+		return res
+	}
+
+	if res.Answer, err = l.generate(opts.SpaceID, c.Question, docs); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	f1 := rageval.TokenF1(res.Answer, c.ReferenceAnswer)
+	res.AnswerF1 = &f1
+
+	similarity, err := l.answerSimilarity(opts.SpaceID, res.Answer, c.ReferenceAnswer)
+	if err != nil {
+		slog.Error("Failed to embed answers, skip answer similarity", slog.String("case", c.ID), slog.String("error", err.Error()))
+		return res
+	}
+	res.AnswerSimilarity = &similarity
+	return res
+}
+
+// generate 使用与 Query 相同的提示词生成回答
+func (l *EvalLogic) generate(spaceID, question string, docs types.RAGDocs) (string, error) {
+	msg := &types.ChatMessage{
+		ID:       utils.GenUniqIDStr(),
+		UserID:   l.GetUserInfo().User,
+		SpaceID:  spaceID,
+		Message:  question,
+		MsgType:  types.MESSAGE_TYPE_TEXT,
+		SendTime: time.Now().Unix(),
+		Role:     types.USER_ROLE_USER,
+		Complete: types.MESSAGE_PROGRESS_COMPLETE,
+	}
+
+	receiver := &evalReceiver{}
+	if err := RAGHandle(l.core, receiver, msg, docs, types.GEN_MODE_NORMAL); err != nil {
+		return "", err
+	}
+	if receiver.progress != types.MESSAGE_PROGRESS_COMPLETE {
+		return "", fmt.Errorf("generate answer failed, progress: %d", receiver.progress)
+	}
+	return receiver.answer, nil
+}
+
+func (l *EvalLogic) answerSimilarity(spaceID, answer, reference string) (float64, error) {
+	settings := NewKnowledgeLogic(l.ctx, l.core).getSpaceSettings(spaceID)
+	embedder, ok := l.core.Srv().AI().EmbeddingWith(settings.Embedding.Driver)
+	if !ok {
+		embedder = l.core.Srv().AI()
+	}
+	vector, err := embedder.EmbeddingForDocument(l.ctx, "", []string{answer, reference})
+	if err != nil {
+		return 0, err
+	}
+	if len(vector.Data) != 2 {
+		return 0, fmt.Errorf("unexpected embedding result length %d", len(vector.Data))
+	}
+	return rageval.Cosine(vector.Data[0], vector.Data[1]), nil
+}
+
+// evalReceiver 收集非流式的回复，不写入消息记录
+type evalReceiver struct {
+	answer   string
+	progress types.MessageProgress
+}
+
+func (s *evalReceiver) IsStream() bool {
+	return false
+}
+
+func (s *evalReceiver) RecvMessageInit(userReqMsg *types.ChatMessage, msgID string, seqID int64, ext types.ChatMessageExt) error {
+	return nil
+}
+
+func (s *evalReceiver) GetReceiveFunc() types.ReceiveFunc {
+	return func(startAt int32, message types.MessageContent, progress types.MessageProgress) error {
+		s.progress = progress
+		if message != nil {
+			s.answer = string(message.Bytes())
+		}
+		return nil
+	}
+}
+
+func (s *evalReceiver) GetDoneFunc(callback func(receiveMsg *types.ChatMessage)) types.DoneFunc {
+	return func(startAt int32) error {
+		if callback != nil {
+			callback(nil)
+		}
+		return nil
+	}
+}
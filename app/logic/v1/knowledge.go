@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/davidscottmills/goeditorjs"
-	"github.com/pgvector/pgvector-go"
 	"github.com/samber/lo"
 	"github.com/sashabaranov/go-openai"
 
@@ -20,9 +19,12 @@ import (
 	"github.com/breeew/brew-api/app/core/srv"
 	"github.com/breeew/brew-api/app/logic/v1/process"
 	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/dedup"
 	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/extract"
 	"github.com/breeew/brew-api/pkg/i18n"
 	"github.com/breeew/brew-api/pkg/safe"
+	"github.com/breeew/brew-api/pkg/search"
 	"github.com/breeew/brew-api/pkg/types"
 	"github.com/breeew/brew-api/pkg/utils"
 )
@@ -108,11 +110,12 @@ func (l *KnowledgeLogic) GetTimeRangeLiteKnowledges(spaceID string, st, et time.
 	return data, nil
 }
 
-func (l *KnowledgeLogic) ListKnowledges(spaceID string, keywords string, resource *types.ResourceQuery, page, pagesize uint64) ([]*types.Knowledge, uint64, error) {
+func (l *KnowledgeLogic) ListKnowledges(spaceID string, keywords string, resource *types.ResourceQuery, tags []string, page, pagesize uint64) ([]*types.Knowledge, uint64, error) {
 	opts := types.GetKnowledgeOptions{
 		SpaceID:  spaceID,
 		Resource: resource,
 		Keywords: keywords,
+		Tags:     tags,
 	}
 	list, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, opts, page, pagesize)
 	if err != nil && err != sql.ErrNoRows {
@@ -153,6 +156,11 @@ func (l *KnowledgeLogic) Delete(spaceID, id string) error {
 				slog.Error("Failed to remark knowledge files to delete status", slog.String("knowledge_id", id), slog.String("space_id", spaceID), slog.Any("error", err))
 			}
 		}
+		if knowledge.Kind == types.KNOWLEDGE_KIND_FILE && knowledge.Source != "" {
+			if err = l.core.Store().FileManagementStore().UpdateStatus(ctx, spaceID, []string{knowledge.Source}, types.FILE_UPLOAD_STATUS_NEED_TO_DELETE); err != nil {
+				slog.Error("Failed to remark knowledge source file to delete status", slog.String("knowledge_id", id), slog.String("space_id", spaceID), slog.Any("error", err))
+			}
+		}
 
 		if err := l.core.Store().KnowledgeStore().Delete(ctx, spaceID, id); err != nil {
 			return errors.New("KnowledgeLogic.Delete.KnowledgeStore.Delete", i18n.ERROR_INTERNAL, err)
@@ -162,6 +170,30 @@ func (l *KnowledgeLogic) Delete(spaceID, id string) error {
 			return errors.New("KnowledgeLogic.Delete.KnowledgeChunkStore.BatchDelete", i18n.ERROR_INTERNAL, err)
 		}
 
+		if err := l.core.Store().KnowledgeProgressStore().Delete(ctx, spaceID, id); err != nil {
+			return errors.New("KnowledgeLogic.Delete.KnowledgeProgressStore.Delete", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeCrawlStore().Delete(ctx, spaceID, id); err != nil {
+			return errors.New("KnowledgeLogic.Delete.KnowledgeCrawlStore.Delete", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeRevisionStore().BatchDelete(ctx, spaceID, id); err != nil {
+			return errors.New("KnowledgeLogic.Delete.KnowledgeRevisionStore.BatchDelete", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeLinkStore().BatchDelete(ctx, spaceID, id); err != nil {
+			return errors.New("KnowledgeLogic.Delete.KnowledgeLinkStore.BatchDelete", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeRelatedStore().BatchDelete(ctx, spaceID, id); err != nil {
+			return errors.New("KnowledgeLogic.Delete.KnowledgeRelatedStore.BatchDelete", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeDuplicateStore().BatchDelete(ctx, spaceID, id); err != nil {
+			return errors.New("KnowledgeLogic.Delete.KnowledgeDuplicateStore.BatchDelete", i18n.ERROR_INTERNAL, err)
+		}
+
 		if err := l.core.Store().VectorStore().BatchDelete(ctx, spaceID, id); err != nil {
 			return errors.New("KnowledgeLogic.Delete.VectorStore.Delete", i18n.ERROR_INTERNAL, err)
 		}
@@ -201,6 +233,7 @@ func (l *KnowledgeLogic) Update(spaceID, id string, args types.UpdateKnowledgeAr
 		}
 	}
 
+	encryptedOldKnowledge := *oldKnowledge
 	if oldKnowledge.Content, err = l.core.DecryptData(oldKnowledge.Content); err != nil {
 		return errors.New("KnowledgeLogic.Update.DecryptData.oldKnowledge", i18n.ERROR_INTERNAL, err)
 	}
@@ -216,24 +249,57 @@ func (l *KnowledgeLogic) Update(spaceID, id string, args types.UpdateKnowledgeAr
 		summary = append(summary, "title")
 	}
 
+	contentHash := l.core.HashContent(string(args.Content))
 	if args.Content, err = l.core.EncryptData(args.Content); err != nil {
 		return errors.New("KnowledgeLogic.Update.EncryptData", i18n.ERROR_INTERNAL, err)
 	}
 
-	err = l.core.Store().KnowledgeStore().Update(l.ctx, spaceID, id, types.UpdateKnowledgeArgs{
-		Resource:    args.Resource,
-		Title:       args.Title,
-		Content:     args.Content,
-		ContentType: args.ContentType,
-		Tags:        args.Tags,
-		Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
-		Kind:        args.Kind,
-		Summary:     strings.Join(summary, ","),
+	err = l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		if err := l.createBaseRevision(ctx, &encryptedOldKnowledge); err != nil {
+			return errors.Trace("KnowledgeLogic.Update", err)
+		}
+
+		err := l.core.Store().KnowledgeStore().Update(ctx, spaceID, id, types.UpdateKnowledgeArgs{
+			Resource:    args.Resource,
+			Title:       args.Title,
+			Content:     args.Content,
+			ContentType: args.ContentType,
+			Tags:        args.Tags,
+			Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+			Kind:        args.Kind,
+			Summary:     strings.Join(summary, ","),
+		})
+		if err != nil {
+			return errors.New("KnowledgeLogic.Update.KnowledgeStore.Update", i18n.ERROR_INTERNAL, err)
+		}
+
+		// 每次修改都保存一个不可变的版本
+		err = l.core.Store().KnowledgeRevisionStore().Create(ctx, types.KnowledgeRevision{
+			ID:          utils.GenRandomID(),
+			KnowledgeID: id,
+			SpaceID:     spaceID,
+			UserID:      l.GetUserInfo().User,
+			Origin:      types.KNOWLEDGE_REVISION_ORIGIN_EDIT,
+			Title:       lo.If(args.Title != "", args.Title).Else(oldKnowledge.Title),
+			Content:     args.Content,
+			ContentType: lo.If(args.ContentType != "", args.ContentType).Else(oldKnowledge.ContentType),
+			ContentHash: contentHash,
+		})
+		if err != nil {
+			return errors.New("KnowledgeLogic.Update.KnowledgeRevisionStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
 	})
 	if err != nil {
-		return errors.New("KnowledgeLogic.Update.KnowledgeStore.Update", i18n.ERROR_INTERNAL, err)
+		return err
 	}
 
+	l.reprocessKnowledgeAsync(spaceID, id)
+	return nil
+}
+
+// reprocessKnowledgeAsync 知识点内容变化后在后台重新处理
+func (l *KnowledgeLogic) reprocessKnowledgeAsync(spaceID, id string) {
 	go safe.Run(func() {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
 		defer cancel()
@@ -261,8 +327,6 @@ func (l *KnowledgeLogic) Update(spaceID, id string, args types.UpdateKnowledgeAr
 				slog.Any("error", err))
 		}
 	})
-
-	return nil
 }
 
 type UsageItem struct {
@@ -270,7 +334,13 @@ type UsageItem struct {
 	Usage   ai.Usage
 }
 
-func (l *KnowledgeLogic) GetQueryRelevanceKnowledges(spaceID, userID, query string, resource *types.ResourceQuery) (types.RAGDocs, []UsageItem, error) {
+// GetQueryRelevanceKnowledges 检索与 query 相关的知识点，filter 不为空时只在满足筛选条件的知识点中检索
+func (l *KnowledgeLogic) GetQueryRelevanceKnowledges(spaceID, userID, query string, resource *types.ResourceQuery, filter *types.KnowledgeFilter) (types.RAGDocs, []UsageItem, error) {
+	return l.queryRelevanceKnowledges(spaceID, userID, query, resource, filter, nil)
+}
+
+// queryRelevanceKnowledges explain 不为空时记录检索各个步骤的中间结果
+func (l *KnowledgeLogic) queryRelevanceKnowledges(spaceID, userID, query string, resource *types.ResourceQuery, filter *types.KnowledgeFilter, explain *types.RetrievalExplain) (types.RAGDocs, []UsageItem, error) {
 	var (
 		result types.RAGDocs
 		usages []UsageItem
@@ -280,6 +350,7 @@ func (l *KnowledgeLogic) GetQueryRelevanceKnowledges(spaceID, userID, query stri
 	resp, err := aiOpts.EnhanceQuery(query)
 	if err != nil {
 		slog.Error("failed to enhance user query", slog.String("query", query), slog.String("error", err.Error()))
+		explain.Warn("enhance query", err)
 		// return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.AI.EnhanceQuery", i18n.ERROR_INTERNAL, err)
 	}
 
@@ -298,44 +369,69 @@ func (l *KnowledgeLogic) GetQueryRelevanceKnowledges(spaceID, userID, query stri
 		queryStrs = append(queryStrs, resp.News...)
 	}
 
-	vector, err := l.core.Srv().AI().EmbeddingForQuery(l.ctx, []string{strings.Join(queryStrs, " ")})
+	spaceSettings := l.getSpaceSettings(spaceID)
+	// 模型迁移期间仍使用旧模型检索，新模型的向量在迁移完成后才会生效
+	embedder, ok := l.core.Srv().AI().EmbeddingWith(spaceSettings.Embedding.Driver)
+	if !ok {
+		slog.Error("Space embedding driver not found, use default driver", slog.String("space_id", spaceID), slog.String("driver", spaceSettings.Embedding.Driver))
+		embedder = l.core.Srv().AI()
+	}
+
+	vector, err := embedder.EmbeddingForQuery(l.ctx, []string{strings.Join(queryStrs, " ")})
 	if err != nil || len(vector.Data) == 0 {
 		return types.RAGDocs{}, nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.AI.EmbeddingForQuery", i18n.ERROR_INTERNAL, err)
 	}
 
 	refs, err := l.core.Store().VectorStore().Query(l.ctx, types.GetVectorsOptions{
-		SpaceID:  spaceID,
-		UserID:   userID,
-		Resource: resource,
-	}, pgvector.NewVector(vector.Data[0]), 100)
+		SpaceID:   spaceID,
+		UserID:    userID,
+		Resource:  resource,
+		Model:     spaceSettings.Embedding.Model, // 未指定模型的空间只按照维度过滤
+		Dimension: len(vector.Data[0]),
+		Knowledge: filter,
+	}, vector.Data[0], 100)
 	if err != nil {
 		return types.RAGDocs{}, nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.VectorStore.Query", i18n.ERROR_INTERNAL, err)
 	}
 
 	slog.Debug("got query result", slog.String("query", query), slog.Any("result", refs))
-	if len(refs) == 0 {
-		return types.RAGDocs{}, nil, nil
-	}
 
 	// rerank
 
-	var (
-		knowledgeIDs []string
-		cosLimit     float32 = 0.5
-	)
-
-	if len(refs) > 10 {
-		cosLimit = refs[0].Cos + 0.15
+	var knowledgeIDs []string
+	if explain != nil {
+		explain.EnhancedQueries = resp.News
+		explain.EmbeddingModel = vector.Model
 	}
-	for i, v := range refs {
-		if i > 0 && (v.Cos > cosLimit && v.OriginalLength > 150) {
-			// TODO：more and more verify best ratio
-			continue
+	vectorRefs := filterVectorRefs(refs, explain)
+
+	settings := spaceSettings.Retrieval
+	var keywordRefs []types.ChunkSearchResult
+	if settings.GetKeywordWeight() > 0 {
+		keywordRefs, err = l.core.Store().KnowledgeChunkStore().Search(l.ctx, types.SearchChunksOptions{
+			SpaceID:   spaceID,
+			UserID:    userID,
+			Resource:  resource,
+			Knowledge: filter,
+		}, l.core.EncryptKeywords(search.Unique(search.Tokenize(query))), 50)
+		if err != nil {
+			// 关键词检索失败时降级为纯向量检索
+			slog.Error("Failed to search knowledge chunks by keywords", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+			explain.Warn("keyword search", err)
 		}
+		slog.Debug("got keyword search result", slog.String("query", query), slog.Any("result", keywordRefs))
+	}
 
-		result.Refs = append(result.Refs, v)
+	result.Refs = fuseRetrievalResults(settings, vectorRefs, keywordRefs)
+	if explain != nil {
+		explain.KeywordHits = keywordRefs
+		explain.Fused = result.Refs
+	}
+	if len(result.Refs) == 0 {
+		return types.RAGDocs{}, nil, nil
 	}
 
+	matched := matchedChunks(result.Refs)
 	result.Refs = lo.UniqBy(result.Refs, func(item types.QueryResult) string {
 		return item.KnowledgeID
 	})
@@ -344,11 +440,24 @@ func (l *KnowledgeLogic) GetQueryRelevanceKnowledges(spaceID, userID, query stri
 		knowledgeIDs = append(knowledgeIDs, v.KnowledgeID)
 	}
 
+	if settings.LinkExpansion > 0 {
+		linked, err := l.expandLinkedKnowledges(spaceID, knowledgeIDs, settings.LinkExpansion)
+		if err != nil {
+			slog.Error("Failed to expand knowledges by links", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+			explain.Warn("link expansion", err)
+		}
+		knowledgeIDs = append(knowledgeIDs, linked...)
+		if explain != nil {
+			explain.LinkedIDs = linked
+		}
+	}
+
 	knowledges, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, types.GetKnowledgeOptions{
 		IDs:      knowledgeIDs,
 		SpaceID:  spaceID,
 		UserID:   userID,
 		Resource: resource,
+		Filter:   filter, // 通过链接扩展的知识点同样需要满足筛选条件
 	}, 1, 100)
 	if err != nil && err != sql.ErrNoRows {
 		return types.RAGDocs{}, nil, errors.New("KnowledgeLogic.Query.KnowledgeStore.ListKnowledge", i18n.ERROR_INTERNAL, err)
@@ -377,14 +486,35 @@ func (l *KnowledgeLogic) GetQueryRelevanceKnowledges(spaceID, userID, query stri
 		return result, usages, nil
 	}
 
-	rankList, usage, err := l.core.Rerank(query, knowledges)
+	rankList, scores, usage, err := l.core.Rerank(query, knowledges)
 	if err != nil {
 		slog.Error("Failed to request rerank api", slog.String("error", err.Error()))
+		explain.Warn("rerank", err)
 		// return result, usage, errors.New("KnowledgeLogic.Query.Rerank", i18n.ERROR_INTERNAL, err)
 		rankList = knowledges
+	} else {
+		// 低于重排阈值的知识点不再作为引用
+		kept := lo.SliceToMap(rankList, func(item *types.Knowledge) (string, bool) {
+			return item.ID, true
+		})
+		result.Refs = lo.Filter(result.Refs, func(item types.QueryResult, _ int) bool {
+			return kept[item.KnowledgeID]
+		})
 	}
 
 	slog.Debug("rerank result", slog.Int("knowledge_length", len(rankList)))
+	if explain != nil {
+		scoreMap := lo.SliceToMap(scores, func(item ai.RankDocItem) (string, float64) {
+			return item.ID, item.Score
+		})
+		for _, v := range rankList {
+			explain.Rerank = append(explain.Rerank, types.ExplainRerankItem{
+				KnowledgeID: v.ID,
+				Title:       v.Title,
+				Score:       scoreMap[v.ID],
+			})
+		}
+	}
 
 	if usage != nil {
 		usages = append(usages, UsageItem{
@@ -398,6 +528,19 @@ func (l *KnowledgeLogic) GetQueryRelevanceKnowledges(spaceID, userID, query stri
 		})
 	}
 
+	if rankList, result.Packing, result.Sources = l.buildKnowledgeContext(spaceID, rankList, matched, explain); result.Packing != nil {
+		// 预算不足未放入上下文的知识点不再作为引用
+		kept := lo.SliceToMap(rankList, func(item *types.Knowledge) (string, bool) {
+			return item.ID, true
+		})
+		result.Refs = lo.Filter(result.Refs, func(item types.QueryResult, _ int) bool {
+			return kept[item.KnowledgeID]
+		})
+	}
+	if explain != nil {
+		explain.Packing = result.Packing
+	}
+
 	if result.Docs, err = l.core.AppendKnowledgeContentToDocs(result.Docs, rankList); err != nil {
 		return result, usages, errors.New("KnowledgeLogic.Query.AppendKnowledgeContentToDocs", i18n.ERROR_INTERNAL, err)
 	}
@@ -405,12 +548,99 @@ func (l *KnowledgeLogic) GetQueryRelevanceKnowledges(spaceID, userID, query stri
 	return result, usages, nil
 }
 
+func (l *KnowledgeLogic) getSpaceSettings(spaceID string) types.SpaceSettings {
+	space, err := l.core.Store().SpaceStore().GetSpace(l.ctx, spaceID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.Error("Failed to get space settings, use default settings", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+		}
+		return types.SpaceSettings{}
+	}
+	return space.Settings
+}
+
+// filterVectorRefs 丢弃与查询距离过远且原文较长的向量命中，第一条命中始终保留
+// 命中超过 10 条时以第一条的距离加 0.15 作为阈值，explain 不为空时记录阈值及丢弃原因
+func filterVectorRefs(refs []types.QueryResult, explain *types.RetrievalExplain) []types.QueryResult {
+	var (
+		res      []types.QueryResult
+		cosLimit float32 = 0.5
+	)
+
+	if len(refs) > 10 {
+		cosLimit = refs[0].Cos + 0.15
+	}
+	if explain != nil {
+		explain.CosLimit = cosLimit
+	}
+	for i, v := range refs {
+		if i > 0 && (v.Cos > cosLimit && v.OriginalLength > 150) {
+			// TODO：more and more verify best ratio
+			if explain != nil {
+				explain.VectorHits = append(explain.VectorHits, types.ExplainVectorHit{
+					QueryResult: v,
+					Dropped:     true,
+					DropReason:  fmt.Sprintf("cos %.4f > cos_limit %.4f and original_length %d > 150", v.Cos, cosLimit, v.OriginalLength),
+				})
+			}
+			continue
+		}
+
+		res = append(res, v)
+		if explain != nil {
+			explain.VectorHits = append(explain.VectorHits, types.ExplainVectorHit{QueryResult: v})
+		}
+	}
+	return res
+}
+
+// fuseRetrievalResults 使用 RRF 合并向量检索与关键词检索的结果(以 chunk 为单位)
+// 仅被关键词命中的 chunk 没有向量距离，Cos 记为 1
+func fuseRetrievalResults(settings types.RetrievalSettings, vectorRefs []types.QueryResult, keywordRefs []types.ChunkSearchResult) []types.QueryResult {
+	if len(keywordRefs) == 0 {
+		return vectorRefs
+	}
+
+	refs := make(map[string]types.QueryResult, len(vectorRefs)+len(keywordRefs))
+	vectorList := search.RankList{Weight: settings.GetVectorWeight()}
+	for _, v := range vectorRefs {
+		refs[v.ID] = v
+		vectorList.IDs = append(vectorList.IDs, v.ID)
+	}
+
+	keywordList := search.RankList{Weight: settings.GetKeywordWeight()}
+	for _, v := range keywordRefs {
+		if _, exist := refs[v.ID]; !exist {
+			refs[v.ID] = types.QueryResult{
+				ID:             v.ID,
+				KnowledgeID:    v.KnowledgeID,
+				Cos:            1,
+				OriginalLength: v.OriginalLength,
+			}
+		}
+		keywordList.IDs = append(keywordList.IDs, v.ID)
+	}
+
+	fused := search.ReciprocalRankFusion(settings.RRFK, vectorList, keywordList)
+	result := make([]types.QueryResult, 0, len(fused))
+	for _, v := range fused {
+		item := refs[v.ID]
+		item.Score = v.Score
+		result = append(result, item)
+	}
+	return result
+}
+
 type KnowledgeQueryResult struct {
 	Refs    []types.QueryResult `json:"-"`
 	Message string              `json:"message"`
 }
 
-func (l *KnowledgeLogic) Query(spaceID, agent string, resource *types.ResourceQuery, query string) (*KnowledgeQueryResult, error) {
+func (l *KnowledgeLogic) Query(spaceID, agent string, resource *types.ResourceQuery, filter *types.KnowledgeFilter, query string) (*KnowledgeQueryResult, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, errors.New("KnowledgeLogic.Query.Filter.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
 	msgArgs := &types.ChatMessage{
 		ID:        utils.GenUniqIDStr(),
 		UserID:    l.GetUserInfo().User,
@@ -451,7 +681,7 @@ func (l *KnowledgeLogic) Query(spaceID, agent string, resource *types.ResourceQu
 			slog.Error("Failed to handle journal message", slog.String("msg_id", msgArgs.ID), slog.String("error", err.Error()))
 		}
 	case types.AGENT_TYPE_NORMAL:
-		docs, usages, err := NewKnowledgeLogic(l.ctx, l.core).GetQueryRelevanceKnowledges(msgArgs.SpaceID, l.GetUserInfo().User, msgArgs.Message, resource)
+		docs, usages, err := NewKnowledgeLogic(l.ctx, l.core).GetQueryRelevanceKnowledges(msgArgs.SpaceID, l.GetUserInfo().User, msgArgs.Message, resource, filter)
 		if len(usages) > 0 {
 			for _, v := range usages {
 				process.NewRecordChatUsageRequest(v.Usage.Model, v.Subject, msgArgs.ID, v.Usage.Usage)
@@ -568,27 +798,29 @@ func UpdateFilesToDelete(ctx context.Context, core *core.Core, spaceID string, c
 	return nil
 }
 
-func (l *KnowledgeLogic) insertContent(isSync bool, spaceID, resource string, kind types.KnowledgeKind, content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, error) {
+// insertContent 保存前检查空间中是否已有重复的内容，发现重复时仍然保存并返回已存在的知识点，
+// strict 为 true 时拒绝保存
+func (l *KnowledgeLogic) insertContent(isSync, strict bool, spaceID, resource string, kind types.KnowledgeKind, content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, *types.DuplicateKnowledge, error) {
 	if resource == "" {
 		resource = types.DEFAULT_RESOURCE
 	}
 
-	var (
-		err         error
-		encryptData []byte
-	)
-	if encryptData, err = l.core.EncryptData(content); err != nil {
-		return "", errors.New("KnowledgeLogic.InsertContent.EncryptDatae", i18n.ERROR_INTERNAL, err)
+	duplicate, err := l.FindDuplicate(spaceID, content, contentType)
+	if err != nil {
+		return "", nil, errors.Trace("KnowledgeLogic.insertContent", err)
+	}
+	if duplicate != nil && strict {
+		return "", duplicate, errors.New("KnowledgeLogic.insertContent.Duplicate", i18n.ERROR_KNOWLEDGE_DUPLICATE,
+			fmt.Errorf("duplicate of knowledge %s(%s)", duplicate.Knowledge.ID, duplicate.Reason)).Code(http.StatusConflict)
 	}
 
-	knowledgeID := utils.GenRandomID()
 	user := l.GetUserInfo()
 	knowledge := types.Knowledge{
-		ID:          knowledgeID,
+		ID:          utils.GenRandomID(),
 		SpaceID:     spaceID,
 		UserID:      user.User,
 		Resource:    resource,
-		Content:     encryptData,
+		Content:     content,
 		ContentType: contentType,
 		Kind:        kind,
 		Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
@@ -596,19 +828,60 @@ func (l *KnowledgeLogic) insertContent(isSync bool, spaceID, resource string, ki
 		CreatedAt:   time.Now().Unix(),
 		UpdatedAt:   time.Now().Unix(),
 	}
-	err = l.core.Store().KnowledgeStore().Create(l.ctx, knowledge)
+	if err := l.insertKnowledge(isSync, knowledge, nil); err != nil {
+		return knowledge.ID, nil, err
+	}
+
+	if contentType == types.KNOWLEDGE_CONTENT_TYPE_BLOCKS {
+		go safe.Run(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			if err := UpdateFilesUploaded(ctx, l.core, spaceID, content); err != nil {
+				slog.Error("Failed to update files uploaded status", slog.String("space_id", spaceID), slog.Any("error", err))
+			}
+		})
+	}
+
+	return knowledge.ID, duplicate, nil
+}
+
+// insertKnowledge 加密保存知识点后交给 process 处理，knowledge.Content 为明文
+// afterCreate 与知识点的创建在同一个事务中执行，用于保存与知识点关联的数据
+func (l *KnowledgeLogic) insertKnowledge(isSync bool, knowledge types.Knowledge, afterCreate func(ctx context.Context) error) error {
+	content := knowledge.Content
+	text, err := dedup.PlainText(knowledge.ContentType, content)
+	if err != nil {
+		return errors.New("KnowledgeLogic.InsertContent.PlainText", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+	knowledge.ContentHash = l.core.HashContent(dedup.Normalize(text))
+
+	encryptData, err := l.core.EncryptData(content)
 	if err != nil {
-		return "", errors.New("KnowledgeLogic.InsertContent.Store.KnowledgeStore.Create", i18n.ERROR_INTERNAL, err)
+		return errors.New("KnowledgeLogic.InsertContent.EncryptDatae", i18n.ERROR_INTERNAL, err)
+	}
+
+	knowledge.Content = encryptData
+	err = l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		if err := l.core.Store().KnowledgeStore().Create(ctx, knowledge); err != nil {
+			return errors.New("KnowledgeLogic.InsertContent.Store.KnowledgeStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		if afterCreate != nil {
+			return afterCreate(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	knowledge.Content = content
 	if isSync {
 		if err = l.processKnowledgeAsync(knowledge); err != nil {
-			return knowledgeID, errors.Trace("KnowledgeLogic.InsertContent", err)
+			return errors.Trace("KnowledgeLogic.InsertContent", err)
 		}
 	} else {
 		go safe.Run(func() {
-			if err = l.processKnowledgeAsync(knowledge); err != nil {
+			if err := l.processKnowledgeAsync(knowledge); err != nil {
 				slog.Error("Process knowledge async failed",
 					slog.String("space_id", knowledge.SpaceID),
 					slog.String("knowledge_id", knowledge.ID),
@@ -616,18 +889,7 @@ func (l *KnowledgeLogic) insertContent(isSync bool, spaceID, resource string, ki
 			}
 		})
 	}
-
-	if contentType == types.KNOWLEDGE_CONTENT_TYPE_BLOCKS {
-		go safe.Run(func() {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-			defer cancel()
-			if err := UpdateFilesUploaded(ctx, l.core, spaceID, content); err != nil {
-				slog.Error("Failed to update files uploaded status", slog.String("space_id", spaceID), slog.Any("error", err))
-			}
-		})
-	}
-
-	return knowledgeID, nil
+	return nil
 }
 
 const (
@@ -635,12 +897,12 @@ const (
 	InserTypeAsync = false
 )
 
-func (l *KnowledgeLogic) InsertContentAsync(spaceID, resource string, kind types.KnowledgeKind, content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, error) {
-	return l.insertContent(InserTypeAsync, spaceID, resource, kind, content, contentType)
+func (l *KnowledgeLogic) InsertContentAsync(strict bool, spaceID, resource string, kind types.KnowledgeKind, content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, *types.DuplicateKnowledge, error) {
+	return l.insertContent(InserTypeAsync, strict, spaceID, resource, kind, content, contentType)
 }
 
-func (l *KnowledgeLogic) InsertContent(spaceID, resource string, kind types.KnowledgeKind, content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, error) {
-	return l.insertContent(InserTypeSync, spaceID, resource, kind, content, contentType)
+func (l *KnowledgeLogic) InsertContent(strict bool, spaceID, resource string, kind types.KnowledgeKind, content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, *types.DuplicateKnowledge, error) {
+	return l.insertContent(InserTypeSync, strict, spaceID, resource, kind, content, contentType)
 	// sw := mark.NewSensitiveWork()
 	// content = sw.Do(content)
 
@@ -694,6 +956,163 @@ func (l *KnowledgeLogic) InsertContent(spaceID, resource string, kind types.Know
 	// return knowledgeID, err
 }
 
+// InsertFile 提取已上传文件的文本创建知识点，知识点通过 Source 关联原始文件
+// 支持 pdf、docx、html、纯文本/markdown 及 csv
+func (l *KnowledgeLogic) InsertFile(isSync bool, spaceID, resource, file string) (string, error) {
+	if resource == "" {
+		resource = types.DEFAULT_RESOURCE
+	}
+	if parsed, err := url.Parse(file); err == nil && parsed.Host != "" {
+		file = parsed.Path
+	}
+
+	fileInfo, err := l.core.Store().FileManagementStore().GetByID(l.ctx, spaceID, file)
+	if err != nil && err != sql.ErrNoRows {
+		return "", errors.New("KnowledgeLogic.InsertFile.FileManagementStore.GetByID", i18n.ERROR_INTERNAL, err)
+	}
+	if fileInfo == nil {
+		return "", errors.New("KnowledgeLogic.InsertFile.FileManagementStore.GetByID.nil", i18n.ERROR_NOT_FOUND, nil).Code(http.StatusNotFound)
+	}
+
+	ctx, cancel := context.WithTimeout(l.ctx, time.Minute)
+	defer cancel()
+	object, err := l.core.FileStorage().DownloadFile(ctx, file)
+	if err != nil {
+		return "", errors.New("KnowledgeLogic.InsertFile.FileStorage.DownloadFile", i18n.ERROR_INTERNAL, err)
+	}
+
+	result, err := extract.Extract(file, object.FileType, object.File)
+	if err != nil {
+		switch err {
+		case extract.ErrUnsupported:
+			return "", errors.New("KnowledgeLogic.InsertFile.Extract", i18n.ERROR_UNSUPPORTED_FEATURE, err).Code(http.StatusUnsupportedMediaType)
+		default:
+			return "", errors.New("KnowledgeLogic.InsertFile.Extract", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+		}
+	}
+
+	knowledge := types.Knowledge{
+		ID:          utils.GenRandomID(),
+		SpaceID:     spaceID,
+		UserID:      l.GetUserInfo().User,
+		Resource:    resource,
+		Title:       result.Title,
+		Content:     types.KnowledgeContent(result.Content),
+		ContentType: types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN,
+		Source:      file,
+		Kind:        types.KNOWLEDGE_KIND_FILE,
+		Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+		MaybeDate:   time.Now().Local().Format("2006-01-02 15:04"),
+		CreatedAt:   time.Now().Unix(),
+		UpdatedAt:   time.Now().Unix(),
+	}
+	if err = l.insertKnowledge(isSync, knowledge, nil); err != nil {
+		return knowledge.ID, err
+	}
+
+	if err = l.core.Store().FileManagementStore().UpdateStatus(l.ctx, spaceID, []string{file}, types.FILE_UPLOAD_STATUS_UPLOADED); err != nil {
+		slog.Error("Failed to update file uploaded status", slog.String("space_id", spaceID), slog.String("file", file), slog.Any("error", err))
+	}
+	return knowledge.ID, nil
+}
+
+// InsertURL 通过 reader 抓取网页内容创建知识点，并按 interval(小时) 定期重新抓取
+// interval 为 0 时使用默认的抓取间隔
+func (l *KnowledgeLogic) InsertURL(isSync bool, spaceID, resource, endpoint string, interval int64) (string, error) {
+	if resource == "" {
+		resource = types.DEFAULT_RESOURCE
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", errors.New("KnowledgeLogic.InsertURL.ParseURL", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	interval *= 3600
+	if interval == 0 {
+		interval = types.DEFAULT_CRAWL_INTERVAL
+	}
+	if interval < types.MIN_CRAWL_INTERVAL || interval > types.MAX_CRAWL_INTERVAL {
+		return "", errors.New("KnowledgeLogic.InsertURL.Interval", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	ctx, cancel := context.WithTimeout(l.ctx, time.Minute)
+	defer cancel()
+	res, err := l.core.Srv().AI().Reader(ctx, endpoint)
+	if err != nil {
+		errMsg := i18n.ERROR_INTERNAL
+		code := http.StatusInternalServerError
+
+		if err == srv.ERROR_UNSUPPORTED_FEATURE {
+			errMsg = i18n.ERROR_UNSUPPORTED_FEATURE
+			code = http.StatusForbidden
+		}
+		return "", errors.New("KnowledgeLogic.InsertURL.Srv.AI.Reader", errMsg, err).Code(code)
+	}
+
+	content := strings.TrimSpace(res.Content)
+	if content == "" {
+		return "", errors.New("KnowledgeLogic.InsertURL.Srv.AI.Reader.Empty", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	user := l.GetUserInfo()
+	now := time.Now().Unix()
+	knowledge := types.Knowledge{
+		ID:          utils.GenRandomID(),
+		SpaceID:     spaceID,
+		UserID:      user.User,
+		Resource:    resource,
+		Content:     types.KnowledgeContent(content),
+		ContentType: types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN,
+		Source:      endpoint,
+		Kind:        types.KNOWLEDGE_KIND_URL,
+		Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+		MaybeDate:   time.Now().Local().Format("2006-01-02 15:04"),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	encryptContent, err := l.core.EncryptData(knowledge.Content)
+	if err != nil {
+		return "", errors.New("KnowledgeLogic.InsertURL.EncryptData", i18n.ERROR_INTERNAL, err)
+	}
+	hash := l.core.HashContent(content)
+
+	err = l.insertKnowledge(isSync, knowledge, func(ctx context.Context) error {
+		err := l.core.Store().KnowledgeCrawlStore().Create(ctx, types.KnowledgeCrawl{
+			KnowledgeID:   knowledge.ID,
+			SpaceID:       spaceID,
+			URL:           endpoint,
+			ContentHash:   hash,
+			Interval:      interval,
+			LastCrawledAt: now,
+			NextCrawlAt:   now + interval,
+		})
+		if err != nil {
+			return errors.New("KnowledgeLogic.InsertURL.KnowledgeCrawlStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+
+		err = l.core.Store().KnowledgeRevisionStore().Create(ctx, types.KnowledgeRevision{
+			ID:          utils.GenRandomID(),
+			KnowledgeID: knowledge.ID,
+			SpaceID:     spaceID,
+			UserID:      user.User,
+			Origin:      types.KNOWLEDGE_REVISION_ORIGIN_CRAWL,
+			Content:     encryptContent,
+			ContentType: knowledge.ContentType,
+			ContentHash: hash,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			return errors.New("KnowledgeLogic.InsertURL.KnowledgeRevisionStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return knowledge.ID, err
+	}
+	return knowledge.ID, nil
+}
+
 func (l *KnowledgeLogic) processKnowledgeAsync(knowledge types.Knowledge) error {
 	ctx, cancel := context.WithTimeout(l.ctx, time.Minute*2)
 	defer cancel()
@@ -0,0 +1,147 @@
+package v1
+
+import (
+	"log/slog"
+
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/contextpack"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// MAX_MATCHED_CHUNKS_PER_KNOWLEDGE 每个知识点优先放入上下文的命中片段数，避免排序靠前的长文档占满预算
+const MAX_MATCHED_CHUNKS_PER_KNOWLEDGE = 3
+
+// matchedChunks 按得分顺序收集每个知识点命中的片段 id
+func matchedChunks(refs []types.QueryResult) map[string][]string {
+	matched := make(map[string][]string)
+	for _, v := range refs {
+		if len(matched[v.KnowledgeID]) < MAX_MATCHED_CHUNKS_PER_KNOWLEDGE {
+			matched[v.KnowledgeID] = append(matched[v.KnowledgeID], v.ID)
+		}
+	}
+	return matched
+}
+
+// buildKnowledgeContext 按对话模型的 token 预算裁剪知识点内容，优先放入命中的片段，预算有剩余时补充相邻片段或使用完整内容
+// 每段内容前加上引用标记，返回放入上下文的知识点(内容替换为裁剪后带引用标记的内容，顺序不变)、裁剪记录及引用标记对应的来源
+// 未限制预算时使用完整内容，裁剪记录为 nil
+func (l *KnowledgeLogic) buildKnowledgeContext(spaceID string, knowledges []*types.Knowledge, matched map[string][]string, explain *types.RetrievalExplain) ([]*types.Knowledge, *types.ContextPacking, []types.CitationSource) {
+	if len(knowledges) == 0 {
+		return knowledges, nil, nil
+	}
+	cfg := l.core.Cfg().AI.Context
+	model := l.core.Srv().AI().ChatModel()
+	budget := cfg.Budget(model)
+
+	var chunks map[string][]types.KnowledgeChunk
+	if budget > 0 {
+		chunks = l.listMatchedKnowledgeChunks(spaceID, knowledges, matched, explain)
+	}
+	docs := make([]contextpack.Document, 0, len(knowledges))
+	for _, v := range knowledges {
+		doc := contextpack.Document{
+			ID:      v.ID,
+			Content: string(v.Content),
+		}
+		hit := lo.SliceToMap(matched[v.ID], func(item string) (string, bool) {
+			return item, true
+		})
+		for i, c := range chunks[v.ID] {
+			doc.Chunks = append(doc.Chunks, c.Chunk)
+			if hit[c.ID] {
+				doc.Matched = append(doc.Matched, i)
+			}
+		}
+		docs = append(docs, doc)
+	}
+
+	res := contextpack.Pack(docs, contextpack.Options{
+		Budget:    budget,
+		Neighbors: cfg.NeighborChunks(),
+		Count:     ai.NewTokenCounter(model),
+	})
+
+	packing := &types.ContextPacking{
+		Model:     model,
+		Budget:    budget,
+		Used:      res.Used,
+		Truncated: []types.ContextPackingItem{},
+	}
+	var (
+		list    = make([]*types.Knowledge, 0, len(knowledges))
+		sources []types.CitationSource
+	)
+	for i, p := range res.Passages {
+		if p.Mode != contextpack.MODE_FULL {
+			packing.Truncated = append(packing.Truncated, types.ContextPackingItem{
+				KnowledgeID:    p.ID,
+				Mode:           p.Mode,
+				Tokens:         p.Tokens,
+				OriginalTokens: p.OriginalTokens,
+			})
+		}
+		if p.Mode == contextpack.MODE_DROPPED {
+			continue
+		}
+
+		item := *knowledges[i]
+		item.Content = types.KnowledgeContent(contextpack.Render(p.Parts, func(part contextpack.Part) string {
+			source := types.CitationSource{
+				Marker:      len(sources) + 1,
+				KnowledgeID: item.ID,
+			}
+			if part.Chunk >= 0 {
+				source.ChunkID = chunks[item.ID][part.Chunk].ID
+			}
+			sources = append(sources, source)
+			return ai.CitationMarker(source.Marker) + " "
+		}))
+		list = append(list, &item)
+	}
+
+	if budget <= 0 {
+		return list, nil, sources
+	}
+	if len(packing.Truncated) > 0 {
+		slog.Debug("knowledge context packed", slog.String("space_id", spaceID), slog.String("model", model), slog.Int("budget", budget),
+			slog.Int("used", res.Used), slog.Any("truncated", packing.Truncated))
+	}
+	return list, packing, sources
+}
+
+// listMatchedKnowledgeChunks 获取有命中片段的知识点的全部片段(已解密，按原文顺序)，获取失败的知识点只使用完整内容
+func (l *KnowledgeLogic) listMatchedKnowledgeChunks(spaceID string, knowledges []*types.Knowledge, matched map[string][]string, explain *types.RetrievalExplain) map[string][]types.KnowledgeChunk {
+	ids := lo.FilterMap(knowledges, func(item *types.Knowledge, _ int) (string, bool) {
+		return item.ID, len(matched[item.ID]) > 0
+	})
+	if len(ids) == 0 {
+		return nil
+	}
+
+	list, err := l.core.Store().KnowledgeChunkStore().ListByKnowledgeIDs(l.ctx, spaceID, ids)
+	if err != nil {
+		slog.Error("Failed to list knowledge chunks, use whole content", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+		explain.Warn("list knowledge chunks", err)
+		return nil
+	}
+
+	res := make(map[string][]types.KnowledgeChunk)
+	failed := make(map[string]bool)
+	for _, v := range list {
+		if failed[v.KnowledgeID] {
+			continue
+		}
+		chunk, err := l.core.DecryptData([]byte(v.Chunk))
+		if err != nil {
+			slog.Error("Failed to decrypt knowledge chunk, use whole content", slog.String("knowledge_id", v.KnowledgeID), slog.String("error", err.Error()))
+			failed[v.KnowledgeID] = true
+			delete(res, v.KnowledgeID)
+			continue
+		}
+		v.Chunk = string(chunk)
+		res[v.KnowledgeID] = append(res[v.KnowledgeID], v)
+	}
+	return res
+}
@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/app/logic/v1/process"
+	"github.com/breeew/brew-api/pkg/dedup"
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// FindDuplicate 查找空间中与 content 重复的知识点，优先比较内容哈希，
+// 哈希不同时使用空间的 embedding 模型检索最相近的切片，相似度不低于 KNOWLEDGE_DUPLICATE_MIN_SCORE 时视为重复
+// 相似度检索失败不影响内容的保存，只记录日志
+func (l *KnowledgeLogic) FindDuplicate(spaceID string, content types.KnowledgeContent, contentType types.KnowledgeContentType) (*types.DuplicateKnowledge, error) {
+	text, err := dedup.PlainText(contentType, content)
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.FindDuplicate.PlainText", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+	hash := l.core.HashContent(dedup.Normalize(text))
+	if hash == "" {
+		return nil, nil
+	}
+
+	list, err := l.core.Store().KnowledgeStore().ListLiteKnowledges(l.ctx, types.GetKnowledgeOptions{
+		SpaceID:     spaceID,
+		ContentHash: hash,
+	}, 1, 1)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.FindDuplicate.KnowledgeStore.ListLiteKnowledges", i18n.ERROR_INTERNAL, err)
+	}
+	if len(list) > 0 {
+		return &types.DuplicateKnowledge{
+			Knowledge: list[0],
+			Reason:    types.KNOWLEDGE_DUPLICATE_REASON_HASH,
+			Score:     1,
+		}, nil
+	}
+
+	duplicate, err := l.findSimilarKnowledge(spaceID, text)
+	if err != nil {
+		slog.Error("Failed to find similar knowledge", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+		return nil, nil
+	}
+	return duplicate, nil
+}
+
+func (l *KnowledgeLogic) findSimilarKnowledge(spaceID, text string) (*types.DuplicateKnowledge, error) {
+	if runes := []rune(text); len(runes) > types.KNOWLEDGE_DUPLICATE_CHECK_RUNES {
+		text = string(runes[:types.KNOWLEDGE_DUPLICATE_CHECK_RUNES])
+	}
+
+	spaceSettings := l.getSpaceSettings(spaceID)
+	embedder, ok := l.core.Srv().AI().EmbeddingWith(spaceSettings.Embedding.Driver)
+	if !ok {
+		embedder = l.core.Srv().AI()
+	}
+	vector, err := embedder.EmbeddingForDocument(l.ctx, "", []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if vector.Usage != nil {
+		process.NewRecordUsageRequest(vector.Model, types.USAGE_TYPE_KNOWLEDGE, types.USAGE_SUB_TYPE_EMBEDDING, spaceID, l.GetUserInfo().User, vector.Usage)
+	}
+	if len(vector.Data) == 0 {
+		return nil, nil
+	}
+
+	refs, err := l.core.Store().VectorStore().Query(l.ctx, types.GetVectorsOptions{
+		SpaceID:   spaceID,
+		Model:     spaceSettings.Embedding.Model,
+		Dimension: len(vector.Data[0]),
+	}, vector.Data[0], 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 || 1-refs[0].Cos < types.KNOWLEDGE_DUPLICATE_MIN_SCORE {
+		return nil, nil
+	}
+
+	knowledge, err := l.core.Store().KnowledgeStore().ListLiteKnowledges(l.ctx, types.GetKnowledgeOptions{
+		SpaceID: spaceID,
+		ID:      refs[0].KnowledgeID,
+	}, 1, 1)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if len(knowledge) == 0 {
+		return nil, nil
+	}
+	return &types.DuplicateKnowledge{
+		Knowledge: knowledge[0],
+		Reason:    types.KNOWLEDGE_DUPLICATE_REASON_SIMILAR,
+		Score:     1 - refs[0].Cos,
+	}, nil
+}
+
+// GetDuplicateReport 返回定时去重任务最近一次为空间生成的报告，互相重复的知识点合并为一组
+func (l *KnowledgeLogic) GetDuplicateReport(spaceID string) (*types.KnowledgeDuplicateReport, error) {
+	list, err := l.core.Store().KnowledgeDuplicateStore().List(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.GetDuplicateReport.KnowledgeDuplicateStore.List", i18n.ERROR_INTERNAL, err)
+	}
+
+	report := &types.KnowledgeDuplicateReport{
+		Groups: []types.KnowledgeDuplicateGroup{},
+	}
+	if len(list) == 0 {
+		return report, nil
+	}
+	report.CreatedAt = list[0].CreatedAt
+
+	pairs := lo.Map(list, func(item types.KnowledgeDuplicate, _ int) [2]string {
+		return [2]string{item.KnowledgeID, item.DuplicateID}
+	})
+	nodes, err := l.listLiteKnowledgeMap(spaceID, lo.Uniq(lo.Flatten(lo.Map(pairs, func(item [2]string, _ int) []string {
+		return item[:]
+	}))))
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.GetDuplicateReport", err)
+	}
+
+	groupOf := make(map[string]int)
+	for i, ids := range dedup.Group(pairs) {
+		group := types.KnowledgeDuplicateGroup{}
+		for _, id := range ids {
+			groupOf[id] = i
+			if node, exist := nodes[id]; exist {
+				group.Knowledges = append(group.Knowledges, node)
+			}
+		}
+		report.Groups = append(report.Groups, group)
+	}
+	for _, v := range list {
+		i := groupOf[v.KnowledgeID]
+		report.Groups[i].Pairs = append(report.Groups[i].Pairs, v)
+	}
+
+	// 知识点在报告生成后被删除时，组内可能只剩一个知识点
+	report.Groups = lo.Filter(report.Groups, func(item types.KnowledgeDuplicateGroup, _ int) bool {
+		return len(item.Knowledges) > 1
+	})
+	return report, nil
+}
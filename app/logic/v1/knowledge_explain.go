@@ -0,0 +1,42 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/breeew/brew-api/app/logic/v1/process"
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// ExplainQuery 执行与 Query 相同的检索步骤但不请求对话模型，返回各步骤的中间结果及将要发送给模型的提示词
+// 改写查询、embedding 及重排仍会请求模型并记录用量
+func (l *KnowledgeLogic) ExplainQuery(spaceID string, resource *types.ResourceQuery, filter *types.KnowledgeFilter, query string) (*types.RetrievalExplain, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, errors.New("KnowledgeLogic.ExplainQuery.Filter.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	userID := l.GetUserInfo().User
+	explain := &types.RetrievalExplain{
+		Query: query,
+	}
+	docs, usages, err := l.queryRelevanceKnowledges(spaceID, userID, query, resource, filter, explain)
+	for _, v := range usages {
+		process.NewRecordUsageRequest(v.Usage.Model, types.USAGE_TYPE_CHAT, v.Subject, spaceID, userID, v.Usage.Usage)
+	}
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.ExplainQuery", err)
+	}
+
+	fillExplainPrompt(explain, docs, buildRAGPrompt(l.core, docs))
+	return explain, nil
+}
+
+// fillExplainPrompt 记录最终提供给模型的参考内容及提示词
+func fillExplainPrompt(explain *types.RetrievalExplain, docs types.RAGDocs, prompt string) {
+	explain.Passages = docs.Docs
+	if explain.Passages == nil {
+		explain.Passages = []*types.PassageInfo{}
+	}
+	explain.Prompt = prompt
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+type explainLang struct{}
+
+func (explainLang) Lang() string { return ai.MODEL_BASE_LANGUAGE_EN }
+
+func Test_filterVectorRefs(t *testing.T) {
+	refs := []types.QueryResult{
+		{ID: "c0", KnowledgeID: "k0", Cos: 0.1, OriginalLength: 500},
+		{ID: "c1", KnowledgeID: "k1", Cos: 0.2, OriginalLength: 500},
+		{ID: "c2", KnowledgeID: "k2", Cos: 0.3, OriginalLength: 500},
+		{ID: "c3", KnowledgeID: "k3", Cos: 0.3, OriginalLength: 100},
+	}
+	for i := 0; i < 8; i++ {
+		refs = append(refs, types.QueryResult{ID: "far", KnowledgeID: "far", Cos: 0.9, OriginalLength: 100})
+	}
+
+	explain := &types.RetrievalExplain{}
+	res := filterVectorRefs(refs, explain)
+
+	// 命中超过 10 条时阈值为第一条的距离加 0.15
+	assert.InDelta(t, 0.25, explain.CosLimit, 1e-6)
+	assert.Len(t, explain.VectorHits, len(refs))
+	assert.Len(t, res, len(refs)-1)
+
+	// 距离超过阈值且原文较长的命中被丢弃，并记录原因
+	dropped := explain.VectorHits[2]
+	assert.Equal(t, "c2", dropped.ID)
+	assert.True(t, dropped.Dropped)
+	assert.Equal(t, "cos 0.3000 > cos_limit 0.2500 and original_length 500 > 150", dropped.DropReason)
+
+	// 原文较短的命中保留
+	assert.False(t, explain.VectorHits[3].Dropped)
+	assert.Empty(t, explain.VectorHits[3].DropReason)
+
+	// 命中较少时使用默认阈值，第一条命中始终保留
+	explain = &types.RetrievalExplain{}
+	res = filterVectorRefs([]types.QueryResult{{ID: "c0", Cos: 0.8, OriginalLength: 500}, {ID: "c1", Cos: 0.6, OriginalLength: 500}}, explain)
+	assert.InDelta(t, 0.5, explain.CosLimit, 1e-6)
+	assert.Equal(t, []types.QueryResult{{ID: "c0", Cos: 0.8, OriginalLength: 500}}, res)
+	assert.False(t, explain.VectorHits[0].Dropped)
+	assert.True(t, explain.VectorHits[1].Dropped)
+
+	// 不需要 explain 时结果一致
+	assert.Equal(t, res, filterVectorRefs([]types.QueryResult{{ID: "c0", Cos: 0.8, OriginalLength: 500}, {ID: "c1", Cos: 0.6, OriginalLength: 500}}, nil))
+}
+
+func Test_fillExplainPrompt(t *testing.T) {
+	prompts := core.Prompt{
+		Base:  "base prompt",
+		Query: "answer with:\n{relevant_passage}",
+	}
+
+	docs := types.RAGDocs{
+		Refs: []types.QueryResult{{ID: "c0", KnowledgeID: "k0"}},
+		Docs: []*types.PassageInfo{{ID: "k0", Content: "brew stores knowledge", DateTime: "2024-01-01", Resource: "knowledge"}},
+	}
+	explain := &types.RetrievalExplain{Query: "what does brew store"}
+	fillExplainPrompt(explain, docs, ragPrompt(prompts, docs, explainLang{}))
+	assert.Equal(t, docs.Docs, explain.Passages)
+	assert.Equal(t, "answer with:\nEvent Time：2024-01-01\nID：k0\nResource Kind：knowledge\nContent：brew stores knowledge\n", explain.Prompt)
+
+	// 没有参考内容时使用基础提示词，参考内容为空列表而不是 null
+	explain = &types.RetrievalExplain{Query: "hello"}
+	fillExplainPrompt(explain, types.RAGDocs{}, ragPrompt(prompts, types.RAGDocs{}, explainLang{}))
+	assert.Equal(t, "base prompt", explain.Prompt)
+	assert.NotNil(t, explain.Passages)
+	assert.Empty(t, explain.Passages)
+}
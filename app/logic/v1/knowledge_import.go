@@ -0,0 +1,296 @@
+package v1
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/app/logic/v1/process"
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/notes"
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+type ImportNotesResult struct {
+	Knowledges int `json:"knowledges"` // 创建的知识点数量
+	Resources  int `json:"resources"`  // 新创建的 resource 数量
+	Images     int `json:"images"`     // 上传的图片数量
+	Skipped    int `json:"skipped"`    // 正文为空被忽略的笔记数量
+}
+
+// ImportNotes 将 Obsidian vault、Notion 导出或 markdown 目录打包的 zip 导入为 markdown 知识点
+// front-matter 中的 tags 作为知识点的标签，笔记所在目录对应同名的 resource，
+// 笔记之间的链接替换为 knowledge:// 链接，引用的图片上传至文件存储
+// 导入的知识点由 KnowledgeProcess 限速处理，只生成切片，保留笔记的标题及已有标签
+func (l *KnowledgeLogic) ImportNotes(spaceID string, r io.ReaderAt, size int64) (*ImportNotesResult, error) {
+	vault, err := notes.Open(r, size)
+	if err != nil {
+		if stderrors.Is(err, notes.ErrTooLarge) {
+			return nil, errors.New("KnowledgeLogic.ImportNotes.Open", i18n.ERROR_MORE_TAHN_MAX, err).Code(http.StatusRequestEntityTooLarge)
+		}
+		return nil, errors.New("KnowledgeLogic.ImportNotes.Open", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	im := &notesImporter{
+		KnowledgeLogic: l,
+		spaceID:        spaceID,
+		userID:         l.GetUserInfo().User,
+		vault:          vault,
+		ids:            make(map[*notes.Note]string, len(vault.Notes)),
+		images:         make(map[*notes.Attachment]string),
+		resources:      make(map[string]string),
+		result:         &ImportNotesResult{},
+	}
+	for _, n := range vault.Notes {
+		im.ids[n] = utils.GenRandomID()
+	}
+
+	if err = im.prepareResources(); err != nil {
+		return nil, err
+	}
+
+	// 图片地址需要写入笔记内容，因此在事务之前上传，失败时删除已上传的图片
+	knowledges, err := im.buildKnowledges()
+	if err != nil {
+		im.deleteImages()
+		return nil, err
+	}
+
+	err = l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		for _, v := range im.newResources {
+			if err := l.core.Store().ResourceStore().Create(ctx, v); err != nil {
+				return errors.New("KnowledgeLogic.ImportNotes.ResourceStore.Create", i18n.ERROR_INTERNAL, err)
+			}
+		}
+		for _, v := range im.files {
+			if err := l.core.Store().FileManagementStore().Create(ctx, v); err != nil {
+				return errors.New("KnowledgeLogic.ImportNotes.FileManagementStore.Create", i18n.ERROR_INTERNAL, err)
+			}
+		}
+		for _, list := range lo.Chunk(knowledges, ARCHIVE_BATCH_SIZE) {
+			if err := l.core.Store().KnowledgeStore().BatchCreate(ctx, list); err != nil {
+				return errors.New("KnowledgeLogic.ImportNotes.KnowledgeStore.BatchCreate", i18n.ERROR_INTERNAL, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		im.deleteImages()
+		return nil, err
+	}
+
+	process.NewImportSummaryRequests(spaceID, lo.Map(knowledges, func(item *types.Knowledge, _ int) string {
+		return item.ID
+	}))
+
+	im.result.Knowledges = len(knowledges)
+	im.result.Resources = len(im.newResources)
+	im.result.Images = len(im.files)
+	return im.result, nil
+}
+
+type notesImporter struct {
+	*KnowledgeLogic
+	spaceID string
+	userID  string
+	vault   *notes.Vault
+
+	ids          map[*notes.Note]string       // 笔记 -> 知识点id
+	images       map[*notes.Attachment]string // 已上传的图片 -> 图片地址
+	resources    map[string]string            // 目录 -> resource id
+	newResources []types.Resource
+	files        []types.FileManagement
+	result       *ImportNotesResult
+}
+
+// prepareResources 目录优先使用标题相同的已有 resource，根目录下的笔记归属默认 resource
+func (im *notesImporter) prepareResources() error {
+	existing, err := im.core.Store().ResourceStore().ListResources(im.ctx, im.spaceID, types.NO_PAGING, types.NO_PAGING)
+	if err != nil {
+		return errors.New("KnowledgeLogic.ImportNotes.ResourceStore.ListResources", i18n.ERROR_INTERNAL, err)
+	}
+	titles := make(map[string]string, len(existing))
+	ids := make(map[string]bool, len(existing))
+	for _, v := range existing {
+		ids[v.ID] = true
+		if _, exist := titles[v.Title]; !exist {
+			titles[v.Title] = v.ID
+		}
+	}
+
+	now := time.Now().Unix()
+	for _, n := range im.vault.Notes {
+		if n.Folder == "" {
+			continue
+		}
+		title := folderResourceTitle(n.Folder)
+		if _, exist := im.resources[n.Folder]; exist {
+			continue
+		}
+		if id, exist := titles[title]; exist {
+			im.resources[n.Folder] = id
+			continue
+		}
+
+		id := folderResourceID(n.Folder)
+		im.resources[n.Folder] = id
+		titles[title] = id
+		if ids[id] {
+			continue
+		}
+		im.newResources = append(im.newResources, types.Resource{
+			ID:          id,
+			Title:       title,
+			UserID:      im.userID,
+			SpaceID:     im.spaceID,
+			Description: fmt.Sprintf("Imported from %s", n.Folder),
+			CreatedAt:   now,
+		})
+	}
+	return nil
+}
+
+// folderResourceID 由目录路径生成 resource id，resource id 只能包含字母，重复导入同一目录时得到相同的 id
+func folderResourceID(folder string) string {
+	hash := utils.MD5(folder)[:16]
+	id := make([]byte, 0, len(hash))
+	for _, c := range hash {
+		if c >= '0' && c <= '9' {
+			id = append(id, byte('g'+c-'0'))
+		} else {
+			id = append(id, byte(c))
+		}
+	}
+	return "folder" + string(id)
+}
+
+// folderResourceTitle resource 标题最长 255 个字符，过长的目录保留末尾部分
+func folderResourceTitle(folder string) string {
+	runes := []rune(folder)
+	if len(runes) <= 255 {
+		return folder
+	}
+	return string(runes[len(runes)-255:])
+}
+
+func (im *notesImporter) buildKnowledges() ([]*types.Knowledge, error) {
+	var (
+		uploadErr error
+		now       = time.Now()
+		list      = make([]*types.Knowledge, 0, len(im.vault.Notes))
+	)
+	rw := notes.Rewriter{
+		Link: func(target *notes.Note) string {
+			return types.KnowledgeLinkURL(im.ids[target])
+		},
+		Image: func(a *notes.Attachment) string {
+			u, err := im.uploadImage(a)
+			if err != nil && uploadErr == nil {
+				uploadErr = err
+			}
+			return u
+		},
+	}
+
+	for _, n := range im.vault.Notes {
+		content := im.vault.Rewrite(n, rw)
+		if uploadErr != nil {
+			return nil, uploadErr
+		}
+		if strings.TrimSpace(content) == "" {
+			im.result.Skipped++
+			continue
+		}
+
+		encryptContent, err := im.core.EncryptData([]byte(content))
+		if err != nil {
+			return nil, errors.New("KnowledgeLogic.ImportNotes.EncryptData", i18n.ERROR_INTERNAL, err)
+		}
+
+		resource := types.DEFAULT_RESOURCE
+		if n.Folder != "" {
+			resource = im.resources[n.Folder]
+		}
+
+		// 笔记已有标题，没有标签时由 AI 生成
+		summary := []string{"content"}
+		if len(n.Tags) == 0 {
+			summary = append(summary, "tags")
+		}
+
+		list = append(list, &types.Knowledge{
+			ID:          im.ids[n],
+			SpaceID:     im.spaceID,
+			UserID:      im.userID,
+			Kind:        types.KNOWLEDGE_KIND_TEXT,
+			Resource:    resource,
+			Title:       n.Title,
+			Tags:        pq.StringArray(lo.Uniq(n.Tags)),
+			Content:     encryptContent,
+			ContentType: types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN,
+			Source:      n.Path,
+			Summary:     strings.Join(summary, ","),
+			Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+			MaybeDate:   now.Local().Format("2006-01-02 15:04"),
+			CreatedAt:   now.Unix(),
+			UpdatedAt:   now.Unix(),
+		})
+	}
+	return list, nil
+}
+
+// deleteImages 导入失败时删除已上传的图片，避免文件存储中残留无人引用的文件
+func (im *notesImporter) deleteImages() {
+	for _, v := range im.files {
+		if err := im.core.FileStorage().DeleteFile(v.File); err != nil {
+			slog.Error("Failed to delete imported note image", slog.String("space_id", im.spaceID), slog.String("file", v.File), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// uploadImage 上传笔记引用的图片，同一图片只上传一次
+func (im *notesImporter) uploadImage(a *notes.Attachment) (string, error) {
+	if u, exist := im.images[a]; exist {
+		return u, nil
+	}
+
+	data, err := a.Read()
+	if err != nil {
+		slog.Warn("Failed to read note attachment", slog.String("space_id", im.spaceID), slog.String("file", a.Path), slog.String("error", err.Error()))
+		im.images[a] = ""
+		return "", nil
+	}
+
+	filePath := genUserFilePath(im.spaceID, "knowledge")
+	fileName := utils.GenRandomID() + strings.ToLower(path.Ext(a.Path))
+	if err = im.core.FileStorage().SaveFile(filePath, fileName, data); err != nil {
+		return "", errors.New("KnowledgeLogic.ImportNotes.FileStorage.SaveFile", i18n.ERROR_INTERNAL, err)
+	}
+
+	fullPath := path.Join(filePath, fileName)
+	im.files = append(im.files, types.FileManagement{
+		SpaceID:    im.spaceID,
+		UserID:     im.userID,
+		File:       fullPath,
+		FileSize:   int64(len(data)),
+		ObjectType: "knowledge",
+		Kind:       "image",
+		Status:     types.FILE_UPLOAD_STATUS_UPLOADED,
+		CreatedAt:  time.Now().Unix(),
+	})
+
+	u := strings.TrimSuffix(im.core.FileStorage().GetStaticDomain(), "/") + fullPath
+	im.images[a] = u
+	return u, nil
+}
@@ -0,0 +1,237 @@
+package v1
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// MAX_RAG_LINK_EXPANSION 检索时通过链接扩展的知识点数量上限
+const MAX_RAG_LINK_EXPANSION = 10
+
+// ListBacklinks 返回链接到该知识点的其他知识点
+func (l *KnowledgeLogic) ListBacklinks(spaceID, id string) ([]types.KnowledgeBacklink, error) {
+	list, err := l.core.Store().KnowledgeLinkStore().ListLinks(l.ctx, types.GetKnowledgeLinksOptions{
+		SpaceID: spaceID,
+		ToIDs:   []string{id},
+	}, 1, types.MAX_KNOWLEDGE_GRAPH_EDGES)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.ListBacklinks.KnowledgeLinkStore.ListLinks", i18n.ERROR_INTERNAL, err)
+	}
+	if err = decryptLinkText(l.core, list); err != nil {
+		return nil, errors.New("KnowledgeLogic.ListBacklinks.DecryptData", i18n.ERROR_INTERNAL, err)
+	}
+
+	nodes, err := l.listLiteKnowledgeMap(spaceID, lo.Map(list, func(item types.KnowledgeLink, _ int) string {
+		return item.FromID
+	}))
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.ListBacklinks", err)
+	}
+
+	res := make([]types.KnowledgeBacklink, 0, len(list))
+	for _, v := range list {
+		if node, exist := nodes[v.FromID]; exist {
+			res = append(res, types.KnowledgeBacklink{
+				Knowledge: node,
+				Text:      v.Text,
+			})
+		}
+	}
+	return res, nil
+}
+
+// GetGraph 返回知识点之间的链接关系，id 为空时返回整个空间中存在链接的知识点，
+// 否则返回以 id 为中心、depth 层以内的知识点，边数最多为 MAX_KNOWLEDGE_GRAPH_EDGES
+func (l *KnowledgeLogic) GetGraph(spaceID, id string, depth int) (*types.KnowledgeGraph, error) {
+	var (
+		graph = &types.KnowledgeGraph{}
+		edges []types.KnowledgeLink
+		err   error
+	)
+	if id == "" {
+		edges, err = l.core.Store().KnowledgeLinkStore().ListLinks(l.ctx, types.GetKnowledgeLinksOptions{
+			SpaceID: spaceID,
+		}, 1, types.MAX_KNOWLEDGE_GRAPH_EDGES+1)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("KnowledgeLogic.GetGraph.KnowledgeLinkStore.ListLinks", i18n.ERROR_INTERNAL, err)
+		}
+	} else {
+		if depth <= 0 {
+			depth = 1
+		}
+		if depth > types.MAX_KNOWLEDGE_GRAPH_DEPTH {
+			return nil, errors.New("KnowledgeLogic.GetGraph.Depth", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+		}
+
+		center, err := l.core.Store().KnowledgeStore().GetKnowledge(l.ctx, spaceID, id)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("KnowledgeLogic.GetGraph.KnowledgeStore.GetKnowledge", i18n.ERROR_INTERNAL, err)
+		}
+		if center == nil {
+			return nil, errors.New("KnowledgeLogic.GetGraph.KnowledgeStore.GetKnowledge.nil", i18n.ERROR_NOT_FOUND, nil).Code(http.StatusNotFound)
+		}
+
+		if edges, err = l.neighbourhoodLinks(spaceID, id, depth); err != nil {
+			return nil, errors.Trace("KnowledgeLogic.GetGraph", err)
+		}
+	}
+
+	if len(edges) > types.MAX_KNOWLEDGE_GRAPH_EDGES {
+		edges = edges[:types.MAX_KNOWLEDGE_GRAPH_EDGES]
+		graph.Truncated = true
+	}
+
+	ids := []string{}
+	if id != "" {
+		ids = append(ids, id)
+	}
+	for _, v := range edges {
+		ids = append(ids, v.FromID, v.ToID)
+	}
+	nodes, err := l.listLiteKnowledgeMap(spaceID, lo.Uniq(ids))
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.GetGraph", err)
+	}
+
+	graph.Nodes = lo.FilterMap(lo.Uniq(ids), func(item string, _ int) (*types.KnowledgeLite, bool) {
+		node, exist := nodes[item]
+		return node, exist
+	})
+	graph.Edges = lo.Filter(edges, func(item types.KnowledgeLink, _ int) bool {
+		return nodes[item.FromID] != nil && nodes[item.ToID] != nil
+	})
+	if err = decryptLinkText(l.core, graph.Edges); err != nil {
+		return nil, errors.New("KnowledgeLogic.GetGraph.DecryptData", i18n.ERROR_INTERNAL, err)
+	}
+	return graph, nil
+}
+
+// decryptLinkText 链接文字来自知识点内容，与内容一样加密保存
+func decryptLinkText(core *core.Core, list []types.KnowledgeLink) error {
+	for i, v := range list {
+		if v.Text == "" {
+			continue
+		}
+		text, err := core.DecryptData([]byte(v.Text))
+		if err != nil {
+			return err
+		}
+		list[i].Text = string(text)
+	}
+	return nil
+}
+
+// neighbourhoodLinks 按层获取与 id 相连的链接，不区分方向，结果多于 MAX_KNOWLEDGE_GRAPH_EDGES 时停止
+func (l *KnowledgeLogic) neighbourhoodLinks(spaceID, id string, depth int) ([]types.KnowledgeLink, error) {
+	var (
+		edges    []types.KnowledgeLink
+		visited  = map[string]bool{id: true}
+		seen     = make(map[string]bool)
+		frontier = []string{id}
+	)
+	for i := 0; i < depth && len(frontier) > 0; i++ {
+		list, err := l.core.Store().KnowledgeLinkStore().ListLinks(l.ctx, types.GetKnowledgeLinksOptions{
+			SpaceID: spaceID,
+			FromIDs: frontier,
+			ToIDs:   frontier,
+		}, 1, uint64(types.MAX_KNOWLEDGE_GRAPH_EDGES-len(edges)+1))
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("KnowledgeLogic.GetGraph.KnowledgeLinkStore.ListLinks", i18n.ERROR_INTERNAL, err)
+		}
+
+		frontier = nil
+		for _, v := range list {
+			key := v.FromID + ":" + v.ToID
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			edges = append(edges, v)
+
+			for _, node := range []string{v.FromID, v.ToID} {
+				if !visited[node] {
+					visited[node] = true
+					frontier = append(frontier, node)
+				}
+			}
+		}
+		if len(edges) > types.MAX_KNOWLEDGE_GRAPH_EDGES {
+			break
+		}
+	}
+	return edges, nil
+}
+
+func (l *KnowledgeLogic) listLiteKnowledgeMap(spaceID string, ids []string) (map[string]*types.KnowledgeLite, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	list, err := l.core.Store().KnowledgeStore().ListLiteKnowledges(l.ctx, types.GetKnowledgeOptions{
+		SpaceID: spaceID,
+		IDs:     ids,
+	}, types.NO_PAGING, types.NO_PAGING)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.KnowledgeStore.ListLiteKnowledges", i18n.ERROR_INTERNAL, err)
+	}
+	return lo.SliceToMap(list, func(item *types.KnowledgeLite) (string, *types.KnowledgeLite) {
+		return item.ID, item
+	}), nil
+}
+
+// expandLinkedKnowledges 返回与前 n 个命中的知识点直接相连(一跳)的其他知识点，最多 MAX_RAG_LINK_EXPANSION 个
+func (l *KnowledgeLogic) expandLinkedKnowledges(spaceID string, hits []string, n int) ([]string, error) {
+	top := hits[:min(n, len(hits))]
+	if len(top) == 0 {
+		return nil, nil
+	}
+
+	list, err := l.core.Store().KnowledgeLinkStore().ListLinks(l.ctx, types.GetKnowledgeLinksOptions{
+		SpaceID: spaceID,
+		FromIDs: top,
+		ToIDs:   top,
+	}, 1, types.MAX_KNOWLEDGE_GRAPH_EDGES)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// 优先扩展排名靠前的知识点的链接
+	rank := make(map[string]int, len(hits))
+	for i, v := range hits {
+		rank[v] = i
+	}
+	linked := make(map[string]int)
+	for _, v := range list {
+		for _, pair := range [][2]string{{v.FromID, v.ToID}, {v.ToID, v.FromID}} {
+			hit, ok := rank[pair[0]]
+			if !ok || hit >= len(top) {
+				continue
+			}
+			if _, exist := rank[pair[1]]; exist {
+				continue
+			}
+			if r, exist := linked[pair[1]]; !exist || hit < r {
+				linked[pair[1]] = hit
+			}
+		}
+	}
+
+	res := lo.Keys(linked)
+	sort.Slice(res, func(i, j int) bool {
+		if linked[res[i]] != linked[res[j]] {
+			return linked[res[i]] < linked[res[j]]
+		}
+		return res[i] < res[j]
+	})
+	if len(res) > MAX_RAG_LINK_EXPANSION {
+		res = res[:MAX_RAG_LINK_EXPANSION]
+	}
+	return res, nil
+}
@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"database/sql"
+
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// ListRelated 返回与知识点语义相近的知识点，在知识点生成向量后由 KnowledgeProcess 计算
+func (l *KnowledgeLogic) ListRelated(spaceID, id string) ([]types.RelatedKnowledge, error) {
+	list, err := l.core.Store().KnowledgeRelatedStore().List(l.ctx, spaceID, id, types.RELATED_KNOWLEDGE_LIMIT)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.ListRelated.KnowledgeRelatedStore.List", i18n.ERROR_INTERNAL, err)
+	}
+
+	nodes, err := l.listLiteKnowledgeMap(spaceID, lo.Map(list, func(item types.KnowledgeRelated, _ int) string {
+		return item.RelatedID
+	}))
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.ListRelated", err)
+	}
+
+	res := make([]types.RelatedKnowledge, 0, len(list))
+	for _, v := range list {
+		if node, exist := nodes[v.RelatedID]; exist {
+			res = append(res, types.RelatedKnowledge{
+				Knowledge: node,
+				Score:     v.Score,
+			})
+		}
+	}
+	return res, nil
+}
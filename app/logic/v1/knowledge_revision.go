@@ -0,0 +1,205 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+// ListRevisions 按时间倒序返回知识点的历史版本，不包含内容
+func (l *KnowledgeLogic) ListRevisions(spaceID, knowledgeID string, page, pageSize uint64) ([]types.KnowledgeRevision, uint64, error) {
+	list, err := l.core.Store().KnowledgeRevisionStore().List(l.ctx, spaceID, knowledgeID, page, pageSize)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, errors.New("KnowledgeLogic.ListRevisions.KnowledgeRevisionStore.List", i18n.ERROR_INTERNAL, err)
+	}
+
+	total, err := l.core.Store().KnowledgeRevisionStore().Total(l.ctx, spaceID, knowledgeID)
+	if err != nil {
+		return nil, 0, errors.New("KnowledgeLogic.ListRevisions.KnowledgeRevisionStore.Total", i18n.ERROR_INTERNAL, err)
+	}
+	return list, total, nil
+}
+
+func (l *KnowledgeLogic) getRevision(spaceID, knowledgeID, id string) (*types.KnowledgeRevision, error) {
+	revision, err := l.core.Store().KnowledgeRevisionStore().Get(l.ctx, spaceID, knowledgeID, id)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.getRevision.KnowledgeRevisionStore.Get", i18n.ERROR_INTERNAL, err)
+	}
+	if revision == nil {
+		return nil, errors.New("KnowledgeLogic.getRevision.KnowledgeRevisionStore.Get.nil", i18n.ERROR_NOT_FOUND, nil).Code(http.StatusNotFound)
+	}
+	return revision, nil
+}
+
+// revisionMarkdown 返回版本内容的 markdown 形式，用于比较差异
+func (l *KnowledgeLogic) revisionMarkdown(content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, error) {
+	content, err := l.core.DecryptData(content)
+	if err != nil {
+		return "", err
+	}
+	if contentType == types.KNOWLEDGE_CONTENT_TYPE_BLOCKS {
+		return utils.ConvertEditorJSBlocksToMarkdown(json.RawMessage(content))
+	}
+	return content.String(), nil
+}
+
+// DiffRevisions 比较两个版本的内容，to 为空时与知识点当前的内容比较
+func (l *KnowledgeLogic) DiffRevisions(spaceID, knowledgeID, from, to string) (*types.KnowledgeRevisionDiff, error) {
+	fromRevision, err := l.getRevision(spaceID, knowledgeID, from)
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.DiffRevisions", err)
+	}
+
+	var toRevision *types.KnowledgeRevision
+	if to != "" {
+		if toRevision, err = l.getRevision(spaceID, knowledgeID, to); err != nil {
+			return nil, errors.Trace("KnowledgeLogic.DiffRevisions", err)
+		}
+	} else {
+		knowledge, err := l.core.Store().KnowledgeStore().GetKnowledge(l.ctx, spaceID, knowledgeID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("KnowledgeLogic.DiffRevisions.KnowledgeStore.GetKnowledge", i18n.ERROR_INTERNAL, err)
+		}
+		if knowledge == nil {
+			return nil, errors.New("KnowledgeLogic.DiffRevisions.KnowledgeStore.GetKnowledge.nil", i18n.ERROR_NOT_FOUND, nil).Code(http.StatusNotFound)
+		}
+		toRevision = &types.KnowledgeRevision{
+			KnowledgeID: knowledge.ID,
+			SpaceID:     knowledge.SpaceID,
+			UserID:      knowledge.UserID,
+			Title:       knowledge.Title,
+			Content:     knowledge.Content,
+			ContentType: knowledge.ContentType,
+			CreatedAt:   knowledge.UpdatedAt,
+		}
+	}
+
+	fromContent, err := l.revisionMarkdown(fromRevision.Content, fromRevision.ContentType)
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.DiffRevisions.revisionMarkdown.from", i18n.ERROR_INTERNAL, err)
+	}
+	toContent, err := l.revisionMarkdown(toRevision.Content, toRevision.ContentType)
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.DiffRevisions.revisionMarkdown.to", i18n.ERROR_INTERNAL, err)
+	}
+
+	revisionName := func(r *types.KnowledgeRevision) string {
+		id := r.ID
+		if id == "" {
+			id = "current"
+		}
+		return fmt.Sprintf("%s\t%s", id, time.Unix(r.CreatedAt, 0).Local().Format("2006-01-02 15:04:05"))
+	}
+	diff, err := utils.MarkdownDiff(revisionName(fromRevision), revisionName(toRevision), fromContent, toContent)
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.DiffRevisions.MarkdownDiff", i18n.ERROR_INTERNAL, err)
+	}
+
+	fromRevision.Content = nil
+	toRevision.Content = nil
+	return &types.KnowledgeRevisionDiff{
+		From: *fromRevision,
+		To:   *toRevision,
+		Diff: diff,
+	}, nil
+}
+
+// RestoreRevision 将知识点恢复到指定的历史版本，并生成一个新的版本，恢复后重新切片、向量化
+func (l *KnowledgeLogic) RestoreRevision(spaceID, knowledgeID, id string) error {
+	knowledge, err := l.core.Store().KnowledgeStore().GetKnowledge(l.ctx, spaceID, knowledgeID)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("KnowledgeLogic.RestoreRevision.KnowledgeStore.GetKnowledge", i18n.ERROR_INTERNAL, err)
+	}
+	if knowledge == nil || knowledge.UserID != l.GetUserInfo().User {
+		return errors.New("KnowledgeLogic.RestoreRevision.KnowledgeStore.GetKnowledge", i18n.ERROR_NOT_FOUND, err).Code(http.StatusNotFound)
+	}
+
+	revision, err := l.getRevision(spaceID, knowledgeID, id)
+	if err != nil {
+		return errors.Trace("KnowledgeLogic.RestoreRevision", err)
+	}
+
+	err = l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		if err := l.createBaseRevision(ctx, knowledge); err != nil {
+			return errors.Trace("KnowledgeLogic.RestoreRevision", err)
+		}
+
+		summary := "content"
+		if revision.Title == "" {
+			summary += ",title"
+		}
+		err := l.core.Store().KnowledgeStore().Update(ctx, spaceID, knowledgeID, types.UpdateKnowledgeArgs{
+			Title:       revision.Title,
+			Content:     revision.Content,
+			ContentType: revision.ContentType,
+			Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+			Summary:     summary,
+		})
+		if err != nil {
+			return errors.New("KnowledgeLogic.RestoreRevision.KnowledgeStore.Update", i18n.ERROR_INTERNAL, err)
+		}
+
+		err = l.core.Store().KnowledgeRevisionStore().Create(ctx, types.KnowledgeRevision{
+			ID:          utils.GenRandomID(),
+			KnowledgeID: knowledgeID,
+			SpaceID:     spaceID,
+			UserID:      l.GetUserInfo().User,
+			Origin:      types.KNOWLEDGE_REVISION_ORIGIN_RESTORE,
+			Title:       revision.Title,
+			Content:     revision.Content,
+			ContentType: revision.ContentType,
+			ContentHash: revision.ContentHash,
+		})
+		if err != nil {
+			return errors.New("KnowledgeLogic.RestoreRevision.KnowledgeRevisionStore.Create", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	l.reprocessKnowledgeAsync(spaceID, knowledgeID)
+	return nil
+}
+
+// createBaseRevision 知识点首次修改前没有历史版本，将修改前的内容保存为第一个版本，knowledge.Content 为密文
+func (l *KnowledgeLogic) createBaseRevision(ctx context.Context, knowledge *types.Knowledge) error {
+	total, err := l.core.Store().KnowledgeRevisionStore().Total(ctx, knowledge.SpaceID, knowledge.ID)
+	if err != nil {
+		return errors.New("KnowledgeLogic.createBaseRevision.KnowledgeRevisionStore.Total", i18n.ERROR_INTERNAL, err)
+	}
+	if total > 0 {
+		return nil
+	}
+
+	content, err := l.core.DecryptData(knowledge.Content)
+	if err != nil {
+		return errors.New("KnowledgeLogic.createBaseRevision.DecryptData", i18n.ERROR_INTERNAL, err)
+	}
+
+	err = l.core.Store().KnowledgeRevisionStore().Create(ctx, types.KnowledgeRevision{
+		ID:          utils.GenRandomID(),
+		KnowledgeID: knowledge.ID,
+		SpaceID:     knowledge.SpaceID,
+		UserID:      knowledge.UserID,
+		Origin:      types.KNOWLEDGE_REVISION_ORIGIN_CREATE,
+		Title:       knowledge.Title,
+		Content:     knowledge.Content,
+		ContentType: knowledge.ContentType,
+		ContentHash: l.core.HashContent(string(content)),
+		CreatedAt:   knowledge.UpdatedAt,
+	})
+	if err != nil {
+		return errors.New("KnowledgeLogic.createBaseRevision.KnowledgeRevisionStore.Create", i18n.ERROR_INTERNAL, err)
+	}
+	return nil
+}
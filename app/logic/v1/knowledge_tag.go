@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// ListTags 返回空间中的标签及使用次数
+func (l *KnowledgeLogic) ListTags(spaceID string) ([]types.KnowledgeTag, error) {
+	list, err := l.core.Store().KnowledgeStore().ListTags(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.ListTags.KnowledgeStore.ListTags", i18n.ERROR_INTERNAL, err)
+	}
+	if list == nil {
+		list = []types.KnowledgeTag{}
+	}
+	return list, nil
+}
+
+func verifyTag(tag string) (string, bool) {
+	tag = strings.TrimSpace(tag)
+	return tag, tag != "" && utf8.RuneCountInString(tag) <= types.MAX_TAG_LENGTH
+}
+
+// RenameTag 重命名空间中的标签，新标签已存在时等同于合并，返回修改的知识点数量
+func (l *KnowledgeLogic) RenameTag(spaceID, tag, newTag string) (int64, error) {
+	return l.MergeTags(spaceID, []string{tag}, newTag)
+}
+
+// MergeTags 将多个标签合并为 target，target 可以是其中一个标签或新的标签，返回修改的知识点数量
+func (l *KnowledgeLogic) MergeTags(spaceID string, tags []string, target string) (int64, error) {
+	target, ok := verifyTag(target)
+	if !ok {
+		return 0, errors.New("KnowledgeLogic.MergeTags.Target", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+	from := lo.Without(lo.Uniq(lo.Map(tags, func(item string, _ int) string {
+		return strings.TrimSpace(item)
+	})), "", target)
+	if len(from) == 0 {
+		return 0, errors.New("KnowledgeLogic.MergeTags.Tags", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	total, err := l.core.Store().KnowledgeStore().RenameTags(l.ctx, spaceID, from, target)
+	if err != nil {
+		return 0, errors.New("KnowledgeLogic.MergeTags.KnowledgeStore.RenameTags", i18n.ERROR_INTERNAL, err)
+	}
+	return total, nil
+}
+
+// DeleteTag 从空间的所有知识点中移除标签，返回修改的知识点数量
+func (l *KnowledgeLogic) DeleteTag(spaceID, tag string) (int64, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return 0, errors.New("KnowledgeLogic.DeleteTag.Tag", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	total, err := l.core.Store().KnowledgeStore().DeleteTags(l.ctx, spaceID, []string{tag})
+	if err != nil {
+		return 0, errors.New("KnowledgeLogic.DeleteTag.KnowledgeStore.DeleteTags", i18n.ERROR_INTERNAL, err)
+	}
+	return total, nil
+}
+
+// ListTagMergeProposals 返回定时任务生成的标签合并建议，生成之后已被重命名或删除的标签不再出现在建议中
+func (l *KnowledgeLogic) ListTagMergeProposals(spaceID string) ([]types.KnowledgeTagMerge, error) {
+	list, err := l.core.Store().KnowledgeTagMergeStore().List(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.ListTagMergeProposals.KnowledgeTagMergeStore.List", i18n.ERROR_INTERNAL, err)
+	}
+	if len(list) == 0 {
+		return []types.KnowledgeTagMerge{}, nil
+	}
+
+	tags, err := l.ListTags(spaceID)
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.ListTagMergeProposals", err)
+	}
+	exist := lo.SliceToMap(tags, func(item types.KnowledgeTag) (string, bool) {
+		return item.Tag, true
+	})
+
+	res := make([]types.KnowledgeTagMerge, 0, len(list))
+	for _, v := range list {
+		v.Tags = lo.Filter(v.Tags, func(item string, _ int) bool {
+			return exist[item]
+		})
+		if len(v.Tags) < 2 {
+			continue
+		}
+		if !exist[v.Target] {
+			v.Target = v.Tags[0]
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
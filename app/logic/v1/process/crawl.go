@@ -0,0 +1,167 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/safe"
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+const (
+	// CRAWL_BATCH_SIZE 每次定时任务最多抓取的网页数量
+	CRAWL_BATCH_SIZE = 20
+	// CRAWL_RETRY_BACKOFF 抓取失败后的首次重试间隔(秒)，之后每次失败翻倍，最长不超过抓取间隔
+	CRAWL_RETRY_BACKOFF = 10 * 60
+)
+
+func init() {
+	register.RegisterFunc(ProcessKey{}, func(provider *Process) {
+		provider.Cron().AddFunc("*/10 * * * *", func() {
+			NewCrawlProcess(provider.Core()).Flush(context.Background())
+		})
+	})
+}
+
+// CrawlProcess 定期重新抓取 url 类知识点，内容变化时保存历史版本并重新切片、向量化
+type CrawlProcess struct {
+	core *core.Core
+}
+
+func NewCrawlProcess(core *core.Core) *CrawlProcess {
+	return &CrawlProcess{core: core}
+}
+
+func (p *CrawlProcess) Flush(ctx context.Context) {
+	list, err := p.core.Store().KnowledgeCrawlStore().ListDue(ctx, time.Now().Unix(), CRAWL_BATCH_SIZE)
+	if err != nil {
+		slog.Error("Failed to list due knowledge crawls", slog.String("error", err.Error()))
+		return
+	}
+
+	for _, item := range list {
+		safe.Run(func() {
+			p.crawl(ctx, item)
+		})
+	}
+}
+
+// crawlRetryAt 根据连续失败次数计算下一次重试的时间
+func crawlRetryAt(now, interval int64, failedTimes int) int64 {
+	backoff := int64(CRAWL_RETRY_BACKOFF)
+	for i := 0; i < failedTimes && backoff < interval; i++ {
+		backoff *= 2
+	}
+	return now + min(backoff, interval)
+}
+
+func (p *CrawlProcess) crawl(ctx context.Context, item types.KnowledgeCrawl) {
+	logAttrs := []any{
+		slog.String("space_id", item.SpaceID),
+		slog.String("knowledge_id", item.KnowledgeID),
+		slog.String("url", item.URL),
+	}
+
+	// 与知识点的处理使用同一把锁，避免抓取到的新内容与正在进行的切片互相覆盖
+	lockCtx, unlock := context.WithCancel(ctx)
+	defer unlock()
+	ok, err := p.core.TryLock(lockCtx, fmt.Sprintf("knowledge:process:%s", item.KnowledgeID))
+	if err != nil {
+		slog.Error("Failed to lock knowledge crawl", append(logAttrs, slog.String("error", err.Error()))...)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	knowledge, err := p.core.Store().KnowledgeStore().GetKnowledge(ctx, item.SpaceID, item.KnowledgeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// 知识点已被删除
+			if err = p.core.Store().KnowledgeCrawlStore().Delete(ctx, item.SpaceID, item.KnowledgeID); err != nil {
+				slog.Error("Failed to delete orphan knowledge crawl", append(logAttrs, slog.String("error", err.Error()))...)
+			}
+			return
+		}
+		slog.Error("Failed to get crawl knowledge", append(logAttrs, slog.String("error", err.Error()))...)
+		return
+	}
+	if knowledge.Stage != types.KNOWLEDGE_STAGE_DONE {
+		// 上一次的内容还未处理完成，等待下一次调度
+		return
+	}
+
+	now := time.Now().Unix()
+	readerCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	res, err := p.core.Srv().AI().Reader(readerCtx, item.URL)
+	if err == nil && strings.TrimSpace(res.Content) == "" {
+		err = fmt.Errorf("empty content")
+	}
+	if err != nil {
+		slog.Warn("Failed to crawl knowledge url", append(logAttrs, slog.Int("failed_times", item.FailedTimes+1), slog.String("error", err.Error()))...)
+		if err = p.core.Store().KnowledgeCrawlStore().Failed(ctx, item.SpaceID, item.KnowledgeID, err.Error(), crawlRetryAt(now, item.Interval, item.FailedTimes)); err != nil {
+			slog.Error("Failed to record knowledge crawl failure", append(logAttrs, slog.String("error", err.Error()))...)
+		}
+		return
+	}
+
+	content := strings.TrimSpace(res.Content)
+	hash := p.core.HashContent(content)
+	// 早期版本保存的是未加密钥的 sha256，内容未变化时直接替换为新的哈希
+	if hash == item.ContentHash || utils.SHA256(content) == item.ContentHash {
+		if err = p.core.Store().KnowledgeCrawlStore().Crawled(ctx, item.SpaceID, item.KnowledgeID, hash, now, now+item.Interval); err != nil {
+			slog.Error("Failed to update knowledge crawl", append(logAttrs, slog.String("error", err.Error()))...)
+		}
+		return
+	}
+
+	encryptContent, err := p.core.EncryptData(types.KnowledgeContent(content))
+	if err != nil {
+		slog.Error("Failed to encrypt crawled content", append(logAttrs, slog.String("error", err.Error()))...)
+		return
+	}
+
+	err = p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		err := p.core.Store().KnowledgeRevisionStore().Create(ctx, types.KnowledgeRevision{
+			ID:          utils.GenRandomID(),
+			KnowledgeID: knowledge.ID,
+			SpaceID:     knowledge.SpaceID,
+			UserID:      knowledge.UserID,
+			Origin:      types.KNOWLEDGE_REVISION_ORIGIN_CRAWL,
+			Title:       knowledge.Title,
+			Content:     encryptContent,
+			ContentType: types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN,
+			ContentHash: hash,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create knowledge revision: %w", err)
+		}
+
+		// 交给 KnowledgeProcess.Flush 重新切片、向量化
+		err = p.core.Store().KnowledgeStore().Update(ctx, knowledge.SpaceID, knowledge.ID, types.UpdateKnowledgeArgs{
+			Content:     encryptContent,
+			ContentType: types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN,
+			Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+			Summary:     "content",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update knowledge content: %w", err)
+		}
+
+		return p.core.Store().KnowledgeCrawlStore().Crawled(ctx, item.SpaceID, item.KnowledgeID, hash, now, now+item.Interval)
+	})
+	if err != nil {
+		slog.Error("Failed to save crawled content", append(logAttrs, slog.String("error", err.Error()))...)
+		return
+	}
+	slog.Info("Knowledge url content changed", logAttrs...)
+}
@@ -0,0 +1,36 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_crawlRetryAt(t *testing.T) {
+	const (
+		now  = int64(1000)
+		hour = int64(3600)
+		day  = 24 * hour
+	)
+	cases := []struct {
+		name        string
+		interval    int64
+		failedTimes int
+		want        int64
+	}{
+		{"first failure", day, 0, CRAWL_RETRY_BACKOFF},
+		{"second failure", day, 1, 2 * CRAWL_RETRY_BACKOFF},
+		{"third failure", day, 2, 4 * CRAWL_RETRY_BACKOFF},
+		{"fourth failure", day, 3, 8 * CRAWL_RETRY_BACKOFF},
+		{"capped by interval", day, 10, day},
+		{"many failures do not overflow", day, 1000, day},
+		{"interval shorter than backoff", 60, 0, 60},
+		{"below interval", hour, 2, 4 * CRAWL_RETRY_BACKOFF},
+		{"next step exceeds interval", hour, 3, hour},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, now+c.want, crawlRetryAt(now, c.interval, c.failedTimes))
+		})
+	}
+}
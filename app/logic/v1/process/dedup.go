@@ -0,0 +1,155 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/pkg/dedup"
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+const (
+	// DEDUP_SPACE_BATCH_SIZE 去重任务每次读取的空间数量
+	DEDUP_SPACE_BATCH_SIZE = 100
+	// DEDUP_KNOWLEDGE_BATCH_SIZE 补齐内容哈希时每次读取的知识点数量
+	DEDUP_KNOWLEDGE_BATCH_SIZE = 100
+)
+
+func init() {
+	register.RegisterFunc(ProcessKey{}, func(provider *Process) {
+		provider.Cron().AddFunc("0 5 * * *", func() {
+			err := NewDedupProcess(provider.Core()).Flush(context.Background())
+			if err != nil {
+				slog.Error("Failed to generate knowledge duplicate reports", slog.String("error", err.Error()))
+			} else {
+				slog.Info("Successfully generate knowledge duplicate reports")
+			}
+		})
+	})
+}
+
+// DedupProcess 定期为每个空间生成去重报告，内容哈希相同或相关知识点的相似度
+// 不低于 KNOWLEDGE_DUPLICATE_MIN_SCORE 的知识点视为重复
+type DedupProcess struct {
+	core *core.Core
+}
+
+func NewDedupProcess(core *core.Core) *DedupProcess {
+	return &DedupProcess{core: core}
+}
+
+func (p *DedupProcess) Flush(ctx context.Context) error {
+	var afterID string
+	for {
+		list, err := p.core.Store().SpaceStore().ListSpaceIDs(ctx, afterID, DEDUP_SPACE_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		for _, spaceID := range list {
+			if err = p.FillContentHash(ctx, spaceID); err != nil {
+				slog.Error("Failed to fill knowledge content hash", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+			}
+			if err = p.Report(ctx, spaceID); err != nil {
+				slog.Error("Failed to generate knowledge duplicate report", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+			}
+		}
+		if len(list) < DEDUP_SPACE_BATCH_SIZE {
+			return nil
+		}
+		afterID = list[len(list)-1]
+	}
+}
+
+// FillContentHash 为没有内容哈希的知识点计算哈希，包括升级前创建的知识点及清空了旧哈希的知识点
+// 内容为空的知识点哈希仍为空，每次都会被重新检查
+func (p *DedupProcess) FillContentHash(ctx context.Context, spaceID string) error {
+	var afterID string
+	for {
+		list, err := p.core.Store().KnowledgeStore().ListMissingContentHash(ctx, spaceID, afterID, DEDUP_KNOWLEDGE_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		for _, v := range list {
+			content, err := p.core.DecryptData(v.Content)
+			if err != nil {
+				return err
+			}
+			text, err := dedup.PlainText(v.ContentType, content)
+			if err != nil {
+				slog.Warn("Failed to convert knowledge content", slog.String("knowledge_id", v.ID), slog.String("error", err.Error()))
+				continue
+			}
+			if hash := p.core.HashContent(dedup.Normalize(text)); hash != "" {
+				if err = p.core.Store().KnowledgeStore().SetContentHash(ctx, spaceID, v.ID, hash); err != nil {
+					return err
+				}
+			}
+		}
+		if len(list) < DEDUP_KNOWLEDGE_BATCH_SIZE {
+			return nil
+		}
+		afterID = list[len(list)-1].ID
+	}
+}
+
+// Report 重新生成空间的去重报告
+func (p *DedupProcess) Report(ctx context.Context, spaceID string) error {
+	hashes, err := p.core.Store().KnowledgeStore().ListHashDuplicates(ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	related, err := p.core.Store().KnowledgeRelatedStore().ListAboveScore(ctx, spaceID, types.KNOWLEDGE_DUPLICATE_MIN_SCORE)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	data := duplicatePairs(spaceID, hashes, related, time.Now().Unix())
+	return p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := p.core.Store().KnowledgeDuplicateStore().DeleteAll(ctx, spaceID); err != nil {
+			return err
+		}
+		return p.core.Store().KnowledgeDuplicateStore().BatchCreate(ctx, data)
+	})
+}
+
+// duplicatePairs 哈希相同的知识点都与其中最早创建的知识点组成一对，
+// 内容相同的知识点之间不再记录相似关系
+func duplicatePairs(spaceID string, hashes []types.KnowledgeHashDuplicate, related []types.KnowledgeRelated, now int64) []types.KnowledgeDuplicate {
+	var (
+		res   []types.KnowledgeDuplicate
+		group = make(map[string]string) // 知识点 -> 内容哈希
+	)
+	for _, v := range hashes {
+		for _, id := range v.IDs {
+			group[id] = v.ContentHash
+		}
+		for _, id := range v.IDs[1:] {
+			res = append(res, types.KnowledgeDuplicate{
+				SpaceID:     spaceID,
+				KnowledgeID: v.IDs[0],
+				DuplicateID: id,
+				Reason:      types.KNOWLEDGE_DUPLICATE_REASON_HASH,
+				Score:       1,
+				CreatedAt:   now,
+			})
+		}
+	}
+	for _, v := range related {
+		if hash, exist := group[v.KnowledgeID]; exist && group[v.RelatedID] == hash {
+			continue
+		}
+		res = append(res, types.KnowledgeDuplicate{
+			SpaceID:     spaceID,
+			KnowledgeID: v.KnowledgeID,
+			DuplicateID: v.RelatedID,
+			Reason:      types.KNOWLEDGE_DUPLICATE_REASON_SIMILAR,
+			Score:       v.Score,
+			CreatedAt:   now,
+		})
+	}
+	return res
+}
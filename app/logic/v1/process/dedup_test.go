@@ -0,0 +1,27 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func Test_duplicatePairs(t *testing.T) {
+	hashes := []types.KnowledgeHashDuplicate{
+		{ContentHash: "h1", IDs: []string{"a", "b", "c"}},
+	}
+	related := []types.KnowledgeRelated{
+		{KnowledgeID: "b", RelatedID: "c", Score: 0.99},
+		{KnowledgeID: "c", RelatedID: "d", Score: 0.97},
+	}
+
+	assert.Equal(t, []types.KnowledgeDuplicate{
+		{SpaceID: "s", KnowledgeID: "a", DuplicateID: "b", Reason: types.KNOWLEDGE_DUPLICATE_REASON_HASH, Score: 1, CreatedAt: 100},
+		{SpaceID: "s", KnowledgeID: "a", DuplicateID: "c", Reason: types.KNOWLEDGE_DUPLICATE_REASON_HASH, Score: 1, CreatedAt: 100},
+		{SpaceID: "s", KnowledgeID: "c", DuplicateID: "d", Reason: types.KNOWLEDGE_DUPLICATE_REASON_SIMILAR, Score: 0.97, CreatedAt: 100},
+	}, duplicatePairs("s", hashes, related, 100))
+
+	assert.Nil(t, duplicatePairs("s", nil, nil, 100))
+}
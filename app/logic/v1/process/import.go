@@ -0,0 +1,52 @@
+package process
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/breeew/brew-api/pkg/safe"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// IMPORT_SUMMARY_INTERVAL 批量导入的知识点加入 SummaryChan 的最小间隔，所有导入任务共享
+const IMPORT_SUMMARY_INTERVAL = time.Second
+
+var importLimiter = rate.NewLimiter(rate.Every(IMPORT_SUMMARY_INTERVAL), 1)
+
+// NewImportSummaryRequests 按照 importLimiter 的速率将批量导入的知识点逐个交给 ProcessSummary，
+// 避免大量导入占满 SummaryChan 影响新写入的知识点，服务重启时未处理的知识点由 Flush 继续处理
+func NewImportSummaryRequests(spaceID string, ids []string) {
+	if knowledgeProcess == nil {
+		return
+	}
+
+	go safe.Run(func() {
+		p := knowledgeProcess
+		for _, id := range ids {
+			if err := importLimiter.Wait(p.ctx); err != nil {
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(p.ctx, time.Second*10)
+			knowledge, err := p.core.Store().KnowledgeStore().GetKnowledge(ctx, spaceID, id)
+			cancel()
+			if err != nil {
+				slog.Error("Failed to get imported knowledge", slog.String("space_id", spaceID), slog.String("knowledge_id", id), slog.String("error", err.Error()))
+				continue
+			}
+			if knowledge.Stage != types.KNOWLEDGE_STAGE_SUMMARIZE {
+				// 已经由 Flush 处理
+				continue
+			}
+
+			if knowledge.Content, err = p.core.DecryptData(knowledge.Content); err != nil {
+				slog.Error("Failed to decrypt knowledge content", slog.String("knowledge_id", id), slog.String("error", err.Error()))
+				continue
+			}
+			NewSummaryRequest(*knowledge)
+		}
+	})
+}
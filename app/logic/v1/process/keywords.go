@@ -0,0 +1,69 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/search"
+)
+
+// KEYWORDS_CHUNK_BATCH_SIZE 补齐全文索引时每次读取的切片数量
+const KEYWORDS_CHUNK_BATCH_SIZE = 200
+
+func init() {
+	register.RegisterFunc(ProcessKey{}, func(provider *Process) {
+		provider.Cron().AddFunc("0 3 * * *", func() {
+			total, err := NewKeywordsProcess(provider.Core()).Flush(context.Background())
+			if err != nil {
+				slog.Error("Failed to fill knowledge chunk keywords", slog.String("error", err.Error()))
+			} else if total > 0 {
+				slog.Info("Successfully fill knowledge chunk keywords", slog.Int("total", total))
+			}
+		})
+	})
+}
+
+// KeywordsProcess 为升级前生成的切片补齐关键词全文索引，新切片在 sealChunks 中生成索引
+type KeywordsProcess struct {
+	core *core.Core
+}
+
+func NewKeywordsProcess(core *core.Core) *KeywordsProcess {
+	return &KeywordsProcess{core: core}
+}
+
+// Flush 返回补齐的切片数量，分词结果为空的切片索引仍为空，每次都会被重新检查
+func (p *KeywordsProcess) Flush(ctx context.Context) (int, error) {
+	var (
+		afterID string
+		total   int
+	)
+	for {
+		list, err := p.core.Store().KnowledgeChunkStore().ListMissingKeywords(ctx, afterID, KEYWORDS_CHUNK_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return total, err
+		}
+		for _, v := range list {
+			chunk, err := p.core.DecryptData([]byte(v.Chunk))
+			if err != nil {
+				slog.Error("Failed to decrypt knowledge chunk", slog.String("id", v.ID), slog.String("error", err.Error()))
+				continue
+			}
+			keywords := p.core.EncryptKeywords(search.Tokenize(string(chunk)))
+			if len(keywords) == 0 {
+				continue
+			}
+			if err = p.core.Store().KnowledgeChunkStore().SetKeywords(ctx, v.SpaceID, v.KnowledgeID, v.ID, keywords); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if len(list) < KEYWORDS_CHUNK_BATCH_SIZE {
+			return total, nil
+		}
+		afterID = list[len(list)-1].ID
+	}
+}
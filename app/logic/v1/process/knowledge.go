@@ -6,18 +6,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/holdno/firetower/protocol"
-	"github.com/pgvector/pgvector-go"
+	"github.com/samber/lo"
 	"github.com/sashabaranov/go-openai"
 
 	"github.com/breeew/brew-api/app/core"
 	"github.com/breeew/brew-api/app/core/srv"
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/chunker"
+	"github.com/breeew/brew-api/pkg/dedup"
 	"github.com/breeew/brew-api/pkg/mark"
 	"github.com/breeew/brew-api/pkg/safe"
+	"github.com/breeew/brew-api/pkg/search"
 	"github.com/breeew/brew-api/pkg/types"
 	"github.com/breeew/brew-api/pkg/utils"
 )
@@ -34,6 +39,7 @@ func StartKnowledgeProcess(core *core.Core, concurrency int) context.CancelFunc
 		core:                     core,
 		SummaryChan:              make(chan *SummaryRequest, 1000),
 		EmbeddingChan:            make(chan *EmbeddingRequest, 1000),
+		ReEmbeddingChan:          make(chan *ReEmbeddingRequest, 100),
 		RecordUsageChan:          make(chan *RecordUsageRequest, 100),
 		RecordChatUsageChan:      make(chan *RecordChatUsageRequest, 10000),
 		RecordSessionUsageChan:   make(chan *RecordSessionUsageRequest, 100),
@@ -42,6 +48,7 @@ func StartKnowledgeProcess(core *core.Core, concurrency int) context.CancelFunc
 	}
 
 	go safe.Run(knowledgeProcess.Start)
+	go safe.Run(knowledgeProcess.resumeReEmbedding)
 	go safe.Run(func() {
 		knowledgeProcess.Flush()
 		ticker := time.NewTicker(time.Minute)
@@ -96,6 +103,7 @@ type KnowledgeProcess struct {
 	core                     *core.Core
 	SummaryChan              chan *SummaryRequest
 	EmbeddingChan            chan *EmbeddingRequest
+	ReEmbeddingChan          chan *ReEmbeddingRequest
 	RecordUsageChan          chan *RecordUsageRequest
 	RecordChatUsageChan      chan *RecordChatUsageRequest
 	RecordSessionUsageChan   chan *RecordSessionUsageRequest
@@ -120,6 +128,10 @@ func (p *KnowledgeProcess) Start() {
 			p.ProcessUsage()
 		})
 	}
+	// 模型迁移较为耗时且会占用大量 embedding 配额，同一时间只迁移一个空间
+	go safe.Run(func() {
+		p.ProcessReEmbedding()
+	})
 }
 
 type SummaryRequest struct {
@@ -132,8 +144,9 @@ type SummaryResponse struct {
 	Err error
 }
 
+// CheckProcess 同一个 id 同时只会有一个 handler 在执行，锁在 handler 结束后释放
 func (p *KnowledgeProcess) CheckProcess(id string, handler func()) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	ok, err := p.core.TryLock(ctx, fmt.Sprintf("knowledge:process:%s", id))
 	if err != nil {
@@ -268,95 +281,33 @@ func (p *KnowledgeProcess) processEmbedding(req *EmbeddingRequest) {
 		}
 	}()
 
-	// if req.data.Summary == "" {
-	// 	err = errors.New("empty summary")
-	// 	return
-	// }
-
-	sw := mark.NewSensitiveWork()
-	// content := sw.Do(req.data.Summary)
-
-	var chunksData []types.KnowledgeChunk
-
-	if req.data.Kind == types.KNOWLEDGE_KIND_CHUNK {
-		// type KnowledgeChunk struct {
-		// 	ID             string `json:"id" db:"id"`                           // 主键，字符串类型
-		// 	KnowledgeID    string `json:"knowledge_id" db:"knowledge_id"`       // 知识点ID
-		// 	SpaceID        string `json:"space_id" db:"space_id"`               // 空间ID
-		// 	UserID         string `json:"user_id" db:"user_id"`                 // 用户ID
-		// 	Chunk          string `json:"chunk" db:"chunk"`                     // 知识片段
-		// 	OriginalLength int    `json:"original_length" db:"original_length"` // 原文长度
-		// 	UpdatedAt      int64  `json:"updated_at" db:"updated_at"`           // 更新时间
-		// 	CreatedAt      int64  `json:"created_at" db:"created_at"`           // 创建时间
-		// }
-
-		markdownContent := string(req.data.Content)
-		if req.data.ContentType == types.KNOWLEDGE_CONTENT_TYPE_BLOCKS {
-			markdownContent, err = utils.ConvertEditorJSBlocksToMarkdown(json.RawMessage(req.data.Content))
-			if err != nil {
-				slog.Error("Failed to convert editor blocks to markdown", append(logAttrs, slog.String("error", err.Error()))...)
-				return
-			}
-		}
-		chunksData = append(chunksData, types.KnowledgeChunk{
-			ID:             req.data.ID,
-			KnowledgeID:    req.data.ID,
-			SpaceID:        req.data.SpaceID,
-			UserID:         req.data.UserID,
-			Chunk:          markdownContent,
-			OriginalLength: len([]rune(markdownContent)),
-			UpdatedAt:      time.Now().Unix(),
-			CreatedAt:      time.Now().Unix(),
-		})
-	} else {
-		chunksData, err = p.core.Store().KnowledgeChunkStore().List(ctx, req.data.SpaceID, req.data.ID)
-		if err != nil {
-			slog.Error("Failed to list knowledge chuns", append(logAttrs, slog.String("error", err.Error()))...)
-			return
-		}
+	baseVectors, chunks, err := p.prepareEmbeddingChunks(ctx, req.data)
+	if err != nil {
+		slog.Error("Failed to prepare knowledge chunks", append(logAttrs, slog.String("error", err.Error()))...)
+		return
 	}
 
+	// 空间正在迁移 embedding 模型时，同时生成新旧两个模型的向量，迁移完成前旧模型的向量继续提供检索
 	var (
 		vectors []types.Vector
-		chunks  []string
+		models  = make(map[string]struct{})
 	)
-	for _, v := range chunksData {
-		decryptData, err := p.core.DecryptData([]byte(v.Chunk))
-		if err != nil {
-			slog.Error("Failed to decrypt knowledge chunk", slog.String("error", err.Error()))
+	for _, driver := range p.embeddingDrivers(ctx, req.data.SpaceID) {
+		var list []types.Vector
+		if list, err = p.embeddingVectors(ctx, driver, req.data, baseVectors, chunks, func(finished, total int) {
+			publishStageProgressMessage(p.core.Srv().Tower(), req.data.SpaceID, req.data.ID, types.KNOWLEDGE_STAGE_EMBEDDING, finished*100/total)
+		}); err != nil {
+			slog.Error("Failed to embedding for document", append(logAttrs, slog.String("driver", driver), slog.String("error", err.Error()))...)
+			return
+		}
+		if len(list) == 0 {
 			continue
 		}
-		v.Chunk = string(decryptData)
-		chunks = append(chunks, sw.Do(v.Chunk))
-
-		vectors = append(vectors, types.Vector{
-			ID:             v.ID,
-			KnowledgeID:    v.KnowledgeID,
-			SpaceID:        v.SpaceID,
-			UserID:         v.UserID,
-			Resource:       req.data.Resource,
-			OriginalLength: v.OriginalLength,
-			// Embedding:   pgvector.NewVector(vector),
-			CreatedAt: time.Now().Unix(),
-			UpdatedAt: time.Now().Unix(),
-		})
-	}
-
-	vectorResults, err := p.core.Srv().AI().EmbeddingForDocument(ctx, "", chunks)
-	if err != nil {
-		slog.Error("Failed to embedding for document", append(logAttrs, slog.String("error", err.Error()))...)
-		return
-	}
-
-	NewRecordKnowledgeUsageRequest(vectorResults.Model, types.USAGE_SUB_TYPE_EMBEDDING, req.data, vectorResults.Usage)
-
-	if len(vectorResults.Data) != len(vectors) {
-		slog.Error("Embedding results count not matched chunks count", append(logAttrs, slog.String("error", "embedding result length not match"))...)
-		return
-	}
-
-	for i, v := range vectorResults.Data {
-		vectors[i].Embedding = pgvector.NewVector(v)
+		if _, exist := models[list[0].Model]; exist {
+			continue
+		}
+		models[list[0].Model] = struct{}{}
+		vectors = append(vectors, list...)
 	}
 
 	err = p.core.Store().Transaction(req.ctx, func(ctx context.Context) error {
@@ -409,6 +360,137 @@ func (p *KnowledgeProcess) processEmbedding(req *EmbeddingRequest) {
 		publishStageChangedMessage(p.core.Srv().Tower(), req.data.SpaceID, req.data.ID, types.KNOWLEDGE_STAGE_DONE)
 		return nil
 	})
+	if err != nil || len(vectors) == 0 {
+		return
+	}
+
+	// 使用当前提供检索的模型的向量计算相关知识点，失败不影响知识点的处理状态
+	related, relatedErr := UpdateRelatedKnowledges(ctx, p.core, req.data, lo.Filter(vectors, func(item types.Vector, _ int) bool {
+		return item.Model == vectors[0].Model
+	}))
+	if relatedErr != nil {
+		slog.Error("Failed to update related knowledges", append(logAttrs, slog.String("error", relatedErr.Error()))...)
+		return
+	}
+	publishRelatedMessage(p.core.Srv().Tower(), req.data.SpaceID, req.data.ID, related)
+}
+
+// prepareEmbeddingChunks 获取知识点需要生成向量的片段，返回未填充 embedding 的向量记录及对应的脱敏后文本
+func (p *KnowledgeProcess) prepareEmbeddingChunks(ctx context.Context, knowledge *types.Knowledge) ([]types.Vector, []string, error) {
+	var (
+		err        error
+		chunksData []types.KnowledgeChunk
+	)
+
+	if knowledge.Kind == types.KNOWLEDGE_KIND_CHUNK {
+		markdownContent := string(knowledge.Content)
+		if knowledge.ContentType == types.KNOWLEDGE_CONTENT_TYPE_BLOCKS {
+			markdownContent, err = utils.ConvertEditorJSBlocksToMarkdown(json.RawMessage(knowledge.Content))
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to convert editor blocks to markdown: %w", err)
+			}
+		}
+		chunksData = append(chunksData, types.KnowledgeChunk{
+			ID:             knowledge.ID,
+			KnowledgeID:    knowledge.ID,
+			SpaceID:        knowledge.SpaceID,
+			UserID:         knowledge.UserID,
+			Chunk:          markdownContent,
+			OriginalLength: len([]rune(markdownContent)),
+			UpdatedAt:      time.Now().Unix(),
+			CreatedAt:      time.Now().Unix(),
+		})
+	} else {
+		chunksData, err = p.core.Store().KnowledgeChunkStore().List(ctx, knowledge.SpaceID, knowledge.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to list knowledge chunks: %w", err)
+		}
+	}
+
+	var (
+		sw      = mark.NewSensitiveWork()
+		vectors []types.Vector
+		chunks  []string
+	)
+	for _, v := range chunksData {
+		decryptData, err := p.core.DecryptData([]byte(v.Chunk))
+		if err != nil {
+			slog.Error("Failed to decrypt knowledge chunk", slog.String("error", err.Error()))
+			continue
+		}
+		v.Chunk = string(decryptData)
+		chunks = append(chunks, sw.Do(v.Chunk))
+
+		vectors = append(vectors, types.Vector{
+			ID:             v.ID,
+			KnowledgeID:    v.KnowledgeID,
+			SpaceID:        v.SpaceID,
+			UserID:         v.UserID,
+			Resource:       knowledge.Resource,
+			OriginalLength: v.OriginalLength,
+			CreatedAt:      time.Now().Unix(),
+			UpdatedAt:      time.Now().Unix(),
+		})
+	}
+	return vectors, chunks, nil
+}
+
+// embeddingDrivers 返回空间需要生成向量的 embedding 驱动，空字符串表示使用全局 usage 配置
+func (p *KnowledgeProcess) embeddingDrivers(ctx context.Context, spaceID string) []string {
+	space, err := p.core.Store().SpaceStore().GetSpace(ctx, spaceID)
+	if err != nil {
+		slog.Error("Failed to get space embedding settings, use default driver", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+		return []string{""}
+	}
+
+	drivers := []string{space.Settings.Embedding.Driver}
+	if space.Settings.Embedding.IsMigrating() && space.Settings.Embedding.Migration.Driver != space.Settings.Embedding.Driver {
+		drivers = append(drivers, space.Settings.Embedding.Migration.Driver)
+	}
+	return drivers
+}
+
+// EMBEDDING_BATCH_SIZE 单次请求 embedding 的最大切片数，部分模型限制了单次请求的数量
+const EMBEDDING_BATCH_SIZE = 10
+
+// embeddingVectors 使用指定的 embedding 驱动为 chunks 生成向量，并记录生成向量的模型及维度
+// 切片数量较多时分批请求，每批完成后回调 progress
+func (p *KnowledgeProcess) embeddingVectors(ctx context.Context, driver string, knowledge *types.Knowledge, base []types.Vector, chunks []string, progress func(finished, total int)) ([]types.Vector, error) {
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	d, ok := p.core.Srv().AI().EmbeddingWith(driver)
+	if !ok {
+		return nil, fmt.Errorf("embedding driver %s not found", driver)
+	}
+
+	vectors := make([]types.Vector, 0, len(base))
+	for _, batch := range lo.Chunk(lo.Range(len(chunks)), EMBEDDING_BATCH_SIZE) {
+		res, err := d.EmbeddingForDocument(ctx, "", chunks[batch[0]:batch[len(batch)-1]+1])
+		if err != nil {
+			return nil, err
+		}
+
+		NewRecordKnowledgeUsageRequest(res.Model, types.USAGE_SUB_TYPE_EMBEDDING, knowledge, res.Usage)
+
+		if len(res.Data) != len(batch) {
+			return nil, fmt.Errorf("embedding result length not match, chunks: %d, results: %d", len(batch), len(res.Data))
+		}
+
+		for i, v := range res.Data {
+			item := base[batch[i]]
+			item.Embedding = v
+			item.Model = res.Model
+			item.Dimension = len(v)
+			vectors = append(vectors, item)
+		}
+
+		if progress != nil && len(chunks) > EMBEDDING_BATCH_SIZE {
+			progress(len(vectors), len(chunks))
+		}
+	}
+	return vectors, nil
 }
 
 func (p *KnowledgeProcess) processSummary(req *SummaryRequest) {
@@ -464,56 +546,88 @@ func (p *KnowledgeProcess) processSummary(req *SummaryRequest) {
 		}
 	}
 
-	secretContent := sw.Do(markdownContent)
+	if needToUpdate(req.data.Summary, "content") {
+		if err := UpdateKnowledgeLinks(ctx, p.core, *req.data); err != nil {
+			// 链接不影响知识点的处理
+			slog.Error("Failed to update knowledge links", append(logAttrs, slog.String("error", err.Error()))...)
+		}
+		if hash := p.core.HashContent(dedup.Normalize(markdownContent)); hash != knowledge.ContentHash {
+			if err := p.core.Store().KnowledgeStore().SetContentHash(ctx, req.data.SpaceID, req.data.ID, hash); err != nil {
+				slog.Error("Failed to update knowledge content hash", append(logAttrs, slog.String("error", err.Error()))...)
+			}
+		}
+	}
 
-	summary, err := p.core.Srv().AI().Chunk(ctx, &secretContent)
-	if err != nil {
-		slog.Error("Failed to summarize knowledge", append(logAttrs, slog.String("error", err.Error()))...)
+	settings := p.chunkSettings(ctx, req.data.SpaceID, req.data.Resource)
+	if !chunker.IsLocal(settings.Strategy) && needToUpdate(req.data.Summary, "content") && chunker.CountTokens(markdownContent) > LARGE_DOCUMENT_TOKENS {
+		// 超长文档分段处理，避免单次请求超出模型上下文
+		err = p.processLargeSummary(req, markdownContent, logAttrs)
 		return
 	}
 
-	NewRecordKnowledgeUsageRequest(summary.Model, types.USAGE_SUB_TYPE_SUMMARY, req.data, summary.Usage)
+	var (
+		summary    ai.ChunkResult
+		chunkTexts []string
+	)
+	if chunker.IsLocal(settings.Strategy) {
+		// 本地切片不需要请求 LLM，不生成标签，标题仅在知识点没有标题时从正文中获取
+		chunkTexts = chunker.Split(settings.Strategy, markdownContent, chunker.Options{
+			ChunkSize: settings.ChunkSize,
+			Overlap:   settings.Overlap,
+		})
+		if req.data.Title == "" {
+			summary.Title = chunker.Title(markdownContent)
+		}
+	} else {
+		secretContent := sw.Do(markdownContent)
+
+		summary, err = p.core.Srv().AI().Chunk(ctx, &secretContent)
+		if err != nil {
+			slog.Error("Failed to summarize knowledge", append(logAttrs, slog.String("error", err.Error()))...)
+			return
+		}
+
+		NewRecordKnowledgeUsageRequest(summary.Model, types.USAGE_SUB_TYPE_SUMMARY, req.data, summary.Usage)
+
+		slog.Debug("Knowledge summary result", slog.String("knowledge_id", req.data.ID), slog.String("space_id", req.data.SpaceID), slog.Any("result", summary))
 
-	slog.Debug("Knowledge summary result", slog.String("knowledge_id", req.data.ID), slog.String("space_id", req.data.SpaceID), slog.Any("result", summary))
+		for _, v := range summary.Chunks {
+			chunkTexts = append(chunkTexts, sw.Undo(v))
+		}
+	}
 
 	if summary.DateTime == "" {
 		summary.DateTime = req.data.MaybeDate
 	}
 
-	if len(summary.Chunks) == 0 {
-		summary.Chunks = append(summary.Chunks, markdownContent)
+	if len(chunkTexts) == 0 {
+		chunkTexts = append(chunkTexts, markdownContent)
 	}
 
 	originalLenght := len([]rune(markdownContent))
 	var chunks []*types.KnowledgeChunk
-	for _, v := range summary.Chunks {
+	for i, v := range chunkTexts {
 		chunks = append(chunks, &types.KnowledgeChunk{
 			ID:             utils.GenRandomID(),
 			SpaceID:        req.data.SpaceID,
 			KnowledgeID:    req.data.ID,
 			UserID:         req.data.UserID,
-			Chunk:          sw.Undo(v),
+			Chunk:          v,
+			Seq:            i,
 			OriginalLength: originalLenght,
 			UpdatedAt:      time.Now().Unix(),
 			CreatedAt:      time.Now().Unix(),
 		})
 	}
 
-	if req.data.Summary != "" {
-		needToUpdate := make(map[string]bool)
-		for _, v := range strings.Split(req.data.Summary, ",") {
-			needToUpdate[v] = true
-		}
-
-		if !needToUpdate["title"] {
-			summary.Title = ""
-		}
-		if !needToUpdate["tags"] {
-			summary.Tags = nil
-		}
-		if !needToUpdate["content"] {
-			chunks = nil
-		}
+	if !needToUpdate(req.data.Summary, "title") {
+		summary.Title = ""
+	}
+	if !needToUpdate(req.data.Summary, "tags") {
+		summary.Tags = nil
+	}
+	if !needToUpdate(req.data.Summary, "content") {
+		chunks = nil
 	}
 
 	// if summary.Title != "" {
@@ -527,14 +641,8 @@ func (p *KnowledgeProcess) processSummary(req *SummaryRequest) {
 				return err
 			}
 
-			for _, v := range chunks {
-				encryptData, err := p.core.EncryptData([]byte(v.Chunk))
-				if err != nil {
-					slog.Error("Failed to encrypt knowledge chunk content", slog.String("error", err.Error()), slog.String("id", v.ID))
-					return err
-				}
-
-				v.Chunk = string(encryptData)
+			if err = p.sealChunks(chunks); err != nil {
+				return err
 			}
 
 			if err = p.core.Store().KnowledgeChunkStore().BatchCreate(req.ctx, chunks); err != nil {
@@ -553,7 +661,56 @@ func (p *KnowledgeProcess) processSummary(req *SummaryRequest) {
 	})
 }
 
+// sealChunks 生成全文索引并加密切片内容，全文索引需要在加密前基于明文生成
+func (p *KnowledgeProcess) sealChunks(chunks []*types.KnowledgeChunk) error {
+	for _, v := range chunks {
+		v.Keywords = p.core.EncryptKeywords(search.Tokenize(v.Chunk))
+		encryptData, err := p.core.EncryptData([]byte(v.Chunk))
+		if err != nil {
+			slog.Error("Failed to encrypt knowledge chunk content", slog.String("error", err.Error()), slog.String("id", v.ID))
+			return err
+		}
+
+		v.Chunk = string(encryptData)
+	}
+	return nil
+}
+
+// needToUpdate knowledge.Summary 不为空时表示仅需要更新其中列出的字段(title,tags,content)
+func needToUpdate(fields, field string) bool {
+	if fields == "" {
+		return true
+	}
+	return lo.Contains(strings.Split(fields, ","), field)
+}
+
+// chunkSettings 获取知识点所属 resource 的切片配置，获取失败时使用默认的 LLM 切片
+func (p *KnowledgeProcess) chunkSettings(ctx context.Context, spaceID, resource string) types.ChunkSettings {
+	space, err := p.core.Store().SpaceStore().GetSpace(ctx, spaceID)
+	if err != nil {
+		slog.Error("Failed to get space chunk settings, use default settings", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+		return types.ChunkSettings{}
+	}
+	return space.Settings.GetChunkSettings(resource)
+}
+
 func publishStageChangedMessage(tower *srv.Tower, spaceID, knowledgeID string, stage types.KnowledgeStage) {
+	publishStageMessage(tower, spaceID, map[string]string{
+		"knowledge_id": knowledgeID,
+		"stage":        stage.String(),
+	})
+}
+
+// publishStageProgressMessage 推送当前阶段的处理进度，progress 为 0~100 的百分比
+func publishStageProgressMessage(tower *srv.Tower, spaceID, knowledgeID string, stage types.KnowledgeStage, progress int) {
+	publishStageMessage(tower, spaceID, map[string]string{
+		"knowledge_id": knowledgeID,
+		"stage":        stage.String(),
+		"progress":     strconv.Itoa(progress),
+	})
+}
+
+func publishStageMessage(tower *srv.Tower, spaceID string, data map[string]string) {
 	fire := tower.NewFire(protocol.SourceSystem, tower.Pusher())
 	fire.Message = protocol.TopicMessage[srv.PublishData]{
 		Topic: "/knowledge/list/" + spaceID,
@@ -561,10 +718,7 @@ func publishStageChangedMessage(tower *srv.Tower, spaceID, knowledgeID string, s
 		Data: srv.PublishData{
 			Version: "v1",
 			Subject: "stage_changed",
-			Data: map[string]string{
-				"knowledge_id": knowledgeID,
-				"stage":        stage.String(),
-			},
+			Data:    data,
 		},
 	}
 
@@ -0,0 +1,209 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/chunker"
+	"github.com/breeew/brew-api/pkg/mark"
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+const (
+	// LARGE_DOCUMENT_TOKENS 超过该长度的文档分段交给 LLM 切片，避免超出模型上下文或丢失内容
+	LARGE_DOCUMENT_TOKENS = 12000
+	// LARGE_DOCUMENT_SECTION_TOKENS 每个分段的最大长度
+	LARGE_DOCUMENT_SECTION_TOKENS = 6000
+	// LARGE_DOCUMENT_MAX_TAGS 合并各分段标签后保留的最大数量
+	LARGE_DOCUMENT_MAX_TAGS = 10
+	// LARGE_DOCUMENT_SECTION_SEQ_STEP 第 i 个分段的切片序号从 i*LARGE_DOCUMENT_SECTION_SEQ_STEP 开始
+	LARGE_DOCUMENT_SECTION_SEQ_STEP = 10000
+)
+
+// processLargeSummary 按标题将超长文档分段，逐段切片并保存，每段完成后记录进度并推送百分比
+// 处理失败时已完成的分段不会重复处理，内容变化后重新开始
+func (p *KnowledgeProcess) processLargeSummary(req *SummaryRequest, markdownContent string, logAttrs []any) error {
+	sections := chunker.SplitMarkdown(markdownContent, chunker.Options{ChunkSize: LARGE_DOCUMENT_SECTION_TOKENS})
+	if len(sections) == 0 {
+		return fmt.Errorf("empty document")
+	}
+
+	progress, err := p.loadProgress(req.ctx, req.data, types.KNOWLEDGE_STAGE_SUMMARIZE, p.core.HashContent(markdownContent), len(sections))
+	if err != nil {
+		slog.Error("Failed to load knowledge progress", append(logAttrs, slog.String("error", err.Error()))...)
+		return err
+	}
+
+	if progress.Finished > 0 {
+		slog.Info("Resume large document summary", append(logAttrs, slog.Int("finished", progress.Finished), slog.Int("total", progress.Total))...)
+	}
+
+	originalLength := len([]rune(markdownContent))
+	for i := progress.Finished; i < len(sections); i++ {
+		if p.ctx.Err() != nil {
+			return p.ctx.Err()
+		}
+
+		if err = p.summarySection(req, sections[i], originalLength, progress); err != nil {
+			slog.Error("Failed to summarize document section", append(logAttrs, slog.Int("section", i), slog.String("error", err.Error()))...)
+			return err
+		}
+
+		publishStageProgressMessage(p.core.Srv().Tower(), req.data.SpaceID, req.data.ID, types.KNOWLEDGE_STAGE_SUMMARIZE, progress.Percent())
+	}
+
+	summary := progress.Summary
+	if summary.DateTime == "" {
+		summary.DateTime = req.data.MaybeDate
+	}
+	if !needToUpdate(req.data.Summary, "title") {
+		summary.Title = ""
+	}
+	if !needToUpdate(req.data.Summary, "tags") {
+		summary.Tags = nil
+	}
+
+	ctx, cancel := context.WithTimeout(req.ctx, time.Second*10)
+	defer cancel()
+	return p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := p.core.Store().KnowledgeStore().FinishedStageSummarize(ctx, req.data.SpaceID, req.data.ID, ai.ChunkResult{
+			Title:    summary.Title,
+			Tags:     summary.Tags,
+			DateTime: summary.DateTime,
+		}); err != nil {
+			slog.Error("Failed to set finished summary stage", append(logAttrs, slog.String("error", err.Error()))...)
+			return err
+		}
+
+		if err := p.core.Store().KnowledgeProgressStore().Delete(ctx, req.data.SpaceID, req.data.ID); err != nil {
+			slog.Error("Failed to delete knowledge progress", append(logAttrs, slog.String("error", err.Error()))...)
+			return err
+		}
+
+		publishStageChangedMessage(p.core.Srv().Tower(), req.data.SpaceID, req.data.ID, types.KNOWLEDGE_STAGE_EMBEDDING)
+		return nil
+	})
+}
+
+// resumable 保存的进度与当前内容一致时才能继续，内容或分段数量变化后已完成的分段不再有效
+func resumable(progress *types.KnowledgeProgress, stage types.KnowledgeStage, hash string, total int) bool {
+	return progress != nil && progress.Stage == stage && progress.ContentHash == hash && progress.Total == total
+}
+
+// loadProgress 获取知识点的处理进度，进度不存在或已失效时清理已生成的切片并重新开始
+func (p *KnowledgeProcess) loadProgress(ctx context.Context, knowledge *types.Knowledge, stage types.KnowledgeStage, hash string, total int) (*types.KnowledgeProgress, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	progress, err := p.core.Store().KnowledgeProgressStore().Get(ctx, knowledge.SpaceID, knowledge.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if resumable(progress, stage, hash, total) {
+		return progress, nil
+	}
+
+	progress = &types.KnowledgeProgress{
+		KnowledgeID: knowledge.ID,
+		SpaceID:     knowledge.SpaceID,
+		Stage:       stage,
+		ContentHash: hash,
+		Total:       total,
+	}
+
+	err = p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := p.core.Store().KnowledgeChunkStore().BatchDelete(ctx, knowledge.SpaceID, knowledge.ID); err != nil {
+			return err
+		}
+		return p.core.Store().KnowledgeProgressStore().Upsert(ctx, *progress)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// summarySection 处理单个分段，分段的切片与进度在同一个事务中保存
+func (p *KnowledgeProcess) summarySection(req *SummaryRequest, section string, originalLength int, progress *types.KnowledgeProgress) error {
+	ctx, cancel := context.WithTimeout(req.ctx, time.Minute*5)
+	defer cancel()
+
+	sw := mark.NewSensitiveWork()
+	secretContent := sw.Do(section)
+	result, err := p.core.Srv().AI().Chunk(ctx, &secretContent)
+	if err != nil {
+		return err
+	}
+
+	NewRecordKnowledgeUsageRequest(result.Model, types.USAGE_SUB_TYPE_SUMMARY, req.data, result.Usage)
+
+	var (
+		chunks  []*types.KnowledgeChunk
+		seqBase = progress.Finished * LARGE_DOCUMENT_SECTION_SEQ_STEP
+	)
+	for i, v := range result.Chunks {
+		chunks = append(chunks, &types.KnowledgeChunk{
+			ID:             utils.GenRandomID(),
+			SpaceID:        req.data.SpaceID,
+			KnowledgeID:    req.data.ID,
+			UserID:         req.data.UserID,
+			Chunk:          sw.Undo(v),
+			Seq:            seqBase + i,
+			OriginalLength: originalLength,
+			UpdatedAt:      time.Now().Unix(),
+			CreatedAt:      time.Now().Unix(),
+		})
+	}
+	if len(chunks) == 0 {
+		chunks = append(chunks, &types.KnowledgeChunk{
+			ID:             utils.GenRandomID(),
+			SpaceID:        req.data.SpaceID,
+			KnowledgeID:    req.data.ID,
+			UserID:         req.data.UserID,
+			Chunk:          section,
+			Seq:            seqBase,
+			OriginalLength: originalLength,
+			UpdatedAt:      time.Now().Unix(),
+			CreatedAt:      time.Now().Unix(),
+		})
+	}
+
+	// 标题与时间以第一个分段为准，标签合并各分段的结果
+	next := *progress
+	if next.Summary.Title == "" {
+		next.Summary.Title = result.Title
+	}
+	if next.Summary.DateTime == "" {
+		next.Summary.DateTime = result.DateTime
+	}
+	next.Summary.Tags = lo.Uniq(append(next.Summary.Tags, result.Tags...))
+	if len(next.Summary.Tags) > LARGE_DOCUMENT_MAX_TAGS {
+		next.Summary.Tags = next.Summary.Tags[:LARGE_DOCUMENT_MAX_TAGS]
+	}
+	next.Finished++
+
+	if err = p.sealChunks(chunks); err != nil {
+		return err
+	}
+
+	err = p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := p.core.Store().KnowledgeChunkStore().BatchCreate(ctx, chunks); err != nil {
+			return err
+		}
+		return p.core.Store().KnowledgeProgressStore().Upsert(ctx, next)
+	})
+	if err != nil {
+		return err
+	}
+
+	*progress = next
+	return nil
+}
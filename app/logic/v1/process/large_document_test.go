@@ -0,0 +1,52 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/chunker"
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+func largeDocument(title string) string {
+	var sb strings.Builder
+	for i := 0; i < 4; i++ {
+		sb.WriteString(fmt.Sprintf("# %s %d\n\n", title, i))
+		sb.WriteString(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 800))
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+func Test_resumable(t *testing.T) {
+	key := []byte("test-key")
+	content := largeDocument("Chapter")
+	sections := chunker.SplitMarkdown(content, chunker.Options{ChunkSize: LARGE_DOCUMENT_SECTION_TOKENS})
+	assert.Greater(t, len(sections), 1)
+
+	hash := utils.HMACSHA256(content, key)
+	progress := &types.KnowledgeProgress{
+		Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+		ContentHash: hash,
+		Total:       len(sections),
+		Finished:    1,
+	}
+
+	// 内容未变化时从已完成的分段之后继续
+	assert.True(t, resumable(progress, types.KNOWLEDGE_STAGE_SUMMARIZE, hash, len(sections)))
+	assert.Equal(t, 100/len(sections), progress.Percent())
+
+	// 分段数量不变但内容变化，已完成的分段失效
+	changed := largeDocument("Section")
+	changedSections := chunker.SplitMarkdown(changed, chunker.Options{ChunkSize: LARGE_DOCUMENT_SECTION_TOKENS})
+	assert.Equal(t, len(sections), len(changedSections))
+	assert.False(t, resumable(progress, types.KNOWLEDGE_STAGE_SUMMARIZE, utils.HMACSHA256(changed, key), len(changedSections)))
+
+	assert.False(t, resumable(progress, types.KNOWLEDGE_STAGE_SUMMARIZE, hash, len(sections)+1))
+	assert.False(t, resumable(progress, types.KNOWLEDGE_STAGE_EMBEDDING, hash, len(sections)))
+	assert.False(t, resumable(nil, types.KNOWLEDGE_STAGE_SUMMARIZE, hash, len(sections)))
+}
@@ -0,0 +1,66 @@
+package process
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/pkg/links"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// UpdateKnowledgeLinks 根据知识点内容(明文)重建其指向其他知识点的链接，
+// 只保留同一空间中存在的知识点，忽略指向自身的链接
+func UpdateKnowledgeLinks(ctx context.Context, core *core.Core, knowledge types.Knowledge) error {
+	list := lo.Filter(links.Extract(knowledge.ContentType, knowledge.Content), func(item links.Link, _ int) bool {
+		return item.ID != knowledge.ID
+	})
+
+	var exist map[string]bool
+	if len(list) > 0 {
+		targets, err := core.Store().KnowledgeStore().ListLiteKnowledges(ctx, types.GetKnowledgeOptions{
+			SpaceID: knowledge.SpaceID,
+			IDs: lo.Map(list, func(item links.Link, _ int) string {
+				return item.ID
+			}),
+		}, types.NO_PAGING, types.NO_PAGING)
+		if err != nil {
+			return err
+		}
+		exist = lo.SliceToMap(targets, func(item *types.KnowledgeLite) (string, bool) {
+			return item.ID, true
+		})
+	}
+
+	now := time.Now().Unix()
+	var data []types.KnowledgeLink
+	for _, item := range list {
+		if !exist[item.ID] {
+			continue
+		}
+		// 链接文字来自知识点内容，同样加密保存
+		if item.Text != "" {
+			text, err := core.EncryptData([]byte(item.Text))
+			if err != nil {
+				return err
+			}
+			item.Text = string(text)
+		}
+		data = append(data, types.KnowledgeLink{
+			SpaceID:   knowledge.SpaceID,
+			FromID:    knowledge.ID,
+			ToID:      item.ID,
+			Text:      item.Text,
+			CreatedAt: now,
+		})
+	}
+
+	return core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := core.Store().KnowledgeLinkStore().DeleteFrom(ctx, knowledge.SpaceID, knowledge.ID); err != nil {
+			return err
+		}
+		return core.Store().KnowledgeLinkStore().BatchCreate(ctx, data)
+	})
+}
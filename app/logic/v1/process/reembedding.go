@@ -0,0 +1,276 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// reEmbeddingPageSize 每批迁移的知识点数量，每批完成后保存一次迁移进度
+const reEmbeddingPageSize = 20
+
+type ReEmbeddingRequest struct {
+	ctx      context.Context
+	spaceID  string
+	response chan CommonProcessResponse
+}
+
+// NewReEmbeddingRequest 在后台将空间的向量迁移至 settings.embedding.migration 指定的模型
+func NewReEmbeddingRequest(spaceID string) chan CommonProcessResponse {
+	if knowledgeProcess == nil || knowledgeProcess.ctx.Err() != nil {
+		slog.Error("Knowledge Process not working", slog.String("space_id", spaceID))
+		return nil
+	}
+
+	resp := make(chan CommonProcessResponse, 1)
+	knowledgeProcess.ReEmbeddingChan <- &ReEmbeddingRequest{
+		ctx:      context.Background(),
+		spaceID:  spaceID,
+		response: resp,
+	}
+	return resp
+}
+
+func (p *KnowledgeProcess) ProcessReEmbedding() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case req := <-p.ReEmbeddingChan:
+			if req == nil {
+				continue
+			}
+
+			req.response <- CommonProcessResponse{
+				Error: p.processReEmbedding(req),
+			}
+		}
+	}
+}
+
+// resumeReEmbedding 服务重启后继续未完成的模型迁移
+func (p *KnowledgeProcess) resumeReEmbedding() {
+	ctx, cancel := context.WithTimeout(p.ctx, time.Second*10)
+	defer cancel()
+	list, err := p.core.Store().SpaceStore().ListEmbeddingMigrating(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		slog.Error("Failed to list embedding migrating spaces", slog.String("error", err.Error()))
+		return
+	}
+
+	for _, v := range list {
+		NewReEmbeddingRequest(v.SpaceID)
+	}
+}
+
+func (p *KnowledgeProcess) processReEmbedding(req *ReEmbeddingRequest) error {
+	logAttrs := []any{
+		slog.String("space_id", req.spaceID),
+		slog.String("component", "KnowledgeProcess.processReEmbedding"),
+	}
+
+	// 迁移耗时较长，锁的有效期与本次迁移一致
+	lockCtx, unlock := context.WithCancel(p.ctx)
+	defer unlock()
+	ok, err := p.core.TryLock(lockCtx, fmt.Sprintf("knowledge:process:reembedding:%s", req.spaceID))
+	if err != nil {
+		slog.Error("Failed to lock space re-embedding process", append(logAttrs, slog.String("error", err.Error()))...)
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(req.ctx, time.Second*10)
+	defer cancel()
+	space, err := p.core.Store().SpaceStore().GetSpace(ctx, req.spaceID)
+	if err != nil {
+		slog.Error("Failed to get space", append(logAttrs, slog.String("error", err.Error()))...)
+		return err
+	}
+
+	if !space.Settings.Embedding.IsMigrating() {
+		return nil
+	}
+	migration := *space.Settings.Embedding.Migration
+
+	slog.Info("Start re-embedding space", append(logAttrs,
+		slog.String("driver", migration.Driver),
+		slog.String("model", migration.Model),
+		slog.String("cursor", migration.Cursor))...)
+
+	for {
+		if p.ctx.Err() != nil {
+			// 服务退出，下次启动时继续
+			return p.ctx.Err()
+		}
+
+		ctx, cancel := context.WithTimeout(req.ctx, time.Second*10)
+		ids, err := p.core.Store().KnowledgeStore().ListKnowledgeIDs(ctx, types.GetKnowledgeOptions{
+			SpaceID: req.spaceID,
+			AfterID: migration.Cursor,
+		}, 1, reEmbeddingPageSize)
+		cancel()
+		if err != nil && err != sql.ErrNoRows {
+			slog.Error("Failed to list knowledge ids", append(logAttrs, slog.String("error", err.Error()))...)
+			return p.failEmbeddingMigration(req.spaceID, migration, err)
+		}
+
+		if len(ids) == 0 {
+			break
+		}
+
+		if err = migrateKnowledges(&migration, ids, func(id string) error {
+			err := p.reEmbeddingKnowledge(req.ctx, req.spaceID, id, migration)
+			if err != nil {
+				slog.Error("Failed to re-embedding knowledge", append(logAttrs, slog.String("knowledge_id", id), slog.String("error", err.Error()))...)
+			}
+			return err
+		}); err != nil {
+			return p.failEmbeddingMigration(req.spaceID, migration, err)
+		}
+
+		canceled, err := p.updateEmbeddingSettings(req.ctx, req.spaceID, migration, func(settings *types.EmbeddingSettings) {
+			settings.Migration.Cursor = migration.Cursor
+			settings.Migration.Processed = migration.Processed
+		})
+		if err != nil {
+			slog.Error("Failed to save re-embedding progress", append(logAttrs, slog.String("error", err.Error()))...)
+			return err
+		}
+		if canceled {
+			return nil
+		}
+	}
+
+	// 切换至新模型，并清理旧模型的向量
+	canceled, err := p.updateEmbeddingSettings(req.ctx, req.spaceID, migration, func(settings *types.EmbeddingSettings) {
+		*settings = migratedEmbeddingSettings(migration)
+	})
+	if err != nil {
+		slog.Error("Failed to switch space embedding model", append(logAttrs, slog.String("error", err.Error()))...)
+		return err
+	}
+	if canceled {
+		return nil
+	}
+
+	ctx, cancel = context.WithTimeout(req.ctx, time.Minute)
+	defer cancel()
+	if err = p.core.Store().VectorStore().PruneModels(ctx, req.spaceID, migration.Model); err != nil {
+		// 旧模型的向量已不会参与检索，清理失败不影响使用
+		slog.Error("Failed to prune old embedding vectors", append(logAttrs, slog.String("error", err.Error()))...)
+	}
+
+	slog.Info("Re-embedding space finished", append(logAttrs, slog.Int("processed", migration.Processed))...)
+	return nil
+}
+
+// migrateKnowledges 按顺序迁移 ids 中的知识点，每完成一个将 cursor 推进到该知识点
+// 失败时 cursor 停留在最后一个迁移成功的知识点，继续迁移时从其之后开始
+func migrateKnowledges(migration *types.EmbeddingMigration, ids []string, migrate func(id string) error) error {
+	for _, id := range ids {
+		if err := migrate(id); err != nil {
+			return err
+		}
+		migration.Cursor = id
+		migration.Processed++
+	}
+	return nil
+}
+
+// migratedEmbeddingSettings 迁移完成后空间使用的 embedding 配置，其他模型的向量随后被清理
+func migratedEmbeddingSettings(migration types.EmbeddingMigration) types.EmbeddingSettings {
+	return types.EmbeddingSettings{
+		Driver:    migration.Driver,
+		Model:     migration.Model,
+		Dimension: migration.Dimension,
+	}
+}
+
+// reEmbeddingKnowledge 使用迁移目标模型重新生成知识点的向量，未处理完成的知识点由正常流程同时生成新旧模型的向量
+func (p *KnowledgeProcess) reEmbeddingKnowledge(ctx context.Context, spaceID, knowledgeID string, migration types.EmbeddingMigration) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*5)
+	defer cancel()
+
+	knowledge, err := p.core.Store().KnowledgeStore().GetKnowledge(ctx, spaceID, knowledgeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	if knowledge.Stage != types.KNOWLEDGE_STAGE_DONE {
+		return nil
+	}
+
+	if knowledge.Content, err = p.core.DecryptData(knowledge.Content); err != nil {
+		return err
+	}
+
+	base, chunks, err := p.prepareEmbeddingChunks(ctx, knowledge)
+	if err != nil {
+		return err
+	}
+
+	vectors, err := p.embeddingVectors(ctx, migration.Driver, knowledge, base, chunks, nil)
+	if err != nil {
+		return err
+	}
+
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	return p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := p.core.Store().VectorStore().DeleteByModel(ctx, spaceID, knowledgeID, vectors[0].Model); err != nil {
+			return err
+		}
+		return p.core.Store().VectorStore().BatchCreate(ctx, vectors)
+	})
+}
+
+// updateEmbeddingSettings 更新空间的 embedding 配置，只写入 embedding 字段，不影响同时修改的其他配置
+// 迁移已被取消或已切换至其他模型时不做修改，返回 canceled = true
+func (p *KnowledgeProcess) updateEmbeddingSettings(ctx context.Context, spaceID string, migration types.EmbeddingMigration, update func(settings *types.EmbeddingSettings)) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	space, err := p.core.Store().SpaceStore().GetSpace(ctx, spaceID)
+	if err != nil {
+		return false, err
+	}
+
+	current := space.Settings.Embedding.Migration
+	if current == nil || current.Driver != migration.Driver || current.StartedAt != migration.StartedAt {
+		return true, nil
+	}
+
+	update(&space.Settings.Embedding)
+	// 读取之后迁移仍可能被取消，由 store 再次校验
+	updated, err := p.core.Store().SpaceStore().UpdateEmbeddingMigration(ctx, spaceID, migration, space.Settings.Embedding)
+	if err != nil {
+		return false, err
+	}
+	return !updated, nil
+}
+
+func (p *KnowledgeProcess) failEmbeddingMigration(spaceID string, migration types.EmbeddingMigration, cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if _, err := p.updateEmbeddingSettings(ctx, spaceID, migration, func(settings *types.EmbeddingSettings) {
+		settings.Migration.Status = types.EMBEDDING_MIGRATION_STATUS_FAILED
+		settings.Migration.Error = cause.Error()
+		settings.Migration.Cursor = migration.Cursor
+		settings.Migration.Processed = migration.Processed
+	}); err != nil {
+		slog.Error("Failed to set embedding migration failed", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+	}
+	return cause
+}
@@ -0,0 +1,72 @@
+package process
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func Test_migrateKnowledges(t *testing.T) {
+	all := []string{"k1", "k2", "k3", "k4", "k5"}
+	// 与 ListKnowledgeIDs 一致，按 id 顺序返回 afterID 之后的一页
+	list := func(afterID string, limit int) []string {
+		i := sort.SearchStrings(all, afterID)
+		if i < len(all) && all[i] == afterID {
+			i++
+		}
+		return all[i:min(i+limit, len(all))]
+	}
+
+	var (
+		migration = types.EmbeddingMigration{Driver: "openai", Model: "m2"}
+		migrated  []string
+		failOn    = "k4"
+		errFailed = errors.New("embedding failed")
+	)
+	run := func() error {
+		for {
+			ids := list(migration.Cursor, 2)
+			if len(ids) == 0 {
+				return nil
+			}
+			if err := migrateKnowledges(&migration, ids, func(id string) error {
+				if id == failOn {
+					return errFailed
+				}
+				migrated = append(migrated, id)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 失败时 cursor 停留在最后一个成功的知识点
+	assert.ErrorIs(t, run(), errFailed)
+	assert.Equal(t, "k3", migration.Cursor)
+	assert.Equal(t, 3, migration.Processed)
+
+	// 从 cursor 之后继续，已迁移的知识点不会重复处理
+	failOn = ""
+	assert.NoError(t, run())
+	assert.Equal(t, all, migrated)
+	assert.Equal(t, "k5", migration.Cursor)
+	assert.Equal(t, len(all), migration.Processed)
+}
+
+func Test_migratedEmbeddingSettings(t *testing.T) {
+	settings := migratedEmbeddingSettings(types.EmbeddingMigration{
+		Driver:    "openai",
+		Model:     "m2",
+		Dimension: 3,
+		Status:    types.EMBEDDING_MIGRATION_STATUS_RUNNING,
+		Cursor:    "k5",
+		Processed: 5,
+	})
+	assert.Equal(t, types.EmbeddingSettings{Driver: "openai", Model: "m2", Dimension: 3}, settings)
+	assert.False(t, settings.IsMigrating())
+}
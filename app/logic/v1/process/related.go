@@ -0,0 +1,135 @@
+package process
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/holdno/firetower/protocol"
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/app/core/srv"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+const (
+	// RELATED_QUERY_CHUNKS 计算相关知识点时最多使用的切片数量
+	RELATED_QUERY_CHUNKS = 10
+	// RELATED_QUERY_LIMIT 每个切片检索的相近切片数量
+	RELATED_QUERY_LIMIT = 30
+)
+
+// UpdateRelatedKnowledges 使用知识点已生成的向量检索同一空间中语义相近的知识点并保存，
+// vectors 需要为同一模型生成的向量，不需要额外请求 embedding 模型
+func UpdateRelatedKnowledges(ctx context.Context, core *core.Core, knowledge *types.Knowledge, vectors []types.Vector) ([]types.KnowledgeRelated, error) {
+	vectors = lo.Filter(vectors, func(item types.Vector, _ int) bool {
+		return len(item.Embedding) > 0
+	})
+	if len(vectors) > RELATED_QUERY_CHUNKS {
+		vectors = vectors[:RELATED_QUERY_CHUNKS]
+	}
+
+	var results [][]types.QueryResult
+	for _, v := range vectors {
+		res, err := core.Store().VectorStore().Query(ctx, types.GetVectorsOptions{
+			SpaceID:   knowledge.SpaceID,
+			Model:     v.Model,
+			Dimension: len(v.Embedding),
+		}, v.Embedding, RELATED_QUERY_LIMIT)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	related := aggregateRelated(knowledge.ID, results, types.RELATED_KNOWLEDGE_LIMIT, types.RELATED_KNOWLEDGE_MIN_SCORE)
+	now := time.Now().Unix()
+	data := make([]types.KnowledgeRelated, 0, len(related)*2)
+	for _, v := range related {
+		v.SpaceID = knowledge.SpaceID
+		v.CreatedAt = now
+		data = append(data, v)
+		// 相关关系是对称的，同时更新对方的相关知识点
+		data = append(data, types.KnowledgeRelated{
+			SpaceID:     knowledge.SpaceID,
+			KnowledgeID: v.RelatedID,
+			RelatedID:   knowledge.ID,
+			Score:       v.Score,
+			CreatedAt:   now,
+		})
+	}
+
+	err := core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := core.Store().KnowledgeRelatedStore().BatchDelete(ctx, knowledge.SpaceID, knowledge.ID); err != nil {
+			return err
+		}
+		return core.Store().KnowledgeRelatedStore().Upsert(ctx, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return related, nil
+}
+
+// aggregateRelated 合并多个切片的检索结果，知识点之间的相似度取切片之间最大的余弦相似度(1 - 余弦距离)
+func aggregateRelated(knowledgeID string, results [][]types.QueryResult, limit int, minScore float32) []types.KnowledgeRelated {
+	scores := make(map[string]float32)
+	for _, list := range results {
+		for _, v := range list {
+			if v.KnowledgeID == knowledgeID {
+				continue
+			}
+			score := 1 - v.Cos
+			if score < minScore {
+				continue
+			}
+			if score > scores[v.KnowledgeID] {
+				scores[v.KnowledgeID] = score
+			}
+		}
+	}
+
+	res := make([]types.KnowledgeRelated, 0, len(scores))
+	for id, score := range scores {
+		res = append(res, types.KnowledgeRelated{
+			KnowledgeID: knowledgeID,
+			RelatedID:   id,
+			Score:       score,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].RelatedID < res[j].RelatedID
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// publishRelatedMessage 推送知识点新的相关知识点
+func publishRelatedMessage(tower *srv.Tower, spaceID, knowledgeID string, related []types.KnowledgeRelated) {
+	fire := tower.NewFire(protocol.SourceSystem, tower.Pusher())
+	fire.Message = protocol.TopicMessage[srv.PublishData]{
+		Topic: "/knowledge/list/" + spaceID,
+		Type:  protocol.PublishOperation,
+		Data: srv.PublishData{
+			Version: "v1",
+			Subject: "related_changed",
+			Data: map[string]any{
+				"knowledge_id": knowledgeID,
+				"related": lo.Map(related, func(item types.KnowledgeRelated, _ int) map[string]any {
+					return map[string]any{
+						"knowledge_id": item.RelatedID,
+						"score":        item.Score,
+					}
+				}),
+			},
+		},
+	}
+
+	tower.Publish(fire)
+}
@@ -0,0 +1,32 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func Test_aggregateRelated(t *testing.T) {
+	results := [][]types.QueryResult{
+		{
+			{KnowledgeID: "self", Cos: 0},
+			{KnowledgeID: "a", Cos: 0.3},
+			{KnowledgeID: "b", Cos: 0.2},
+			{KnowledgeID: "far", Cos: 0.8},
+		},
+		{
+			{KnowledgeID: "a", Cos: 0.1},
+			{KnowledgeID: "c", Cos: 0.2},
+		},
+	}
+
+	got := aggregateRelated("self", results, 10, 0.5)
+	assert.Equal(t, []string{"a", "b", "c"}, []string{got[0].RelatedID, got[1].RelatedID, got[2].RelatedID})
+	assert.InDelta(t, 0.9, got[0].Score, 1e-6)
+	assert.Equal(t, "self", got[0].KnowledgeID)
+
+	assert.Len(t, aggregateRelated("self", results, 2, 0.5), 2)
+	assert.Empty(t, aggregateRelated("self", nil, 10, 0.5))
+}
@@ -0,0 +1,182 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/samber/lo"
+
+	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/pkg/dedup"
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+func init() {
+	register.RegisterFunc(ProcessKey{}, func(provider *Process) {
+		provider.Cron().AddFunc("0 6 * * 0", func() {
+			err := NewTagMergeProcess(provider.Core()).Flush(context.Background())
+			if err != nil {
+				slog.Error("Failed to generate tag merge proposals", slog.String("error", err.Error()))
+			} else {
+				slog.Info("Successfully generate tag merge proposals")
+			}
+		})
+	})
+}
+
+// TagMergeProcess 每周为开启了 suggest_merges 的空间生成标签合并建议，
+// 写法不同(大小写、空白)或向量相似度不低于 TAG_MERGE_MIN_SCORE 的标签建议合并为使用次数最多的标签
+type TagMergeProcess struct {
+	core *core.Core
+}
+
+func NewTagMergeProcess(core *core.Core) *TagMergeProcess {
+	return &TagMergeProcess{core: core}
+}
+
+func (p *TagMergeProcess) Flush(ctx context.Context) error {
+	spaces, err := p.core.Store().SpaceStore().ListTagMergeEnabled(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	for _, v := range spaces {
+		if err = p.Propose(ctx, v); err != nil {
+			slog.Error("Failed to generate tag merge proposals", slog.String("space_id", v.SpaceID), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// Propose 重新生成空间的标签合并建议
+func (p *TagMergeProcess) Propose(ctx context.Context, space types.Space) error {
+	tags, err := p.core.Store().KnowledgeStore().ListTags(ctx, space.SpaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if len(tags) > types.TAG_MERGE_MAX_TAGS {
+		tags = tags[:types.TAG_MERGE_MAX_TAGS]
+	}
+
+	var vectors [][]float32
+	if len(tags) > 1 {
+		if vectors, err = p.embeddingTags(ctx, space, tags); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().Unix()
+	proposals := clusterTags(tags, vectors, types.TAG_MERGE_MIN_SCORE)
+	for i := range proposals {
+		proposals[i].ID = utils.GenRandomID()
+		proposals[i].SpaceID = space.SpaceID
+		proposals[i].CreatedAt = now
+	}
+
+	return p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := p.core.Store().KnowledgeTagMergeStore().DeleteAll(ctx, space.SpaceID); err != nil {
+			return err
+		}
+		return p.core.Store().KnowledgeTagMergeStore().BatchCreate(ctx, proposals)
+	})
+}
+
+func (p *TagMergeProcess) embeddingTags(ctx context.Context, space types.Space, tags []types.KnowledgeTag) ([][]float32, error) {
+	d, ok := p.core.Srv().AI().EmbeddingWith(space.Settings.Embedding.Driver)
+	if !ok {
+		return nil, fmt.Errorf("embedding driver %s not found", space.Settings.Embedding.Driver)
+	}
+
+	vectors := make([][]float32, 0, len(tags))
+	for _, batch := range lo.Chunk(tags, EMBEDDING_BATCH_SIZE) {
+		res, err := d.EmbeddingForQuery(ctx, lo.Map(batch, func(item types.KnowledgeTag, _ int) string {
+			return item.Tag
+		}))
+		if err != nil {
+			return nil, err
+		}
+		if res.Usage != nil {
+			NewRecordUsageRequest(res.Model, types.USAGE_TYPE_KNOWLEDGE, types.USAGE_SUB_TYPE_EMBEDDING, space.SpaceID, "", res.Usage)
+		}
+		if len(res.Data) != len(batch) {
+			return nil, fmt.Errorf("embedding result length not match, tags: %d, results: %d", len(batch), len(res.Data))
+		}
+		vectors = append(vectors, res.Data...)
+	}
+	return vectors, nil
+}
+
+// clusterTags 将写法相同(忽略大小写及空白)或余弦相似度不低于 minScore 的标签连接为一组，间接相连的标签属于同一组
+// vectors 与 tags 一一对应，为空时只按照写法分组，每组保留使用次数最多的标签
+func clusterTags(tags []types.KnowledgeTag, vectors [][]float32, minScore float32) []types.KnowledgeTagMerge {
+	var (
+		pairs  [][2]string
+		scores = make(map[[2]string]float32)
+	)
+	for i := range tags {
+		for j := i + 1; j < len(tags); j++ {
+			score := float32(0)
+			if dedup.Normalize(tags[i].Tag) == dedup.Normalize(tags[j].Tag) {
+				score = 1
+			} else if len(vectors) == len(tags) {
+				score = cosine(vectors[i], vectors[j])
+			}
+			if score < minScore {
+				continue
+			}
+			pair := [2]string{tags[i].Tag, tags[j].Tag}
+			pairs = append(pairs, pair)
+			scores[pair] = score
+		}
+	}
+
+	counts := lo.SliceToMap(tags, func(item types.KnowledgeTag) (string, int64) {
+		return item.Tag, item.Count
+	})
+	var res []types.KnowledgeTagMerge
+	for _, group := range dedup.Group(pairs) {
+		sort.SliceStable(group, func(i, j int) bool {
+			return counts[group[i]] > counts[group[j]]
+		})
+
+		member := lo.SliceToMap(group, func(item string) (string, bool) {
+			return item, true
+		})
+		score := float32(1)
+		for pair, v := range scores {
+			if member[pair[0]] && v < score {
+				score = v
+			}
+		}
+
+		res = append(res, types.KnowledgeTagMerge{
+			Tags:   pq.StringArray(group),
+			Target: group[0],
+			Score:  score,
+		})
+	}
+	return res
+}
+
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(na) * math.Sqrt(nb)))
+}
@@ -0,0 +1,42 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func Test_clusterTags(t *testing.T) {
+	tags := []types.KnowledgeTag{
+		{Tag: "golang", Count: 5},
+		{Tag: "Go", Count: 8},
+		{Tag: "go", Count: 2},
+		{Tag: "docker", Count: 3},
+		{Tag: "cooking", Count: 1},
+	}
+	vectors := [][]float32{
+		{1, 0.1, 0},
+		{0, 1, 0},
+		{0, 1, 0},
+		{0, 0, 1},
+		{0.7, -0.7, 0.1},
+	}
+	// golang 与 Go/go 的向量不相近，只按写法合并
+	assert.Equal(t, []types.KnowledgeTagMerge{
+		{Tags: pq.StringArray{"Go", "go"}, Target: "Go", Score: 1},
+	}, clusterTags(tags, vectors, 0.9))
+
+	vectors[0] = []float32{0.1, 1, 0}
+	res := clusterTags(tags, vectors, 0.9)
+	assert.Len(t, res, 1)
+	assert.Equal(t, pq.StringArray{"Go", "golang", "go"}, res[0].Tags)
+	assert.Equal(t, "Go", res[0].Target)
+	assert.InDelta(t, 0.995, res[0].Score, 0.001)
+
+	// 没有向量时只按照写法分组
+	assert.Len(t, clusterTags(tags, nil, 0.9), 1)
+	assert.Nil(t, clusterTags(tags[3:], vectors[3:], 0.9))
+}
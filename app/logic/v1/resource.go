@@ -89,6 +89,18 @@ func (l *ResourceLogic) Delete(spaceID, id string) error {
 			return errors.New("ResourceLogic.Delete.KnowledgeChunkStore.BatchDeleteByIDs", i18n.ERROR_INTERNAL, err)
 		}
 
+		if err = l.core.Store().KnowledgeLinkStore().BatchDeleteByIDs(ctx, knowledgeIDs); err != nil {
+			return errors.New("ResourceLogic.Delete.KnowledgeLinkStore.BatchDeleteByIDs", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err = l.core.Store().KnowledgeRelatedStore().BatchDeleteByIDs(ctx, knowledgeIDs); err != nil {
+			return errors.New("ResourceLogic.Delete.KnowledgeRelatedStore.BatchDeleteByIDs", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err = l.core.Store().KnowledgeDuplicateStore().BatchDeleteByIDs(ctx, knowledgeIDs); err != nil {
+			return errors.New("ResourceLogic.Delete.KnowledgeDuplicateStore.BatchDeleteByIDs", i18n.ERROR_INTERNAL, err)
+		}
+
 		if err = l.core.Store().VectorStore().DeleteByResource(ctx, spaceID, id); err != nil {
 			return errors.New("ResourceLogic.Delete.VectorStore.DeleteByResource", i18n.ERROR_INTERNAL, err)
 		}
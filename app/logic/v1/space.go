@@ -6,8 +6,12 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/samber/lo"
+
 	"github.com/breeew/brew-api/app/core"
 	"github.com/breeew/brew-api/app/core/srv"
+	"github.com/breeew/brew-api/app/logic/v1/process"
+	"github.com/breeew/brew-api/pkg/chunker"
 	"github.com/breeew/brew-api/pkg/errors"
 	"github.com/breeew/brew-api/pkg/i18n"
 	"github.com/breeew/brew-api/pkg/types"
@@ -155,6 +159,106 @@ func (l *SpaceLogic) UpdateSpace(spaceID, title, desc string) error {
 	return nil
 }
 
+// UpdateSpaceSettings 更新空间配置，如混合检索的融合权重
+func (l *SpaceLogic) UpdateSpaceSettings(spaceID string, settings types.SpaceSettings) error {
+	retrieval := settings.Retrieval
+	if retrieval.VectorWeight < 0 || retrieval.KeywordWeight < 0 || retrieval.RRFK < 0 {
+		return errors.New("SpaceLogic.UpdateSpaceSettings.Verify", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	for _, v := range append([]types.ChunkSettings{settings.Chunk}, lo.Values(settings.ResourceChunk)...) {
+		if !chunker.IsValidStrategy(v.Strategy) || v.ChunkSize < 0 || v.Overlap < 0 || (v.ChunkSize > 0 && v.Overlap >= v.ChunkSize) {
+			return errors.New("SpaceLogic.UpdateSpaceSettings.VerifyChunk", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+		}
+	}
+
+	space, err := l.core.Store().SpaceStore().GetSpace(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("SpaceLogic.UpdateSpaceSettings.SpaceStore.GetSpace", i18n.ERROR_INTERNAL, err)
+	}
+
+	if space == nil {
+		return errors.New("SpaceLogic.UpdateSpaceSettings.SpaceStore.GetSpace.nil", i18n.ERROR_NOT_FOUND, nil).Code(http.StatusNotFound)
+	}
+
+	// embedding 配置由模型迁移任务维护，这里只更新检索、切片及标签配置
+	space.Settings.Retrieval = retrieval
+	space.Settings.Chunk = settings.Chunk
+	space.Settings.ResourceChunk = settings.ResourceChunk
+	space.Settings.Tags = settings.Tags
+	if err = l.core.Store().SpaceStore().UpdateSettings(l.ctx, spaceID, space.Settings); err != nil {
+		return errors.New("SpaceLogic.UpdateSpaceSettings.SpaceStore.UpdateSettings", i18n.ERROR_INTERNAL, err)
+	}
+
+	return nil
+}
+
+// embeddingProbeText 用于探测 embedding 模型名称及维度的文本
+const embeddingProbeText = "brew"
+
+// MigrateSpaceEmbedding 将空间的向量迁移至指定的 embedding 驱动，迁移在后台进行，完成前旧模型的向量继续提供检索
+// 相同驱动的迁移失败后再次调用会从上次中断的位置继续
+func (l *SpaceLogic) MigrateSpaceEmbedding(spaceID, driver string) error {
+	space, err := l.core.Store().SpaceStore().GetSpace(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("SpaceLogic.MigrateSpaceEmbedding.SpaceStore.GetSpace", i18n.ERROR_INTERNAL, err)
+	}
+
+	if space == nil {
+		return errors.New("SpaceLogic.MigrateSpaceEmbedding.SpaceStore.GetSpace.nil", i18n.ERROR_NOT_FOUND, nil).Code(http.StatusNotFound)
+	}
+
+	settings := &space.Settings.Embedding
+	if settings.IsMigrating() {
+		return errors.New("SpaceLogic.MigrateSpaceEmbedding.IsMigrating", i18n.ERROR_EXIST, nil).Code(http.StatusConflict)
+	}
+
+	target, ok := l.core.Srv().AI().EmbeddingWith(driver)
+	if !ok {
+		return errors.New("SpaceLogic.MigrateSpaceEmbedding.AI.EmbeddingWith", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	// 探测新模型的名称及维度，同时验证驱动可用
+	probe, err := target.EmbeddingForDocument(l.ctx, "", []string{embeddingProbeText})
+	if err != nil || len(probe.Data) == 0 {
+		return errors.New("SpaceLogic.MigrateSpaceEmbedding.AI.EmbeddingForDocument", i18n.ERROR_INTERNAL, err)
+	}
+
+	if settings.Model == "" {
+		// 固定当前提供检索的模型，避免迁移期间与新模型的向量混在一起检索
+		serving, ok := l.core.Srv().AI().EmbeddingWith(settings.Driver)
+		if !ok {
+			serving = l.core.Srv().AI()
+		}
+		current, err := serving.EmbeddingForDocument(l.ctx, "", []string{embeddingProbeText})
+		if err != nil || len(current.Data) == 0 {
+			return errors.New("SpaceLogic.MigrateSpaceEmbedding.AI.EmbeddingForDocument", i18n.ERROR_INTERNAL, err)
+		}
+		settings.Model = current.Model
+		settings.Dimension = len(current.Data[0])
+	}
+
+	migration := &types.EmbeddingMigration{
+		Driver:    driver,
+		Model:     probe.Model,
+		Dimension: len(probe.Data[0]),
+		Status:    types.EMBEDDING_MIGRATION_STATUS_RUNNING,
+		StartedAt: time.Now().Unix(),
+	}
+	if settings.Migration != nil && settings.Migration.Driver == driver && settings.Migration.Model == probe.Model {
+		migration.Cursor = settings.Migration.Cursor
+		migration.Processed = settings.Migration.Processed
+	}
+	settings.Migration = migration
+
+	if err = l.core.Store().SpaceStore().UpdateSettings(l.ctx, spaceID, space.Settings); err != nil {
+		return errors.New("SpaceLogic.MigrateSpaceEmbedding.SpaceStore.UpdateSettings", i18n.ERROR_INTERNAL, err)
+	}
+
+	process.NewReEmbeddingRequest(spaceID)
+	return nil
+}
+
 func (l *SpaceLogic) LeaveSpace(spaceID string) error {
 	user := l.GetUserInfo()
 
@@ -225,6 +329,34 @@ func (l *SpaceLogic) DeleteUserSpace(spaceID string) error {
 			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeChunkStore.DeleteAll", i18n.ERROR_INTERNAL, err)
 		}
 
+		if err := l.core.Store().KnowledgeProgressStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeProgressStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeCrawlStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeCrawlStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeRevisionStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeRevisionStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeLinkStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeLinkStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeRelatedStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeRelatedStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeDuplicateStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeDuplicateStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeTagMergeStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeTagMergeStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
 		if err := l.core.Store().VectorStore().DeleteAll(ctx, spaceID); err != nil {
 			return errors.New("SpaceLogic.DeleteUserSpace.VectorStore.DeleteAll", i18n.ERROR_INTERNAL, err)
 		}
@@ -284,6 +416,7 @@ func (l *SpaceLogic) ListUserSpace() ([]types.UserSpaceDetail, error) {
 			Title:       v.Title,
 			Role:        spaceRoleMap[v.SpaceID],
 			Description: v.Description,
+			Settings:    v.Settings,
 			CreatedAt:   v.CreatedAt,
 		})
 	}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -67,6 +68,9 @@ func randomFileName(fileName string) string {
 func (l *UploadLogic) GenClientUploadKey(objectType, kind, fileName string, size int64) (UploadKey, error) {
 	userID := l.UserInfo.GetUserInfo().User
 	spaceID, _ := InjectSpaceID(l.ctx)
+	if !isValidObjectType(objectType) {
+		return UploadKey{}, errors.New("UploadLogic.GenClientUploadKey.ObjectType", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
 	filePath := genUserFilePath(spaceID, objectType)
 	fileName = randomFileName(fileName)
 
@@ -121,6 +125,13 @@ func (l *UploadLogic) GenClientUploadKey(objectType, kind, fileName string, size
 	}, nil
 }
 
+// objectTypeRegexp object_type 作为存储路径的一部分，只允许字母、数字、下划线及中划线
+var objectTypeRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func isValidObjectType(v string) bool {
+	return objectTypeRegexp.MatchString(v)
+}
+
 func genUserFilePath(userID, _type string) string {
 	return filepath.Join("/brew/", userID, _type, time.Now().Format("20060102"))
 }
@@ -92,6 +92,12 @@ func APIError(c *gin.Context, err error) {
 	printErrorLog(c, res, err)
 }
 
+// APIErrorWithData api响应失败，同时返回帮助调用方处理错误的数据
+func APIErrorWithData(c *gin.Context, err error, data interface{}) {
+	c.MustGet(ResponseKey).(*Response).Data = data
+	APIError(c, err)
+}
+
 func printErrorLog(c *gin.Context, res *Response, err error) {
 	endTime := time.Now().Unix()
 	// 统一打印日志
@@ -0,0 +1,121 @@
+package qdrant
+
+// provider for https://qdrant.tech/
+// 通过 qdrant 的 REST API 实现 store.VectorStore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	NAME = "qdrant"
+
+	DEFAULT_COLLECTION = "bw_vectors"
+	DEFAULT_DIMENSION  = 1024
+)
+
+type Config struct {
+	Endpoint   string `toml:"endpoint"`   // eg: http://127.0.0.1:6333
+	APIKey     string `toml:"api_key"`    // qdrant cloud / 开启鉴权时使用
+	Collection string `toml:"collection"` // default: bw_vectors
+	Dimension  int    `toml:"dimension"`  // default: 1024, 需要与 embedding 模型输出的维度保持一致
+	Timeout    int    `toml:"timeout"`    // 请求超时时间，单位秒，default: 10
+}
+
+func (c *Config) FromENV() {
+	c.Endpoint = os.Getenv("BREW_API_VECTOR_DB_QDRANT_ENDPOINT")
+	c.APIKey = os.Getenv("BREW_API_VECTOR_DB_QDRANT_API_KEY")
+	c.Collection = os.Getenv("BREW_API_VECTOR_DB_QDRANT_COLLECTION")
+}
+
+type Client struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+}
+
+func NewClient(endpoint, apiKey string, timeout time.Duration) *Client {
+	return &Client{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		apiKey:   apiKey,
+	}
+}
+
+// response qdrant 接口的统一返回结构
+// 成功时 status 为 "ok"，失败时为 {"error": "..."}
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Status json.RawMessage `json:"status"`
+	Time   float64         `json:"time"`
+}
+
+type statusError struct {
+	Error string `json:"error"`
+}
+
+// ErrNotFound qdrant 返回 404，例如 collection 不存在
+var ErrNotFound = fmt.Errorf("qdrant: not found")
+
+func (c *Client) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal qdrant request body: %w", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("api-key", c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to request qdrant: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	var res response
+	if err = json.Unmarshal(raw, &res); err != nil {
+		return fmt.Errorf("Failed to unmarshal qdrant response, status %d: %s", resp.StatusCode, string(raw))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var se statusError
+		if err = json.Unmarshal(res.Status, &se); err == nil && se.Error != "" {
+			return fmt.Errorf("qdrant error, status %d: %s", resp.StatusCode, se.Error)
+		}
+		return fmt.Errorf("qdrant error, status %d: %s", resp.StatusCode, string(raw))
+	}
+
+	if result != nil && len(res.Result) > 0 {
+		if err = json.Unmarshal(res.Result, result); err != nil {
+			return fmt.Errorf("Failed to unmarshal qdrant result: %w", err)
+		}
+	}
+	return nil
+}
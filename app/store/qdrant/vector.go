@@ -0,0 +1,552 @@
+package qdrant
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/breeew/brew-api/app/store"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+var _ store.VectorStore = (*VectorStore)(nil)
+
+// VectorStore 基于 qdrant 的向量存储
+// 注意 qdrant 不参与 postgres 的事务，写入操作需要保持幂等(先 BatchDelete 再 BatchCreate)
+// qdrant 的 collection 只能存储固定维度的向量，默认维度使用 collection 本身，其他维度的向量写入 <collection>_<dimension>
+type VectorStore struct {
+	client     *Client
+	collection string
+	dimension  int
+
+	mu          sync.RWMutex
+	collections map[int]string // dimension -> 已存在的 collection
+
+	resolveKnowledges KnowledgeResolver
+}
+
+// KnowledgeResolver 返回空间中满足筛选条件的知识点ID
+// qdrant 的 payload 中没有标签、时间等知识点元数据，GetVectorsOptions.Knowledge 需要先在 postgres 中筛选出知识点
+type KnowledgeResolver func(ctx context.Context, spaceID string, filter *types.KnowledgeFilter) ([]string, error)
+
+func NewVectorStore(cfg Config) *VectorStore {
+	if cfg.Collection == "" {
+		cfg.Collection = DEFAULT_COLLECTION
+	}
+	if cfg.Dimension == 0 {
+		cfg.Dimension = DEFAULT_DIMENSION
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10
+	}
+	return &VectorStore{
+		client:      NewClient(cfg.Endpoint, cfg.APIKey, time.Duration(cfg.Timeout)*time.Second),
+		collection:  cfg.Collection,
+		dimension:   cfg.Dimension,
+		collections: make(map[int]string),
+	}
+}
+
+// SetKnowledgeResolver 设置知识点筛选条件的解析函数，未设置时不支持 GetVectorsOptions.Knowledge
+func (s *VectorStore) SetKnowledgeResolver(fn KnowledgeResolver) {
+	s.resolveKnowledges = fn
+}
+
+func (s *VectorStore) GetTable(...interface{}) string {
+	return s.collection
+}
+
+func (s *VectorStore) collectionName(dimension int) string {
+	if dimension == s.dimension {
+		return s.collection
+	}
+	return fmt.Sprintf("%s_%d", s.collection, dimension)
+}
+
+func collectionPath(collection, sub string) string {
+	return "/collections/" + collection + sub
+}
+
+type collectionsResult struct {
+	Collections []struct {
+		Name string `json:"name"`
+	} `json:"collections"`
+}
+
+// Install 初始化默认维度的 collection 及 payload 索引，并加载已存在的其他维度的 collection
+func (s *VectorStore) Install(ctx context.Context) error {
+	var res collectionsResult
+	if err := s.client.do(ctx, http.MethodGet, "/collections", nil, &res); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, v := range res.Collections {
+		if v.Name == s.collection {
+			s.collections[s.dimension] = v.Name
+			continue
+		}
+		suffix, ok := strings.CutPrefix(v.Name, s.collection+"_")
+		if !ok {
+			continue
+		}
+		if dimension, err := strconv.Atoi(suffix); err == nil && dimension > 0 {
+			s.collections[dimension] = v.Name
+		}
+	}
+	s.mu.Unlock()
+
+	_, err := s.ensureCollection(ctx, s.dimension)
+	return err
+}
+
+// ensureCollection 返回指定维度对应的 collection，不存在时创建
+func (s *VectorStore) ensureCollection(ctx context.Context, dimension int) (string, error) {
+	if dimension <= 0 {
+		return "", fmt.Errorf("qdrant: invalid vector dimension %d", dimension)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name, ok := s.collections[dimension]; ok {
+		return name, nil
+	}
+
+	name := s.collectionName(dimension)
+	err := s.client.do(ctx, http.MethodGet, collectionPath(name, ""), nil, nil)
+	if err != nil && err != ErrNotFound {
+		return "", err
+	}
+
+	if err == ErrNotFound {
+		if err = s.client.do(ctx, http.MethodPut, collectionPath(name, ""), map[string]any{
+			"vectors": map[string]any{
+				"size":     dimension,
+				"distance": "Cosine",
+			},
+		}, nil); err != nil {
+			return "", fmt.Errorf("Failed to create qdrant collection: %w", err)
+		}
+
+		for field, schema := range map[string]string{
+			"space_id":     "keyword",
+			"knowledge_id": "keyword",
+			"user_id":      "keyword",
+			"resource":     "keyword",
+			"model":        "keyword",
+			"created_at":   "integer",
+		} {
+			if err = s.client.do(ctx, http.MethodPut, collectionPath(name, "/index?wait=true"), map[string]any{
+				"field_name":   field,
+				"field_schema": schema,
+			}, nil); err != nil {
+				return "", fmt.Errorf("Failed to create qdrant payload index %s: %w", field, err)
+			}
+		}
+	}
+
+	s.collections[dimension] = name
+	return name, nil
+}
+
+// existCollections 返回已存在的 collection，dimension 大于 0 时仅返回该维度的 collection
+func (s *VectorStore) existCollections(dimension int) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if dimension > 0 {
+		if name, ok := s.collections[dimension]; ok {
+			return []string{name}
+		}
+		return nil
+	}
+
+	res := make([]string, 0, len(s.collections))
+	for _, name := range s.collections {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// pointID qdrant 仅支持 uint64 或 uuid 作为 point id，这里由业务 id 及模型生成固定的 uuid
+// model 为空时与历史数据保持一致
+func pointID(id, model string) string {
+	if model != "" {
+		id = model + "/" + id
+	}
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(id)).String()
+}
+
+type point struct {
+	ID      string    `json:"id"`
+	Vector  []float32 `json:"vector,omitempty"`
+	Payload payload   `json:"payload"`
+}
+
+type payload struct {
+	ID             string `json:"id"`
+	KnowledgeID    string `json:"knowledge_id"`
+	SpaceID        string `json:"space_id"`
+	UserID         string `json:"user_id"`
+	Resource       string `json:"resource"`
+	Model          string `json:"model"`
+	Dimension      int    `json:"dimension"`
+	OriginalLength int    `json:"original_length"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+func (p point) toVector() types.Vector {
+	return types.Vector{
+		ID:             p.Payload.ID,
+		KnowledgeID:    p.Payload.KnowledgeID,
+		SpaceID:        p.Payload.SpaceID,
+		UserID:         p.Payload.UserID,
+		Resource:       p.Payload.Resource,
+		Model:          p.Payload.Model,
+		Dimension:      p.Payload.Dimension,
+		Embedding:      p.Vector,
+		OriginalLength: p.Payload.OriginalLength,
+		CreatedAt:      p.Payload.CreatedAt,
+		UpdatedAt:      p.Payload.UpdatedAt,
+	}
+}
+
+type condition struct {
+	Key   string `json:"key"`
+	Match match  `json:"match"`
+}
+
+type match struct {
+	Value string   `json:"value,omitempty"`
+	Any   []string `json:"any,omitempty"`
+}
+
+type filter struct {
+	Must    []condition `json:"must,omitempty"`
+	MustNot []condition `json:"must_not,omitempty"`
+}
+
+func (f *filter) eq(key, value string) *filter {
+	if value != "" {
+		f.Must = append(f.Must, condition{Key: key, Match: match{Value: value}})
+	}
+	return f
+}
+
+// buildFilter 将 GetVectorsOptions 转换为 qdrant 的 payload filter
+func buildFilter(opts types.GetVectorsOptions) *filter {
+	f := &filter{}
+	f.eq("id", opts.ID).
+		eq("knowledge_id", opts.KnowledgeID).
+		eq("space_id", opts.SpaceID).
+		eq("user_id", opts.UserID)
+
+	if opts.Resource != nil {
+		if len(opts.Resource.Include) > 0 {
+			f.Must = append(f.Must, condition{Key: "resource", Match: match{Any: opts.Resource.Include}})
+		} else if len(opts.Resource.Exclude) > 0 {
+			f.MustNot = append(f.MustNot, condition{Key: "resource", Match: match{Any: opts.Resource.Exclude}})
+		}
+	}
+	if opts.Model != "" {
+		f.Must = append(f.Must, condition{Key: "model", Match: match{Any: []string{opts.Model, ""}}})
+	}
+	return f
+}
+
+// buildQueryFilter 在 buildFilter 的基础上将知识点筛选条件解析为 knowledge_id 列表，没有满足条件的知识点时 ok 为 false
+func (s *VectorStore) buildQueryFilter(ctx context.Context, opts types.GetVectorsOptions) (f *filter, ok bool, err error) {
+	f = buildFilter(opts)
+	if opts.Knowledge.IsEmpty() {
+		return f, true, nil
+	}
+	if s.resolveKnowledges == nil {
+		return nil, false, fmt.Errorf("qdrant: knowledge filter is not supported without a knowledge resolver")
+	}
+	ids, err := s.resolveKnowledges(ctx, opts.SpaceID, opts.Knowledge)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(ids) == 0 {
+		return nil, false, nil
+	}
+	f.Must = append(f.Must, condition{Key: "knowledge_id", Match: match{Any: ids}})
+	return f, true, nil
+}
+
+// Create 创建新的文本向量记录
+func (s *VectorStore) Create(ctx context.Context, data types.Vector) error {
+	return s.BatchCreate(ctx, []types.Vector{data})
+}
+
+// BatchCreate 批量创建新的文本向量记录，id 已存在时覆盖
+// 不同维度的向量写入各自的 collection
+func (s *VectorStore) BatchCreate(ctx context.Context, datas []types.Vector) error {
+	if len(datas) == 0 {
+		return nil
+	}
+
+	var (
+		dimensions []int
+		points     = make(map[int][]point)
+	)
+	for _, data := range datas {
+		if data.CreatedAt == 0 {
+			data.CreatedAt = time.Now().Unix()
+		}
+		if data.UpdatedAt == 0 {
+			data.UpdatedAt = time.Now().Unix()
+		}
+		dimension := len(data.Embedding)
+		if _, ok := points[dimension]; !ok {
+			dimensions = append(dimensions, dimension)
+		}
+		points[dimension] = append(points[dimension], point{
+			ID:     pointID(data.ID, data.Model),
+			Vector: data.Embedding,
+			Payload: payload{
+				ID:             data.ID,
+				KnowledgeID:    data.KnowledgeID,
+				SpaceID:        data.SpaceID,
+				UserID:         data.UserID,
+				Resource:       data.Resource,
+				Model:          data.Model,
+				Dimension:      dimension,
+				OriginalLength: data.OriginalLength,
+				CreatedAt:      data.CreatedAt,
+				UpdatedAt:      data.UpdatedAt,
+			},
+		})
+	}
+
+	for _, dimension := range dimensions {
+		collection, err := s.ensureCollection(ctx, dimension)
+		if err != nil {
+			return err
+		}
+		if err = s.client.do(ctx, http.MethodPut, collectionPath(collection, "/points?wait=true"), map[string]any{
+			"points": points[dimension],
+		}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type scrollResult struct {
+	Points []point `json:"points"`
+}
+
+func (s *VectorStore) scroll(ctx context.Context, collection string, f *filter, limit uint64) ([]point, error) {
+	var res scrollResult
+	err := s.client.do(ctx, http.MethodPost, collectionPath(collection, "/points/scroll"), map[string]any{
+		"filter":       f,
+		"limit":        limit,
+		"with_payload": true,
+		"with_vector":  true,
+		"order_by": map[string]any{
+			"key":       "created_at",
+			"direction": "desc",
+		},
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Points, nil
+}
+
+// scrollAll 在所有匹配维度的 collection 中查找，合并后按 created_at 降序返回
+func (s *VectorStore) scrollAll(ctx context.Context, opts types.GetVectorsOptions, limit uint64) ([]point, error) {
+	f, ok, err := s.buildQueryFilter(ctx, opts)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var res []point
+	for _, collection := range s.existCollections(opts.Dimension) {
+		points, err := s.scroll(ctx, collection, f, limit)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, points...)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Payload.CreatedAt > res[j].Payload.CreatedAt
+	})
+	if uint64(len(res)) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// GetVector 获取知识点的任意一条向量记录，不存在时返回 sql.ErrNoRows 以与 sqlstore 保持一致
+func (s *VectorStore) GetVector(ctx context.Context, spaceID, knowledgeID string) (*types.Vector, error) {
+	points, err := s.scrollAll(ctx, types.GetVectorsOptions{SpaceID: spaceID, KnowledgeID: knowledgeID}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	res := points[0].toVector()
+	return &res, nil
+}
+
+// Update 更新文本向量记录，仅更新与 vector 维度相同的记录
+func (s *VectorStore) Update(ctx context.Context, spaceID, knowledgeID, id, model string, vector []float32) error {
+	collections := s.existCollections(len(vector))
+	if len(collections) == 0 {
+		return nil
+	}
+	collection := collections[0]
+
+	// 同一个 id 的向量每个模型各有一条，这里需要精确匹配模型
+	f := buildFilter(types.GetVectorsOptions{ID: id, SpaceID: spaceID, KnowledgeID: knowledgeID})
+	f.Must = append(f.Must, condition{Key: "model", Match: match{Any: []string{model}}})
+	exist, err := s.scroll(ctx, collection, f, 1)
+	if err != nil {
+		return err
+	}
+	if len(exist) == 0 {
+		return nil
+	}
+
+	if err = s.client.do(ctx, http.MethodPut, collectionPath(collection, "/points/vectors?wait=true"), map[string]any{
+		"points": []point{{ID: exist[0].ID, Vector: vector}},
+	}, nil); err != nil {
+		return err
+	}
+
+	return s.client.do(ctx, http.MethodPost, collectionPath(collection, "/points/payload?wait=true"), map[string]any{
+		"payload": map[string]any{"updated_at": time.Now().Unix()},
+		"points":  []string{exist[0].ID},
+	}, nil)
+}
+
+func (s *VectorStore) deleteByFilter(ctx context.Context, f *filter) error {
+	for _, collection := range s.existCollections(0) {
+		if err := s.client.do(ctx, http.MethodPost, collectionPath(collection, "/points/delete?wait=true"), map[string]any{
+			"filter": f,
+		}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete 删除文本向量记录
+func (s *VectorStore) Delete(ctx context.Context, spaceID, knowledgeID, id string) error {
+	return s.deleteByFilter(ctx, buildFilter(types.GetVectorsOptions{ID: id, SpaceID: spaceID, KnowledgeID: knowledgeID}))
+}
+
+func (s *VectorStore) DeleteByResource(ctx context.Context, spaceID, resource string) error {
+	return s.deleteByFilter(ctx, buildFilter(types.GetVectorsOptions{
+		SpaceID:  spaceID,
+		Resource: &types.ResourceQuery{Include: []string{resource}},
+	}))
+}
+
+func (s *VectorStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
+	return s.deleteByFilter(ctx, buildFilter(types.GetVectorsOptions{SpaceID: spaceID, KnowledgeID: knowledgeID}))
+}
+
+// DeleteByModel 删除指定模型生成的向量，model 可能为空(历史数据)，因此使用 any 匹配，knowledgeID 为空时删除整个空间下该模型的向量
+func (s *VectorStore) DeleteByModel(ctx context.Context, spaceID, knowledgeID, model string) error {
+	if spaceID == "" {
+		return fmt.Errorf("qdrant: empty space id")
+	}
+	f := buildFilter(types.GetVectorsOptions{SpaceID: spaceID, KnowledgeID: knowledgeID})
+	f.Must = append(f.Must, condition{Key: "model", Match: match{Any: []string{model}}})
+	return s.deleteByFilter(ctx, f)
+}
+
+// PruneModels 删除空间下除 keepModel 以外的所有模型生成的向量
+func (s *VectorStore) PruneModels(ctx context.Context, spaceID, keepModel string) error {
+	if spaceID == "" {
+		return fmt.Errorf("qdrant: empty space id")
+	}
+	f := buildFilter(types.GetVectorsOptions{SpaceID: spaceID})
+	f.MustNot = append(f.MustNot, condition{Key: "model", Match: match{Any: []string{keepModel}}})
+	return s.deleteByFilter(ctx, f)
+}
+
+func (s *VectorStore) DeleteAll(ctx context.Context, spaceID string) error {
+	if spaceID == "" {
+		return fmt.Errorf("qdrant: empty space id")
+	}
+	return s.deleteByFilter(ctx, buildFilter(types.GetVectorsOptions{SpaceID: spaceID}))
+}
+
+// ListVectors 分页获取文本向量记录列表
+// qdrant 的 scroll 在排序时不支持 offset，这里取前 page*pageSize 条后截取
+func (s *VectorStore) ListVectors(ctx context.Context, opts types.GetVectorsOptions, page, pageSize uint64) ([]types.Vector, error) {
+	if page == 0 {
+		page = 1
+	}
+	points, err := s.scrollAll(ctx, opts, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (page - 1) * pageSize
+	if uint64(len(points)) <= offset {
+		return nil, nil
+	}
+
+	var res []types.Vector
+	for _, v := range points[offset:] {
+		res = append(res, v.toVector())
+	}
+	return res, nil
+}
+
+type scoredPoint struct {
+	ID      string  `json:"id"`
+	Score   float32 `json:"score"`
+	Payload payload `json:"payload"`
+}
+
+// Query 向量检索，只在与 vectors 维度相同的 collection 中检索
+// qdrant 的 Cosine 返回的是相似度，这里转换为与 pgvector <=> 一致的余弦距离(1 - similarity)，结果按距离升序
+func (s *VectorStore) Query(ctx context.Context, opts types.GetVectorsOptions, vectors []float32, limit uint64) ([]types.QueryResult, error) {
+	collections := s.existCollections(len(vectors))
+	if len(collections) == 0 {
+		return nil, nil
+	}
+	f, ok, err := s.buildQueryFilter(ctx, opts)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var points []scoredPoint
+	err = s.client.do(ctx, http.MethodPost, collectionPath(collections[0], "/points/search"), map[string]any{
+		"vector":       vectors,
+		"filter":       f,
+		"limit":        limit,
+		"with_payload": true,
+	}, &points)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]types.QueryResult, 0, len(points))
+	for _, v := range points {
+		res = append(res, types.QueryResult{
+			ID:             v.Payload.ID,
+			KnowledgeID:    v.Payload.KnowledgeID,
+			Cos:            1 - v.Score,
+			OriginalLength: v.Payload.OriginalLength,
+		})
+	}
+	return res, nil
+}
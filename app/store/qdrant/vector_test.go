@@ -0,0 +1,515 @@
+package qdrant_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/app/store/qdrant"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// fakeQdrant 内存版 qdrant，仅实现 VectorStore 用到的 REST API
+type fakeQdrant struct {
+	mu          sync.Mutex
+	apiKey      string
+	collections map[string]*fakeCollection
+}
+
+type fakeCollection struct {
+	size    int
+	indexes map[string]string
+	points  map[string]*fakePoint
+}
+
+type fakePoint struct {
+	ID      string         `json:"id"`
+	Vector  []float32      `json:"vector"`
+	Payload map[string]any `json:"payload"`
+}
+
+type fakeFilter struct {
+	Must    []fakeCondition `json:"must"`
+	MustNot []fakeCondition `json:"must_not"`
+}
+
+type fakeCondition struct {
+	Key   string `json:"key"`
+	Match struct {
+		Value any   `json:"value"`
+		Any   []any `json:"any"`
+	} `json:"match"`
+}
+
+func (c fakeCondition) match(p *fakePoint) bool {
+	v := fmt.Sprint(p.Payload[c.Key])
+	if c.Match.Value != nil {
+		return v == fmt.Sprint(c.Match.Value)
+	}
+	for _, item := range c.Match.Any {
+		if v == fmt.Sprint(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeFilter) match(p *fakePoint) bool {
+	if f == nil {
+		return true
+	}
+	for _, c := range f.Must {
+		if !c.match(p) {
+			return false
+		}
+	}
+	for _, c := range f.MustNot {
+		if c.match(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func newFakeQdrant(apiKey string) *httptest.Server {
+	f := &fakeQdrant{
+		apiKey:      apiKey,
+		collections: make(map[string]*fakeCollection),
+	}
+	return httptest.NewServer(f)
+}
+
+func (f *fakeQdrant) reply(w http.ResponseWriter, code int, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if code != http.StatusOK {
+		json.NewEncoder(w).Encode(map[string]any{"status": map[string]any{"error": result}, "time": 0})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"result": result, "status": "ok", "time": 0})
+}
+
+func (f *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.apiKey != "" && r.Header.Get("api-key") != f.apiKey {
+		f.reply(w, http.StatusForbidden, "invalid api key")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "collections" && r.Method == http.MethodGet {
+		var names []map[string]string
+		for name := range f.collections {
+			names = append(names, map[string]string{"name": name})
+		}
+		f.reply(w, http.StatusOK, map[string]any{"collections": names})
+		return
+	}
+	if len(parts) < 2 || parts[0] != "collections" {
+		f.reply(w, http.StatusNotFound, "not found")
+		return
+	}
+	name, action := parts[1], strings.Join(parts[2:], "/")
+
+	var body struct {
+		Vectors struct {
+			Size int `json:"size"`
+		} `json:"vectors"`
+		FieldName   string          `json:"field_name"`
+		FieldSchema string          `json:"field_schema"`
+		Points      json.RawMessage `json:"points"`
+		Filter      *fakeFilter     `json:"filter"`
+		Vector      []float32       `json:"vector"`
+		Limit       int             `json:"limit"`
+		Payload     map[string]any  `json:"payload"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	var (
+		points   []fakePoint
+		pointIDs []string
+	)
+	if action == "points/payload" {
+		json.Unmarshal(body.Points, &pointIDs)
+	} else if len(body.Points) > 0 {
+		json.Unmarshal(body.Points, &points)
+	}
+
+	if action == "" && r.Method == http.MethodPut {
+		f.collections[name] = &fakeCollection{
+			size:    body.Vectors.Size,
+			indexes: make(map[string]string),
+			points:  make(map[string]*fakePoint),
+		}
+		f.reply(w, http.StatusOK, true)
+		return
+	}
+
+	c, ok := f.collections[name]
+	if !ok {
+		f.reply(w, http.StatusNotFound, "Collection `"+name+"` doesn't exist!")
+		return
+	}
+
+	switch action {
+	case "":
+		f.reply(w, http.StatusOK, map[string]any{"status": "green"})
+	case "index":
+		c.indexes[body.FieldName] = body.FieldSchema
+		f.reply(w, http.StatusOK, map[string]any{"status": "completed"})
+	case "points":
+		for _, p := range points {
+			if len(p.Vector) != c.size {
+				f.reply(w, http.StatusBadRequest, "Wrong input: Vector dimension error")
+				return
+			}
+			p := p
+			c.points[p.ID] = &p
+		}
+		f.reply(w, http.StatusOK, map[string]any{"status": "completed"})
+	case "points/vectors":
+		for _, p := range points {
+			if exist, ok := c.points[p.ID]; ok {
+				exist.Vector = p.Vector
+			}
+		}
+		f.reply(w, http.StatusOK, map[string]any{"status": "completed"})
+	case "points/payload":
+		for _, id := range pointIDs {
+			if exist, ok := c.points[id]; ok {
+				for k, v := range body.Payload {
+					exist.Payload[k] = v
+				}
+			}
+		}
+		f.reply(w, http.StatusOK, map[string]any{"status": "completed"})
+	case "points/delete":
+		for id, p := range c.points {
+			if body.Filter.match(p) {
+				delete(c.points, id)
+			}
+		}
+		f.reply(w, http.StatusOK, map[string]any{"status": "completed"})
+	case "points/scroll":
+		var res []*fakePoint
+		for _, p := range c.points {
+			if body.Filter.match(p) {
+				res = append(res, p)
+			}
+		}
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].Payload["created_at"].(float64) > res[j].Payload["created_at"].(float64)
+		})
+		if len(res) > body.Limit {
+			res = res[:body.Limit]
+		}
+		f.reply(w, http.StatusOK, map[string]any{"points": res, "next_page_offset": nil})
+	case "points/search":
+		type scored struct {
+			ID      string         `json:"id"`
+			Score   float64        `json:"score"`
+			Payload map[string]any `json:"payload"`
+		}
+		var res []scored
+		for _, p := range c.points {
+			if body.Filter.match(p) {
+				res = append(res, scored{ID: p.ID, Score: cosine(body.Vector, p.Vector), Payload: p.Payload})
+			}
+		}
+		sort.Slice(res, func(i, j int) bool { return res[i].Score > res[j].Score })
+		if len(res) > body.Limit {
+			res = res[:body.Limit]
+		}
+		f.reply(w, http.StatusOK, res)
+	default:
+		f.reply(w, http.StatusNotFound, "not found")
+	}
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func setupStore(t *testing.T) *qdrant.VectorStore {
+	srv := newFakeQdrant("test-key")
+	t.Cleanup(srv.Close)
+
+	s := qdrant.NewVectorStore(qdrant.Config{
+		Endpoint:  srv.URL,
+		APIKey:    "test-key",
+		Dimension: 3,
+	})
+	if err := s.Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// install twice should be ok
+	if err := s.Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func Test_VectorStore(t *testing.T) {
+	s := setupStore(t)
+	ctx := context.Background()
+
+	assert.Equal(t, qdrant.DEFAULT_COLLECTION, s.GetTable())
+
+	err := s.BatchCreate(ctx, []types.Vector{
+		{ID: "1", KnowledgeID: "k1", SpaceID: "s1", UserID: "u1", Resource: "knowledge", Embedding: []float32{1, 0, 0}, OriginalLength: 10, CreatedAt: 1},
+		{ID: "2", KnowledgeID: "k1", SpaceID: "s1", UserID: "u1", Resource: "knowledge", Embedding: []float32{0.9, 0.1, 0}, OriginalLength: 20, CreatedAt: 2},
+		{ID: "3", KnowledgeID: "k2", SpaceID: "s1", UserID: "u1", Resource: "journal", Embedding: []float32{0, 1, 0}, CreatedAt: 3},
+		{ID: "4", KnowledgeID: "k3", SpaceID: "s2", UserID: "u2", Resource: "knowledge", Embedding: []float32{1, 0, 0}, CreatedAt: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1"}, []float32{1, 0, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, res, 3)
+	assert.Equal(t, "1", res[0].ID)
+	assert.Equal(t, "k1", res[0].KnowledgeID)
+	assert.Equal(t, 10, res[0].OriginalLength)
+	assert.InDelta(t, 0, res[0].Cos, 1e-6)
+	assert.Equal(t, "3", res[2].ID)
+	assert.InDelta(t, 1, res[2].Cos, 1e-6)
+
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Resource: &types.ResourceQuery{Exclude: []string{"knowledge"}}}, []float32{1, 0, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, res, 1)
+	assert.Equal(t, "3", res[0].ID)
+
+	list, err := s.ListVectors(ctx, types.GetVectorsOptions{SpaceID: "s1"}, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, list, 1)
+	assert.Equal(t, "1", list[0].ID)
+	assert.Equal(t, []float32{1, 0, 0}, list[0].Embedding)
+
+	if err = s.Update(ctx, "s1", "k2", "3", "", []float32{1, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.GetVector(ctx, "s1", "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []float32{1, 0, 0}, v.Embedding)
+	assert.Equal(t, "journal", v.Resource)
+
+	if err = s.BatchDelete(ctx, "s1", "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.GetVector(ctx, "s1", "k1"); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	if err = s.DeleteAll(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	res, err = s.Query(ctx, types.GetVectorsOptions{}, []float32{1, 0, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, res, 1)
+	assert.Equal(t, "4", res[0].ID)
+}
+
+func Test_VectorStoreError(t *testing.T) {
+	s := setupStore(t)
+
+	err := s.Create(context.Background(), types.Vector{ID: "1", SpaceID: "s1"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dimension")
+
+	srv := newFakeQdrant("test-key")
+	defer srv.Close()
+
+	unauthorized := qdrant.NewVectorStore(qdrant.Config{Endpoint: srv.URL})
+	err = unauthorized.Install(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid api key")
+}
+
+func Test_VectorStoreMultiModel(t *testing.T) {
+	srv := newFakeQdrant("")
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	s := qdrant.NewVectorStore(qdrant.Config{Endpoint: srv.URL, Dimension: 3})
+	if err := s.Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.BatchCreate(ctx, []types.Vector{
+		// 未记录模型的历史数据
+		{ID: "1", KnowledgeID: "k1", SpaceID: "s1", Embedding: []float32{1, 0, 0}, CreatedAt: 1},
+		{ID: "1", KnowledgeID: "k1", SpaceID: "s1", Model: "m3", Embedding: []float32{0, 1, 0}, CreatedAt: 2},
+		{ID: "1", KnowledgeID: "k1", SpaceID: "s1", Model: "m2", Embedding: []float32{1, 0}, CreatedAt: 3},
+		{ID: "2", KnowledgeID: "k2", SpaceID: "s1", Model: "m2", Embedding: []float32{0, 1}, CreatedAt: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 维的向量写入单独的 collection
+	res, err := s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m2"}, []float32{1, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, res, 2)
+	assert.Equal(t, "1", res[0].ID)
+
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m3"}, []float32{1, 0, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, res, 2)
+	assert.InDelta(t, 0, res[0].Cos, 1e-6)
+
+	// 未知维度
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1"}, []float32{1, 0, 0, 0}, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+
+	// 重启后可以识别已存在的其他维度的 collection
+	reopen := qdrant.NewVectorStore(qdrant.Config{Endpoint: srv.URL, Dimension: 3})
+	if err = reopen.Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+	list, err := reopen.ListVectors(ctx, types.GetVectorsOptions{SpaceID: "s1"}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, list, 4)
+	assert.Equal(t, "m2", list[0].Model)
+	assert.Equal(t, 2, list[0].Dimension)
+
+	if err = reopen.DeleteByModel(ctx, "s1", "k1", "m2"); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = reopen.ListVectors(ctx, types.GetVectorsOptions{SpaceID: "s1", Dimension: 2}, 1, 10)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "2", list[0].ID)
+
+	if err = reopen.PruneModels(ctx, "s1", "m3"); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = reopen.ListVectors(ctx, types.GetVectorsOptions{SpaceID: "s1"}, 1, 10)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "m3", list[0].Model)
+}
+
+func Test_VectorStoreKnowledgeFilter(t *testing.T) {
+	s := setupStore(t)
+	ctx := context.Background()
+
+	err := s.BatchCreate(ctx, []types.Vector{
+		{ID: "1", KnowledgeID: "k1", SpaceID: "s1", Embedding: []float32{1, 0, 0}, CreatedAt: 1},
+		{ID: "2", KnowledgeID: "k2", SpaceID: "s1", Embedding: []float32{0, 1, 0}, CreatedAt: 2},
+		{ID: "3", KnowledgeID: "k3", SpaceID: "s1", Embedding: []float32{0, 0, 1}, CreatedAt: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := &types.KnowledgeFilter{Tags: []string{"k8s"}}
+	opts := types.GetVectorsOptions{SpaceID: "s1", Knowledge: filter}
+
+	// 未设置解析函数时不能忽略筛选条件
+	_, err = s.Query(ctx, opts, []float32{1, 0, 0}, 10)
+	assert.Error(t, err)
+
+	var matched []string
+	s.SetKnowledgeResolver(func(ctx context.Context, spaceID string, f *types.KnowledgeFilter) ([]string, error) {
+		assert.Equal(t, "s1", spaceID)
+		assert.Equal(t, filter, f)
+		return matched, nil
+	})
+
+	res, err := s.Query(ctx, opts, []float32{1, 0, 0}, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+
+	matched = []string{"k2", "k3"}
+	res, err = s.Query(ctx, opts, []float32{1, 0, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []string{"k2", "k3"}, lo.Map(res, func(item types.QueryResult, _ int) string {
+		return item.KnowledgeID
+	}))
+
+	list, err := s.ListVectors(ctx, opts, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, list, 2)
+	assert.Equal(t, "3", list[0].ID)
+}
+
+func Test_VectorStorePruneModels(t *testing.T) {
+	srv := newFakeQdrant("")
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	s := qdrant.NewVectorStore(qdrant.Config{Endpoint: srv.URL, Dimension: 3})
+	if err := s.Install(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.BatchCreate(ctx, []types.Vector{
+		{ID: "1", KnowledgeID: "k1", SpaceID: "s1", Embedding: []float32{1, 0, 0}, CreatedAt: 1},
+		{ID: "1", KnowledgeID: "k1", SpaceID: "s1", Model: "m1", Embedding: []float32{1, 0, 0}, CreatedAt: 2},
+		{ID: "1", KnowledgeID: "k1", SpaceID: "s1", Model: "m2", Embedding: []float32{0, 1, 0}, CreatedAt: 3},
+		{ID: "2", KnowledgeID: "k2", SpaceID: "s2", Model: "m1", Embedding: []float32{1, 0, 0}, CreatedAt: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 迁移过程中只更新目标模型的向量
+	if err = s.Update(ctx, "s1", "k1", "1", "m2", []float32{0, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 迁移完成后只保留目标模型的向量，未记录模型的历史数据一并清理，其他空间不受影响
+	if err = s.PruneModels(ctx, "s1", "m2"); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.ListVectors(ctx, types.GetVectorsOptions{SpaceID: "s1"}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, list, 1)
+	assert.Equal(t, "m2", list[0].Model)
+	assert.Equal(t, []float32{0, 0, 1}, list[0].Embedding)
+
+	list, _ = s.ListVectors(ctx, types.GetVectorsOptions{SpaceID: "s2"}, 1, 10)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "m1", list[0].Model)
+}
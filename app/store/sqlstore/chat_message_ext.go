@@ -27,7 +27,7 @@ func NewChatMessageExtStore(provider SqlProviderAchieve) *ChatMessageExtStore {
 	store := &ChatMessageExtStore{}
 	store.SetProvider(provider)
 	store.SetTable(types.TABLE_CHAT_MESSAGE_EXT)
-	store.SetAllColumns("message_id", "space_id", "session_id", "evaluate", "generation_status", "rel_docs", "created_at", "updated_at")
+	store.SetAllColumns("message_id", "space_id", "session_id", "evaluate", "generation_status", "rel_docs", "context_packing", "citations", "created_at", "updated_at")
 	return store
 }
 
@@ -42,8 +42,8 @@ func (s *ChatMessageExtStore) Create(ctx context.Context, data types.ChatMessage
 	}
 
 	query := sq.Insert(s.GetTable()).
-		Columns("message_id", "space_id", "session_id", "evaluate", "generation_status", "rel_docs", "created_at", "updated_at").
-		Values(data.MessageID, data.SpaceID, data.SessionID, data.Evaluate, data.GenerationStatus, pq.Array(data.RelDocs), data.CreatedAt, data.UpdatedAt)
+		Columns("message_id", "space_id", "session_id", "evaluate", "generation_status", "rel_docs", "context_packing", "citations", "created_at", "updated_at").
+		Values(data.MessageID, data.SpaceID, data.SessionID, data.Evaluate, data.GenerationStatus, pq.Array(data.RelDocs), data.ContextPacking, data.Citations, data.CreatedAt, data.UpdatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -92,6 +92,22 @@ func (s *ChatMessageExtStore) Update(ctx context.Context, messageID string, data
 	return err
 }
 
+// UpdateCitations 回答生成完成后写入回答中的引用
+func (s *ChatMessageExtStore) UpdateCitations(ctx context.Context, messageID string, citations types.Citations) error {
+	query := sq.Update(s.GetTable()).
+		Set("citations", citations).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"message_id": messageID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
 // Delete 删除 ChatMessageExt 记录
 func (s *ChatMessageExtStore) Delete(ctx context.Context, id string) error {
 	query := sq.Delete(s.GetTable()).Where(sq.Eq{"id": id})
@@ -6,6 +6,8 @@ CREATE TABLE bw_chat_message_ext (
     evaluate SMALLINT NOT NULL,                 -- 评价状态，使用 EvaluateType 枚举
     generation_status SMALLINT NOT NULL,        -- 生成状态，使用 GenerationStatusType 枚举
     rel_docs TEXT[],              -- 相关文档数组，存储多个文档标识符
+    context_packing JSONB,        -- 知识内容按 token 预算裁剪的记录
+    citations JSONB,              -- 回答中的引用，包括知识点、片段及引用标记在回答中的位置
     created_at BIGINT NOT NULL,            -- 创建时间，Unix 时间戳
     updated_at BIGINT NOT NULL             -- 更新时间，Unix 时间戳
 );
@@ -19,5 +21,11 @@ COMMENT ON COLUMN bw_chat_message_ext.space_id IS '空间ID，表示消息所属
 COMMENT ON COLUMN bw_chat_message_ext.evaluate IS '评价状态，使用 EvaluateType 枚举';
 COMMENT ON COLUMN bw_chat_message_ext.generation_status IS '生成状态，使用 GenerationStatusType 枚举';
 COMMENT ON COLUMN bw_chat_message_ext.rel_docs IS '相关文档数组，存储多个文档标识符';
+COMMENT ON COLUMN bw_chat_message_ext.context_packing IS '知识内容按 token 预算裁剪的记录';
+COMMENT ON COLUMN bw_chat_message_ext.citations IS '回答中的引用，包括知识点、片段及引用标记在回答中的位置';
 COMMENT ON COLUMN bw_chat_message_ext.created_at IS '创建时间，Unix 时间戳';
-COMMENT ON COLUMN bw_chat_message_ext.updated_at IS '更新时间，Unix 时间戳';
\ No newline at end of file
+COMMENT ON COLUMN bw_chat_message_ext.updated_at IS '更新时间，Unix 时间戳';
+
+-- 已有数据库升级
+-- ALTER TABLE bw_chat_message_ext ADD COLUMN context_packing JSONB;
+-- ALTER TABLE bw_chat_message_ext ADD COLUMN citations JSONB;
@@ -151,10 +151,14 @@ func (s *ChatSessionStore) DeleteAll(ctx context.Context, spaceID string) error
 	return nil
 }
 
+// List userID 为空时返回空间内所有用户的会话
 func (s *ChatSessionStore) List(ctx context.Context, spaceID, userID string, page, pageSize uint64) ([]types.ChatSession, error) {
 	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).
-		Where(sq.Eq{"space_id": spaceID, "user_id": userID}).
-		OrderBy("latest_access_time DESC")
+		Where(sq.Eq{"space_id": spaceID}).
+		OrderBy("latest_access_time DESC", "id DESC")
+	if userID != "" {
+		query = query.Where(sq.Eq{"user_id": userID})
+	}
 
 	if page != types.NO_PAGING || pageSize != types.NO_PAGING {
 		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
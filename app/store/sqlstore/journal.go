@@ -113,10 +113,13 @@ func (s *JournalStore) Delete(ctx context.Context, id int64) error {
 	return err
 }
 
-// List
+// List userID 为空时返回空间内所有用户的日记
 func (s *JournalStore) List(ctx context.Context, spaceID, userID string, page, pageSize uint64) ([]types.Journal, error) {
-	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "user_id": userID}).
-		Limit(pageSize).Offset((page - 1) * pageSize).OrderBy("date DESC")
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID}).
+		Limit(pageSize).Offset((page-1)*pageSize).OrderBy("date DESC", "id DESC")
+	if userID != "" {
+		query = query.Where(sq.Eq{"user_id": userID})
+	}
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -29,7 +29,7 @@ func NewKnowledgeStore(provider SqlProviderAchieve) *KnowledgeStore {
 	store := &KnowledgeStore{}
 	store.SetProvider(provider)
 	store.SetTable(types.TABLE_KNOWLEDGE)
-	store.SetAllColumns("id", "title", "user_id", "space_id", "tags", "content", "content_type", "resource", "kind", "summary", "maybe_date", "stage", "retry_times", "created_at", "updated_at")
+	store.SetAllColumns("id", "title", "user_id", "space_id", "tags", "content", "content_type", "content_hash", "source", "resource", "kind", "summary", "maybe_date", "stage", "retry_times", "created_at", "updated_at")
 	return store
 }
 
@@ -42,8 +42,8 @@ func (s *KnowledgeStore) Create(ctx context.Context, data types.Knowledge) error
 		data.UpdatedAt = time.Now().Unix()
 	}
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "title", "user_id", "space_id", "tags", "content", "content_type", "resource", "kind", "summary", "maybe_date", "stage", "retry_times", "created_at", "updated_at").
-		Values(data.ID, data.Title, data.UserID, data.SpaceID, pq.Array(data.Tags), data.Content.String(), data.ContentType, data.Resource, data.Kind, data.Summary, data.MaybeDate, data.Stage, data.RetryTimes, data.CreatedAt, data.UpdatedAt)
+		Columns("id", "title", "user_id", "space_id", "tags", "content", "content_type", "content_hash", "source", "resource", "kind", "summary", "maybe_date", "stage", "retry_times", "created_at", "updated_at").
+		Values(data.ID, data.Title, data.UserID, data.SpaceID, pq.Array(data.Tags), data.Content.String(), data.ContentType, data.ContentHash, data.Source, data.Resource, data.Kind, data.Summary, data.MaybeDate, data.Stage, data.RetryTimes, data.CreatedAt, data.UpdatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -59,12 +59,12 @@ func (s *KnowledgeStore) Create(ctx context.Context, data types.Knowledge) error
 
 func (s *KnowledgeStore) BatchCreate(ctx context.Context, datas []*types.Knowledge) error {
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "title", "user_id", "space_id", "tags", "content", "content_type", "resource", "kind", "summary", "maybe_date", "stage", "retry_times", "created_at", "updated_at")
+		Columns("id", "title", "user_id", "space_id", "tags", "content", "content_type", "content_hash", "source", "resource", "kind", "summary", "maybe_date", "stage", "retry_times", "created_at", "updated_at")
 	for _, data := range datas {
 		if data.CreatedAt == 0 {
 			data.CreatedAt = time.Now().Unix()
 		}
-		query = query.Values(data.ID, data.Title, data.UserID, data.SpaceID, pq.Array(data.Tags), data.Content.String(), data.ContentType, data.Resource, data.Kind, data.Summary, data.MaybeDate, data.Stage, data.RetryTimes, data.CreatedAt, data.UpdatedAt)
+		query = query.Values(data.ID, data.Title, data.UserID, data.SpaceID, pq.Array(data.Tags), data.Content.String(), data.ContentType, data.ContentHash, data.Source, data.Resource, data.Kind, data.Summary, data.MaybeDate, data.Stage, data.RetryTimes, data.CreatedAt, data.UpdatedAt)
 	}
 
 	queryString, args, err := query.ToSql()
@@ -199,6 +199,116 @@ func (s *KnowledgeStore) SetRetryTimes(ctx context.Context, spaceID, id string,
 	return err
 }
 
+// SetContentHash 更新内容哈希，哈希由内容派生，不修改 updated_at
+func (s *KnowledgeStore) SetContentHash(ctx context.Context, spaceID, id, hash string) error {
+	query := sq.Update(s.GetTable()).
+		Set("content_hash", hash).
+		Where(sq.Eq{"space_id": spaceID, "id": id})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// ListMissingContentHash 按 id 升序返回空间中 id 大于 afterID 且没有内容哈希的知识点
+func (s *KnowledgeStore) ListMissingContentHash(ctx context.Context, spaceID, afterID string, limit uint64) ([]types.Knowledge, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID, "content_hash": ""}).
+		Where(sq.Gt{"id": afterID}).
+		OrderBy("id").
+		Limit(limit)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.Knowledge
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListHashDuplicates 返回空间中内容哈希相同的知识点，每组按创建时间排序
+func (s *KnowledgeStore) ListHashDuplicates(ctx context.Context, spaceID string) ([]types.KnowledgeHashDuplicate, error) {
+	query := sq.Select("content_hash", "array_agg(id ORDER BY created_at, id) AS ids").
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID}).
+		Where(sq.NotEq{"content_hash": ""}).
+		GroupBy("content_hash").
+		Having("COUNT(*) > 1")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeHashDuplicate
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListTags 返回空间中的标签及使用该标签的知识点数量，按使用次数倒序
+func (s *KnowledgeStore) ListTags(ctx context.Context, spaceID string) ([]types.KnowledgeTag, error) {
+	query := sq.Select("tag", "COUNT(*) AS count").
+		From(s.GetTable()+", unnest(tags) AS tag").
+		Where(sq.Eq{"space_id": spaceID}).
+		GroupBy("tag").
+		OrderBy("count DESC", "tag")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeTag
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RenameTags 将空间中的 from 标签统一替换为 to，替换后重复的标签只保留第一次出现的位置，返回修改的知识点数量
+func (s *KnowledgeStore) RenameTags(ctx context.Context, spaceID string, from []string, to string) (int64, error) {
+	query := sq.Update(s.GetTable()).
+		Set("tags", sq.Expr(`ARRAY(SELECT t FROM (SELECT CASE WHEN tag = ANY(?::text[]) THEN ?::text ELSE tag END AS t, MIN(ord) AS o FROM unnest(tags) WITH ORDINALITY AS u(tag, ord) GROUP BY 1) s ORDER BY o)`, pq.Array(from), to)).
+		Where(sq.Eq{"space_id": spaceID}).
+		Where(sq.Expr("tags && ?::text[]", pq.Array(from)))
+
+	return s.execTags(ctx, query)
+}
+
+// DeleteTags 从空间的所有知识点中移除标签，返回修改的知识点数量
+func (s *KnowledgeStore) DeleteTags(ctx context.Context, spaceID string, tags []string) (int64, error) {
+	query := sq.Update(s.GetTable()).
+		Set("tags", sq.Expr(`ARRAY(SELECT tag FROM unnest(tags) WITH ORDINALITY AS u(tag, ord) WHERE tag <> ALL(?::text[]) ORDER BY ord)`, pq.Array(tags))).
+		Where(sq.Eq{"space_id": spaceID}).
+		Where(sq.Expr("tags && ?::text[]", pq.Array(tags)))
+
+	return s.execTags(ctx, query)
+}
+
+func (s *KnowledgeStore) execTags(ctx context.Context, query sq.UpdateBuilder) (int64, error) {
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, ErrorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // Delete 删除知识记录
 func (s *KnowledgeStore) Delete(ctx context.Context, spaceID, id string) error {
 	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "id": id})
@@ -312,8 +422,9 @@ func (s *KnowledgeStore) ListKnowledges(ctx context.Context, opts types.GetKnowl
 	return res, nil
 }
 
+// ListKnowledgeIDs 按 id 升序返回知识点 id 列表，可配合 GetKnowledgeOptions.AfterID 进行游标分页
 func (s *KnowledgeStore) ListKnowledgeIDs(ctx context.Context, opts types.GetKnowledgeOptions, page, pageSize uint64) ([]string, error) {
-	query := sq.Select("id").From(s.GetTable())
+	query := sq.Select("id").From(s.GetTable()).OrderBy("id")
 	if page != 0 || pageSize != 0 {
 		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
 	}
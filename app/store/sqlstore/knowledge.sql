@@ -10,6 +10,8 @@ CREATE TABLE bw_knowledge (
     tags TEXT[],
     content TEXT NOT NULL,
     content_type VARCHAR(30) NOT NULL,
+    content_hash VARCHAR(64) NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
     summary TEXT NOT NULL,
     maybe_date VARCHAR(20) NOT NULL,
     retry_times SMALLINT NOT NULL DEFAULT 0,
@@ -28,6 +30,8 @@ COMMENT ON COLUMN bw_knowledge.resource IS '资源类型/knowledge/context';
 COMMENT ON COLUMN bw_knowledge.title IS '内容标题';
 COMMENT ON COLUMN bw_knowledge.content IS '知识内容';
 COMMENT ON COLUMN bw_knowledge.content_type IS '内容格式';
+COMMENT ON COLUMN bw_knowledge.content_hash IS '归一化后明文内容的 HMAC-SHA256，用于判断重复内容';
+COMMENT ON COLUMN bw_knowledge.source IS '原始来源，文件类知识点为文件路径';
 COMMENT ON COLUMN bw_knowledge.summary IS 'summary顾虑条件';
 COMMENT ON COLUMN bw_knowledge.maybe_date IS 'AI分析出的事件发生时间 / 创建时间';
 COMMENT ON COLUMN bw_knowledge.retry_times IS '流水线相关动作重试次数';
@@ -36,4 +40,11 @@ COMMENT ON COLUMN bw_knowledge.updated_at IS '更新时间';
 
 -- 创建索引
 CREATE INDEX idx_bw_knowledge_main ON bw_knowledge (space_id, resource);
-CREATE INDEX idx_bw_knowledge_retry ON bw_knowledge (stage, retry_times);
\ No newline at end of file
+CREATE INDEX idx_bw_knowledge_retry ON bw_knowledge (stage, retry_times);
+CREATE INDEX idx_bw_knowledge_content_hash ON bw_knowledge (space_id, content_hash);
+-- 已有数据库升级
+-- ALTER TABLE bw_knowledge ADD COLUMN source TEXT NOT NULL DEFAULT '';
+-- ALTER TABLE bw_knowledge ADD COLUMN content_hash VARCHAR(64) NOT NULL DEFAULT '';
+-- CREATE INDEX idx_bw_knowledge_content_hash ON bw_knowledge (space_id, content_hash);
+-- 内容哈希改为带密钥的哈希，清空后由去重任务重新计算
+-- UPDATE bw_knowledge SET content_hash = '';
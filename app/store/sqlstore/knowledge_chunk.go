@@ -2,6 +2,7 @@ package sqlstore
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -26,10 +27,15 @@ func NewKnowledgeChunkStore(provider SqlProviderAchieve) *KnowledgeChunkStore {
 	repo := &KnowledgeChunkStore{}
 	repo.SetProvider(provider)
 	repo.SetTable(types.TABLE_KNOWLEDGE_CHUNK)
-	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "updated_at", "created_at")
+	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "chunk", "seq", "original_length", "updated_at", "created_at")
 	return repo
 }
 
+// keywordsExpr 由 lexeme 生成 tsvector，lexeme 已经过 search.HashTokens 处理，使用 simple 配置避免再次分词
+func keywordsExpr(keywords []string) sq.Sqlizer {
+	return sq.Expr("to_tsvector('simple', ?)", strings.Join(keywords, " "))
+}
+
 // Create 创建新的知识片段记录
 func (s *KnowledgeChunkStore) Create(ctx context.Context, data types.KnowledgeChunk) error {
 	if data.CreatedAt == 0 {
@@ -39,8 +45,8 @@ func (s *KnowledgeChunkStore) Create(ctx context.Context, data types.KnowledgeCh
 		data.UpdatedAt = time.Now().Unix()
 	}
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "updated_at", "created_at").
-		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Chunk, data.OriginalLength, data.UpdatedAt, data.CreatedAt)
+		Columns("id", "knowledge_id", "space_id", "user_id", "chunk", "seq", "keywords", "original_length", "updated_at", "created_at").
+		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Chunk, data.Seq, keywordsExpr(data.Keywords), data.OriginalLength, data.UpdatedAt, data.CreatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -60,7 +66,7 @@ func (s *KnowledgeChunkStore) BatchCreate(ctx context.Context, data []*types.Kno
 	}
 
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "updated_at", "created_at")
+		Columns("id", "knowledge_id", "space_id", "user_id", "chunk", "seq", "keywords", "original_length", "updated_at", "created_at")
 
 	// 遍历数据，构建批量插入的 values
 	for _, item := range data {
@@ -70,7 +76,7 @@ func (s *KnowledgeChunkStore) BatchCreate(ctx context.Context, data []*types.Kno
 		if item.UpdatedAt == 0 {
 			item.UpdatedAt = time.Now().Unix()
 		}
-		query = query.Values(item.ID, item.KnowledgeID, item.SpaceID, item.UserID, item.Chunk, item.OriginalLength, item.UpdatedAt, item.CreatedAt)
+		query = query.Values(item.ID, item.KnowledgeID, item.SpaceID, item.UserID, item.Chunk, item.Seq, keywordsExpr(item.Keywords), item.OriginalLength, item.UpdatedAt, item.CreatedAt)
 	}
 
 	queryString, args, err := query.ToSql()
@@ -116,6 +122,42 @@ func (s *KnowledgeChunkStore) Update(ctx context.Context, spaceID, knowledgeID,
 	return err
 }
 
+// SetKeywords 更新全文索引，索引由切片内容派生，不修改 updated_at
+func (s *KnowledgeChunkStore) SetKeywords(ctx context.Context, spaceID, knowledgeID, id string, keywords []string) error {
+	query := sq.Update(s.GetTable()).
+		Set("keywords", keywordsExpr(keywords)).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID, "id": id})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// ListMissingKeywords 按 id 升序返回 id 大于 afterID 且没有全文索引的切片
+func (s *KnowledgeChunkStore) ListMissingKeywords(ctx context.Context, afterID string, limit uint64) ([]types.KnowledgeChunk, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where("keywords = ''::tsvector").
+		Where(sq.Gt{"id": afterID}).
+		OrderBy("id").
+		Limit(limit)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeChunk
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 // Delete 根据ID删除知识片段记录
 func (s *KnowledgeChunkStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
 	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
@@ -166,10 +208,10 @@ func (s *KnowledgeChunkStore) DeleteAll(ctx context.Context, spaceID string) err
 	return err
 }
 
-// List 获取知识片段列表，支持分页
+// List 获取知识片段列表，按原文顺序排列
 func (s *KnowledgeChunkStore) List(ctx context.Context, spaceID, knowledgeID string) ([]types.KnowledgeChunk, error) {
 	query := sq.Select(s.GetAllColumns()...).
-		From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID}).OrderBy("id")
+		From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID}).OrderBy("seq", "id")
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -182,3 +224,51 @@ func (s *KnowledgeChunkStore) List(ctx context.Context, spaceID, knowledgeID str
 	}
 	return res, nil
 }
+
+// ListByKnowledgeIDs 批量获取多个知识点的片段，按知识点及原文顺序排列
+func (s *KnowledgeChunkStore) ListByKnowledgeIDs(ctx context.Context, spaceID string, knowledgeIDs []string) ([]types.KnowledgeChunk, error) {
+	if len(knowledgeIDs) == 0 {
+		return nil, nil
+	}
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeIDs}).OrderBy("knowledge_id", "seq", "id")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeChunk
+	if err := s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Search 关键词检索，lexemes 之间为 OR 关系，按 ts_rank_cd 降序返回
+func (s *KnowledgeChunkStore) Search(ctx context.Context, opts types.SearchChunksOptions, lexemes []string, limit uint64) ([]types.ChunkSearchResult, error) {
+	if len(lexemes) == 0 {
+		return nil, nil
+	}
+	tsQuery := strings.Join(lexemes, " | ")
+
+	query := sq.Select("c.id", "c.knowledge_id", "c.original_length").
+		Column(sq.Expr("ts_rank_cd(c.keywords, to_tsquery('simple', ?)) AS rank", tsQuery)).
+		From(s.GetTable() + " c").
+		Join(types.TABLE_KNOWLEDGE.Name() + " k ON k.id = c.knowledge_id AND k.space_id = c.space_id").
+		Where(sq.Expr("c.keywords @@ to_tsquery('simple', ?)", tsQuery)).
+		OrderBy("rank DESC").
+		Limit(limit)
+	opts.Apply(&query)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.ChunkSearchResult
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -5,6 +5,8 @@ CREATE TABLE bw_knowledge_chunk (
     space_id VARCHAR(32) NOT NULL, -- 空间ID
     user_id VARCHAR(32) NOT NULL, -- 用户ID
     chunk TEXT NOT NULL, -- 知识片段
+    seq INT NOT NULL DEFAULT 0, -- 片段在原文中的顺序
+    keywords TSVECTOR NOT NULL DEFAULT ''::tsvector, -- 关键词全文索引
     original_length INT NOT NULL DEFAULT 0, -- 关联知识点长度
     updated_at BIGINT NOT NULL DEFAULT 0, -- 更新时间
     created_at BIGINT NOT NULL DEFAULT 0 -- 创建时间
@@ -13,6 +15,7 @@ CREATE TABLE bw_knowledge_chunk (
 -- 创建索引
 CREATE INDEX idx_bw_knowledge_chunk_space_id_knowledge ON bw_knowledge_chunk (space_id,knowledge_id);
 CREATE INDEX idx_bw_knowledge_chunk_space_user_id ON bw_knowledge_chunk (space_id,user_id);
+CREATE INDEX idx_bw_knowledge_chunk_keywords ON bw_knowledge_chunk USING GIN (keywords);
 
 -- 为字段添加注释
 COMMENT ON COLUMN bw_knowledge_chunk.id IS '主键，自增ID';
@@ -20,6 +23,14 @@ COMMENT ON COLUMN bw_knowledge_chunk.knowledge_id IS '知识点ID';
 COMMENT ON COLUMN bw_knowledge_chunk.space_id IS '空间ID';
 COMMENT ON COLUMN bw_knowledge_chunk.user_id IS '用户ID';
 COMMENT ON COLUMN bw_knowledge_chunk.chunk IS '知识片段';
+COMMENT ON COLUMN bw_knowledge_chunk.seq IS '片段在原文中的顺序，升级前生成的片段均为 0';
+COMMENT ON COLUMN bw_knowledge_chunk.keywords IS '关键词全文索引，词项经过 HMAC 处理，不包含明文';
 COMMENT ON COLUMN bw_knowledge_chunk.original_length IS '关联知识点长度';
 COMMENT ON COLUMN bw_knowledge_chunk.updated_at IS '创建时间';
 COMMENT ON COLUMN bw_knowledge_chunk.created_at IS '创建时间';
+
+-- 已有数据库升级，升级前生成的片段 seq 均为 0，需要重新处理知识点才能得到准确的片段顺序
+-- ALTER TABLE bw_knowledge_chunk ADD COLUMN seq INT NOT NULL DEFAULT 0;
+-- 升级前生成的片段没有全文索引，由每日的 KeywordsProcess 补齐
+-- ALTER TABLE bw_knowledge_chunk ADD COLUMN keywords TSVECTOR NOT NULL DEFAULT ''::tsvector;
+-- CREATE INDEX idx_bw_knowledge_chunk_keywords ON bw_knowledge_chunk USING GIN (keywords);
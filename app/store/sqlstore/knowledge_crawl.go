@@ -0,0 +1,151 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc[*Provider](RegisterKey{}, func(provider *Provider) {
+		provider.stores.KnowledgeCrawlStore = NewKnowledgeCrawlStore(provider)
+	})
+}
+
+// KnowledgeCrawlStore 处理 bw_knowledge_crawl 表的操作
+type KnowledgeCrawlStore struct {
+	CommonFields
+}
+
+// NewKnowledgeCrawlStore 创建一个新的 KnowledgeCrawlStore 实例
+func NewKnowledgeCrawlStore(provider SqlProviderAchieve) *KnowledgeCrawlStore {
+	repo := &KnowledgeCrawlStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_CRAWL)
+	repo.SetAllColumns("knowledge_id", "space_id", "url", "content_hash", "crawl_interval", "last_crawled_at", "next_crawl_at", "failed_times", "last_error", "created_at", "updated_at")
+	return repo
+}
+
+// Create 创建抓取计划
+func (s *KnowledgeCrawlStore) Create(ctx context.Context, data types.KnowledgeCrawl) error {
+	if data.CreatedAt == 0 {
+		data.CreatedAt = time.Now().Unix()
+	}
+	if data.UpdatedAt == 0 {
+		data.UpdatedAt = data.CreatedAt
+	}
+	query := sq.Insert(s.GetTable()).
+		Columns(s.GetAllColumns()...).
+		Values(data.KnowledgeID, data.SpaceID, data.URL, data.ContentHash, data.Interval, data.LastCrawledAt, data.NextCrawlAt, data.FailedTimes, data.LastError, data.CreatedAt, data.UpdatedAt)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// Get 获取知识点的抓取计划
+func (s *KnowledgeCrawlStore) Get(ctx context.Context, spaceID, knowledgeID string) (*types.KnowledgeCrawl, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res types.KnowledgeCrawl
+	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListDue 按计划时间返回已到期的抓取计划，间隔为 0 的不再自动抓取
+func (s *KnowledgeCrawlStore) ListDue(ctx context.Context, now int64, limit uint64) ([]types.KnowledgeCrawl, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.LtOrEq{"next_crawl_at": now}, sq.Gt{"crawl_interval": 0}).
+		OrderBy("next_crawl_at").
+		Limit(limit)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeCrawl
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Crawled 记录一次成功的抓取，并清空失败记录
+func (s *KnowledgeCrawlStore) Crawled(ctx context.Context, spaceID, knowledgeID, contentHash string, crawledAt, nextCrawlAt int64) error {
+	query := sq.Update(s.GetTable()).
+		Set("content_hash", contentHash).
+		Set("last_crawled_at", crawledAt).
+		Set("next_crawl_at", nextCrawlAt).
+		Set("failed_times", 0).
+		Set("last_error", "").
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// Failed 记录一次失败的抓取
+func (s *KnowledgeCrawlStore) Failed(ctx context.Context, spaceID, knowledgeID, reason string, nextCrawlAt int64) error {
+	query := sq.Update(s.GetTable()).
+		Set("failed_times", sq.Expr("failed_times + 1")).
+		Set("last_error", reason).
+		Set("next_crawl_at", nextCrawlAt).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// Delete 删除知识点的抓取计划
+func (s *KnowledgeCrawlStore) Delete(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+func (s *KnowledgeCrawlStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
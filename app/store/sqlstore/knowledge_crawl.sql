@@ -0,0 +1,31 @@
+-- 创建表 bw_knowledge_crawl
+CREATE TABLE bw_knowledge_crawl (
+    knowledge_id VARCHAR(32) PRIMARY KEY,         -- 知识点ID
+    space_id VARCHAR(32) NOT NULL,                -- 空间ID
+    url TEXT NOT NULL,                            -- 抓取地址
+    content_hash VARCHAR(64) NOT NULL DEFAULT '', -- 最近一次抓取到的内容摘要
+    crawl_interval BIGINT NOT NULL,               -- 抓取间隔(秒)
+    last_crawled_at BIGINT NOT NULL DEFAULT 0,    -- 最近一次成功抓取的时间
+    next_crawl_at BIGINT NOT NULL,                -- 下一次抓取的时间
+    failed_times INT NOT NULL DEFAULT 0,          -- 连续失败次数
+    last_error TEXT NOT NULL DEFAULT '',          -- 最近一次失败的原因
+    created_at BIGINT NOT NULL,                   -- 创建时间
+    updated_at BIGINT NOT NULL                    -- 更新时间
+);
+
+CREATE INDEX idx_bw_knowledge_crawl_next_crawl_at ON bw_knowledge_crawl (next_crawl_at);
+CREATE INDEX idx_bw_knowledge_crawl_space_id ON bw_knowledge_crawl (space_id);
+
+-- 添加字段备注
+COMMENT ON TABLE bw_knowledge_crawl IS 'url 类知识点的抓取计划';
+COMMENT ON COLUMN bw_knowledge_crawl.knowledge_id IS '知识点ID';
+COMMENT ON COLUMN bw_knowledge_crawl.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_crawl.url IS '抓取地址';
+COMMENT ON COLUMN bw_knowledge_crawl.content_hash IS '最近一次抓取到的内容的 sha256 摘要，变化时重新切片';
+COMMENT ON COLUMN bw_knowledge_crawl.crawl_interval IS '抓取间隔(秒)，0 表示不再自动抓取';
+COMMENT ON COLUMN bw_knowledge_crawl.last_crawled_at IS '最近一次成功抓取的时间，UNIX时间戳';
+COMMENT ON COLUMN bw_knowledge_crawl.next_crawl_at IS '下一次抓取的时间，UNIX时间戳';
+COMMENT ON COLUMN bw_knowledge_crawl.failed_times IS '连续失败次数，成功后清零';
+COMMENT ON COLUMN bw_knowledge_crawl.last_error IS '最近一次失败的原因';
+COMMENT ON COLUMN bw_knowledge_crawl.created_at IS '创建时间，UNIX时间戳';
+COMMENT ON COLUMN bw_knowledge_crawl.updated_at IS '更新时间，UNIX时间戳';
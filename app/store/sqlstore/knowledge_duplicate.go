@@ -0,0 +1,112 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc[*Provider](RegisterKey{}, func(provider *Provider) {
+		provider.stores.KnowledgeDuplicateStore = NewKnowledgeDuplicateStore(provider)
+	})
+}
+
+// KnowledgeDuplicateStore 处理 bw_knowledge_duplicate 表的操作
+type KnowledgeDuplicateStore struct {
+	CommonFields
+}
+
+// NewKnowledgeDuplicateStore 创建一个新的 KnowledgeDuplicateStore 实例
+func NewKnowledgeDuplicateStore(provider SqlProviderAchieve) *KnowledgeDuplicateStore {
+	repo := &KnowledgeDuplicateStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_DUPLICATE)
+	repo.SetAllColumns("space_id", "knowledge_id", "duplicate_id", "reason", "score", "created_at")
+	return repo
+}
+
+// BatchCreate 批量写入重复的知识点
+func (s *KnowledgeDuplicateStore) BatchCreate(ctx context.Context, data []types.KnowledgeDuplicate) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	query := sq.Insert(s.GetTable()).Columns(s.GetAllColumns()...)
+	for _, item := range data {
+		if item.CreatedAt == 0 {
+			item.CreatedAt = time.Now().Unix()
+		}
+		query = query.Values(item.SpaceID, item.KnowledgeID, item.DuplicateID, item.Reason, item.Score, item.CreatedAt)
+	}
+	query = query.Suffix("ON CONFLICT (knowledge_id, duplicate_id) DO NOTHING")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// List 返回空间最近一次去重报告中的所有重复记录
+func (s *KnowledgeDuplicateStore) List(ctx context.Context, spaceID string) ([]types.KnowledgeDuplicate, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID}).
+		OrderBy("score DESC", "knowledge_id", "duplicate_id")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeDuplicate
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BatchDelete 删除与知识点相关的重复记录
+func (s *KnowledgeDuplicateStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID}, sq.Or{sq.Eq{"knowledge_id": knowledgeID}, sq.Eq{"duplicate_id": knowledgeID}})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// BatchDeleteByIDs 删除与多个知识点相关的所有记录
+func (s *KnowledgeDuplicateStore) BatchDeleteByIDs(ctx context.Context, knowledgeIDs []string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Or{sq.Eq{"knowledge_id": knowledgeIDs}, sq.Eq{"duplicate_id": knowledgeIDs}})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+func (s *KnowledgeDuplicateStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
@@ -0,0 +1,22 @@
+-- 创建表 bw_knowledge_duplicate
+CREATE TABLE bw_knowledge_duplicate (
+    space_id VARCHAR(32) NOT NULL,     -- 空间ID
+    knowledge_id VARCHAR(32) NOT NULL, -- 知识点ID
+    duplicate_id VARCHAR(32) NOT NULL, -- 与之重复的知识点ID
+    reason VARCHAR(20) NOT NULL,       -- 判断为重复的依据
+    score REAL NOT NULL,               -- 相似度
+    created_at BIGINT NOT NULL,        -- 报告生成时间
+    PRIMARY KEY (knowledge_id, duplicate_id)
+);
+
+CREATE INDEX idx_bw_knowledge_duplicate_duplicate_id ON bw_knowledge_duplicate (duplicate_id);
+CREATE INDEX idx_bw_knowledge_duplicate_space_id ON bw_knowledge_duplicate (space_id);
+
+-- 添加字段备注
+COMMENT ON TABLE bw_knowledge_duplicate IS '定时去重任务生成的空间去重报告，每次生成时替换空间的全部记录';
+COMMENT ON COLUMN bw_knowledge_duplicate.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_duplicate.knowledge_id IS '知识点ID，内容相同时为其中最早创建的知识点';
+COMMENT ON COLUMN bw_knowledge_duplicate.duplicate_id IS '与之重复的知识点ID';
+COMMENT ON COLUMN bw_knowledge_duplicate.reason IS '判断为重复的依据，hash: 内容相同，similar: 向量相似度超过阈值';
+COMMENT ON COLUMN bw_knowledge_duplicate.score IS '相似度，内容相同时为 1';
+COMMENT ON COLUMN bw_knowledge_duplicate.created_at IS '报告生成时间，UNIX时间戳';
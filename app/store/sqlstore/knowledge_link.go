@@ -0,0 +1,126 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc[*Provider](RegisterKey{}, func(provider *Provider) {
+		provider.stores.KnowledgeLinkStore = NewKnowledgeLinkStore(provider)
+	})
+}
+
+// KnowledgeLinkStore 处理 bw_knowledge_link 表的操作
+type KnowledgeLinkStore struct {
+	CommonFields
+}
+
+// NewKnowledgeLinkStore 创建一个新的 KnowledgeLinkStore 实例
+func NewKnowledgeLinkStore(provider SqlProviderAchieve) *KnowledgeLinkStore {
+	repo := &KnowledgeLinkStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_LINK)
+	repo.SetAllColumns("space_id", "from_id", "to_id", "text", "created_at")
+	return repo
+}
+
+// BatchCreate 批量创建链接，已存在的链接忽略
+func (s *KnowledgeLinkStore) BatchCreate(ctx context.Context, data []types.KnowledgeLink) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	query := sq.Insert(s.GetTable()).Columns(s.GetAllColumns()...)
+	for _, item := range data {
+		if item.CreatedAt == 0 {
+			item.CreatedAt = time.Now().Unix()
+		}
+		query = query.Values(item.SpaceID, item.FromID, item.ToID, item.Text, item.CreatedAt)
+	}
+	query = query.Suffix("ON CONFLICT (from_id, to_id) DO NOTHING")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// ListLinks 按创建时间返回链接
+func (s *KnowledgeLinkStore) ListLinks(ctx context.Context, opts types.GetKnowledgeLinksOptions, page, pageSize uint64) ([]types.KnowledgeLink, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).OrderBy("created_at", "from_id", "to_id")
+	if page != 0 || pageSize != 0 {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+	opts.Apply(&query)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeLink
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteFrom 删除知识点指向其他知识点的链接
+func (s *KnowledgeLinkStore) DeleteFrom(ctx context.Context, spaceID, fromID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "from_id": fromID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// BatchDelete 删除与知识点相关的所有链接，包括其他知识点指向它的链接
+func (s *KnowledgeLinkStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID}, sq.Or{sq.Eq{"from_id": knowledgeID}, sq.Eq{"to_id": knowledgeID}})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// BatchDeleteByIDs 删除与多个知识点相关的所有链接
+func (s *KnowledgeLinkStore) BatchDeleteByIDs(ctx context.Context, knowledgeIDs []string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Or{sq.Eq{"from_id": knowledgeIDs}, sq.Eq{"to_id": knowledgeIDs}})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+func (s *KnowledgeLinkStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
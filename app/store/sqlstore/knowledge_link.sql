@@ -0,0 +1,23 @@
+-- 创建表 bw_knowledge_link
+CREATE TABLE bw_knowledge_link (
+    space_id VARCHAR(32) NOT NULL,     -- 空间ID
+    from_id VARCHAR(32) NOT NULL,      -- 链接所在的知识点ID
+    to_id VARCHAR(32) NOT NULL,        -- 被链接的知识点ID
+    text TEXT NOT NULL DEFAULT '',     -- 链接文字(加密)
+    created_at BIGINT NOT NULL,        -- 创建时间
+    PRIMARY KEY (from_id, to_id)
+);
+
+CREATE INDEX idx_bw_knowledge_link_to_id ON bw_knowledge_link (to_id);
+CREATE INDEX idx_bw_knowledge_link_space_id ON bw_knowledge_link (space_id);
+
+-- 添加字段备注
+COMMENT ON TABLE bw_knowledge_link IS '知识点之间的链接，由知识点内容解析得到';
+COMMENT ON COLUMN bw_knowledge_link.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_link.from_id IS '链接所在的知识点ID';
+COMMENT ON COLUMN bw_knowledge_link.to_id IS '被链接的知识点ID';
+COMMENT ON COLUMN bw_knowledge_link.text IS '链接文字，与知识点内容一样加密保存';
+COMMENT ON COLUMN bw_knowledge_link.created_at IS '创建时间，UNIX时间戳';
+
+-- 已有数据库升级，链接文字改为加密保存，清空后在知识点重新处理时生成
+-- UPDATE bw_knowledge_link SET text = '';
@@ -0,0 +1,93 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc[*Provider](RegisterKey{}, func(provider *Provider) {
+		provider.stores.KnowledgeProgressStore = NewKnowledgeProgressStore(provider)
+	})
+}
+
+// KnowledgeProgressStore 处理 bw_knowledge_progress 表的操作
+type KnowledgeProgressStore struct {
+	CommonFields
+}
+
+// NewKnowledgeProgressStore 创建一个新的 KnowledgeProgressStore 实例
+func NewKnowledgeProgressStore(provider SqlProviderAchieve) *KnowledgeProgressStore {
+	repo := &KnowledgeProgressStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_PROGRESS)
+	repo.SetAllColumns("knowledge_id", "space_id", "stage", "content_hash", "total", "finished", "summary", "created_at", "updated_at")
+	return repo
+}
+
+// Get 获取知识点的处理进度
+func (s *KnowledgeProgressStore) Get(ctx context.Context, spaceID, knowledgeID string) (*types.KnowledgeProgress, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res types.KnowledgeProgress
+	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Upsert 保存知识点的处理进度，已存在时覆盖
+func (s *KnowledgeProgressStore) Upsert(ctx context.Context, data types.KnowledgeProgress) error {
+	if data.CreatedAt == 0 {
+		data.CreatedAt = time.Now().Unix()
+	}
+	data.UpdatedAt = time.Now().Unix()
+
+	query := sq.Insert(s.GetTable()).
+		Columns(s.GetAllColumns()...).
+		Values(data.KnowledgeID, data.SpaceID, data.Stage, data.ContentHash, data.Total, data.Finished, data.Summary, data.CreatedAt, data.UpdatedAt).
+		Suffix("ON CONFLICT (knowledge_id) DO UPDATE SET stage = EXCLUDED.stage, content_hash = EXCLUDED.content_hash, total = EXCLUDED.total, finished = EXCLUDED.finished, summary = EXCLUDED.summary, updated_at = EXCLUDED.updated_at")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// Delete 删除知识点的处理进度
+func (s *KnowledgeProgressStore) Delete(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+func (s *KnowledgeProgressStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
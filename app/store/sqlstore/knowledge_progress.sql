@@ -0,0 +1,26 @@
+-- 创建表 bw_knowledge_progress
+CREATE TABLE bw_knowledge_progress (
+    knowledge_id VARCHAR(32) PRIMARY KEY,         -- 知识点ID
+    space_id VARCHAR(32) NOT NULL,                -- 空间ID
+    stage SMALLINT NOT NULL,                      -- 正在处理的阶段
+    content_hash VARCHAR(64) NOT NULL,            -- 内容摘要，内容变化后进度失效
+    total INT NOT NULL DEFAULT 0,                 -- 分段总数
+    finished INT NOT NULL DEFAULT 0,              -- 已完成的分段数
+    summary JSONB NOT NULL DEFAULT '{}',          -- 已完成分段汇总的标题、标签等
+    created_at BIGINT NOT NULL,                   -- 创建时间
+    updated_at BIGINT NOT NULL                    -- 更新时间
+);
+
+CREATE INDEX idx_bw_knowledge_progress_space_id ON bw_knowledge_progress (space_id);
+
+-- 添加字段备注
+COMMENT ON TABLE bw_knowledge_progress IS '大文档分段处理进度，处理完成后删除';
+COMMENT ON COLUMN bw_knowledge_progress.knowledge_id IS '知识点ID';
+COMMENT ON COLUMN bw_knowledge_progress.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_progress.stage IS '正在处理的阶段';
+COMMENT ON COLUMN bw_knowledge_progress.content_hash IS '内容摘要，内容变化后进度失效';
+COMMENT ON COLUMN bw_knowledge_progress.total IS '分段总数';
+COMMENT ON COLUMN bw_knowledge_progress.finished IS '已完成的分段数，分段按顺序处理';
+COMMENT ON COLUMN bw_knowledge_progress.summary IS '已完成分段汇总的标题、标签等';
+COMMENT ON COLUMN bw_knowledge_progress.created_at IS '创建时间，UNIX时间戳';
+COMMENT ON COLUMN bw_knowledge_progress.updated_at IS '更新时间，UNIX时间戳';
@@ -0,0 +1,134 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc[*Provider](RegisterKey{}, func(provider *Provider) {
+		provider.stores.KnowledgeRelatedStore = NewKnowledgeRelatedStore(provider)
+	})
+}
+
+// KnowledgeRelatedStore 处理 bw_knowledge_related 表的操作
+type KnowledgeRelatedStore struct {
+	CommonFields
+}
+
+// NewKnowledgeRelatedStore 创建一个新的 KnowledgeRelatedStore 实例
+func NewKnowledgeRelatedStore(provider SqlProviderAchieve) *KnowledgeRelatedStore {
+	repo := &KnowledgeRelatedStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_RELATED)
+	repo.SetAllColumns("space_id", "knowledge_id", "related_id", "score", "created_at")
+	return repo
+}
+
+// Upsert 批量写入相关知识点，已存在的记录更新相似度
+func (s *KnowledgeRelatedStore) Upsert(ctx context.Context, data []types.KnowledgeRelated) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	query := sq.Insert(s.GetTable()).Columns(s.GetAllColumns()...)
+	for _, item := range data {
+		if item.CreatedAt == 0 {
+			item.CreatedAt = time.Now().Unix()
+		}
+		query = query.Values(item.SpaceID, item.KnowledgeID, item.RelatedID, item.Score, item.CreatedAt)
+	}
+	query = query.Suffix("ON CONFLICT (knowledge_id, related_id) DO UPDATE SET score = EXCLUDED.score, created_at = EXCLUDED.created_at")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// List 按相似度倒序返回知识点的相关知识点
+func (s *KnowledgeRelatedStore) List(ctx context.Context, spaceID, knowledgeID string, limit uint64) ([]types.KnowledgeRelated, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID}).
+		OrderBy("score DESC", "related_id").
+		Limit(limit)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeRelated
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListAboveScore 返回空间中相似度不低于 minScore 的相关知识点，相关关系是对称的，每对知识点只返回一条
+func (s *KnowledgeRelatedStore) ListAboveScore(ctx context.Context, spaceID string, minScore float32) ([]types.KnowledgeRelated, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID}).
+		Where(sq.GtOrEq{"score": minScore}).
+		Where("knowledge_id < related_id").
+		OrderBy("score DESC", "knowledge_id", "related_id")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeRelated
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BatchDelete 删除知识点的相关知识点，以及其他知识点中指向该知识点的记录
+func (s *KnowledgeRelatedStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID}, sq.Or{sq.Eq{"knowledge_id": knowledgeID}, sq.Eq{"related_id": knowledgeID}})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// BatchDeleteByIDs 删除与多个知识点相关的所有记录
+func (s *KnowledgeRelatedStore) BatchDeleteByIDs(ctx context.Context, knowledgeIDs []string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Or{sq.Eq{"knowledge_id": knowledgeIDs}, sq.Eq{"related_id": knowledgeIDs}})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+func (s *KnowledgeRelatedStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
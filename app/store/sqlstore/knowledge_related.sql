@@ -0,0 +1,20 @@
+-- 创建表 bw_knowledge_related
+CREATE TABLE bw_knowledge_related (
+    space_id VARCHAR(32) NOT NULL,     -- 空间ID
+    knowledge_id VARCHAR(32) NOT NULL, -- 知识点ID
+    related_id VARCHAR(32) NOT NULL,   -- 相关的知识点ID
+    score REAL NOT NULL,               -- 余弦相似度
+    created_at BIGINT NOT NULL,        -- 计算时间
+    PRIMARY KEY (knowledge_id, related_id)
+);
+
+CREATE INDEX idx_bw_knowledge_related_related_id ON bw_knowledge_related (related_id);
+CREATE INDEX idx_bw_knowledge_related_space_id ON bw_knowledge_related (space_id);
+
+-- 添加字段备注
+COMMENT ON TABLE bw_knowledge_related IS '基于向量相似度的相关知识点推荐，知识点生成向量后更新';
+COMMENT ON COLUMN bw_knowledge_related.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_related.knowledge_id IS '知识点ID';
+COMMENT ON COLUMN bw_knowledge_related.related_id IS '相关的知识点ID';
+COMMENT ON COLUMN bw_knowledge_related.score IS '两个知识点切片向量之间最大的余弦相似度';
+COMMENT ON COLUMN bw_knowledge_related.created_at IS '计算时间，UNIX时间戳';
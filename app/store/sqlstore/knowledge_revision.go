@@ -0,0 +1,127 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc[*Provider](RegisterKey{}, func(provider *Provider) {
+		provider.stores.KnowledgeRevisionStore = NewKnowledgeRevisionStore(provider)
+	})
+}
+
+// KnowledgeRevisionStore 处理 bw_knowledge_revision 表的操作
+type KnowledgeRevisionStore struct {
+	CommonFields
+}
+
+// NewKnowledgeRevisionStore 创建一个新的 KnowledgeRevisionStore 实例
+func NewKnowledgeRevisionStore(provider SqlProviderAchieve) *KnowledgeRevisionStore {
+	repo := &KnowledgeRevisionStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_REVISION)
+	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "origin", "title", "content", "content_type", "content_hash", "created_at")
+	return repo
+}
+
+// Create 创建新的历史版本
+func (s *KnowledgeRevisionStore) Create(ctx context.Context, data types.KnowledgeRevision) error {
+	if data.CreatedAt == 0 {
+		data.CreatedAt = time.Now().Unix()
+	}
+	query := sq.Insert(s.GetTable()).
+		Columns(s.GetAllColumns()...).
+		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Origin, data.Title, data.Content.String(), data.ContentType, data.ContentHash, data.CreatedAt)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// Get 获取指定的历史版本，包含内容
+func (s *KnowledgeRevisionStore) Get(ctx context.Context, spaceID, knowledgeID, id string) (*types.KnowledgeRevision, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID, "id": id})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res types.KnowledgeRevision
+	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// List 按创建时间倒序返回知识点的历史版本，不包含内容
+func (s *KnowledgeRevisionStore) List(ctx context.Context, spaceID, knowledgeID string, page, pageSize uint64) ([]types.KnowledgeRevision, error) {
+	query := sq.Select("id", "knowledge_id", "space_id", "user_id", "origin", "title", "content_type", "content_hash", "created_at").
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID}).
+		OrderBy("created_at DESC", "id DESC")
+	if page != 0 || pageSize != 0 {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeRevision
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *KnowledgeRevisionStore) Total(ctx context.Context, spaceID, knowledgeID string) (uint64, error) {
+	query := sq.Select("COUNT(*)").From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, ErrorSqlBuild(err)
+	}
+
+	var res uint64
+	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
+// BatchDelete 删除知识点的所有历史版本
+func (s *KnowledgeRevisionStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+func (s *KnowledgeRevisionStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
@@ -0,0 +1,28 @@
+-- 创建表 bw_knowledge_revision
+CREATE TABLE bw_knowledge_revision (
+    id VARCHAR(32) PRIMARY KEY,                   -- 版本ID
+    knowledge_id VARCHAR(32) NOT NULL,            -- 知识点ID
+    space_id VARCHAR(32) NOT NULL,                -- 空间ID
+    user_id VARCHAR(32) NOT NULL,                 -- 产生该版本的用户
+    origin VARCHAR(20) NOT NULL,                  -- 版本来源
+    title TEXT NOT NULL DEFAULT '',               -- 版本对应的标题
+    content TEXT NOT NULL,                        -- 加密后的内容
+    content_type VARCHAR(20) NOT NULL,            -- 内容格式
+    content_hash VARCHAR(64) NOT NULL,            -- 明文内容的摘要
+    created_at BIGINT NOT NULL                    -- 创建时间
+);
+
+CREATE INDEX idx_bw_knowledge_revision_knowledge_id ON bw_knowledge_revision (space_id, knowledge_id, created_at);
+
+-- 添加字段备注
+COMMENT ON TABLE bw_knowledge_revision IS '知识点的历史版本，每个版本保存完整的内容快照';
+COMMENT ON COLUMN bw_knowledge_revision.id IS '版本ID';
+COMMENT ON COLUMN bw_knowledge_revision.knowledge_id IS '知识点ID';
+COMMENT ON COLUMN bw_knowledge_revision.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_revision.user_id IS '产生该版本的用户';
+COMMENT ON COLUMN bw_knowledge_revision.origin IS '版本来源，create: 首次修改前的原始内容，edit: 用户修改，restore: 恢复历史版本，crawl: url 抓取';
+COMMENT ON COLUMN bw_knowledge_revision.title IS '版本对应的标题';
+COMMENT ON COLUMN bw_knowledge_revision.content IS '加密后的内容';
+COMMENT ON COLUMN bw_knowledge_revision.content_type IS '内容格式';
+COMMENT ON COLUMN bw_knowledge_revision.content_hash IS '明文内容的 sha256 摘要';
+COMMENT ON COLUMN bw_knowledge_revision.created_at IS '创建时间，UNIX时间戳';
@@ -0,0 +1,85 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/breeew/brew-api/pkg/register"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc[*Provider](RegisterKey{}, func(provider *Provider) {
+		provider.stores.KnowledgeTagMergeStore = NewKnowledgeTagMergeStore(provider)
+	})
+}
+
+// KnowledgeTagMergeStore 处理 bw_knowledge_tag_merge 表的操作
+type KnowledgeTagMergeStore struct {
+	CommonFields
+}
+
+// NewKnowledgeTagMergeStore 创建一个新的 KnowledgeTagMergeStore 实例
+func NewKnowledgeTagMergeStore(provider SqlProviderAchieve) *KnowledgeTagMergeStore {
+	repo := &KnowledgeTagMergeStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_TAG_MERGE)
+	repo.SetAllColumns("id", "space_id", "tags", "target", "score", "created_at")
+	return repo
+}
+
+// BatchCreate 批量写入标签合并建议
+func (s *KnowledgeTagMergeStore) BatchCreate(ctx context.Context, data []types.KnowledgeTagMerge) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	query := sq.Insert(s.GetTable()).Columns(s.GetAllColumns()...)
+	for _, item := range data {
+		if item.CreatedAt == 0 {
+			item.CreatedAt = time.Now().Unix()
+		}
+		query = query.Values(item.ID, item.SpaceID, item.Tags, item.Target, item.Score, item.CreatedAt)
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// List 返回空间的标签合并建议，按相似度倒序
+func (s *KnowledgeTagMergeStore) List(ctx context.Context, spaceID string) ([]types.KnowledgeTagMerge, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID}).
+		OrderBy("score DESC", "target")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeTagMerge
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *KnowledgeTagMergeStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
@@ -0,0 +1,20 @@
+-- 创建表 bw_knowledge_tag_merge
+CREATE TABLE bw_knowledge_tag_merge (
+    id VARCHAR(32) PRIMARY KEY,    -- 建议ID
+    space_id VARCHAR(32) NOT NULL, -- 空间ID
+    tags TEXT[] NOT NULL,          -- 建议合并的标签
+    target TEXT NOT NULL,          -- 建议保留的标签
+    score REAL NOT NULL,           -- 相似度
+    created_at BIGINT NOT NULL     -- 生成时间
+);
+
+CREATE INDEX idx_bw_knowledge_tag_merge_space_id ON bw_knowledge_tag_merge (space_id);
+
+-- 添加字段备注
+COMMENT ON TABLE bw_knowledge_tag_merge IS '根据标签向量相似度生成的标签合并建议，每次生成时替换空间的全部记录';
+COMMENT ON COLUMN bw_knowledge_tag_merge.id IS '建议ID';
+COMMENT ON COLUMN bw_knowledge_tag_merge.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_tag_merge.tags IS '建议合并的标签，包含保留的标签';
+COMMENT ON COLUMN bw_knowledge_tag_merge.target IS '建议保留的标签，为组内使用次数最多的标签';
+COMMENT ON COLUMN bw_knowledge_tag_merge.score IS '组内相连标签之间最低的余弦相似度';
+COMMENT ON COLUMN bw_knowledge_tag_merge.created_at IS '生成时间，UNIX时间戳';
@@ -31,6 +31,13 @@ type Provider struct {
 type Stores struct {
 	store.KnowledgeStore
 	store.KnowledgeChunkStore
+	store.KnowledgeProgressStore
+	store.KnowledgeRevisionStore
+	store.KnowledgeCrawlStore
+	store.KnowledgeLinkStore
+	store.KnowledgeRelatedStore
+	store.KnowledgeDuplicateStore
+	store.KnowledgeTagMergeStore
 	store.VectorStore
 	store.AccessTokenStore
 	store.UserSpaceStore
@@ -107,10 +114,43 @@ func (p *Provider) KnowledgeStore() store.KnowledgeStore {
 	return p.stores.KnowledgeStore
 }
 
+func (p *Provider) KnowledgeProgressStore() store.KnowledgeProgressStore {
+	return p.stores.KnowledgeProgressStore
+}
+
+func (p *Provider) KnowledgeRevisionStore() store.KnowledgeRevisionStore {
+	return p.stores.KnowledgeRevisionStore
+}
+
+func (p *Provider) KnowledgeCrawlStore() store.KnowledgeCrawlStore {
+	return p.stores.KnowledgeCrawlStore
+}
+
+func (p *Provider) KnowledgeLinkStore() store.KnowledgeLinkStore {
+	return p.stores.KnowledgeLinkStore
+}
+
+func (p *Provider) KnowledgeRelatedStore() store.KnowledgeRelatedStore {
+	return p.stores.KnowledgeRelatedStore
+}
+
+func (p *Provider) KnowledgeDuplicateStore() store.KnowledgeDuplicateStore {
+	return p.stores.KnowledgeDuplicateStore
+}
+
+func (p *Provider) KnowledgeTagMergeStore() store.KnowledgeTagMergeStore {
+	return p.stores.KnowledgeTagMergeStore
+}
+
 func (p *Provider) VectorStore() store.VectorStore {
 	return p.stores.VectorStore
 }
 
+// SetVectorStore 替换默认的 pgvector 向量存储，例如 qdrant
+func (p *Provider) SetVectorStore(s store.VectorStore) {
+	p.stores.VectorStore = s
+}
+
 func (p *Provider) AccessTokenStore() store.AccessTokenStore {
 	return p.stores.AccessTokenStore
 }
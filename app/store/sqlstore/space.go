@@ -2,6 +2,7 @@ package sqlstore
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -26,7 +27,7 @@ func NewSpaceStore(provider SqlProviderAchieve) *SpaceStore {
 	repo := &SpaceStore{}
 	repo.SetProvider(provider)
 	repo.SetTable(types.TABLE_SPACE)
-	repo.SetAllColumns("space_id", "title", "description", "created_at")
+	repo.SetAllColumns("space_id", "title", "description", "settings", "created_at")
 	return repo
 }
 
@@ -36,8 +37,8 @@ func (s *SpaceStore) Create(ctx context.Context, data types.Space) error {
 		data.CreatedAt = time.Now().Unix()
 	}
 	query := sq.Insert(s.GetTable()).
-		Columns("space_id", "title", "description", "created_at").
-		Values(data.SpaceID, data.Title, data.Description, data.CreatedAt)
+		Columns("space_id", "title", "description", "settings", "created_at").
+		Values(data.SpaceID, data.Title, data.Description, data.Settings, data.CreatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -87,6 +88,101 @@ func (s *SpaceStore) Update(ctx context.Context, spaceID, title, desc string) er
 	return err
 }
 
+// UpdateSettings 更新空间配置
+func (s *SpaceStore) UpdateSettings(ctx context.Context, spaceID string, settings types.SpaceSettings) error {
+	query := sq.Update(s.GetTable()).
+		Set("settings", settings).
+		Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// UpdateEmbeddingMigration 只更新空间配置中的 embedding 字段，避免覆盖同时修改的其他配置
+// 空间当前的迁移与 migration 不是同一次(已取消或重新发起)时不做修改，返回 false
+func (s *SpaceStore) UpdateEmbeddingMigration(ctx context.Context, spaceID string, migration types.EmbeddingMigration, embedding types.EmbeddingSettings) (bool, error) {
+	raw, err := json.Marshal(embedding)
+	if err != nil {
+		return false, err
+	}
+
+	query := sq.Update(s.GetTable()).
+		Set("settings", sq.Expr("jsonb_set(settings, '{embedding}', ?::jsonb)", string(raw))).
+		Where(sq.Eq{"space_id": spaceID}).
+		Where(sq.Expr("settings->'embedding'->'migration'->>'driver' = ?", migration.Driver)).
+		Where(sq.Expr("(settings->'embedding'->'migration'->>'started_at')::BIGINT = ?", migration.StartedAt))
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return false, ErrorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListEmbeddingMigrating 获取正在进行 embedding 模型迁移的空间，用于服务重启后继续迁移
+func (s *SpaceStore) ListEmbeddingMigrating(ctx context.Context) ([]types.Space, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).
+		Where(sq.Expr("settings->'embedding'->'migration'->>'status' = ?", types.EMBEDDING_MIGRATION_STATUS_RUNNING))
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.Space
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListTagMergeEnabled 获取开启了标签合并建议的空间
+func (s *SpaceStore) ListTagMergeEnabled(ctx context.Context) ([]types.Space, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).
+		Where(sq.Expr("(settings->'tags'->>'suggest_merges')::boolean"))
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []types.Space
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListSpaceIDs 按 space_id 升序返回大于 afterID 的空间ID，用于遍历所有空间
+func (s *SpaceStore) ListSpaceIDs(ctx context.Context, afterID string, limit uint64) ([]string, error) {
+	query := sq.Select("space_id").From(s.GetTable()).
+		Where(sq.Gt{"space_id": afterID}).
+		OrderBy("space_id").
+		Limit(limit)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, ErrorSqlBuild(err)
+	}
+
+	var res []string
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (s *SpaceStore) Delete(ctx context.Context, spaceID string) error {
 	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
 
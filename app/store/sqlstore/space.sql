@@ -2,6 +2,7 @@ CREATE TABLE bw_space (
     space_id VARCHAR(32) NOT NULL,  -- 空间的唯一标识
     title VARCHAR(32) NOT NULL,  -- 空间的唯一标识
     description TEXT NOT NULL, -- 用户在空间中的角色
+    settings JSONB NOT NULL DEFAULT '{}'::jsonb, -- 空间配置
     created_at BIGINT NOT NULL, -- 记录创建时间
     UNIQUE (space_id) -- 确保每个空间只有一个记录
 );
@@ -10,7 +11,10 @@ CREATE TABLE bw_space (
 COMMENT ON COLUMN bw_space.space_id IS '空间ID';
 COMMENT ON COLUMN bw_space.title IS '空间标题';
 COMMENT ON COLUMN bw_space.description IS '简介';
+COMMENT ON COLUMN bw_space.settings IS '空间配置，如混合检索权重';
 COMMENT ON COLUMN bw_space.created_at IS '创建时间，存储为时间戳';
 
 -- 创建 user_id 和 space_id 索引
-CREATE INDEX idx_space_id ON bw_space (space_id);
\ No newline at end of file
+CREATE INDEX idx_space_id ON bw_space (space_id);
+-- 已有数据库升级
+-- ALTER TABLE bw_space ADD COLUMN settings JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -21,12 +21,24 @@ type VectorStore struct {
 	CommonFields
 }
 
+// vectorRow pgvector 的行结构，embedding 列需要由 pgvector.Vector 负责编解码
+type vectorRow struct {
+	types.Vector
+	Embedding pgvector.Vector `db:"embedding"`
+}
+
+func (r vectorRow) toVector() types.Vector {
+	v := r.Vector
+	v.Embedding = r.Embedding.Slice()
+	return v
+}
+
 // NewBwVectorStore 创建新的 BwVectorStore 实例
 func NewVectorStore(provider SqlProviderAchieve) *VectorStore {
 	repo := &VectorStore{}
 	repo.SetProvider(provider)
 	repo.SetTable(types.TABLE_VECTORS)
-	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "embedding", "original_length", "created_at", "updated_at")
+	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "model", "dimension", "original_length", "created_at", "updated_at")
 	return repo
 }
 
@@ -39,8 +51,8 @@ func (s *VectorStore) Create(ctx context.Context, data types.Vector) error {
 		data.UpdatedAt = time.Now().Unix()
 	}
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "original_length", "created_at", "updated_at").
-		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Resource, data.Embedding, data.OriginalLength, data.CreatedAt, data.UpdatedAt)
+		Columns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "model", "dimension", "original_length", "created_at", "updated_at").
+		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Resource, pgvector.NewVector(data.Embedding), data.Model, len(data.Embedding), data.OriginalLength, data.CreatedAt, data.UpdatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -59,7 +71,7 @@ func (s *VectorStore) Create(ctx context.Context, data types.Vector) error {
 // BatchCreate 批量创建新的文本向量记录
 func (s *VectorStore) BatchCreate(ctx context.Context, datas []types.Vector) error {
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "original_length", "created_at", "updated_at")
+		Columns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "model", "dimension", "original_length", "created_at", "updated_at")
 
 	for _, data := range datas {
 		if data.CreatedAt == 0 {
@@ -68,7 +80,7 @@ func (s *VectorStore) BatchCreate(ctx context.Context, datas []types.Vector) err
 		if data.UpdatedAt == 0 {
 			data.UpdatedAt = time.Now().Unix()
 		}
-		query = query.Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Resource, data.Embedding, data.OriginalLength, data.CreatedAt, data.UpdatedAt)
+		query = query.Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Resource, pgvector.NewVector(data.Embedding), data.Model, len(data.Embedding), data.OriginalLength, data.CreatedAt, data.UpdatedAt)
 	}
 
 	queryString, args, err := query.ToSql()
@@ -92,19 +104,21 @@ func (s *VectorStore) GetVector(ctx context.Context, spaceID, knowledgeID string
 		return nil, ErrorSqlBuild(err)
 	}
 
-	var res types.Vector
-	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
+	var row vectorRow
+	if err = s.GetReplica(ctx).Get(&row, queryString, args...); err != nil {
 		return nil, err
 	}
+	res := row.toVector()
 	return &res, nil
 }
 
 // Update 更新文本向量记录
-func (s *VectorStore) Update(ctx context.Context, spaceID, knowledgeID, id string, vector pgvector.Vector) error {
+func (s *VectorStore) Update(ctx context.Context, spaceID, knowledgeID, id, model string, vector []float32) error {
 	query := sq.Update(s.GetTable()).
-		Set("embedding", vector).
+		Set("embedding", pgvector.NewVector(vector)).
+		Set("dimension", len(vector)).
 		Set("updated_at", time.Now().Unix()).
-		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID, "id": id})
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID, "id": id, "model": model})
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -152,6 +166,35 @@ func (s *VectorStore) BatchDelete(ctx context.Context, spaceID, knowledgeID stri
 	return err
 }
 
+// DeleteByModel 删除指定模型生成的向量，knowledgeID 为空时删除整个空间下该模型的向量
+func (s *VectorStore) DeleteByModel(ctx context.Context, spaceID, knowledgeID, model string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "model": model})
+	if knowledgeID != "" {
+		query = query.Where(sq.Eq{"knowledge_id": knowledgeID})
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// PruneModels 删除空间下除 keepModel 以外的所有模型生成的向量，用于模型迁移完成后清理旧向量
+func (s *VectorStore) PruneModels(ctx context.Context, spaceID, keepModel string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID}).Where(sq.NotEq{"model": keepModel})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return ErrorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
 func (s *VectorStore) DeleteAll(ctx context.Context, spaceID string) error {
 	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
 
@@ -173,22 +216,28 @@ func (s *VectorStore) ListVectors(ctx context.Context, opts types.GetVectorsOpti
 		return nil, ErrorSqlBuild(err)
 	}
 
-	var res []types.Vector
-	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+	var rows []vectorRow
+	if err = s.GetReplica(ctx).Select(&rows, queryString, args...); err != nil {
 		return nil, err
 	}
+
+	res := make([]types.Vector, 0, len(rows))
+	for _, v := range rows {
+		res = append(res, v.toVector())
+	}
 	return res, nil
 }
 
-// ListBwVectors 分页获取文本向量记录列表
-func (s *VectorStore) Query(ctx context.Context, opts types.GetVectorsOptions, vectors pgvector.Vector, limit uint64) ([]types.QueryResult, error) {
+// Query 向量检索，只与相同维度的向量比较，不同维度的向量无法计算距离
+func (s *VectorStore) Query(ctx context.Context, opts types.GetVectorsOptions, vectors []float32, limit uint64) ([]types.QueryResult, error) {
 	// pgvector supported distance functions are:
 	// <-> - L2 distance
 	// <#> - (negative) inner product
 	// <=> - cosine distance
 	// <+> - L1 distance (added in 0.7.0)
-	cosColum, vectorArgs, _ := sq.Expr("(embedding <=> ?) as cos", vectors).ToSql()
+	cosColum, vectorArgs, _ := sq.Expr("(embedding <=> ?) as cos", pgvector.NewVector(vectors)).ToSql()
 	query := sq.Select("id", "knowledge_id", "original_length", cosColum).From(s.GetTable()).Limit(limit).OrderBy("cos ASC")
+	opts.Dimension = len(vectors)
 	opts.Apply(&query)
 
 	queryString, args, err := query.ToSql()
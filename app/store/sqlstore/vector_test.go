@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/breeew/brew-api/pkg/types"
-	"github.com/pgvector/pgvector-go"
 )
 
 type PGConfig struct {
@@ -46,7 +45,7 @@ func TestQuery(t *testing.T) {
 	if err = json.Unmarshal(raw, &vectors); err != nil {
 		t.Fatal(err)
 	}
-	res, err := provider.stores.VectorStore.Query(ctx, types.GetVectorsOptions{SpaceID: "test"}, pgvector.NewVector(vectors), 5)
+	res, err := provider.stores.VectorStore.Query(ctx, types.GetVectorsOptions{SpaceID: "test"}, vectors, 5)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1,14 +1,17 @@
 -- 创建表
 CREATE TABLE bw_vectors (
-    id VARCHAR(32) PRIMARY KEY,
+    id VARCHAR(32) NOT NULL,
     knowledge_id VARCHAR(32) NOT NULL,
     space_id VARCHAR(32) NOT NULL,
     user_id VARCHAR(32) NOT NULL,
     resource VARCHAR(32) NOT NULL,
-    embedding vector(1024) NOT NULL,
+    embedding vector NOT NULL,
+    model VARCHAR(64) NOT NULL DEFAULT '',
+    dimension INT NOT NULL DEFAULT 0,
     original_length INT NOT NULL DEFAULT 0,
     created_at BIGINT NOT NULL,
-    updated_at BIGINT NOT NULL
+    updated_at BIGINT NOT NULL,
+    PRIMARY KEY (id, model)
 );
 
 -- 添加字段注释
@@ -17,9 +20,22 @@ COMMENT ON COLUMN bw_vectors.space_id IS '空间ID，用于标识所属空间';
 COMMENT ON COLUMN bw_vectors.user_id IS '用户ID，用于标识向量所属用户';
 COMMENT ON COLUMN bw_vectors.embedding IS '文本向量，存储经过编码后的文本向量表示';
 COMMENT ON COLUMN bw_vectors.resource IS '资源类型';
+COMMENT ON COLUMN bw_vectors.model IS '生成向量的 embedding 模型，模型迁移期间同一个 chunk 会同时存在新旧两个模型的向量';
+COMMENT ON COLUMN bw_vectors.dimension IS '向量维度，检索时只比较相同维度的向量';
 COMMENT ON COLUMN bw_knowledge_chunk.original_length IS '关联知识点长度';
 COMMENT ON COLUMN bw_vectors.created_at IS '创建时间，UNIX时间戳';
 COMMENT ON COLUMN bw_vectors.updated_at IS '更新时间，UNIX时间戳';
 
 
-CREATE INDEX idx_vectors_space_id_resource_knowledge_id ON bw_vectors (space_id, resource, knowledge_id);
\ No newline at end of file
+CREATE INDEX idx_vectors_space_id_resource_knowledge_id ON bw_vectors (space_id, resource, knowledge_id);
+CREATE INDEX idx_vectors_space_id_model_dimension ON bw_vectors (space_id, model, dimension);
+
+-- embedding 列不限制维度，以支持不同维度的 embedding 模型共存
+-- 如需 hnsw 索引，需要按维度建立部分索引，例如:
+-- CREATE INDEX idx_vectors_embedding_1024 ON bw_vectors USING hnsw ((embedding::vector(1024)) vector_cosine_ops) WHERE dimension = 1024;
+
+-- 从旧版本升级:
+-- ALTER TABLE bw_vectors ALTER COLUMN embedding TYPE vector;
+-- ALTER TABLE bw_vectors ADD COLUMN model VARCHAR(64) NOT NULL DEFAULT '', ADD COLUMN dimension INT NOT NULL DEFAULT 0;
+-- UPDATE bw_vectors SET dimension = vector_dims(embedding);
+-- ALTER TABLE bw_vectors DROP CONSTRAINT bw_vectors_pkey, ADD PRIMARY KEY (id, model);
@@ -5,8 +5,6 @@ import (
 	"encoding/json"
 	"time"
 
-	"github.com/pgvector/pgvector-go"
-
 	"github.com/breeew/brew-api/pkg/ai"
 	"github.com/breeew/brew-api/pkg/sqlstore"
 	"github.com/breeew/brew-api/pkg/types"
@@ -34,6 +32,16 @@ type KnowledgeStore interface {
 	FinishedStageSummarize(ctx context.Context, spaceID, id string, summary ai.ChunkResult) error
 	FinishedStageEmbedding(ctx context.Context, spaceID, id string) error
 	SetRetryTimes(ctx context.Context, spaceID, id string, retryTimes int) error
+	SetContentHash(ctx context.Context, spaceID, id, hash string) error
+	// ListMissingContentHash 返回没有内容哈希的知识点，用于补齐历史数据
+	ListMissingContentHash(ctx context.Context, spaceID, afterID string, limit uint64) ([]types.Knowledge, error)
+	// ListHashDuplicates 返回内容哈希相同的知识点
+	ListHashDuplicates(ctx context.Context, spaceID string) ([]types.KnowledgeHashDuplicate, error)
+	// ListTags 按使用次数倒序返回空间中的标签
+	ListTags(ctx context.Context, spaceID string) ([]types.KnowledgeTag, error)
+	// RenameTags 将 from 中的标签替换为 to，返回修改的知识点数量
+	RenameTags(ctx context.Context, spaceID string, from []string, to string) (int64, error)
+	DeleteTags(ctx context.Context, spaceID string, tags []string) (int64, error)
 	ListProcessingKnowledges(ctx context.Context, retryTimes int, page, pageSize uint64) ([]types.Knowledge, error)
 	ListFailedKnowledges(ctx context.Context, stage types.KnowledgeStage, retryTimes int, page, pageSize uint64) ([]types.Knowledge, error)
 }
@@ -50,23 +58,109 @@ type KnowledgeChunkStore interface {
 	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
 	BatchDeleteByIDs(ctx context.Context, knowledgeIDs []string) error
 	List(ctx context.Context, spaceID, knowledgeID string) ([]types.KnowledgeChunk, error)
+	ListByKnowledgeIDs(ctx context.Context, spaceID string, knowledgeIDs []string) ([]types.KnowledgeChunk, error)
+	Search(ctx context.Context, opts types.SearchChunksOptions, lexemes []string, limit uint64) ([]types.ChunkSearchResult, error)
+	SetKeywords(ctx context.Context, spaceID, knowledgeID, id string, keywords []string) error
+	// ListMissingKeywords 返回没有全文索引的切片，用于补齐历史数据
+	ListMissingKeywords(ctx context.Context, afterID string, limit uint64) ([]types.KnowledgeChunk, error)
+}
+
+// KnowledgeProgressStore 大文档分段处理进度
+type KnowledgeProgressStore interface {
+	sqlstore.SqlCommons
+	Get(ctx context.Context, spaceID, knowledgeID string) (*types.KnowledgeProgress, error)
+	Upsert(ctx context.Context, data types.KnowledgeProgress) error
+	Delete(ctx context.Context, spaceID, knowledgeID string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+// KnowledgeRevisionStore 知识点的历史版本，版本创建后不可修改
+type KnowledgeRevisionStore interface {
+	sqlstore.SqlCommons
+	Create(ctx context.Context, data types.KnowledgeRevision) error
+	Get(ctx context.Context, spaceID, knowledgeID, id string) (*types.KnowledgeRevision, error)
+	// List 按创建时间倒序返回历史版本，不包含内容
+	List(ctx context.Context, spaceID, knowledgeID string, page, pageSize uint64) ([]types.KnowledgeRevision, error)
+	Total(ctx context.Context, spaceID, knowledgeID string) (uint64, error)
+	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+// KnowledgeCrawlStore url 类知识点的抓取计划
+type KnowledgeCrawlStore interface {
+	sqlstore.SqlCommons
+	Create(ctx context.Context, data types.KnowledgeCrawl) error
+	Get(ctx context.Context, spaceID, knowledgeID string) (*types.KnowledgeCrawl, error)
+	// ListDue 返回已到抓取时间的记录
+	ListDue(ctx context.Context, now int64, limit uint64) ([]types.KnowledgeCrawl, error)
+	// Crawled 记录一次成功的抓取
+	Crawled(ctx context.Context, spaceID, knowledgeID, contentHash string, crawledAt, nextCrawlAt int64) error
+	// Failed 记录一次失败的抓取
+	Failed(ctx context.Context, spaceID, knowledgeID, reason string, nextCrawlAt int64) error
+	Delete(ctx context.Context, spaceID, knowledgeID string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+// KnowledgeLinkStore 知识点之间的链接
+type KnowledgeLinkStore interface {
+	sqlstore.SqlCommons
+	BatchCreate(ctx context.Context, data []types.KnowledgeLink) error
+	ListLinks(ctx context.Context, opts types.GetKnowledgeLinksOptions, page, pageSize uint64) ([]types.KnowledgeLink, error)
+	// DeleteFrom 删除知识点指向其他知识点的链接
+	DeleteFrom(ctx context.Context, spaceID, fromID string) error
+	// BatchDelete 删除知识点的出链及入链
+	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
+	BatchDeleteByIDs(ctx context.Context, knowledgeIDs []string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+// KnowledgeRelatedStore 基于向量相似度的相关知识点
+type KnowledgeDuplicateStore interface {
+	sqlstore.SqlCommons
+	BatchCreate(ctx context.Context, data []types.KnowledgeDuplicate) error
+	List(ctx context.Context, spaceID string) ([]types.KnowledgeDuplicate, error)
+	// BatchDelete 删除与知识点相关的重复记录
+	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
+	BatchDeleteByIDs(ctx context.Context, knowledgeIDs []string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+type KnowledgeTagMergeStore interface {
+	sqlstore.SqlCommons
+	BatchCreate(ctx context.Context, data []types.KnowledgeTagMerge) error
+	List(ctx context.Context, spaceID string) ([]types.KnowledgeTagMerge, error)
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+type KnowledgeRelatedStore interface {
+	sqlstore.SqlCommons
+	Upsert(ctx context.Context, data []types.KnowledgeRelated) error
+	// List 按相似度倒序返回
+	List(ctx context.Context, spaceID, knowledgeID string, limit uint64) ([]types.KnowledgeRelated, error)
+	// ListAboveScore 每对知识点只返回一条
+	ListAboveScore(ctx context.Context, spaceID string, minScore float32) ([]types.KnowledgeRelated, error)
+	// BatchDelete 删除知识点的相关知识点及其他知识点中指向它的记录
+	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
+	BatchDeleteByIDs(ctx context.Context, knowledgeIDs []string) error
+	DeleteAll(ctx context.Context, spaceID string) error
 }
 
-// TODO support other vector db
-// current only pg
-// next qdrant
+// VectorStore 向量存储，与具体的向量数据库无关
+// 当前支持 pgvector(sqlstore) 与 qdrant，通过配置 vector_db.driver 选择
 type VectorStore interface {
 	sqlstore.SqlCommons
 	Create(ctx context.Context, data types.Vector) error
 	BatchCreate(ctx context.Context, datas []types.Vector) error
 	GetVector(ctx context.Context, spaceID, knowledgeID string) (*types.Vector, error)
-	Update(ctx context.Context, spaceID, knowledgeID, id string, vector pgvector.Vector) error
+	Update(ctx context.Context, spaceID, knowledgeID, id, model string, vector []float32) error
 	Delete(ctx context.Context, spaceID, knowledgeID, id string) error
 	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
+	DeleteByModel(ctx context.Context, spaceID, knowledgeID, model string) error
+	PruneModels(ctx context.Context, spaceID, keepModel string) error
 	DeleteAll(ctx context.Context, spaceID string) error
 	DeleteByResource(ctx context.Context, spaceID, resource string) error
 	ListVectors(ctx context.Context, opts types.GetVectorsOptions, page, pageSize uint64) ([]types.Vector, error)
-	Query(ctx context.Context, opts types.GetVectorsOptions, vectors pgvector.Vector, limit uint64) ([]types.QueryResult, error)
+	Query(ctx context.Context, opts types.GetVectorsOptions, vectors []float32, limit uint64) ([]types.QueryResult, error)
 }
 
 type AccessTokenStore interface {
@@ -97,6 +191,11 @@ type SpaceStore interface {
 	Create(ctx context.Context, data types.Space) error
 	GetSpace(ctx context.Context, spaceID string) (*types.Space, error)
 	Update(ctx context.Context, spaceID, title, desc string) error
+	UpdateSettings(ctx context.Context, spaceID string, settings types.SpaceSettings) error
+	UpdateEmbeddingMigration(ctx context.Context, spaceID string, migration types.EmbeddingMigration, embedding types.EmbeddingSettings) (bool, error)
+	ListEmbeddingMigrating(ctx context.Context) ([]types.Space, error)
+	ListSpaceIDs(ctx context.Context, afterID string, limit uint64) ([]string, error)
+	ListTagMergeEnabled(ctx context.Context) ([]types.Space, error)
 	Delete(ctx context.Context, spaceID string) error
 	List(ctx context.Context, spaceIDs []string, page, pageSize uint64) ([]types.Space, error)
 }
@@ -176,6 +275,7 @@ type ChatMessageExtStore interface {
 	GetChatMessageExt(ctx context.Context, spaceID, sessionID, messageID string) (*types.ChatMessageExt, error)
 	ListChatMessageExts(ctx context.Context, messageIDs []string) ([]types.ChatMessageExt, error)
 	Update(ctx context.Context, id string, data types.ChatMessageExt) error
+	UpdateCitations(ctx context.Context, messageID string, citations types.Citations) error
 	Delete(ctx context.Context, id string) error
 	DeleteAll(ctx context.Context, spaceID string) error
 	DeleteSessionMessageExt(ctx context.Context, spaceID, sessionID string) error
@@ -17,7 +17,7 @@ func main() {
 		},
 	}
 
-	root.AddCommand(service.NewCommand(), service.NewProcessCommand())
+	root.AddCommand(service.NewCommand(), service.NewProcessCommand(), service.NewExportCommand(), service.NewImportCommand(), service.NewEvalCommand())
 
 	if err := root.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/breeew/brew-api/app/core"
+	v1 "github.com/breeew/brew-api/app/logic/v1"
+	"github.com/breeew/brew-api/pkg/plugins"
+	"github.com/breeew/brew-api/pkg/security"
+)
+
+type ArchiveOptions struct {
+	Options
+	SpaceID string
+	UserID  string
+	File    string
+	Reembed bool
+}
+
+// NewExportCommand 将空间导出为归档文件
+func NewExportCommand() *cobra.Command {
+	opts := &ArchiveOptions{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "export a space as a portable archive",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunExport(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(&opts.SpaceID, "space", "", "id of the space to export")
+	cmd.Flags().StringVar(&opts.UserID, "user", "", "id of the user whose journals and chat sessions are exported")
+	cmd.Flags().StringVarP(&opts.File, "output", "o", "", "archive file path, default: space-{space}.zip")
+	cmd.MarkFlagRequired("space")
+	cmd.MarkFlagRequired("user")
+	return cmd
+}
+
+func RunExport(opts *ArchiveOptions) error {
+	app := core.MustSetupCore(core.MustLoadBaseConfig(opts.ConfigPath))
+	plugins.Setup(app.InstallPlugins, opts.Init)
+
+	if opts.File == "" {
+		opts.File = fmt.Sprintf("space-%s.zip", opts.SpaceID)
+	}
+	f, err := os.Create(opts.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx := context.WithValue(context.Background(), v1.TOKEN_CONTEXT_KEY, security.TokenClaims{
+		User:  opts.UserID,
+		Appid: app.DefaultAppid(),
+	})
+	if err = v1.NewArchiveLogic(ctx, app).ExportSpace(opts.SpaceID, f); err != nil {
+		os.Remove(opts.File)
+		return err
+	}
+	fmt.Println("Space exported to", opts.File)
+	return nil
+}
+
+// NewImportCommand 从归档文件创建新的空间，导入的空间归属于指定的用户
+func NewImportCommand() *cobra.Command {
+	opts := &ArchiveOptions{}
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "import a space from an archive",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunImport(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(&opts.UserID, "user", "", "id of the user who owns the imported space")
+	cmd.Flags().StringVar(&opts.File, "input", "", "archive file path")
+	cmd.Flags().BoolVar(&opts.Reembed, "reembed", false, "regenerate embeddings with the current model instead of importing them")
+	cmd.MarkFlagRequired("user")
+	cmd.MarkFlagRequired("input")
+	return cmd
+}
+
+func RunImport(opts *ArchiveOptions) error {
+	app := core.MustSetupCore(core.MustLoadBaseConfig(opts.ConfigPath))
+	plugins.Setup(app.InstallPlugins, opts.Init)
+
+	f, err := os.Open(opts.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.WithValue(context.Background(), v1.TOKEN_CONTEXT_KEY, security.TokenClaims{
+		User:  opts.UserID,
+		Appid: app.DefaultAppid(),
+	})
+	spaceID, err := v1.NewArchiveLogic(ctx, app).ImportSpace(f, stat.Size(), opts.Reembed)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Space imported:", spaceID)
+	return nil
+}
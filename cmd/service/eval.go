@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/breeew/brew-api/app/core"
+	v1 "github.com/breeew/brew-api/app/logic/v1"
+	"github.com/breeew/brew-api/pkg/plugins"
+	"github.com/breeew/brew-api/pkg/rageval"
+	"github.com/breeew/brew-api/pkg/security"
+)
+
+type EvalOptions struct {
+	Options
+	SpaceID  string
+	UserID   string
+	Dataset  string
+	Output   string
+	Ks       []int
+	Generate bool
+}
+
+// NewEvalCommand 使用数据集离线评估空间的检索与生成效果
+func NewEvalCommand() *cobra.Command {
+	opts := &EvalOptions{}
+	cmd := &cobra.Command{
+		Use:   "eval",
+		Short: "evaluate retrieval and generation of a space with a dataset",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunEval(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(&opts.SpaceID, "space", "", "id of the space to evaluate")
+	cmd.Flags().StringVar(&opts.UserID, "user", "", "only retrieve knowledge of this user, default: the whole space")
+	cmd.Flags().StringVar(&opts.Dataset, "dataset", "", "jsonl file, one {\"id\", \"question\", \"expected_ids\", \"reference_answer\"} per line")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "write the full report as json to this file")
+	cmd.Flags().IntSliceVar(&opts.Ks, "k", rageval.DefaultKs, "cutoffs of recall@k")
+	cmd.Flags().BoolVar(&opts.Generate, "generate", false, "generate answers for cases with a reference answer and score them")
+	cmd.MarkFlagRequired("space")
+	cmd.MarkFlagRequired("dataset")
+	return cmd
+}
+
+func RunEval(opts *EvalOptions) error {
+	f, err := os.Open(opts.Dataset)
+	if err != nil {
+		return err
+	}
+	cases, err := rageval.LoadCases(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("load dataset: %w", err)
+	}
+
+	app := core.MustSetupCore(core.MustLoadBaseConfig(opts.ConfigPath))
+	plugins.Setup(app.InstallPlugins, opts.Init)
+
+	ctx := context.WithValue(context.Background(), v1.TOKEN_CONTEXT_KEY, security.TokenClaims{
+		User:  opts.UserID,
+		Appid: app.DefaultAppid(),
+	})
+	report, err := v1.NewEvalLogic(ctx, app).Run(cases, v1.EvalOptions{
+		SpaceID:  opts.SpaceID,
+		UserID:   opts.UserID,
+		Ks:       opts.Ks,
+		Generate: opts.Generate,
+	})
+	if err != nil {
+		return err
+	}
+
+	report.WriteText(os.Stdout)
+	if opts.Output == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(opts.Output, raw, 0o644); err != nil {
+		return err
+	}
+	fmt.Println("Report written to", opts.Output)
+	return nil
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"encoding/json"
 	"log/slog"
+	"mime/multipart"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +13,8 @@ import (
 	"github.com/breeew/brew-api/app/core"
 	v1 "github.com/breeew/brew-api/app/logic/v1"
 	"github.com/breeew/brew-api/app/response"
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
 	"github.com/breeew/brew-api/pkg/types"
 	"github.com/breeew/brew-api/pkg/utils"
 )
@@ -62,10 +66,12 @@ type CreateKnowledgeRequest struct {
 	ContentType types.KnowledgeContentType `json:"content_type" binding:"required"`
 	Kind        string                     `json:"kind"`
 	Async       bool                       `json:"async"`
+	Strict      bool                       `json:"strict"` // 已存在重复内容时拒绝创建
 }
 
 type CreateKnowledgeResponse struct {
-	ID string `json:"id"`
+	ID        string                    `json:"id"`
+	Duplicate *types.DuplicateKnowledge `json:"duplicate,omitempty"` // 已存在的重复知识点
 }
 
 func (s *HttpSrv) CreateKnowledge(c *gin.Context) {
@@ -77,7 +83,7 @@ func (s *HttpSrv) CreateKnowledge(c *gin.Context) {
 	}
 
 	spaceID, _ := v1.InjectSpaceID(c)
-	var handler func(spaceID, resource string, kind types.KnowledgeKind, content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, error)
+	var handler func(strict bool, spaceID, resource string, kind types.KnowledgeKind, content types.KnowledgeContent, contentType types.KnowledgeContentType) (string, *types.DuplicateKnowledge, error)
 	logic := v1.NewKnowledgeLogic(c, s.Core)
 	if req.Async {
 		handler = logic.InsertContentAsync
@@ -85,7 +91,40 @@ func (s *HttpSrv) CreateKnowledge(c *gin.Context) {
 		handler = logic.InsertContent
 	}
 
-	id, err := handler(spaceID, req.Resource, types.KindNewFromString(req.Kind), req.Content, req.ContentType)
+	id, duplicate, err := handler(req.Strict, spaceID, req.Resource, types.KindNewFromString(req.Kind), req.Content, req.ContentType)
+	if err != nil {
+		if duplicate != nil {
+			response.APIErrorWithData(c, err, CreateKnowledgeResponse{
+				Duplicate: duplicate,
+			})
+			return
+		}
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, CreateKnowledgeResponse{
+		ID:        id,
+		Duplicate: duplicate,
+	})
+}
+
+type CreateFileKnowledgeRequest struct {
+	File     string `json:"file" binding:"required"` // 已上传文件的路径，即 upload key 返回的 full_path
+	Resource string `json:"resource"`
+	Async    bool   `json:"async"`
+}
+
+func (s *HttpSrv) CreateFileKnowledge(c *gin.Context) {
+	var req CreateFileKnowledgeRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	id, err := v1.NewKnowledgeLogic(c, s.Core).InsertFile(!req.Async, spaceID, req.Resource, req.File)
 	if err != nil {
 		response.APIError(c, err)
 		return
@@ -96,6 +135,219 @@ func (s *HttpSrv) CreateKnowledge(c *gin.Context) {
 	})
 }
 
+type CreateURLKnowledgeRequest struct {
+	URL      string `json:"url" binding:"required"`
+	Resource string `json:"resource"`
+	Async    bool   `json:"async"`
+	Interval int64  `json:"interval"` // 重新抓取的间隔(小时)，默认 24 小时
+}
+
+func (s *HttpSrv) CreateURLKnowledge(c *gin.Context) {
+	var req CreateURLKnowledgeRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	id, err := v1.NewKnowledgeLogic(c, s.Core).InsertURL(!req.Async, spaceID, req.Resource, req.URL, req.Interval)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, CreateKnowledgeResponse{
+		ID: id,
+	})
+}
+
+type ImportNotesRequest struct {
+	File *multipart.FileHeader `form:"file" binding:"required"` // Obsidian vault、Notion 导出或 markdown 目录打包的 zip
+}
+
+func (s *HttpSrv) ImportNotes(c *gin.Context) {
+	var req ImportNotesRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	file, err := req.File.Open()
+	if err != nil {
+		response.APIError(c, errors.New("ImportNotes.File.Open", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest))
+		return
+	}
+	defer file.Close()
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	result, err := v1.NewKnowledgeLogic(c, s.Core).ImportNotes(spaceID, file, req.File.Size)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, result)
+}
+
+type ListKnowledgeRevisionsRequest struct {
+	ID       string `json:"id" form:"id" binding:"required"`
+	Page     uint64 `json:"page" form:"page" binding:"required"`
+	PageSize uint64 `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
+}
+
+type ListKnowledgeRevisionsResponse struct {
+	List  []types.KnowledgeRevision `json:"list"`
+	Total uint64                    `json:"total"`
+}
+
+func (s *HttpSrv) ListKnowledgeRevisions(c *gin.Context) {
+	var req ListKnowledgeRevisionsRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, total, err := v1.NewKnowledgeLogic(c, s.Core).ListRevisions(spaceID, req.ID, req.Page, req.PageSize)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, ListKnowledgeRevisionsResponse{
+		List:  list,
+		Total: total,
+	})
+}
+
+type DiffKnowledgeRevisionsRequest struct {
+	ID   string `json:"id" form:"id" binding:"required"`
+	From string `json:"from" form:"from" binding:"required"`
+	To   string `json:"to" form:"to"` // 为空时与当前内容比较
+}
+
+func (s *HttpSrv) DiffKnowledgeRevisions(c *gin.Context) {
+	var req DiffKnowledgeRevisionsRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	res, err := v1.NewKnowledgeLogic(c, s.Core).DiffRevisions(spaceID, req.ID, req.From, req.To)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, res)
+}
+
+type RestoreKnowledgeRevisionRequest struct {
+	ID       string `json:"id" binding:"required"`
+	Revision string `json:"revision" binding:"required"`
+}
+
+func (s *HttpSrv) RestoreKnowledgeRevision(c *gin.Context) {
+	var req RestoreKnowledgeRevisionRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err := v1.NewKnowledgeLogic(c, s.Core).RestoreRevision(spaceID, req.ID, req.Revision); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
+type ListKnowledgeBacklinksRequest struct {
+	ID string `json:"id" form:"id" binding:"required"`
+}
+
+func (s *HttpSrv) ListKnowledgeBacklinks(c *gin.Context) {
+	var req ListKnowledgeBacklinksRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, err := v1.NewKnowledgeLogic(c, s.Core).ListBacklinks(spaceID, req.ID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, list)
+}
+
+type ListRelatedKnowledgeRequest struct {
+	ID string `json:"id" form:"id" binding:"required"`
+}
+
+func (s *HttpSrv) ListRelatedKnowledge(c *gin.Context) {
+	var req ListRelatedKnowledgeRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, err := v1.NewKnowledgeLogic(c, s.Core).ListRelated(spaceID, req.ID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, list)
+}
+
+// GetKnowledgeDuplicates 返回定时去重任务生成的空间去重报告
+func (s *HttpSrv) GetKnowledgeDuplicates(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	report, err := v1.NewKnowledgeLogic(c, s.Core).GetDuplicateReport(spaceID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, report)
+}
+
+type GetKnowledgeGraphRequest struct {
+	ID    string `json:"id" form:"id"`       // 为空时返回整个空间的知识图谱
+	Depth int    `json:"depth" form:"depth"` // 以 id 为中心展开的层数，默认 1，最大 3
+}
+
+func (s *HttpSrv) GetKnowledgeGraph(c *gin.Context) {
+	var req GetKnowledgeGraphRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	graph, err := v1.NewKnowledgeLogic(c, s.Core).GetGraph(spaceID, req.ID, req.Depth)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, graph)
+}
+
 type GetKnowledgeRequest struct {
 	ID string `json:"id" form:"id" binding:"required"`
 }
@@ -121,10 +373,11 @@ func (s *HttpSrv) GetKnowledge(c *gin.Context) {
 }
 
 type ListKnowledgeRequest struct {
-	Resource string `json:"resource" form:"resource"`
-	Keywords string `json:"keywords" form:"keywords"`
-	Page     uint64 `json:"page" form:"page" binding:"required"`
-	PageSize uint64 `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
+	Resource string   `json:"resource" form:"resource"`
+	Keywords string   `json:"keywords" form:"keywords"`
+	Tags     []string `json:"tags" form:"tags"` // 同时包含所有标签的知识点
+	Page     uint64   `json:"page" form:"page" binding:"required"`
+	PageSize uint64   `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
 }
 
 type ListKnowledgeResponse struct {
@@ -148,7 +401,7 @@ func (s *HttpSrv) ListKnowledge(c *gin.Context) {
 	}
 
 	spaceID, _ := v1.InjectSpaceID(c)
-	list, total, err := v1.NewKnowledgeLogic(c, s.Core).ListKnowledges(spaceID, req.Keywords, resource, req.Page, req.PageSize)
+	list, total, err := v1.NewKnowledgeLogic(c, s.Core).ListKnowledges(spaceID, req.Keywords, resource, req.Tags, req.Page, req.PageSize)
 	if err != nil {
 		response.APIError(c, err)
 		return
@@ -170,6 +423,7 @@ func KnowledgeToKnowledgeResponse(item *types.Knowledge) *types.KnowledgeRespons
 		SpaceID:     item.SpaceID,
 		Title:       item.Title,
 		ContentType: item.ContentType,
+		Source:      item.Source,
 		Tags:        item.Tags,
 		Kind:        item.Kind,
 		Resource:    item.Resource,
@@ -212,9 +466,10 @@ func (s *HttpSrv) DeleteKnowledge(c *gin.Context) {
 }
 
 type QueryRequest struct {
-	Query    string               `json:"query" binding:"required"`
-	Agent    string               `json:"agent"`
-	Resource *types.ResourceQuery `json:"resource"`
+	Query    string                 `json:"query" binding:"required"`
+	Agent    string                 `json:"agent"`
+	Resource *types.ResourceQuery   `json:"resource"`
+	Filter   *types.KnowledgeFilter `json:"filter"`
 }
 
 func (s *HttpSrv) Query(c *gin.Context) {
@@ -227,7 +482,26 @@ func (s *HttpSrv) Query(c *gin.Context) {
 
 	spaceID, _ := v1.InjectSpaceID(c)
 	// v1.KnowledgeQueryResult
-	result, err := v1.NewKnowledgeLogic(c, s.Core).Query(spaceID, req.Agent, req.Resource, req.Query)
+	result, err := v1.NewKnowledgeLogic(c, s.Core).Query(spaceID, req.Agent, req.Resource, req.Filter, req.Query)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, result)
+}
+
+// ExplainQuery 返回检索过程的中间结果及提示词，不请求对话模型，用于排查回答不准确的原因
+func (s *HttpSrv) ExplainQuery(c *gin.Context) {
+	var req QueryRequest
+
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	result, err := v1.NewKnowledgeLogic(c, s.Core).ExplainQuery(spaceID, req.Resource, req.Filter, req.Query)
 	if err != nil {
 		response.APIError(c, err)
 		return
@@ -260,3 +534,94 @@ func (s *HttpSrv) GetDateCreatedKnowledge(c *gin.Context) {
 	}
 	response.APISuccess(c, result)
 }
+
+func (s *HttpSrv) ListKnowledgeTags(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, err := v1.NewKnowledgeLogic(c, s.Core).ListTags(spaceID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, list)
+}
+
+type UpdateKnowledgeTagsResponse struct {
+	Updated int64 `json:"updated"` // 修改的知识点数量
+}
+
+type RenameKnowledgeTagRequest struct {
+	Tag    string `json:"tag" binding:"required"`
+	NewTag string `json:"new_tag" binding:"required"`
+}
+
+func (s *HttpSrv) RenameKnowledgeTag(c *gin.Context) {
+	var req RenameKnowledgeTagRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	updated, err := v1.NewKnowledgeLogic(c, s.Core).RenameTag(spaceID, req.Tag, req.NewTag)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, UpdateKnowledgeTagsResponse{Updated: updated})
+}
+
+type MergeKnowledgeTagsRequest struct {
+	Tags   []string `json:"tags" binding:"required"`
+	Target string   `json:"target" binding:"required"` // 合并后的标签，可以是 tags 中的一个或新的标签
+}
+
+func (s *HttpSrv) MergeKnowledgeTags(c *gin.Context) {
+	var req MergeKnowledgeTagsRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	updated, err := v1.NewKnowledgeLogic(c, s.Core).MergeTags(spaceID, req.Tags, req.Target)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, UpdateKnowledgeTagsResponse{Updated: updated})
+}
+
+type DeleteKnowledgeTagRequest struct {
+	Tag string `json:"tag" binding:"required"`
+}
+
+func (s *HttpSrv) DeleteKnowledgeTag(c *gin.Context) {
+	var req DeleteKnowledgeTagRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	updated, err := v1.NewKnowledgeLogic(c, s.Core).DeleteTag(spaceID, req.Tag)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, UpdateKnowledgeTagsResponse{Updated: updated})
+}
+
+func (s *HttpSrv) ListKnowledgeTagMergeProposals(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, err := v1.NewKnowledgeLogic(c, s.Core).ListTagMergeProposals(spaceID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, list)
+}
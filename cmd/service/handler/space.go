@@ -1,10 +1,18 @@
 package handler
 
 import (
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
 	v1 "github.com/breeew/brew-api/app/logic/v1"
 	"github.com/breeew/brew-api/app/response"
+	"github.com/breeew/brew-api/pkg/errors"
+	"github.com/breeew/brew-api/pkg/i18n"
 	"github.com/breeew/brew-api/pkg/types"
 	"github.com/breeew/brew-api/pkg/utils"
 )
@@ -130,6 +138,46 @@ func (s *HttpSrv) UpdateSpace(c *gin.Context) {
 	response.APISuccess(c, nil)
 }
 
+func (s *HttpSrv) UpdateSpaceSettings(c *gin.Context) {
+	var (
+		err error
+		req types.SpaceSettings
+	)
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err = v1.NewSpaceLogic(c, s.Core).UpdateSpaceSettings(spaceID, req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
+type MigrateSpaceEmbeddingRequest struct {
+	Driver string `json:"driver" binding:"required"`
+}
+
+func (s *HttpSrv) MigrateSpaceEmbedding(c *gin.Context) {
+	var (
+		err error
+		req MigrateSpaceEmbeddingRequest
+	)
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err = v1.NewSpaceLogic(c, s.Core).MigrateSpaceEmbedding(spaceID, req.Driver); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
 func (s *HttpSrv) DeleteUserSpace(c *gin.Context) {
 	spaceID, _ := v1.InjectSpaceID(c)
 	err := v1.NewSpaceLogic(c, s.Core).DeleteUserSpace(spaceID)
@@ -149,3 +197,58 @@ func (s *HttpSrv) LeaveSpace(c *gin.Context) {
 	}
 	response.APISuccess(c, nil)
 }
+
+func (s *HttpSrv) ExportSpace(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"space-%s-%s.zip\"", spaceID, time.Now().Format("20060102")))
+	if err := v1.NewArchiveLogic(c, s.Core).ExportSpace(spaceID, c.Writer); err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			response.APIError(c, err)
+			return
+		}
+		// 归档已经开始输出，只能中断响应
+		slog.Error("Failed to export space", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+		c.Abort()
+	}
+}
+
+type ImportSpaceRequest struct {
+	File    *multipart.FileHeader `form:"file" binding:"required"`
+	Reembed bool                  `form:"reembed"`
+}
+
+type ImportSpaceResponse struct {
+	SpaceID string `json:"space_id"`
+}
+
+func (s *HttpSrv) ImportSpace(c *gin.Context) {
+	var (
+		err error
+		req ImportSpaceRequest
+	)
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	file, err := req.File.Open()
+	if err != nil {
+		response.APIError(c, errors.New("ImportSpace.File.Open", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest))
+		return
+	}
+	defer file.Close()
+
+	spaceID, err := v1.NewArchiveLogic(c, s.Core).ImportSpace(file, req.File.Size, req.Reembed)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, ImportSpaceResponse{
+		SpaceID: spaceID,
+	})
+}
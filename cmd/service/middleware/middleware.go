@@ -264,6 +264,13 @@ func Cors(c *gin.Context) {
 	c.Next()
 }
 
+// MaxBodySize 限制请求体的长度，超出时读取请求体返回错误
+func MaxBodySize(n int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, n)
+	}
+}
+
 type LimiterFunc func(key string, opts ...core.LimitOption) gin.HandlerFunc
 
 func UseLimit(appCore *core.Core, operation string, genKeyFunc func(c *gin.Context) string, opts ...core.LimitOption) gin.HandlerFunc {
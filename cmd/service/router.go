@@ -9,6 +9,8 @@ import (
 	"github.com/breeew/brew-api/app/response"
 	"github.com/breeew/brew-api/cmd/service/handler"
 	"github.com/breeew/brew-api/cmd/service/middleware"
+	"github.com/breeew/brew-api/pkg/archive"
+	"github.com/breeew/brew-api/pkg/notes"
 )
 
 func serve(core *core.Core) {
@@ -97,12 +99,16 @@ func setupHttpRouter(s *handler.HttpSrv) {
 			space.DELETE("/:spaceid/leave", middleware.VerifySpaceIDPermission(s.Core, srv.PermissionView), s.LeaveSpace)
 
 			space.POST("", userLimit("modify_space"), s.CreateUserSpace)
+			space.POST("/import", userLimit("modify_space"), middleware.MaxBodySize(archive.MAX_ARCHIVE_SIZE), s.ImportSpace)
 
 			space.Use(middleware.VerifySpaceIDPermission(s.Core, srv.PermissionAdmin))
 			space.DELETE("/:spaceid", s.DeleteUserSpace)
 			space.PUT("/:spaceid", userLimit("modify_space"), s.UpdateSpace)
+			space.PUT("/:spaceid/settings", userLimit("modify_space"), s.UpdateSpaceSettings)
+			space.POST("/:spaceid/embedding/migrate", userLimit("modify_space"), s.MigrateSpaceEmbedding)
 			space.PUT("/:spaceid/user/role", userLimit("modify_space"), s.SetUserSpaceRole)
 			space.GET("/:spaceid/users", s.ListSpaceUsers)
+			space.GET("/:spaceid/export", userLimit("modify_space"), s.ExportSpace)
 			// share
 			space.POST("/:spaceid/knowledge/share", middleware.PaymentRequired, s.CreateKnowledgeShareToken)
 			space.POST("/:spaceid/session/share", middleware.PaymentRequired, s.CreateSessionShareToken)
@@ -129,15 +135,37 @@ func setupHttpRouter(s *handler.HttpSrv) {
 				viewScope.GET("", s.GetKnowledge)
 				viewScope.GET("/list", spaceLimit("knowledge_list"), s.ListKnowledge)
 				viewScope.POST("/query", spaceLimit("chat_message"), s.Query)
+				viewScope.POST("/query/explain", spaceLimit("chat_message"), s.ExplainQuery)
 				viewScope.GET("/time/list", spaceLimit("knowledge_list"), s.GetDateCreatedKnowledge)
+				viewScope.GET("/revisions", spaceLimit("knowledge_list"), s.ListKnowledgeRevisions)
+				viewScope.GET("/revisions/diff", spaceLimit("knowledge_list"), s.DiffKnowledgeRevisions)
+				viewScope.GET("/backlinks", spaceLimit("knowledge_list"), s.ListKnowledgeBacklinks)
+				viewScope.GET("/graph", spaceLimit("knowledge_list"), s.GetKnowledgeGraph)
+				viewScope.GET("/related", spaceLimit("knowledge_list"), s.ListRelatedKnowledge)
+				viewScope.GET("/duplicates", spaceLimit("knowledge_list"), s.GetKnowledgeDuplicates)
+				viewScope.GET("/tags", spaceLimit("knowledge_list"), s.ListKnowledgeTags)
+				viewScope.GET("/tags/merge/proposals", spaceLimit("knowledge_list"), s.ListKnowledgeTagMergeProposals)
 			}
 
 			editScope := knowledge.Group("")
 			{
 				editScope.Use(middleware.VerifySpaceIDPermission(s.Core, srv.PermissionEdit), spaceLimit("knowledge_modify"))
 				editScope.POST("", aiLimit("create_knowledge"), s.CreateKnowledge)
+				editScope.POST("/file", aiLimit("create_knowledge"), s.CreateFileKnowledge)
+				editScope.POST("/url", aiLimit("create_knowledge"), s.CreateURLKnowledge)
+				editScope.POST("/import/notes", aiLimit("create_knowledge"), middleware.MaxBodySize(notes.MAX_VAULT_SIZE), s.ImportNotes)
 				editScope.PUT("", aiLimit("create_knowledge"), s.UpdateKnowledge)
 				editScope.DELETE("", s.DeleteKnowledge)
+				editScope.POST("/revisions/restore", aiLimit("create_knowledge"), s.RestoreKnowledgeRevision)
+			}
+
+			// 标签的重命名、合并、删除会修改空间内所有成员的知识点
+			adminScope := knowledge.Group("")
+			{
+				adminScope.Use(middleware.VerifySpaceIDPermission(s.Core, srv.PermissionAdmin), spaceLimit("knowledge_modify"))
+				adminScope.PUT("/tags", s.RenameKnowledgeTag)
+				adminScope.POST("/tags/merge", s.MergeKnowledgeTags)
+				adminScope.DELETE("/tags", s.DeleteKnowledgeTag)
 			}
 		}
 
@@ -0,0 +1,480 @@
+package anthropic
+
+// provider for anthropic messages api (https://docs.anthropic.com/en/api/messages) and compatible services
+// - chat (stream), vision, summarize/chunk/enhance query via tool use
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+const (
+	NAME = "anthropic"
+
+	DEFAULT_ENDPOINT   = "https://api.anthropic.com"
+	DEFAULT_CHAT_MODEL = "claude-3-5-sonnet-latest"
+	API_VERSION        = "2023-06-01"
+
+	// DEFAULT_MAX_TOKENS messages 接口必须指定 max_tokens
+	DEFAULT_MAX_TOKENS = 4096
+)
+
+type Driver struct {
+	client   *http.Client
+	token    string
+	endpoint string
+	model    ai.ModelName
+}
+
+// New endpoint 为 messages 接口的根地址，请求地址为 {endpoint}/v1/messages，为空时使用 DEFAULT_ENDPOINT
+func New(token, endpoint string, model ai.ModelName) *Driver {
+	if endpoint == "" {
+		endpoint = DEFAULT_ENDPOINT
+	}
+	if model.ChatModel == "" {
+		model.ChatModel = DEFAULT_CHAT_MODEL
+	}
+	return &Driver{
+		client:   &http.Client{},
+		token:    token,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		model:    model,
+	}
+}
+
+func (s *Driver) Lang() string {
+	return ai.MODEL_BASE_LANGUAGE_EN
+}
+
+func (s *Driver) ChatModel() string {
+	return s.model.ChatModel
+}
+
+type MessagesRequest struct {
+	Model      string      `json:"model"`
+	MaxTokens  int         `json:"max_tokens"`
+	System     string      `json:"system,omitempty"`
+	Messages   []Message   `json:"messages"`
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+	Stream     bool        `json:"stream,omitempty"`
+}
+
+type Message struct {
+	Role    string         `json:"role"`
+	Content []ContentBlock `json:"content"`
+}
+
+// ContentBlock text、image 用于请求，text、tool_use 用于响应
+type ContentBlock struct {
+	Type   string          `json:"type"`
+	Text   string          `json:"text,omitempty"`
+	Source *ImageSource    `json:"source,omitempty"`
+	ID     string          `json:"id,omitempty"`
+	Name   string          `json:"name,omitempty"`
+	Input  json.RawMessage `json:"input,omitempty"`
+}
+
+// ImageSource type 为 base64 时使用 MediaType 及 Data，为 url 时使用 URL
+type ImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type Tool struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	InputSchema jsonschema.Definition `json:"input_schema"`
+}
+
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type MessagesResponse struct {
+	ID         string         `json:"id"`
+	Model      string         `json:"model"`
+	Content    []ContentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      Usage          `json:"usage"`
+}
+
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u Usage) convert() *openai.Usage {
+	return &openai.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+type ErrorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// convertStopReason 转换为 openai 的 finish_reason
+func convertStopReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "max_tokens":
+		return ai.FINISH_REASON_LENGTH
+	case "tool_use":
+		return ai.FINISH_REASON_TOOL_CALLS
+	case "refusal":
+		return ai.FINISH_REASON_FILTER
+	default:
+		return ai.FINISH_REASON_STOP
+	}
+}
+
+func convertContent(msg *types.MessageContext) []ContentBlock {
+	var blocks []ContentBlock
+	if msg.Content != "" {
+		blocks = append(blocks, ContentBlock{Type: "text", Text: msg.Content})
+	}
+	for _, v := range msg.MultiContent {
+		switch v.Type {
+		case openai.ChatMessagePartTypeText:
+			blocks = append(blocks, ContentBlock{Type: "text", Text: v.Text})
+		case openai.ChatMessagePartTypeImageURL:
+			if v.ImageURL == nil {
+				continue
+			}
+			blocks = append(blocks, ContentBlock{Type: "image", Source: convertImage(v.ImageURL.URL)})
+		}
+	}
+	return blocks
+}
+
+// convertImage data url 以 base64 发送，其他地址(如对象存储的预签名地址)由接口下载
+func convertImage(url string) *ImageSource {
+	if after, ok := strings.CutPrefix(url, "data:"); ok {
+		if meta, data, ok := strings.Cut(after, ","); ok && strings.HasSuffix(meta, ";base64") {
+			return &ImageSource{
+				Type:      "base64",
+				MediaType: strings.TrimSuffix(meta, ";base64"),
+				Data:      data,
+			}
+		}
+	}
+	return &ImageSource{Type: "url", URL: url}
+}
+
+// newRequest 系统消息合并为 system，assistant 以外的角色都作为 user 发送
+func (s *Driver) newRequest(query []*types.MessageContext) (MessagesRequest, error) {
+	req := MessagesRequest{
+		Model:     s.model.ChatModel,
+		MaxTokens: DEFAULT_MAX_TOKENS,
+	}
+	var system []string
+	for _, v := range query {
+		if v.Role == types.USER_ROLE_SYSTEM {
+			system = append(system, v.Content)
+			continue
+		}
+		content := convertContent(v)
+		if len(content) == 0 {
+			continue
+		}
+		role := "user"
+		if v.Role == types.USER_ROLE_ASSISTANT {
+			role = "assistant"
+		}
+		req.Messages = append(req.Messages, Message{Role: role, Content: content})
+	}
+	if len(req.Messages) == 0 {
+		// 只有系统提示词时将其作为用户消息发送
+		if len(system) == 0 {
+			return req, errors.New("empty query")
+		}
+		req.Messages = append(req.Messages, Message{Role: "user", Content: []ContentBlock{{Type: "text", Text: strings.Join(system, "\n\n")}}})
+		system = nil
+	}
+	req.System = strings.Join(system, "\n\n")
+	return req, nil
+}
+
+// do 请求失败时返回 ai.StatusError，以便判断是否切换到备用驱动
+func (s *Driver) do(ctx context.Context, body MessagesRequest) (*http.Response, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/v1/messages", bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", s.token)
+	req.Header.Set("anthropic-version", API_VERSION)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		message := string(raw)
+		var errResp ErrorResponse
+		if json.Unmarshal(raw, &errResp) == nil && errResp.Error.Message != "" {
+			message = errResp.Error.Message
+		}
+		return nil, &ai.StatusError{StatusCode: resp.StatusCode, Message: message}
+	}
+	return resp, nil
+}
+
+func (s *Driver) create(ctx context.Context, body MessagesRequest) (*MessagesResponse, error) {
+	resp, err := s.do(ctx, body)
+	if err != nil {
+		return nil, fmt.Errorf("Completion error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result MessagesResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode messages response, %w", err)
+	}
+	return &result, nil
+}
+
+func (s *Driver) NewQuery(ctx context.Context, query []*types.MessageContext) *ai.QueryOptions {
+	return ai.NewQueryOptions(ctx, s, query)
+}
+
+// NewVisionQuery 对话模型本身支持图片
+func (s *Driver) NewVisionQuery(ctx context.Context, query []*types.MessageContext) *ai.QueryOptions {
+	return ai.NewQueryOptions(ctx, s, query)
+}
+
+func (s *Driver) NewEnhance(ctx context.Context) *ai.EnhanceOptions {
+	return ai.NewEnhance(ctx, s)
+}
+
+func (s *Driver) Query(ctx context.Context, query []*types.MessageContext) (ai.GenerateResponse, error) {
+	slog.Debug("Query", slog.Any("query", query), slog.String("driver", NAME))
+
+	var result ai.GenerateResponse
+	req, err := s.newRequest(query)
+	if err != nil {
+		return result, err
+	}
+	resp, err := s.create(ctx, req)
+	if err != nil {
+		return result, err
+	}
+
+	var sb strings.Builder
+	for _, v := range resp.Content {
+		if v.Type == "text" {
+			sb.WriteString(v.Text)
+		}
+	}
+	if sb.Len() == 0 {
+		return result, errors.New("empty response content")
+	}
+	result.Received = append(result.Received, sb.String())
+	result.Usage = resp.Usage.convert()
+	result.Model = resp.Model
+	return result, nil
+}
+
+func (s *Driver) QueryStream(ctx context.Context, query []*types.MessageContext) (ai.Stream, error) {
+	slog.Debug("Query", slog.Any("query_stream", query), slog.String("driver", NAME))
+
+	req, err := s.newRequest(query)
+	if err != nil {
+		return nil, err
+	}
+	req.Stream = true
+	resp, err := s.do(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("Completion error: %w", err)
+	}
+	return newStream(resp.Body, s.model.ChatModel), nil
+}
+
+// callTool 强制模型调用 tool，并将 tool 的参数解析到 result 中
+func (s *Driver) callTool(ctx context.Context, prompt, content string, tool Tool, result any) (*MessagesResponse, error) {
+	resp, err := s.create(ctx, MessagesRequest{
+		Model:     s.model.ChatModel,
+		MaxTokens: DEFAULT_MAX_TOKENS,
+		System:    prompt,
+		Messages: []Message{
+			{Role: "user", Content: []ContentBlock{{Type: "text", Text: content}}},
+		},
+		Tools:      []Tool{tool},
+		ToolChoice: &ToolChoice{Type: "tool", Name: tool.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StopReason == "max_tokens" {
+		slog.Warn("ai finished without stop", slog.String("driver", NAME), slog.String("reason", resp.StopReason))
+	}
+
+	for _, v := range resp.Content {
+		if v.Type != "tool_use" || v.Name != tool.Name {
+			continue
+		}
+		if err = json.Unmarshal(v.Input, result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tool input of %s, %w", tool.Name, err)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("tool %s was not called", tool.Name)
+}
+
+const SummarizeFuncName = "summarize"
+
+func (s *Driver) Summarize(ctx context.Context, doc *string) (ai.SummarizeResult, error) {
+	slog.Debug("Summarize", slog.String("driver", NAME))
+	tool := Tool{
+		Name:        SummarizeFuncName,
+		Description: "Processed summary content.",
+		InputSchema: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"tags": {
+					Type:        jsonschema.Array,
+					Description: "Extract relevant keywords or technical tags from the user's description to assist the user in categorizing related content later. Organize these values in an array format.",
+					Items: &jsonschema.Definition{
+						Type: jsonschema.String,
+					},
+				},
+				"title": {
+					Type:        jsonschema.String,
+					Description: "Generate a title for the content provided by the user and fill in this field.",
+				},
+				"summary": {
+					Type:        jsonschema.String,
+					Description: "Processed summary content.",
+				},
+				"date_time": {
+					Type:        jsonschema.String,
+					Description: "The time mentioned in the user content, formatted as 'year-month-day hour:minute'. If no time can be extracted, leave it empty.",
+				},
+			},
+			Required: []string{"tags", "title", "summary"},
+		},
+	}
+
+	var result ai.SummarizeResult
+	resp, err := s.callTool(ctx, ai.ReplaceVarEN(ai.PROMPT_PROCESS_CONTENT_EN), *doc, tool, &result)
+	if err != nil {
+		return result, err
+	}
+	result.Usage = resp.Usage.convert()
+	result.Model = resp.Model
+	return result, nil
+}
+
+func (s *Driver) Chunk(ctx context.Context, doc *string) (ai.ChunkResult, error) {
+	slog.Debug("Chunk", slog.String("driver", NAME))
+	tool := Tool{
+		Name:        "chunk",
+		Description: "Processed chunks content.",
+		InputSchema: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"tags": {
+					Type:        jsonschema.Array,
+					Description: "Extract relevant keywords or technical tags from the user's description to assist the user in categorizing related content later. Organize these values in an array format.",
+					Items: &jsonschema.Definition{
+						Type: jsonschema.String,
+					},
+				},
+				"title": {
+					Type:        jsonschema.String,
+					Description: "Generate a title for the content provided by the user and fill in this field.",
+				},
+				"chunks": {
+					Type:        jsonschema.Array,
+					Description: "Processed chunks content.",
+					Items: &jsonschema.Definition{
+						Type: jsonschema.String,
+					},
+				},
+				"date_time": {
+					Type:        jsonschema.String,
+					Description: "The time mentioned in the user content, formatted as 'year-month-day hour:minute'. If no time can be extracted, leave it empty.",
+				},
+			},
+			Required: []string{"tags", "title", "chunks"},
+		},
+	}
+
+	var result ai.ChunkResult
+	resp, err := s.callTool(ctx, ai.ReplaceVarEN(ai.PROMPT_CHUNK_CONTENT_EN), *doc, tool, &result)
+	if err != nil {
+		return result, err
+	}
+	result.Usage = resp.Usage.convert()
+	result.Model = resp.Model
+	return result, nil
+}
+
+type EnhanceQueryResult struct {
+	Querys []string `json:"querys"`
+}
+
+func (s *Driver) EnhanceQuery(ctx context.Context, prompt, query string) (ai.EnhanceQueryResult, error) {
+	slog.Debug("EnhanceQuery", slog.String("driver", NAME))
+	tool := Tool{
+		Name:        "enhance_query",
+		Description: "Enhance the user's question with more ways of asking the same question.",
+		InputSchema: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"querys": {
+					Type:        jsonschema.Array,
+					Description: "The queries the user may ask.",
+					Items: &jsonschema.Definition{
+						Type: jsonschema.String,
+					},
+				},
+			},
+			Required: []string{"querys"},
+		},
+	}
+
+	var (
+		enhanced EnhanceQueryResult
+		result   ai.EnhanceQueryResult
+	)
+	resp, err := s.callTool(ctx, prompt, query, tool, &enhanced)
+	if err != nil {
+		return result, err
+	}
+	result.Original = query
+	result.News = enhanced.Querys
+	result.Model = resp.Model
+	result.Usage = resp.Usage.convert()
+	return result, nil
+}
@@ -0,0 +1,228 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	oai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/ai/anthropic"
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func newServer(t *testing.T, handler func(w http.ResponseWriter, body anthropic.MessagesRequest)) (*anthropic.Driver, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-token", r.Header.Get("x-api-key"))
+		assert.Equal(t, anthropic.API_VERSION, r.Header.Get("anthropic-version"))
+
+		var body anthropic.MessagesRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "claude-test", body.Model)
+		assert.Greater(t, body.MaxTokens, 0)
+		handler(w, body)
+	}))
+	return anthropic.New("test-token", srv.URL, ai.ModelName{ChatModel: "claude-test"}), srv.Close
+}
+
+// writeSSE 按 messages 接口的格式输出 sse 事件
+func writeSSE(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, data := range events {
+		var event struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal([]byte(data), &event)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func Test_Query(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, body anthropic.MessagesRequest) {
+		assert.False(t, body.Stream)
+		assert.Equal(t, "you are a test", body.System)
+		if assert.Len(t, body.Messages, 3) {
+			assert.Equal(t, []string{"user", "assistant", "user"}, []string{body.Messages[0].Role, body.Messages[1].Role, body.Messages[2].Role})
+			assert.Equal(t, "where is my car", body.Messages[2].Content[0].Text)
+		}
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test-1","content":[{"type":"text","text":"B2"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`))
+	})
+	defer closeFn()
+
+	res, err := d.NewQuery(context.Background(), []*types.MessageContext{
+		{Role: types.USER_ROLE_SYSTEM, Content: "you are a test"},
+		{Role: types.USER_ROLE_USER, Content: "hi"},
+		{Role: types.USER_ROLE_ASSISTANT, Content: "hello"},
+		{Role: types.USER_ROLE_USER, Content: "where is my car"},
+	}).Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"B2"}, res.Received)
+	assert.Equal(t, "claude-test-1", res.Model)
+	assert.Equal(t, 12, res.Usage.TotalTokens)
+}
+
+func Test_QueryStream(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, body anthropic.MessagesRequest) {
+		assert.True(t, body.Stream)
+		writeSSE(w,
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-test-1","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":5}}`,
+			`{"type":"message_stop"}`,
+		)
+	})
+	defer closeFn()
+
+	stream, err := d.NewQuery(context.Background(), []*types.MessageContext{
+		{Role: types.USER_ROLE_USER, Content: "hi"},
+	}).QueryStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var (
+		content string
+		finish  string
+		usage   *oai.Usage
+	)
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "msg_1", event.ID)
+		assert.Equal(t, "claude-test-1", event.Model)
+		content += event.Content
+		if event.FinishReason != "" {
+			finish = event.FinishReason
+		}
+		if event.Usage != nil {
+			usage = event.Usage
+		}
+	}
+	assert.Equal(t, "Hello world", content)
+	assert.Equal(t, ai.FINISH_REASON_STOP, finish)
+	assert.Equal(t, &oai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, usage)
+}
+
+func Test_QueryStreamError(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, body anthropic.MessagesRequest) {
+		if len(body.Messages[0].Content) > 0 && body.Messages[0].Content[0].Text == "overloaded" {
+			w.WriteHeader(529)
+			w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		writeSSE(w,
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude-test","usage":{"input_tokens":10}}}`,
+			`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+		)
+	})
+	defer closeFn()
+
+	// 建立请求时的错误可以切换到备用驱动
+	_, err := d.QueryStream(context.Background(), []*types.MessageContext{{Role: types.USER_ROLE_USER, Content: "overloaded"}})
+	assert.ErrorContains(t, err, "Overloaded")
+	assert.True(t, ai.IsFailoverError(context.Background(), err))
+
+	stream, err := d.QueryStream(context.Background(), []*types.MessageContext{{Role: types.USER_ROLE_USER, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	_, err = stream.Recv()
+	assert.ErrorContains(t, err, "overloaded_error")
+}
+
+func Test_Vision(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, body anthropic.MessagesRequest) {
+		if assert.Len(t, body.Messages, 1) && assert.Len(t, body.Messages[0].Content, 3) {
+			blocks := body.Messages[0].Content
+			assert.Equal(t, anthropic.ContentBlock{Type: "text", Text: "what are they"}, blocks[0])
+			assert.Equal(t, &anthropic.ImageSource{Type: "base64", MediaType: "image/png", Data: "aW1n"}, blocks[1].Source)
+			assert.Equal(t, &anthropic.ImageSource{Type: "url", URL: "https://example.com/cat.png"}, blocks[2].Source)
+		}
+		w.Write([]byte(`{"id":"msg_1","model":"claude-test","content":[{"type":"text","text":"two cats"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`))
+	})
+	defer closeFn()
+
+	res, err := d.NewVisionQuery(context.Background(), []*types.MessageContext{{
+		Role: types.USER_ROLE_USER,
+		MultiContent: []oai.ChatMessagePart{
+			{Type: oai.ChatMessagePartTypeText, Text: "what are they"},
+			{Type: oai.ChatMessagePartTypeImageURL, ImageURL: &oai.ChatMessageImageURL{URL: "data:image/png;base64,aW1n"}},
+			{Type: oai.ChatMessagePartTypeImageURL, ImageURL: &oai.ChatMessageImageURL{URL: "https://example.com/cat.png"}},
+		},
+	}}).Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"two cats"}, res.Received)
+}
+
+func Test_Summarize(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, body anthropic.MessagesRequest) {
+		if assert.Len(t, body.Tools, 1) {
+			assert.Equal(t, anthropic.SummarizeFuncName, body.Tools[0].Name)
+		}
+		assert.Equal(t, &anthropic.ToolChoice{Type: "tool", Name: anthropic.SummarizeFuncName}, body.ToolChoice)
+		w.Write([]byte(`{"id":"msg_1","model":"claude-test","content":[{"type":"tool_use","id":"toolu_1","name":"summarize","input":{"tags":["docker"],"title":"Docker on CentOS","summary":"requires kernel 3.10"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":20}}`))
+	})
+	defer closeFn()
+
+	content := "Docker supports CentOS 7/8 and requires kernel 3.10 or later."
+	res, err := d.Summarize(context.Background(), &content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Docker on CentOS", res.Title)
+	assert.Equal(t, []string{"docker"}, res.Tags)
+	assert.Equal(t, "requires kernel 3.10", res.Summary)
+	assert.Equal(t, 30, res.Usage.TotalTokens)
+}
+
+func Test_Chunk(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, body anthropic.MessagesRequest) {
+		w.Write([]byte(`{"id":"msg_1","model":"claude-test","content":[{"type":"text","text":"ok"},{"type":"tool_use","id":"toolu_1","name":"chunk","input":{"tags":["docker"],"title":"Docker","chunks":["part 1","part 2"]}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":20}}`))
+	})
+	defer closeFn()
+
+	content := "part 1. part 2."
+	res, err := d.Chunk(context.Background(), &content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"part 1", "part 2"}, res.Chunks)
+}
+
+func Test_EnhanceQuery(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, body anthropic.MessagesRequest) {
+		w.Write([]byte(`{"id":"msg_1","model":"claude-test","content":[{"type":"tool_use","id":"toolu_1","name":"enhance_query","input":{"querys":["where did I park","parking floor"]}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":20}}`))
+	})
+	defer closeFn()
+
+	res, err := d.NewEnhance(context.Background()).EnhanceQuery("where is my car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "where is my car", res.Original)
+	assert.Equal(t, []string{"where did I park", "parking floor"}, res.News)
+}
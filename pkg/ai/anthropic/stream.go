@@ -0,0 +1,120 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/breeew/brew-api/pkg/ai"
+)
+
+// streamEvent messages 接口 sse 事件的 data，事件类型与 data 中的 type 一致
+// https://docs.anthropic.com/en/api/messages-streaming
+type streamEvent struct {
+	Type    string            `json:"type"`
+	Message *MessagesResponse `json:"message"` // message_start
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`        // content_block_delta
+		StopReason string `json:"stop_reason"` // message_delta
+	} `json:"delta"`
+	Usage *Usage `json:"usage"` // message_delta，output_tokens 为累计值
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// stream 实现 ai.Stream，只返回文本增量、结束原因及用量，其他事件(ping、content_block_start 等)忽略
+type stream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	id     string
+	model  string
+	input  int
+	done   bool
+}
+
+func newStream(body io.ReadCloser, model string) *stream {
+	return &stream{
+		body:   body,
+		reader: bufio.NewReader(body),
+		model:  model,
+	}
+}
+
+// next 读取下一个事件的 data，event 行不需要解析
+func (s *stream) next() ([]byte, error) {
+	var data []byte
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		if after, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.TrimSpace(after)...)
+		}
+		if len(line) == 0 && len(data) > 0 {
+			return data, nil
+		}
+		if err != nil {
+			if len(data) > 0 {
+				return data, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+func (s *stream) Recv() (ai.StreamEvent, error) {
+	for {
+		if s.done {
+			return ai.StreamEvent{}, io.EOF
+		}
+		data, err := s.next()
+		if err != nil {
+			return ai.StreamEvent{}, err
+		}
+
+		var event streamEvent
+		if err = json.Unmarshal(data, &event); err != nil {
+			return ai.StreamEvent{}, fmt.Errorf("failed to decode stream event, %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				s.id = event.Message.ID
+				if event.Message.Model != "" {
+					s.model = event.Message.Model
+				}
+				s.input = event.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				return ai.StreamEvent{ID: s.id, Model: s.model, Content: event.Delta.Text}, nil
+			}
+		case "message_delta":
+			res := ai.StreamEvent{
+				ID:           s.id,
+				Model:        s.model,
+				FinishReason: convertStopReason(event.Delta.StopReason),
+			}
+			if event.Usage != nil {
+				res.Usage = Usage{InputTokens: s.input, OutputTokens: event.Usage.OutputTokens}.convert()
+			}
+			return res, nil
+		case "message_stop":
+			s.done = true
+		case "error":
+			if event.Error == nil {
+				return ai.StreamEvent{}, fmt.Errorf("Completion error: %s", data)
+			}
+			return ai.StreamEvent{}, fmt.Errorf("Completion error: %s, %s", event.Error.Type, event.Error.Message)
+		}
+	}
+}
+
+func (s *stream) Close() error {
+	return s.body.Close()
+}
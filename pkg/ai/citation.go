@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// 参考内容中的每段内容带有引用标记 [@N]，模型在回答中引用时原样输出该标记
+// 不使用 markdown 脚注语法 [^N]，避免与知识内容中原有的脚注混淆
+
+var citationMarkerRegexp = regexp.MustCompile(`\[@(\d+)\]`)
+
+func CitationMarker(n int) string {
+	return "[@" + strconv.Itoa(n) + "]"
+}
+
+// CitationMark 回答中的一个引用标记，Start/End 为字符(rune)位置
+type CitationMark struct {
+	Marker int
+	Start  int
+	End    int
+}
+
+// ParseCitationMarks 按出现顺序返回文本中的全部引用标记
+func ParseCitationMarks(text string) []CitationMark {
+	var (
+		res     []CitationMark
+		offset  int // 已统计到的字节位置
+		runePos int // offset 对应的字符位置
+	)
+	for _, m := range citationMarkerRegexp.FindAllStringSubmatchIndex(text, -1) {
+		n, err := strconv.Atoi(text[m[2]:m[3]])
+		if err != nil {
+			continue
+		}
+		runePos += utf8.RuneCountInString(text[offset:m[0]])
+		start := runePos
+		runePos += utf8.RuneCountInString(text[m[0]:m[1]])
+		offset = m[1]
+		res = append(res, CitationMark{
+			Marker: n,
+			Start:  start,
+			End:    runePos,
+		})
+	}
+	return res
+}
+
+// ResolveCitations 将回答中的引用标记对应到参考内容的来源，忽略参考内容中不存在的标记
+func ResolveCitations(answer string, sources []types.CitationSource) types.Citations {
+	if len(sources) == 0 {
+		return nil
+	}
+	sourceMap := make(map[int]types.CitationSource, len(sources))
+	for _, v := range sources {
+		sourceMap[v.Marker] = v
+	}
+
+	var res types.Citations
+	for _, m := range ParseCitationMarks(answer) {
+		source, ok := sourceMap[m.Marker]
+		if !ok {
+			continue
+		}
+		res = append(res, types.Citation{
+			Marker:      m.Marker,
+			KnowledgeID: source.KnowledgeID,
+			ChunkID:     source.ChunkID,
+			Start:       m.Start,
+			End:         m.End,
+		})
+	}
+	return res
+}
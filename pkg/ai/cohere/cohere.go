@@ -0,0 +1,133 @@
+package cohere
+
+// provider for https://cohere.com/ and services compatible with the cohere rerank api
+// - rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/samber/lo"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/breeew/brew-api/pkg/ai"
+)
+
+const (
+	NAME = "cohere"
+
+	DEFAULT_RERANK_ENDPOINT = "https://api.cohere.com/v2/rerank"
+	DEFAULT_RERANK_MODEL    = "rerank-v3.5"
+)
+
+type Driver struct {
+	client   *http.Client
+	token    string
+	endpoint string
+	model    string
+}
+
+// New endpoint 为完整的 rerank 接口地址，为空时使用 DEFAULT_RERANK_ENDPOINT
+func New(token, endpoint, model string) *Driver {
+	if endpoint == "" {
+		endpoint = DEFAULT_RERANK_ENDPOINT
+	}
+	if model == "" {
+		model = DEFAULT_RERANK_MODEL
+	}
+	return &Driver{
+		client:   &http.Client{},
+		token:    token,
+		endpoint: endpoint,
+		model:    model,
+	}
+}
+
+type RerankRequestBody struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	TopN      int      `json:"top_n"`
+	Documents []string `json:"documents"`
+}
+
+type RerankResponse struct {
+	Results []RerankResponseItem `json:"results"`
+	Meta    struct {
+		BilledUnits struct {
+			SearchUnits int `json:"search_units"`
+		} `json:"billed_units"`
+		// 部分兼容服务返回实际的 token 数
+		Tokens struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"tokens"`
+	} `json:"meta"`
+}
+
+type RerankResponseItem struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+func (s *Driver) Rerank(ctx context.Context, query string, docs []*ai.RerankDoc) ([]ai.RankDocItem, *ai.Usage, error) {
+	slog.Debug("Rerank", slog.String("driver", NAME))
+	request := RerankRequestBody{
+		Model: s.model,
+		Query: query,
+		TopN:  len(docs),
+		Documents: lo.Map(docs, func(item *ai.RerankDoc, _ int) string {
+			return item.Content
+		}),
+	}
+
+	raw, _ := json.Marshal(request)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Add("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to request cohere rerank: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("Failed to request rerank api, %s", string(body))
+	}
+
+	var result RerankResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, nil, err
+	}
+
+	var rank []ai.RankDocItem
+	for _, v := range result.Results {
+		if v.Index < 0 || v.Index >= len(docs) {
+			return nil, nil, fmt.Errorf("Unexpected rerank result index %d", v.Index)
+		}
+		rank = append(rank, ai.RankDocItem{
+			ID:    docs[v.Index].ID,
+			Score: v.RelevanceScore,
+		})
+	}
+
+	return rank, &ai.Usage{
+		Model: s.model,
+		Usage: &openai.Usage{
+			PromptTokens: result.Meta.Tokens.InputTokens,
+		},
+	}, nil
+}
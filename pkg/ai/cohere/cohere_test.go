@@ -0,0 +1,62 @@
+package cohere_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/ai/cohere"
+)
+
+func Test_Rerank(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		var body cohere.RerankRequestBody
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "rerank-test", body.Model)
+		assert.Equal(t, "k8s", body.Query)
+		assert.Equal(t, []string{"about go", "about k8s"}, body.Documents)
+		assert.Equal(t, 2, body.TopN)
+
+		w.Write([]byte(`{"results":[{"index":1,"relevance_score":0.9},{"index":0,"relevance_score":0.1}],"meta":{"billed_units":{"search_units":1},"tokens":{"input_tokens":12}}}`))
+	}))
+	defer srv.Close()
+
+	d := cohere.New("test-token", srv.URL, "rerank-test")
+	res, usage, err := d.Rerank(context.Background(), "k8s", []*ai.RerankDoc{
+		{ID: "a", Content: "about go"},
+		{ID: "b", Content: "about k8s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []ai.RankDocItem{{ID: "b", Score: 0.9}, {ID: "a", Score: 0.1}}, res)
+	assert.Equal(t, "rerank-test", usage.Model)
+	assert.Equal(t, 12, usage.Usage.PromptTokens)
+}
+
+func Test_RerankError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Write([]byte(`{"results":[{"index":5,"relevance_score":0.9}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"invalid api token"}`))
+	}))
+	defer srv.Close()
+
+	docs := []*ai.RerankDoc{{ID: "a", Content: "about go"}}
+	_, _, err := cohere.New("bad-token", srv.URL, "").Rerank(context.Background(), "k8s", docs)
+	assert.ErrorContains(t, err, "invalid api token")
+
+	// 兼容服务可以不需要 token
+	_, _, err = cohere.New("", srv.URL, "").Rerank(context.Background(), "k8s", docs)
+	assert.ErrorContains(t, err, "index 5")
+}
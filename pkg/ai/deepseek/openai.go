@@ -48,19 +48,8 @@ func (s *Driver) Lang() string {
 	return ai.MODEL_BASE_LANGUAGE_CN
 }
 
-func (s *Driver) MsgIsOverLimit(msgs []*types.MessageContext) bool {
-	tokenNum, err := ai.NumTokens(lo.Map(msgs, func(item *types.MessageContext, _ int) openai.ChatCompletionMessage {
-		return openai.ChatCompletionMessage{
-			Role:    item.Role.String(),
-			Content: item.Content,
-		}
-	}), s.model.ChatModel)
-	if err != nil {
-		slog.Error("Failed to tik request token", slog.String("error", err.Error()), slog.String("driver", NAME), slog.String("model", s.model.ChatModel))
-		return false
-	}
-
-	return tokenNum > 8000
+func (s *Driver) ChatModel() string {
+	return s.model.ChatModel
 }
 
 func convertPassageToPrompt(docs []*types.PassageInfo) string {
@@ -78,7 +67,7 @@ func (s *Driver) NewQuery(ctx context.Context, query []*types.MessageContext) *a
 	return opts
 }
 
-func (s *Driver) QueryStream(ctx context.Context, query []*types.MessageContext) (*openai.ChatCompletionStream, error) {
+func (s *Driver) QueryStream(ctx context.Context, query []*types.MessageContext) (ai.Stream, error) {
 	messages := lo.Map(query, func(item *types.MessageContext, _ int) openai.ChatCompletionMessage {
 		return openai.ChatCompletionMessage{
 			Role:    item.Role.String(),
@@ -109,7 +98,7 @@ func (s *Driver) QueryStream(ctx context.Context, query []*types.MessageContext)
 
 	slog.Debug("Query", slog.Any("query_stream", req), slog.String("driver", NAME))
 
-	return resp, nil
+	return ai.NewOpenAIStream(resp), nil
 }
 
 func (s *Driver) Query(ctx context.Context, query []*types.MessageContext) (ai.GenerateResponse, error) {
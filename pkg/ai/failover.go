@@ -0,0 +1,210 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// StatusError 非 openai 兼容接口的驱动返回的 http 错误，用于判断是否需要切换到备用驱动
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code %d: %s", e.StatusCode, e.Message)
+}
+
+// IsFailoverError 超时、服务端错误(5xx)及限流(429)时可以切换到备用驱动重试
+// 调用方已取消、请求参数错误、鉴权失败等错误换驱动也无法解决，直接返回
+func IsFailoverError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var (
+		apiErr    *openai.APIError
+		reqErr    *openai.RequestError
+		statusErr *StatusError
+		status    int
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	case errors.As(err, &statusErr):
+		status = statusErr.StatusCode
+	}
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+const (
+	DEFAULT_BREAKER_THRESHOLD = 3
+	DEFAULT_BREAKER_COOLDOWN  = 30 * time.Second
+)
+
+// CircuitBreaker 驱动连续失败达到阈值后在冷却时间内跳过该驱动，冷却结束后放行一次请求试探，成功则恢复
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	states    map[string]*breakerState
+}
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DEFAULT_BREAKER_THRESHOLD
+	}
+	if cooldown <= 0 {
+		cooldown = DEFAULT_BREAKER_COOLDOWN
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		states:    make(map[string]*breakerState),
+	}
+}
+
+func (b *CircuitBreaker) state(name string) *breakerState {
+	s, ok := b.states[name]
+	if !ok {
+		s = &breakerState{}
+		b.states[name] = s
+	}
+	return s
+}
+
+// Allow 判断是否可以请求该驱动
+func (b *CircuitBreaker) Allow(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(name)
+	if s.failures < b.threshold {
+		return true
+	}
+	now := b.now()
+	if now.Before(s.openUntil) {
+		return false
+	}
+	// 冷却结束，放行当前请求试探，试探结果返回前的其他请求仍然跳过
+	s.openUntil = now.Add(b.cooldown)
+	return true
+}
+
+// IsOpen 判断驱动是否处于熔断中，不会占用冷却结束后的试探机会
+func (b *CircuitBreaker) IsOpen(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(name)
+	return s.failures >= b.threshold && b.now().Before(s.openUntil)
+}
+
+func (b *CircuitBreaker) Success(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state(name).failures = 0
+}
+
+func (b *CircuitBreaker) Failure(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(name)
+	s.failures++
+	if s.failures >= b.threshold {
+		s.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// Provider 备用链中的一个驱动
+type Provider[D any] struct {
+	Name   string
+	Driver D
+}
+
+// FailoverPolicy 备用链共用的熔断器及单个驱动的请求超时
+type FailoverPolicy struct {
+	Breaker *CircuitBreaker
+	Timeout time.Duration // 单个驱动在该时间内没有返回(流式请求为开始输出)时切换到下一个驱动，0 表示不限制
+}
+
+// Failover 按顺序请求 providers，超时或遇到 IsFailoverError 的错误时切换到下一个驱动
+// 被熔断的驱动排到最后，所有驱动都不可用时返回最后一个错误
+func Failover[D any, R any](ctx context.Context, policy *FailoverPolicy, providers []Provider[D], call func(ctx context.Context, d D) (R, error)) (R, error) {
+	var (
+		res     R
+		err     error
+		skipped []Provider[D]
+	)
+	attempt := func(p Provider[D]) bool {
+		var (
+			actx     = ctx
+			cancel   = func() {}
+			timedOut = func() bool { return false }
+		)
+		if policy.Timeout > 0 {
+			actx, cancel = context.WithCancel(ctx)
+			timer := time.AfterFunc(policy.Timeout, cancel)
+			timedOut = func() bool { return !timer.Stop() }
+		}
+
+		res, err = call(actx, p.Driver)
+		timeout := timedOut()
+		if err == nil {
+			// 成功时不取消 actx，流式请求还需要继续读取
+			policy.Breaker.Success(p.Name)
+			return true
+		}
+		cancel()
+		if !(timeout && ctx.Err() == nil) && !IsFailoverError(ctx, err) {
+			return true
+		}
+		policy.Breaker.Failure(p.Name)
+		slog.Warn("AI provider request failed, try next provider", slog.String("provider", p.Name), slog.String("error", err.Error()))
+		return false
+	}
+
+	for _, p := range providers {
+		if !policy.Breaker.Allow(p.Name) {
+			skipped = append(skipped, p)
+			continue
+		}
+		if attempt(p) {
+			return res, err
+		}
+	}
+	for _, p := range skipped {
+		if attempt(p) {
+			return res, err
+		}
+	}
+	if err == nil {
+		err = errors.New("no available AI provider")
+	}
+	return res, err
+}
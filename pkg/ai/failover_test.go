@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IsFailoverError(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, IsFailoverError(ctx, &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}))
+	assert.True(t, IsFailoverError(ctx, &openai.RequestError{HTTPStatusCode: http.StatusBadGateway}))
+	assert.True(t, IsFailoverError(ctx, &StatusError{StatusCode: http.StatusServiceUnavailable}))
+	assert.True(t, IsFailoverError(ctx, context.DeadlineExceeded))
+	assert.False(t, IsFailoverError(ctx, &openai.APIError{HTTPStatusCode: http.StatusBadRequest}))
+	assert.False(t, IsFailoverError(ctx, errors.New("invalid response")))
+
+	// 调用方已取消时不再切换
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.False(t, IsFailoverError(canceled, &StatusError{StatusCode: http.StatusInternalServerError}))
+}
+
+func Test_CircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure("a")
+	assert.True(t, b.Allow("a"))
+	b.Failure("a")
+	assert.True(t, b.IsOpen("a"))
+	assert.False(t, b.Allow("a"))
+
+	// 冷却结束后只放行一次试探
+	now = now.Add(time.Minute)
+	assert.False(t, b.IsOpen("a"))
+	assert.True(t, b.Allow("a"))
+	assert.False(t, b.Allow("a"))
+
+	b.Success("a")
+	assert.True(t, b.Allow("a"))
+	assert.False(t, b.IsOpen("a"))
+}
+
+func Test_Failover(t *testing.T) {
+	providers := []Provider[string]{{Name: "a", Driver: "a"}, {Name: "b", Driver: "b"}, {Name: "c", Driver: "c"}}
+	unavailable := &StatusError{StatusCode: http.StatusServiceUnavailable}
+
+	policy := &FailoverPolicy{Breaker: NewCircuitBreaker(1, time.Minute)}
+	var called []string
+	res, err := Failover(context.Background(), policy, providers, func(_ context.Context, d string) (string, error) {
+		called = append(called, d)
+		if d == "a" {
+			return "", unavailable
+		}
+		return d, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "b", res)
+	assert.Equal(t, []string{"a", "b"}, called)
+
+	// a 已熔断，直接请求 b；不可切换的错误直接返回
+	called = nil
+	_, err = Failover(context.Background(), policy, providers, func(_ context.Context, d string) (string, error) {
+		called = append(called, d)
+		return "", &StatusError{StatusCode: http.StatusBadRequest}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"b"}, called)
+
+	// 全部失败时仍会尝试被熔断的驱动，返回最后一个错误
+	called = nil
+	_, err = Failover(context.Background(), policy, providers, func(_ context.Context, d string) (string, error) {
+		called = append(called, d)
+		return "", unavailable
+	})
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, []string{"b", "c", "a"}, called)
+}
+
+func Test_FailoverTimeout(t *testing.T) {
+	providers := []Provider[string]{{Name: "slow", Driver: "slow"}, {Name: "fast", Driver: "fast"}}
+	policy := &FailoverPolicy{Breaker: NewCircuitBreaker(0, 0), Timeout: 20 * time.Millisecond}
+
+	res, err := Failover(context.Background(), policy, providers, func(ctx context.Context, d string) (string, error) {
+		if d == "slow" {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return d, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "fast", res)
+}
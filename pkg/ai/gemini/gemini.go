@@ -2,14 +2,21 @@ package gemini
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
+	"github.com/samber/lo"
 	"github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
 	"github.com/breeew/brew-api/pkg/ai"
@@ -18,193 +25,332 @@ import (
 
 const (
 	NAME = "gemini"
+
+	// MAX_IMAGE_SIZE 图片需要下载后以内联数据发送给 gemini
+	MAX_IMAGE_SIZE = 20 << 20
 )
 
 type Driver struct {
 	client *genai.Client
+	http   *http.Client // 下载图片
+	model  ai.ModelName
 }
 
-func New(token string) *Driver {
-	client, err := genai.NewClient(context.Background(), option.WithAPIKey(token))
+// New endpoint 为空时使用 gemini 官方接口
+func New(token, endpoint string, model ai.ModelName) (*Driver, error) {
+	opts := []option.ClientOption{option.WithAPIKey(token)}
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	client, err := genai.NewClient(context.Background(), opts...)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	if model.ChatModel == "" {
+		model.ChatModel = "gemini-1.5-flash"
+	}
+	if model.EmbeddingModel == "" {
+		model.EmbeddingModel = "text-embedding-004"
 	}
 
 	return &Driver{
 		client: client,
-	}
+		http:   &http.Client{Timeout: time.Second * 30},
+		model:  model,
+	}, nil
 }
 
 func (s *Driver) Lang() string {
 	return ai.MODEL_BASE_LANGUAGE_EN
 }
 
-func (s *Driver) embedding(ctx context.Context, title, content string) ([]float32, error) {
+func (s *Driver) ChatModel() string {
+	return s.model.ChatModel
+}
+
+// convertError 将 gemini 接口的 http 错误转换为 ai.StatusError，以便判断是否切换到备用驱动
+func convertError(err error) error {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return &ai.StatusError{
+			StatusCode: gerr.Code,
+			Message:    gerr.Message,
+		}
+	}
+	return err
+}
+
+func convertUsage(usage *genai.UsageMetadata) *openai.Usage {
+	if usage == nil {
+		return &openai.Usage{}
+	}
+	return &openai.Usage{
+		PromptTokens:     int(usage.PromptTokenCount),
+		CompletionTokens: int(usage.CandidatesTokenCount),
+		TotalTokens:      int(usage.TotalTokenCount),
+	}
+}
+
+// embedding 每次请求最多 100 条内容
+func (s *Driver) embedding(ctx context.Context, title string, content []string) (ai.EmbeddingResult, error) {
 	slog.Debug("Embedding", slog.String("driver", NAME))
-	em := s.client.EmbeddingModel("embedding-001")
+	em := s.client.EmbeddingModel(s.model.EmbeddingModel)
 	if title != "" {
 		em.TaskType = genai.TaskTypeRetrievalDocument
 	} else {
 		em.TaskType = genai.TaskTypeRetrievalQuery
 	}
 
-	res, err := em.EmbedContentWithTitle(ctx, title, genai.Text(content))
-	if err != nil {
-		return nil, err
+	const batchMax = 100
+	r := ai.EmbeddingResult{
+		Model: s.model.EmbeddingModel,
+		Usage: &openai.Usage{},
+	}
+	for i := 0; i < len(content); i += batchMax {
+		batch := em.NewBatch()
+		for _, v := range content[i:min(i+batchMax, len(content))] {
+			batch.AddContentWithTitle(title, genai.Text(v))
+		}
+		resp, err := em.BatchEmbedContents(ctx, batch)
+		if err != nil {
+			return ai.EmbeddingResult{}, fmt.Errorf("Error creating embedding: %w", convertError(err))
+		}
+		for _, v := range resp.Embeddings {
+			r.Data = append(r.Data, v.Values)
+		}
 	}
-	return res.Embedding.Values, nil
+	return r, nil
 }
 
-func (s *Driver) EmbeddingForQuery(ctx context.Context, content string) ([]float32, error) {
+func (s *Driver) EmbeddingForQuery(ctx context.Context, content []string) (ai.EmbeddingResult, error) {
 	return s.embedding(ctx, "", content)
 }
 
-func (s *Driver) EmbeddingForDocument(ctx context.Context, title, content string) ([]float32, error) {
+func (s *Driver) EmbeddingForDocument(ctx context.Context, title string, content []string) (ai.EmbeddingResult, error) {
 	return s.embedding(ctx, title, content)
 }
 
-const GENERATE_PROMPT_TPL = `
-  You are a helpful and informative bot that answers questions using text from the reference passage included below. 
-  Be sure to respond in a complete sentence, being comprehensive, including all relevant background information. 
-  However, you are talking to a non-technical audience, so be sure to break down complicated concepts and 
-  strike a friendly and converstional tone. 
-  If the passage is irrelevant to the answer, you may ignore it.
-  QUESTION: '{query}'
-  PASSAGE: 
-  '{relevant_passage}'
-
-  Please use {lang} to ANSWER:
-`
-
-const GENERATE_PROMPT_TPL_CN = `
-    以下是关于回答用户提问可以参考的内容(json格式)：
-    --------------------------------------
-	{relevant_passage}
-    --------------------------------------
-    你需要结合“参考内容”来回答用户的提问，如果参考内容完全没有用户想要的结果，你再通过自己的知识进行回答。
-    如果你从上述内容中找到了用户想要的答案，可以结合内容相关的属性来给到用户更多的帮助，比如告诉用户你参考了ID为XXX的文章。
-    请你使用 {lang} 语言，以Markdown格式回复用户。
-`
-
-func convertPassageToPrompt(docs []*types.PassageInfo) string {
-	raw, _ := json.MarshalIndent(docs, "", "  ")
-	b := strings.Builder{}
-	b.WriteString("``` json\n")
-	b.Write(raw)
-	b.WriteString("\n")
-	b.WriteString("```\n")
-	return b.String()
+func (s *Driver) NewQuery(ctx context.Context, query []*types.MessageContext) *ai.QueryOptions {
+	return ai.NewQueryOptions(ctx, s, query)
 }
 
-func (s *Driver) Query(ctx context.Context, query string, docs []*types.PassageInfo) (ai.GenerateResponse, error) {
-	prompt := strings.ReplaceAll(GENERATE_PROMPT_TPL_CN, "{query}", query)
-	prompt = strings.ReplaceAll(prompt, "{relevant_passage}", convertPassageToPrompt(docs))
+// NewVisionQuery gemini 的对话模型本身支持图片
+func (s *Driver) NewVisionQuery(ctx context.Context, query []*types.MessageContext) *ai.QueryOptions {
+	return ai.NewQueryOptions(ctx, s, query)
+}
 
-	model := s.client.GenerativeModel("gemini-1.5-pro-latest")
-	model.SystemInstruction = genai.NewUserContent(genai.Text(prompt))
-	// Ask the model to respond with JSON.
-	model.ResponseMIMEType = "application/json"
-	// Specify the schema.
-	model.ResponseSchema = &genai.Schema{
-		Type:  genai.TypeArray,
-		Items: &genai.Schema{Type: genai.TypeString},
-	}
+func (s *Driver) NewEnhance(ctx context.Context) *ai.EnhanceOptions {
+	return ai.NewEnhance(ctx, s)
+}
 
-	slog.Debug("Query", slog.String("prompt", prompt), slog.String("query", query), slog.String("driver", NAME))
+// loadImage 图片地址可以是 data url 或 http 地址(如对象存储的预签名地址)
+func (s *Driver) loadImage(ctx context.Context, url string) (genai.Blob, error) {
+	if strings.HasPrefix(url, "data:") {
+		meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		if !ok || !strings.HasSuffix(meta, ";base64") {
+			return genai.Blob{}, errors.New("unsupported image data url")
+		}
+		raw, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return genai.Blob{}, fmt.Errorf("failed to decode image data url, %w", err)
+		}
+		return genai.Blob{MIMEType: strings.TrimSuffix(meta, ";base64"), Data: raw}, nil
+	}
 
-	var result ai.GenerateResponse
-	resp, err := model.GenerateContent(ctx, genai.Text(query))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return result, err
+		return genai.Blob{}, err
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return genai.Blob{}, fmt.Errorf("failed to download image, %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return genai.Blob{}, fmt.Errorf("failed to download image, status code: %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, MAX_IMAGE_SIZE+1))
+	if err != nil {
+		return genai.Blob{}, fmt.Errorf("failed to download image, %w", err)
+	}
+	if len(raw) > MAX_IMAGE_SIZE {
+		return genai.Blob{}, errors.New("image is too large")
 	}
 
-	if len(resp.Candidates) == 0 {
-		return result, errors.New("empty response content")
+	mimeType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(raw)
 	}
+	return genai.Blob{MIMEType: mimeType, Data: raw}, nil
+}
 
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if resp.Candidates[0].FinishReason != genai.FinishReasonStop {
-			slog.Warn("ProcessContent, ai finished without stop", slog.String("reason", resp.Candidates[0].FinishReason.String()))
-		}
-		if txt, ok := part.(genai.Text); ok {
-			var recipes []string
-			if err := json.Unmarshal([]byte(txt), &recipes); err != nil {
-				return result, fmt.Errorf("failed to unmarshal ai response content, %w", err)
+func (s *Driver) convertParts(ctx context.Context, msg *types.MessageContext) ([]genai.Part, error) {
+	var parts []genai.Part
+	if msg.Content != "" {
+		parts = append(parts, genai.Text(msg.Content))
+	}
+	for _, v := range msg.MultiContent {
+		switch v.Type {
+		case openai.ChatMessagePartTypeText:
+			parts = append(parts, genai.Text(v.Text))
+		case openai.ChatMessagePartTypeImageURL:
+			if v.ImageURL == nil {
+				continue
+			}
+			blob, err := s.loadImage(ctx, v.ImageURL.URL)
+			if err != nil {
+				return nil, err
 			}
-			result.Received = append(result.Received, recipes...)
+			parts = append(parts, blob)
 		}
 	}
+	return parts, nil
+}
 
-	result.Usage = &openai.Usage{
-		PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
-		CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
-		TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
+// newChat 系统消息合并为 SystemInstruction，assistant 对应 gemini 的 model 角色，最后一条消息作为本次发送的内容
+func (s *Driver) newChat(ctx context.Context, query []*types.MessageContext) (*genai.ChatSession, []genai.Part, error) {
+	var (
+		system   []string
+		contents []*genai.Content
+	)
+	for _, v := range query {
+		if v.Role == types.USER_ROLE_SYSTEM {
+			system = append(system, v.Content)
+			continue
+		}
+		parts, err := s.convertParts(ctx, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		contents = append(contents, &genai.Content{
+			Role:  lo.If(v.Role == types.USER_ROLE_ASSISTANT, "model").Else("user"),
+			Parts: parts,
+		})
+	}
+	if len(contents) == 0 {
+		// 只有系统提示词时将其作为用户消息发送
+		if len(system) == 0 {
+			return nil, nil, errors.New("empty query")
+		}
+		contents = append(contents, genai.NewUserContent(genai.Text(strings.Join(system, "\n\n"))))
+		system = nil
 	}
 
-	return result, nil
+	model := s.client.GenerativeModel(s.model.ChatModel)
+	if len(system) > 0 {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(strings.Join(system, "\n\n")))
+	}
+	chat := model.StartChat()
+	chat.History = contents[:len(contents)-1]
+	return chat, contents[len(contents)-1].Parts, nil
 }
 
-const PROMPT_PROCESS_CONTENT_CN = `
-请按照下列内容对文本进行处理：
-1. 分词
-2. 去除停用词
-3. 词形还原
-4. 命名实体识别
+func (s *Driver) Query(ctx context.Context, query []*types.MessageContext) (ai.GenerateResponse, error) {
+	slog.Debug("Query", slog.Any("query", query), slog.String("driver", NAME))
 
-请结合上述几点处理后的内容，生成摘要与标签反馈给用户。
-标签最多提取5个。
+	var result ai.GenerateResponse
+	chat, parts, err := s.newChat(ctx, query)
+	if err != nil {
+		return result, err
+	}
 
-请按照这个流程处理用户提供的文本。
-`
+	var (
+		sb    strings.Builder
+		usage *genai.UsageMetadata
+		iter  = newResponseIterator(chat.SendMessageStream(ctx, parts...))
+	)
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("Completion error: %w", convertError(err))
+		}
+		sb.WriteString(responseText(resp))
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+	}
 
-func (s *Driver) ProcessContent(ctx context.Context, doc *string) (ai.GenerateResponse, error) {
-	model := s.client.GenerativeModel("gemini-1.5-pro-latest")
-	model.SystemInstruction = genai.NewUserContent(genai.Text(PROMPT_PROCESS_CONTENT_CN))
-	// Ask the model to respond with JSON.
-	model.ResponseMIMEType = "application/json"
-	// Specify the schema.
-	model.ResponseSchema = &genai.Schema{
-		Type:  genai.TypeArray,
-		Items: &genai.Schema{Type: genai.TypeString},
+	if sb.Len() == 0 {
+		return result, errors.New("empty response content")
 	}
+	result.Received = append(result.Received, sb.String())
+	result.Usage = convertUsage(usage)
+	result.Model = s.model.ChatModel
+	return result, nil
+}
 
-	slog.Debug("ProcessContent", slog.String("driver", "gemini"), slog.String("prompt", PROMPT_PROCESS_CONTENT_CN), slog.String("doc", *doc))
+// QueryStream 请求失败(如限流)时直接返回错误，以便切换到备用驱动
+func (s *Driver) QueryStream(ctx context.Context, query []*types.MessageContext) (ai.Stream, error) {
+	slog.Debug("Query", slog.Any("query_stream", query), slog.String("driver", NAME))
 
-	var result ai.GenerateResponse
-	resp, err := model.GenerateContent(ctx, genai.Text(*doc))
+	chat, parts, err := s.newChat(ctx, query)
 	if err != nil {
-		return result, err
+		return nil, err
 	}
 
-	if len(resp.Candidates) == 0 {
-		return result, errors.New("empty response content")
+	ctx, cancel := context.WithCancel(ctx)
+	iter := newResponseIterator(chat.SendMessageStream(ctx, parts...))
+	first, err := iter.Next()
+	if err != nil && err != iterator.Done {
+		cancel()
+		return nil, fmt.Errorf("Completion error: %w", convertError(err))
 	}
+	return &stream{
+		model:   s.model.ChatModel,
+		pending: first,
+		iter:    iter,
+		eof:     err == iterator.Done,
+		cancel:  cancel,
+	}, nil
+}
 
+func responseText(resp *genai.GenerateContentResponse) string {
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
+	var sb strings.Builder
 	for _, part := range resp.Candidates[0].Content.Parts {
-		if resp.Candidates[0].FinishReason != genai.FinishReasonStop {
-			slog.Warn("ProcessContent, ai finished without stop", slog.String("reason", resp.Candidates[0].FinishReason.String()))
-		}
 		if txt, ok := part.(genai.Text); ok {
-			var recipes []string
-			if err := json.Unmarshal([]byte(txt), &recipes); err != nil {
-				return result, fmt.Errorf("failed to unmarshal ai response content, %w", err)
-			}
-			result.Received = append(result.Received, recipes...)
+			sb.WriteString(string(txt))
 		}
 	}
-	result.Usage = &openai.Usage{
-		PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
-		CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
-		TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
+	return sb.String()
+}
+
+// generateJSON 通过 structured output 让模型按 schema 返回 json，并解析到 result 中
+func (s *Driver) generateJSON(ctx context.Context, prompt, content string, schema *genai.Schema, result any) (*openai.Usage, error) {
+	model := s.client.GenerativeModel(s.model.ChatModel)
+	model.SystemInstruction = genai.NewUserContent(genai.Text(prompt))
+	model.ResponseMIMEType = "application/json"
+	model.ResponseSchema = schema
+
+	resp, err := model.GenerateContent(ctx, genai.Text(content))
+	if err != nil {
+		return nil, fmt.Errorf("Completion error: %w", convertError(err))
+	}
+	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason != genai.FinishReasonStop {
+		slog.Warn("ai finished without stop", slog.String("driver", NAME), slog.String("reason", resp.Candidates[0].FinishReason.String()))
 	}
 
-	return result, nil
+	text := responseText(resp)
+	if text == "" {
+		return nil, errors.New("empty response content")
+	}
+	if err = json.Unmarshal([]byte(text), result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ai response content, %w", err)
+	}
+	return convertUsage(resp.UsageMetadata), nil
 }
 
 func (s *Driver) Summarize(ctx context.Context, doc *string) (ai.SummarizeResult, error) {
 	slog.Debug("Summarize", slog.String("driver", NAME))
-	// To use functions / tools, we have to first define a schema that describes
-	// the function to the model. The schema is similar to OpenAPI 3.0.
 	schema := &genai.Schema{
 		Type: genai.TypeObject,
 		Properties: map[string]*genai.Schema{
@@ -223,76 +369,96 @@ func (s *Driver) Summarize(ctx context.Context, doc *string) (ai.SummarizeResult
 				Type:        genai.TypeString,
 				Description: "Processed summary content.",
 			},
+			"date_time": {
+				Type:        genai.TypeString,
+				Description: "The time mentioned in the user's content, formatted as year-month-day hour:minute. Leave it empty if there is no time.",
+			},
 		},
 		Required: []string{"tags", "title", "summary"},
 	}
 
-	summaryTool := &genai.Tool{
-		FunctionDeclarations: []*genai.FunctionDeclaration{{
-			Name:        "summarize",
-			Description: "Processed summary content.",
-			Parameters:  schema,
-		}},
+	var result ai.SummarizeResult
+	usage, err := s.generateJSON(ctx, ai.ReplaceVarEN(ai.PROMPT_PROCESS_CONTENT_EN), *doc, schema, &result)
+	if err != nil {
+		return result, err
 	}
+	result.Usage = usage
+	result.Model = s.model.ChatModel
+	return result, nil
+}
 
-	model := s.client.GenerativeModel("gemini-1.5-pro-latest")
-
-	// Before initiating a conversation, we tell the model which tools it has
-	// at its disposal.
-	model.Tools = []*genai.Tool{summaryTool}
-	model.ToolConfig = &genai.ToolConfig{
-		FunctionCallingConfig: &genai.FunctionCallingConfig{
-			Mode: genai.FunctionCallingAny,
+func (s *Driver) Chunk(ctx context.Context, doc *string) (ai.ChunkResult, error) {
+	slog.Debug("Chunk", slog.String("driver", NAME))
+	schema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"tags": {
+				Type:        genai.TypeArray,
+				Description: "Extract relevant keywords or technical tags from the user's description to assist the user in categorizing related content later. Organize these values in an array format.",
+				Items: &genai.Schema{
+					Type: genai.TypeString,
+				},
+			},
+			"title": {
+				Type:        genai.TypeString,
+				Description: "Generate a title for the content provided by the user and fill in this field.",
+			},
+			"chunks": {
+				Type:        genai.TypeArray,
+				Description: "The chunked content blocks.",
+				Items: &genai.Schema{
+					Type: genai.TypeString,
+				},
+			},
+			"date_time": {
+				Type:        genai.TypeString,
+				Description: "The time mentioned in the user's content, formatted as year-month-day hour:minute. Leave it empty if there is no time.",
+			},
 		},
+		Required: []string{"tags", "title", "chunks"},
 	}
 
-	model.SystemInstruction = genai.NewUserContent(genai.Text(ai.ReplaceVarCN(ai.PROMPT_PROCESS_CONTENT_EN)))
-	var result ai.SummarizeResult
-	// res, err := model.GenerateContent(ctx, genai.Text(*doc))
-	// if err != nil {
-	// 	return result, fmt.Errorf("funcall failed: %v", err)
-	// }
-	session := model.StartChat()
-
-	res, err := session.SendMessage(ctx, genai.Text(*doc))
+	var result ai.ChunkResult
+	usage, err := s.generateJSON(ctx, ai.ReplaceVarEN(ai.PROMPT_CHUNK_CONTENT_EN), *doc, schema, &result)
 	if err != nil {
-		return result, fmt.Errorf("session.SendMessage: %v", err)
-	}
-
-	part := res.Candidates[0].Content.Parts[0]
-	funcall, ok := part.(genai.FunctionCall)
-	if !ok || funcall.Name != "summarize" {
-		return result, fmt.Errorf("expected FunctionCall to find_theaters: %v", part)
+		return result, err
 	}
+	result.Usage = usage
+	result.Model = s.model.ChatModel
+	return result, nil
+}
 
-	for k, v := range funcall.Args {
-		// Expect the model to pass a proper string "{k}" argument to the tool.
-		switch k {
-		case "title":
-			if parsed, ok := v.(string); ok {
-				result.Title = parsed
-			} else {
-				return result, fmt.Errorf("funcall args parse expected string: %v", funcall.Args[k])
-			}
-		case "summary":
-			if parsed, ok := v.(string); ok {
-				result.Summary = parsed
-			} else {
-				return result, fmt.Errorf("funcall args parse expected string: %v", funcall.Args[k])
-			}
-		case "tags":
-			if parsed, ok := v.([]interface{}); ok {
-				for _, v := range parsed {
-					if str, ok := v.(string); ok {
-						result.Tags = append(result.Tags, str)
-					}
-				}
-			} else {
-				return result, fmt.Errorf("funcall args parse expected []string: %v", funcall.Args[k])
-			}
-		}
+type EnhanceQueryResult struct {
+	Querys []string `json:"querys"`
+}
 
+func (s *Driver) EnhanceQuery(ctx context.Context, prompt, query string) (ai.EnhanceQueryResult, error) {
+	slog.Debug("EnhanceQuery", slog.String("driver", NAME))
+	schema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"querys": {
+				Type:        genai.TypeArray,
+				Description: "The queries the user may ask.",
+				Items: &genai.Schema{
+					Type: genai.TypeString,
+				},
+			},
+		},
+		Required: []string{"querys"},
 	}
 
+	var (
+		enhanced EnhanceQueryResult
+		result   ai.EnhanceQueryResult
+	)
+	usage, err := s.generateJSON(ctx, prompt, query, schema, &enhanced)
+	if err != nil {
+		return result, err
+	}
+	result.Original = query
+	result.News = enhanced.Querys
+	result.Model = s.model.ChatModel
+	result.Usage = usage
 	return result, nil
 }
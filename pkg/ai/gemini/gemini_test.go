@@ -2,196 +2,262 @@ package gemini_test
 
 import (
 	"context"
-	"log"
-	"log/slog"
-	"os"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
-	"time"
 
+	oai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/ai"
 	"github.com/breeew/brew-api/pkg/ai/gemini"
 	"github.com/breeew/brew-api/pkg/types"
-	"github.com/google/generative-ai-go/genai"
-	"github.com/stretchr/testify/assert"
-	"google.golang.org/api/option"
 )
 
-func init() {
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	})))
+type generateRequest struct {
+	Contents []struct {
+		Role  string           `json:"role"`
+		Parts []map[string]any `json:"parts"`
+	} `json:"contents"`
+	SystemInstruction *struct {
+		Parts []map[string]any `json:"parts"`
+	} `json:"systemInstruction"`
+	GenerationConfig *struct {
+		ResponseMimeType string `json:"responseMimeType"`
+	} `json:"generationConfig"`
 }
 
-func new() *gemini.Driver {
-	return gemini.New(os.Getenv("BREW_API_AI_GEMINI_TOKEN"))
+// candidate 返回 gemini 的单个响应，finishReason 1 为 STOP
+func candidate(text string, finish int, usage bool) string {
+	raw, _ := json.Marshal(text)
+	res := `{"candidates":[{"content":{"role":"model","parts":[{"text":` + string(raw) + `}]},"finishReason":` + strconv.Itoa(finish) + `}]`
+	if usage {
+		res += `,"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":3,"totalTokenCount":8}`
+	}
+	return res + `}`
 }
 
-func Test_Embedding(t *testing.T) {
-	d := new()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
-	res, err := d.EmbeddingForDocument(ctx, "test", "this is test content for test embedding")
+func newServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, body []byte)) (*gemini.Driver, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-token", r.URL.Query().Get("key"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		handler(w, r, body)
+	}))
+	d, err := gemini.New("test-token", srv.URL, ai.ModelName{ChatModel: "gemini-test", EmbeddingModel: "embedding-test"})
 	if err != nil {
 		t.Fatal(err)
 	}
+	return d, srv.Close
+}
 
-	t.Log(len(res))
+func Test_Query(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/models/gemini-test:streamGenerateContent"))
 
-	assert.Greater(t, len(res), 0)
+		var req generateRequest
+		assert.NoError(t, json.Unmarshal(body, &req))
+		if assert.NotNil(t, req.SystemInstruction) {
+			assert.Equal(t, "you are a test", req.SystemInstruction.Parts[0]["text"])
+		}
+		if assert.Len(t, req.Contents, 3) {
+			assert.Equal(t, []string{"user", "model", "user"}, []string{req.Contents[0].Role, req.Contents[1].Role, req.Contents[2].Role})
+			assert.Equal(t, "where is my car", req.Contents[2].Parts[0]["text"])
+		}
+
+		w.Write([]byte(`[` + candidate("B2", 1, true) + `]`))
+	})
+	defer closeFn()
+
+	res, err := d.NewQuery(context.Background(), []*types.MessageContext{
+		{Role: types.USER_ROLE_SYSTEM, Content: "you are a test"},
+		{Role: types.USER_ROLE_USER, Content: "hi"},
+		{Role: types.USER_ROLE_ASSISTANT, Content: "hello"},
+		{Role: types.USER_ROLE_USER, Content: "where is my car"},
+	}).Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"B2"}, res.Received)
+	assert.Equal(t, "gemini-test", res.Model)
+	assert.Equal(t, 8, res.Usage.TotalTokens)
 }
 
-func Test_Generate(t *testing.T) {
-	d := new()
+func Test_QueryStream(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Write([]byte(`[` + candidate("Hello", 0, false) + `,` + candidate(" world", 1, true) + `]`))
+	})
+	defer closeFn()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
-	res, err := d.Query(ctx, "我的车昨天停哪了？", []*types.PassageInfo{
-		{
-			ID:      "xcjoijoijo12",
-			Content: "我有一辆白色的车",
-		},
-		{
-			ID:      "xcjoiaajoijo12",
-			Content: "我有一辆白色的自行车",
-		},
-		{
-			ID:      "xcjoij12312ijo12",
-			Content: "我昨天把车停在了B2层",
-		},
-		{
-			ID:      "3333oijoijo12",
-			Content: "停车楼里有十辆车",
-		},
+	stream, err := d.QueryStream(context.Background(), []*types.MessageContext{
+		{Role: types.USER_ROLE_USER, Content: "hi"},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer stream.Close()
 
-	t.Log(res)
+	var (
+		content string
+		finish  string
+		usage   *oai.Usage
+	)
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "gemini-test", event.Model)
+		if event.Usage != nil {
+			usage = event.Usage
+		}
+		if event.FinishReason != "" {
+			finish = event.FinishReason
+		}
+		content += event.Content
+	}
+	assert.Equal(t, "Hello world", content)
+	assert.Equal(t, ai.FINISH_REASON_STOP, finish)
+	if assert.NotNil(t, usage) {
+		assert.Equal(t, 3, usage.CompletionTokens)
+	}
 }
 
-func Test_ProcessContent(t *testing.T) {
-	content := "Docker 支持 64 位版本 CentOS 7/8，并且要求内核版本不低于 3.10。 CentOS 7 满足最低内核的要求，但由于内核版本比较低，部分功能（如 overlay2 存储层驱动）无法使用，并且部分功能可能不太稳定。"
+func Test_QueryError(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"code":429,"message":"quota exceeded","status":"RESOURCE_EXHAUSTED"}}`))
+	})
+	defer closeFn()
+
+	msgs := []*types.MessageContext{{Role: types.USER_ROLE_USER, Content: "hi"}}
+	_, err := d.Query(context.Background(), msgs)
+	assert.True(t, ai.IsFailoverError(context.Background(), err))
 
-	d := new()
+	_, err = d.QueryStream(context.Background(), msgs)
+	assert.True(t, ai.IsFailoverError(context.Background(), err))
+}
+
+func Test_Vision(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		var req generateRequest
+		assert.NoError(t, json.Unmarshal(body, &req))
+		if assert.Len(t, req.Contents, 1) && assert.Len(t, req.Contents[0].Parts, 2) {
+			assert.Equal(t, "what is it", req.Contents[0].Parts[0]["text"])
+			assert.Equal(t, map[string]any{"mimeType": "image/png", "data": "aW1n"}, req.Contents[0].Parts[1]["inlineData"])
+		}
+		w.Write([]byte(`[` + candidate("a cat", 1, true) + `]`))
+	})
+	defer closeFn()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
-	resp, err := d.ProcessContent(ctx, &content)
+	res, err := d.NewVisionQuery(context.Background(), []*types.MessageContext{{
+		Role: types.USER_ROLE_USER,
+		MultiContent: []oai.ChatMessagePart{
+			{Type: oai.ChatMessagePartTypeText, Text: "what is it"},
+			{Type: oai.ChatMessagePartTypeImageURL, ImageURL: &oai.ChatMessageImageURL{URL: "data:image/png;base64,aW1n"}},
+		},
+	}}).Query()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	t.Log(resp)
+	assert.Equal(t, []string{"a cat"}, res.Received)
 }
 
 func Test_Summarize(t *testing.T) {
-	content := "Docker 支持 64 位版本 CentOS 7/8，并且要求内核版本不低于 3.10。 CentOS 7 满足最低内核的要求，但由于内核版本比较低，部分功能（如 overlay2 存储层驱动）无法使用，并且部分功能可能不太稳定。"
-
-	d := new()
+	d, closeFn := newServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		var req generateRequest
+		assert.NoError(t, json.Unmarshal(body, &req))
+		if assert.NotNil(t, req.GenerationConfig) {
+			assert.Equal(t, "application/json", req.GenerationConfig.ResponseMimeType)
+		}
+		// structured output 不使用流式接口
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/models/gemini-test:generateContent"))
+		w.Write([]byte(candidate(`{"tags":["docker"],"title":"Docker on CentOS","summary":"requires kernel 3.10"}`, 1, true)))
+	})
+	defer closeFn()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
-	resp, err := d.Summarize(ctx, &content)
+	content := "Docker supports CentOS 7/8 and requires kernel 3.10 or later."
+	res, err := d.Summarize(context.Background(), &content)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	t.Log(resp)
+	assert.Equal(t, "Docker on CentOS", res.Title)
+	assert.Equal(t, []string{"docker"}, res.Tags)
+	assert.Equal(t, "requires kernel 3.10", res.Summary)
+	assert.Equal(t, 8, res.Usage.TotalTokens)
 }
 
-func Test_Tool(t *testing.T) {
-	client, err := genai.NewClient(context.Background(), option.WithAPIKey(os.Getenv("BREW_API_AI_GEMINI_TOKEN")))
+func Test_Chunk(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Write([]byte(candidate(`{"tags":["docker"],"title":"Docker","chunks":["part 1","part 2"]}`, 1, true)))
+	})
+	defer closeFn()
+
+	content := "part 1. part 2."
+	res, err := d.Chunk(context.Background(), &content)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// To use functions / tools, we have to first define a schema that describes
-	// the function to the model. The schema is similar to OpenAPI 3.0.
-	// schema := &genai.Schema{
-	// 	Type: genai.TypeObject,
-	// 	Properties: map[string]*genai.Schema{
-	// 		"location": {
-	// 			Type:        genai.TypeString,
-	// 			Description: " San Francisco, CA or a zip code e.g. 95616",
-	// 		},
-	// 		"title": {
-	// 			Type:        genai.TypeString,
-	// 			Description: "Any movie title",
-	// 		},
-	// 	},
-	// 	Required: []string{"location"},
-	// }
-
-	schema := &genai.Schema{
-		Type: genai.TypeObject,
-		Properties: map[string]*genai.Schema{
-			"title": {
-				Type:        genai.TypeString,
-				Description: "summary user content to title",
-			},
-		},
-		Required: []string{"title"},
-	}
+	assert.Equal(t, []string{"part 1", "part 2"}, res.Chunks)
+}
 
-	movieTool := &genai.Tool{
-		FunctionDeclarations: []*genai.FunctionDeclaration{{
-			Name:        "func_summary",
-			Description: "summary content",
-			Parameters:  schema,
-		}},
+func Test_EnhanceQuery(t *testing.T) {
+	d, closeFn := newServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		w.Write([]byte(candidate(`{"querys":["where did I park","parking floor"]}`, 1, true)))
+	})
+	defer closeFn()
+
+	res, err := d.NewEnhance(context.Background()).EnhanceQuery("where is my car")
+	if err != nil {
+		t.Fatal(err)
 	}
+	assert.Equal(t, "where is my car", res.Original)
+	assert.Equal(t, []string{"where did I park", "parking floor"}, res.News)
+}
 
-	model := client.GenerativeModel("gemini-1.5-pro-latest")
+func Test_Embedding(t *testing.T) {
+	var batches []int
+	d, closeFn := newServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/models/embedding-test:batchEmbedContents"))
 
-	// Before initiating a conversation, we tell the model which tools it has
-	// at its disposal.
-	model.Tools = []*genai.Tool{movieTool}
-	model.ToolConfig = &genai.ToolConfig{
-		FunctionCallingConfig: &genai.FunctionCallingConfig{
-			Mode: genai.FunctionCallingAny,
-		},
-	}
+		var req struct {
+			Requests []struct {
+				TaskType int    `json:"taskType"`
+				Title    string `json:"title"`
+			} `json:"requests"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &req))
+		batches = append(batches, len(req.Requests))
 
-	// model.SystemInstruction = genai.NewUserContent(genai.Text(gemini.PROMPT_PROCESS_CONTENT_CN))
-
-	// For using tools, the chat mode is useful because it provides the required
-	// chat context. A model needs to have tools supplied to it in the chat
-	// history so it can use them in subsequent conversations.
-	//
-	// The flow of message expected here is:
-	//
-	// 1. We send a question to the model
-	// 2. The model recognizes that it needs to use a tool to answer the question,
-	//    an returns a FunctionCall response asking to use the tool.
-	// 3. We send a FunctionResponse message, simulating the return value of
-	//    the tool for the model's query.
-	// 4. The model provides its text answer in response to this message.
-	content := "Docker 支持 64 位版本 CentOS 7/8，并且要求内核版本不低于 3.10。 CentOS 7 满足最低内核的要求，但由于内核版本比较低，部分功能（如 overlay2 存储层驱动）无法使用，并且部分功能可能不太稳定。"
-	// ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	// defer cancel()
-	// res, err := model.GenerateContent(ctx, genai.Text(content))
-	// if err != nil {
-	// 	t.Fatal(err)
-	// }
-
-	session := model.StartChat()
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
-	defer cancel()
-	res, err := session.SendMessage(ctx, genai.Text(content))
-	if err != nil {
-		log.Fatalf("session.SendMessage: %v", err)
-	}
+		var embeddings []string
+		for _, v := range req.Requests {
+			// RETRIEVAL_DOCUMENT
+			assert.Equal(t, 2, v.TaskType)
+			assert.Equal(t, "test", v.Title)
+			embeddings = append(embeddings, `{"values":[0.1,0.2]}`)
+		}
+		w.Write([]byte(`{"embeddings":[` + strings.Join(embeddings, ",") + `]}`))
+	})
+	defer closeFn()
 
-	part := res.Candidates[0].Content.Parts[0]
-	funcall, ok := part.(genai.FunctionCall)
-	if !ok || funcall.Name != "func_summary" {
-		log.Fatalf("expected FunctionCall to find_theaters: %v", part)
+	content := make([]string, 150)
+	for i := range content {
+		content[i] = "content"
 	}
-
-	// Expect the model to pass a proper string "location" argument to the tool.
-	if _, ok := funcall.Args["title"].(string); !ok {
-		log.Fatalf("expected string: %v", funcall.Args["location"])
+	res, err := d.EmbeddingForDocument(context.Background(), "test", content)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	t.Log(funcall)
+	assert.Equal(t, []int{100, 50}, batches)
+	assert.Len(t, res.Data, 150)
+	assert.Equal(t, []float32{0.1, 0.2}, res.Data[0])
+	assert.Equal(t, "embedding-test", res.Model)
 }
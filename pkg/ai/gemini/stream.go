@@ -0,0 +1,94 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+
+	"github.com/breeew/brew-api/pkg/ai"
+)
+
+// responseIterator gax 解析流式响应时依赖 json.Decoder 在数组结束处的行为，部分 Go 版本下无法识别结尾的 "]" 而返回解析错误
+// 已收到 finishReason 说明响应已经完整，之后的错误视为结束
+type responseIterator struct {
+	iter     *genai.GenerateContentResponseIterator
+	finished bool
+}
+
+func newResponseIterator(iter *genai.GenerateContentResponseIterator) *responseIterator {
+	return &responseIterator{iter: iter}
+}
+
+func (it *responseIterator) Next() (*genai.GenerateContentResponse, error) {
+	resp, err := it.iter.Next()
+	if err != nil {
+		if it.finished {
+			return nil, iterator.Done
+		}
+		return nil, err
+	}
+	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason != genai.FinishReasonUnspecified {
+		it.finished = true
+	}
+	return resp, nil
+}
+
+func convertFinishReason(reason genai.FinishReason) string {
+	switch reason {
+	case genai.FinishReasonUnspecified:
+		return ""
+	case genai.FinishReasonMaxTokens:
+		return ai.FINISH_REASON_LENGTH
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation:
+		return ai.FINISH_REASON_FILTER
+	default:
+		return ai.FINISH_REASON_STOP
+	}
+}
+
+// stream 实现 ai.Stream，pending 为 QueryStream 中为确认请求成功而提前读取的第一个响应
+type stream struct {
+	model   string
+	pending *genai.GenerateContentResponse
+	iter    *responseIterator
+	eof     bool
+	cancel  context.CancelFunc
+}
+
+func (s *stream) Recv() (ai.StreamEvent, error) {
+	resp := s.pending
+	s.pending = nil
+	if resp == nil {
+		if s.eof {
+			return ai.StreamEvent{}, io.EOF
+		}
+		var err error
+		if resp, err = s.iter.Next(); err == iterator.Done {
+			s.eof = true
+			return ai.StreamEvent{}, io.EOF
+		} else if err != nil {
+			return ai.StreamEvent{}, fmt.Errorf("Completion error: %w", convertError(err))
+		}
+	}
+
+	event := ai.StreamEvent{
+		Model:   s.model,
+		Content: responseText(resp),
+	}
+	if len(resp.Candidates) > 0 {
+		event.FinishReason = convertFinishReason(resp.Candidates[0].FinishReason)
+	}
+	// 每个响应中的 usage 为累计值，只在结束时返回
+	if event.FinishReason != "" && resp.UsageMetadata != nil {
+		event.Usage = convertUsage(resp.UsageMetadata)
+	}
+	return event, nil
+}
+
+func (s *stream) Close() error {
+	s.cancel()
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/breeew/brew-api/pkg/ai"
 	"github.com/samber/lo"
@@ -18,20 +19,28 @@ import (
 )
 
 type Driver struct {
-	client *http.Client
-	token  string
-	models map[string]string
+	client      *http.Client
+	token       string
+	apiEndpoint string
+	models      map[string]string
 }
 
 const (
 	NAME = "jina"
+
+	DEFAULT_API_ENDPOINT = "https://api.jina.ai"
 )
 
-func New(token string, models map[string]string) *Driver {
+// New apiEndpoint 为 jina 兼容的 api 地址，为空时使用 DEFAULT_API_ENDPOINT
+func New(token, apiEndpoint string, models map[string]string) *Driver {
+	if apiEndpoint == "" {
+		apiEndpoint = DEFAULT_API_ENDPOINT
+	}
 	return &Driver{
-		client: &http.Client{},
-		token:  token,
-		models: models,
+		client:      &http.Client{},
+		token:       token,
+		apiEndpoint: strings.TrimSuffix(apiEndpoint, "/"),
+		models:      models,
 	}
 }
 
@@ -97,11 +106,11 @@ func (s *Driver) Rerank(ctx context.Context, query string, docs []*ai.RerankDoc)
 
 	raw, _ := json.Marshal(request)
 
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.jina.ai/v1/rerank", bytes.NewReader(raw))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, s.apiEndpoint+"/v1/rerank", bytes.NewReader(raw))
 	s.applyBaseHeader(req)
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Failed to request jina reader: %w", err)
+		return nil, nil, fmt.Errorf("Failed to request jina rerank: %w", err)
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
@@ -121,6 +130,9 @@ func (s *Driver) Rerank(ctx context.Context, query string, docs []*ai.RerankDoc)
 	var rank []ai.RankDocItem
 
 	for _, v := range result.Results {
+		if v.Index < 0 || v.Index >= len(docs) {
+			return nil, nil, fmt.Errorf("Unexpected rerank result index %d", v.Index)
+		}
 		item := docs[v.Index]
 		rank = append(rank, ai.RankDocItem{
 			ID:    item.ID,
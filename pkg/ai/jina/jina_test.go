@@ -3,9 +3,13 @@ package jina_test
 import (
 	"context"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/breeew/brew-api/pkg/ai"
 	"github.com/breeew/brew-api/pkg/ai/jina"
 )
@@ -17,7 +21,7 @@ func init() {
 }
 
 func new() *jina.Driver {
-	return jina.New(os.Getenv("BREW_API_AI_JINA_TOKEN"), map[string]string{
+	return jina.New(os.Getenv("BREW_API_AI_JINA_TOKEN"), "", map[string]string{
 		"rerank": "jina-reranker-v2-base-multilingual",
 	})
 }
@@ -79,3 +83,23 @@ func TestRerank(t *testing.T) {
 
 	t.Log(res)
 }
+
+func TestRerankEndpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/rerank", r.URL.Path)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"model":"jina-test","usage":{"total_tokens":7},"results":[{"index":1,"relevance_score":0.8},{"index":0,"relevance_score":0.3}]}`))
+	}))
+	defer srv.Close()
+
+	d := jina.New("test-token", srv.URL+"/", map[string]string{"rerank": "jina-test"})
+	res, usage, err := d.Rerank(context.Background(), "k8s", []*ai.RerankDoc{
+		{ID: "a", Content: "about go"},
+		{ID: "b", Content: "about k8s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []ai.RankDocItem{{ID: "b", Score: 0.8}, {ID: "a", Score: 0.3}}, res)
+	assert.Equal(t, 7, usage.Usage.PromptTokens)
+}
@@ -0,0 +1,359 @@
+package local
+
+// 本地 reader，直接抓取网页并转换为 markdown，不依赖外部服务
+// - reader
+// - rerank (lexical)
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/extract"
+)
+
+const (
+	NAME = "local"
+
+	DEFAULT_USER_AGENT = "Mozilla/5.0 (compatible; BrewReader/1.0)"
+	// MAX_BODY_SIZE 网页内容的最大长度，超出部分将被忽略
+	MAX_BODY_SIZE = 10 << 20
+	// MAX_REDIRECTS 最多跟随的重定向次数
+	MAX_REDIRECTS = 5
+)
+
+var ErrForbiddenAddress = errors.New("forbidden address")
+
+type Driver struct {
+	client    *http.Client
+	userAgent string
+	// allow 判断目标地址是否允许访问，默认只允许公网地址
+	allow func(addr netip.Addr) bool
+}
+
+// New 抓取的地址由用户提交，client 会被复制并替换 transport 与重定向策略，
+// 拒绝访问回环、内网、链路本地等地址，防止 SSRF
+func New(client *http.Client, userAgent string) *Driver {
+	if userAgent == "" {
+		userAgent = DEFAULT_USER_AGENT
+	}
+	d := &Driver{
+		userAgent: userAgent,
+		allow:     isPublicAddr,
+	}
+
+	c := http.Client{}
+	if client != nil {
+		c = *client
+	}
+	c.Transport = d.transport(c.Transport)
+	c.CheckRedirect = d.checkRedirect
+	d.client = &c
+	return d
+}
+
+// transport 在建立连接前检查解析后的 ip，避免 DNS rebinding 绕过检查
+// 不使用代理，否则实际连接的是代理地址，无法检查目标地址
+func (s *Driver) transport(rt http.RoundTripper) *http.Transport {
+	t, ok := rt.(*http.Transport)
+	if ok {
+		t = t.Clone()
+	} else {
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	t.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !s.allow(addr.Addr().Unmap()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+			}
+			return nil
+		},
+	}
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+func (s *Driver) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= MAX_REDIRECTS {
+		return fmt.Errorf("stopped after %d redirects", MAX_REDIRECTS)
+	}
+	return s.checkURL(req.Context(), req.URL)
+}
+
+// checkURL 检查协议及主机解析后的所有地址，请求前及每次重定向时调用
+func (s *Driver) checkURL(ctx context.Context, u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid url: %s", u)
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("Failed to resolve host: %w", err)
+	}
+	for _, ip := range ips {
+		if !s.allow(ip.Unmap()) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, u.Hostname())
+		}
+	}
+	return nil
+}
+
+// isPublicAddr 拒绝回环、内网、链路本地、未指定及组播地址
+func isPublicAddr(addr netip.Addr) bool {
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddrSpace.Contains(addr)
+}
+
+// sharedAddrSpace 运营商级 NAT 地址(RFC 6598)，部分云厂商内网也使用该网段
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func (s *Driver) Reader(ctx context.Context, endpoint string) (*ai.ReaderResult, error) {
+	slog.Debug("Reader", slog.String("driver", NAME))
+
+	pageURL, err := url.Parse(endpoint)
+	if err != nil || (pageURL.Scheme != "http" && pageURL.Scheme != "https") || pageURL.Host == "" {
+		return nil, fmt.Errorf("invalid url: %s", endpoint)
+	}
+	if err = s.checkURL(ctx, pageURL); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Failed to request page, status code: %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	body, err := charset.NewReader(io.LimitReader(resp.Body, MAX_BODY_SIZE), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode page: %w", err)
+	}
+
+	switch {
+	case mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		doc, err := html.Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse page: %w", err)
+		}
+		// 以重定向后的地址作为相对链接的基准
+		return &ai.ReaderResult{
+			Content: Markdown(doc, resp.Request.URL),
+		}, nil
+	case strings.HasPrefix(mediaType, "text/"):
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return &ai.ReaderResult{
+			Content: strings.TrimSpace(string(raw)),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+}
+
+// Markdown 提取网页正文并转换为 markdown，标题作为一级标题置于开头
+// 优先使用 <article>、<main> 作为正文，并去除导航、页眉页脚、侧边栏等与正文无关的内容
+func Markdown(doc *html.Node, base *url.URL) string {
+	title := extract.HTMLTitle(doc)
+
+	removeBoilerplate(doc)
+	if base != nil {
+		resolveLinks(doc, base)
+	}
+
+	content := extract.HTMLToMarkdown(mainContent(doc))
+	if title == "" || strings.HasPrefix(content, "# ") {
+		return content
+	}
+	return strings.TrimSpace("# " + title + "\n\n" + content)
+}
+
+// mainContent 返回正文所在的节点，页面中存在多个 <article> 时视为列表页，使用整个页面
+func mainContent(doc *html.Node) *html.Node {
+	var main, articles []*html.Node
+	walk(doc, func(n *html.Node) bool {
+		switch {
+		case n.DataAtom == atom.Article:
+			articles = append(articles, n)
+			return false
+		case n.DataAtom == atom.Main || attr(n, "role") == "main":
+			main = append(main, n)
+		}
+		return true
+	})
+	if len(articles) == 1 {
+		return articles[0]
+	}
+	if len(main) == 1 {
+		return main[0]
+	}
+	return doc
+}
+
+// boilerplateRoles 与正文无关的 ARIA role
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+	"dialog":        true,
+	"alertdialog":   true,
+	"menu":          true,
+	"menubar":       true,
+}
+
+// boilerplateHints class 或 id 中包含这些词的元素通常不是正文
+var boilerplateHints = []string{
+	"navbar", "nav-", "-nav", "menu", "sidebar", "breadcrumb", "footer",
+	"cookie", "banner", "advert", "social", "share", "subscribe", "newsletter",
+	"related", "comment", "popup", "modal", "toc",
+}
+
+func isBoilerplate(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Nav, atom.Header, atom.Footer, atom.Aside, atom.Form, atom.Dialog:
+		// <article> 内的 <header> 通常包含文章标题
+		if n.DataAtom == atom.Header && hasAncestor(n, atom.Article) {
+			return false
+		}
+		return true
+	case atom.Body, atom.Html, atom.Main, atom.Article:
+		return false
+	}
+	if boilerplateRoles[attr(n, "role")] {
+		return true
+	}
+
+	hint := strings.ToLower(attr(n, "class") + " " + attr(n, "id"))
+	for _, token := range strings.Fields(hint) {
+		for _, v := range boilerplateHints {
+			if strings.HasPrefix(token, v) || strings.HasSuffix(token, v) {
+				// 仅凭 class、id 判断容易误伤，包含正文的容器需要保留
+				return !containsElement(n, atom.Article, atom.Main, atom.H1)
+			}
+		}
+	}
+	return false
+}
+
+func containsElement(n *html.Node, list ...atom.Atom) bool {
+	found := false
+	walk(n, func(c *html.Node) bool {
+		for _, a := range list {
+			if c != n && c.DataAtom == a {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+func removeBoilerplate(doc *html.Node) {
+	var list []*html.Node
+	walk(doc, func(n *html.Node) bool {
+		if isBoilerplate(n) {
+			list = append(list, n)
+			return false
+		}
+		return true
+	})
+	for _, n := range list {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// resolveLinks 将链接与图片的相对地址转换为绝对地址
+func resolveLinks(doc *html.Node, base *url.URL) {
+	walk(doc, func(n *html.Node) bool {
+		if n.DataAtom == atom.Base {
+			if href := attr(n, "href"); href != "" {
+				if u, err := base.Parse(href); err == nil {
+					base = u
+				}
+			}
+		}
+		for i, a := range n.Attr {
+			if (a.Key == "href" && n.DataAtom == atom.A) || (a.Key == "src" && n.DataAtom == atom.Img) {
+				if strings.HasPrefix(a.Val, "#") || strings.HasPrefix(a.Val, "data:") {
+					continue
+				}
+				if u, err := base.Parse(strings.TrimSpace(a.Val)); err == nil {
+					n.Attr[i].Val = u.String()
+				}
+			}
+		}
+		return true
+	})
+}
+
+// walk 深度优先遍历元素节点，fn 返回 false 时不再遍历其子节点
+func walk(n *html.Node, fn func(n *html.Node) bool) {
+	if n.Type == html.ElementNode && !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func hasAncestor(n *html.Node, a atom.Atom) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.DataAtom == a {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package local
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head><title>Brew Blog</title></head>
+<body>
+<header><a href="/">Home</a> <a href="/about">About</a></header>
+<nav class="menu"><ul><li><a href="/a">A</a></li></ul></nav>
+<div class="cookie-banner">We use cookies</div>
+<article>
+<header><h1>Hello Brew</h1></header>
+<p>Read the <a href="docs/start.html">guide</a>.</p>
+<img src="/static/logo.png" alt="logo">
+<div class="share-buttons">Share on X</div>
+</article>
+<aside>Related posts</aside>
+<footer>Copyright</footer>
+</body>
+</html>`
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blog/post", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/blog/post", http.StatusFound)
+	})
+	mux.HandleFunc("/gbk", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := simplifiedchinese.GBK.NewEncoder().String("<html><head><title>标题</title></head><body><p>你好，世界</p></body></html>")
+		w.Header().Set("Content-Type", "text/html; charset=gbk")
+		w.Write([]byte(body))
+	})
+	mux.HandleFunc("/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("\n  plain notes\n"))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG"))
+	})
+	return httptest.NewServer(mux)
+}
+
+// newTestDriver 测试服务监听在 127.0.0.1，除此之外仍按默认规则检查
+func newTestDriver(srv *httptest.Server, userAgent string) *Driver {
+	d := New(srv.Client(), userAgent)
+	d.allow = func(addr netip.Addr) bool {
+		return addr == netip.MustParseAddr("127.0.0.1") || isPublicAddr(addr)
+	}
+	return d
+}
+
+func Test_Reader(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	d := newTestDriver(srv, "")
+
+	res, err := d.Reader(context.Background(), srv.URL+"/redirect")
+	assert.NoError(t, err)
+	assert.Equal(t, "# Hello Brew\n\nRead the [guide]("+srv.URL+"/blog/docs/start.html).\n\n![logo]("+srv.URL+"/static/logo.png)", res.Content)
+
+	res, err = d.Reader(context.Background(), srv.URL+"/gbk")
+	assert.NoError(t, err)
+	assert.Equal(t, "# 标题\n\n你好，世界", res.Content)
+
+	res, err = d.Reader(context.Background(), srv.URL+"/notes.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain notes", res.Content)
+
+	_, err = d.Reader(context.Background(), srv.URL+"/image.png")
+	assert.Error(t, err)
+
+	_, err = d.Reader(context.Background(), srv.URL+"/not-found")
+	assert.Error(t, err)
+
+	_, err = d.Reader(context.Background(), "file:///etc/passwd")
+	assert.Error(t, err)
+}
+
+func Test_Reader_UserAgent(t *testing.T) {
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		w.Write([]byte("<p>ok</p>"))
+	}))
+	defer srv.Close()
+
+	res, err := newTestDriver(srv, "brew-test").Reader(context.Background(), srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", res.Content)
+	assert.Equal(t, "brew-test", userAgent)
+}
+
+func Test_Reader_ForbiddenAddress(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	d := New(srv.Client(), "")
+	_, err := d.Reader(context.Background(), srv.URL+"/blog/post")
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+
+	_, err = d.Reader(context.Background(), "http://169.254.169.254/latest/meta-data/")
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+
+	_, err = d.Reader(context.Background(), "http://[::ffff:10.0.0.1]/")
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+}
+
+func Test_Reader_RedirectToPrivate(t *testing.T) {
+	var hops int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops++
+		switch r.URL.Path {
+		case "/private":
+			http.Redirect(w, r, "http://10.0.0.1/admin", http.StatusFound)
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		default:
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	d := newTestDriver(srv, "")
+	_, err := d.Reader(context.Background(), srv.URL+"/private")
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+
+	_, err = d.Reader(context.Background(), srv.URL+"/metadata")
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+
+	hops = 0
+	_, err = d.Reader(context.Background(), srv.URL+"/loop")
+	assert.ErrorContains(t, err, "redirects")
+	assert.Equal(t, MAX_REDIRECTS, hops)
+}
+
+func Test_isPublicAddr(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.100.100.200": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+	} {
+		assert.Equal(t, public, isPublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,104 @@
+package local
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+
+	"github.com/breeew/brew-api/pkg/ai"
+	"github.com/breeew/brew-api/pkg/search"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// lexicalWeight 词法得分所占的权重，其余为检索排名的得分
+const lexicalWeight = 0.7
+
+// Rerank 本地的词法重排，用于 rerank api 不可用时兜底，不消耗 token
+// docs 需要按检索结果的顺序传入，得分(0~1)由文档覆盖的查询词项按 IDF 加权的比例与检索排名加权得到
+// 得分相同时按 BM25 排序，仍然相同时保持检索顺序
+func (s *Driver) Rerank(ctx context.Context, query string, docs []*ai.RerankDoc) ([]ai.RankDocItem, *ai.Usage, error) {
+	slog.Debug("Rerank", slog.String("driver", NAME))
+	return LexicalRerank(query, docs), nil, nil
+}
+
+func LexicalRerank(query string, docs []*ai.RerankDoc) []ai.RankDocItem {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	terms := search.Unique(search.Tokenize(query))
+	var (
+		freqs  = make([]map[string]int, len(docs))
+		lens   = make([]int, len(docs))
+		df     = make(map[string]int, len(terms))
+		avgLen float64
+	)
+	for i, d := range docs {
+		tokens := search.Tokenize(d.Content)
+		freqs[i] = make(map[string]int)
+		for _, t := range tokens {
+			freqs[i][t]++
+		}
+		lens[i] = len(tokens)
+		avgLen += float64(len(tokens))
+		for _, t := range terms {
+			if freqs[i][t] > 0 {
+				df[t]++
+			}
+		}
+	}
+	avgLen /= float64(len(docs))
+	if avgLen == 0 {
+		avgLen = 1
+	}
+
+	idf := make(map[string]float64, len(terms))
+	var totalIDF float64
+	for _, t := range terms {
+		n := float64(df[t])
+		idf[t] = math.Log(1 + (float64(len(docs))-n+0.5)/(n+0.5))
+		totalIDF += idf[t]
+	}
+
+	type scored struct {
+		ai.RankDocItem
+		bm25 float64
+	}
+	list := make([]scored, 0, len(docs))
+	for i, d := range docs {
+		var coverage, bm25 float64
+		for _, t := range terms {
+			tf := float64(freqs[i][t])
+			if tf == 0 {
+				continue
+			}
+			coverage += idf[t]
+			bm25 += idf[t] * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(lens[i])/avgLen))
+		}
+		if totalIDF > 0 {
+			coverage /= totalIDF
+		}
+		retrieval := 1 - float64(i)/float64(len(docs))
+		score := lexicalWeight*coverage + (1-lexicalWeight)*retrieval
+		list = append(list, scored{RankDocItem: ai.RankDocItem{ID: d.ID, Score: score}, bm25: bm25})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].bm25 > list[j].bm25
+	})
+
+	res := make([]ai.RankDocItem, 0, len(list))
+	for _, v := range list {
+		res = append(res, v.RankDocItem)
+	}
+	return res
+}
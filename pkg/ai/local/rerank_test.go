@@ -0,0 +1,50 @@
+package local
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/ai"
+)
+
+func Test_LexicalRerank(t *testing.T) {
+	docs := []*ai.RerankDoc{
+		{ID: "k8s", Content: "k8s notes: pods, services and k8s ingress"},
+		{ID: "go", Content: "Go modules and the go command"},
+		{ID: "deploy", Content: "Deploy the api to k8s with helm, the helm chart lives in deploy/"},
+	}
+
+	res, usage, err := New(nil, "").Rerank(context.Background(), "how to deploy to k8s", docs)
+	assert.NoError(t, err)
+	assert.Nil(t, usage)
+	assert.Len(t, res, 3)
+	assert.Equal(t, "deploy", res[0].ID)
+	assert.Equal(t, "k8s", res[1].ID)
+	assert.Equal(t, "go", res[2].ID)
+	// 没有命中查询词项的文档只保留检索排名的得分
+	assert.InDelta(t, (1-lexicalWeight)*2/3, res[2].Score, 1e-9)
+	assert.True(t, res[0].Score > res[1].Score && res[0].Score <= 1)
+
+	assert.Nil(t, LexicalRerank("k8s", nil))
+}
+
+func Test_LexicalRerank_RetrievalOrder(t *testing.T) {
+	// 词法得分相同时保持检索顺序
+	res := LexicalRerank("k8s", []*ai.RerankDoc{
+		{ID: "b", Content: "k8s"},
+		{ID: "a", Content: "k8s"},
+		{ID: "c", Content: "k8s"},
+	})
+	assert.Equal(t, []string{"b", "a", "c"}, lo.Map(res, func(item ai.RankDocItem, _ int) string { return item.ID }))
+
+	// 查询词项全部未命中时结果与检索顺序一致
+	res = LexicalRerank("helm", []*ai.RerankDoc{
+		{ID: "first", Content: "pods and services"},
+		{ID: "second", Content: "ingress rules"},
+	})
+	assert.Equal(t, "first", res[0].ID)
+	assert.True(t, res[0].Score > res[1].Score)
+}
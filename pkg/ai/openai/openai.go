@@ -56,6 +56,10 @@ func (s *Driver) Lang() string {
 	return ai.MODEL_BASE_LANGUAGE_EN
 }
 
+func (s *Driver) ChatModel() string {
+	return s.model.ChatModel
+}
+
 func (s *Driver) embedding(ctx context.Context, title string, content []string) (ai.EmbeddingResult, error) {
 	slog.Debug("Embedding", slog.String("driver", NAME))
 	queryReq := openai.EmbeddingRequest{
@@ -117,21 +121,6 @@ func (s *Driver) NewEnhance(ctx context.Context) *ai.EnhanceOptions {
 	return ai.NewEnhance(ctx, s)
 }
 
-func (s *Driver) MsgIsOverLimit(msgs []*types.MessageContext) bool {
-	tokenNum, err := ai.NumTokens(lo.Map(msgs, func(item *types.MessageContext, _ int) openai.ChatCompletionMessage {
-		return openai.ChatCompletionMessage{
-			Role:    item.Role.String(),
-			Content: item.Content,
-		}
-	}), s.model.ChatModel)
-	if err != nil {
-		slog.Error("Failed to tik request token", slog.String("error", err.Error()), slog.String("driver", NAME), slog.String("model", s.model.ChatModel))
-		return false
-	}
-
-	return tokenNum > 8000
-}
-
 type EnhanceQueryResult struct {
 	Querys []string `json:"querys"`
 }
@@ -212,7 +201,7 @@ func (s *Driver) EnhanceQuery(ctx context.Context, prompt, query string) (ai.Enh
 	return result, nil
 }
 
-func (s *Driver) QueryStream(ctx context.Context, query []*types.MessageContext) (*openai.ChatCompletionStream, error) {
+func (s *Driver) QueryStream(ctx context.Context, query []*types.MessageContext) (ai.Stream, error) {
 
 	req := openai.ChatCompletionRequest{
 		Model:  s.model.ChatModel,
@@ -235,7 +224,7 @@ func (s *Driver) QueryStream(ctx context.Context, query []*types.MessageContext)
 
 	slog.Debug("Query", slog.Any("query_stream", req), slog.String("driver", NAME), slog.String("model", s.model.ChatModel))
 
-	return resp, nil
+	return ai.NewOpenAIStream(resp), nil
 }
 
 func (s *Driver) Query(ctx context.Context, query []*types.MessageContext) (ai.GenerateResponse, error) {
@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"github.com/sashabaranov/go-openai"
+)
+
+// StreamEvent 流式响应中的一个事件，与驱动使用的协议无关
+// 一个事件可以同时包含增量内容、结束原因及用量，用量通常只在最后一个事件中返回
+type StreamEvent struct {
+	ID           string
+	Model        string
+	Content      string
+	FinishReason string // 与 openai 的 finish_reason 一致，正常结束为 stop
+	Usage        *openai.Usage
+}
+
+// Stream 驱动返回的流式响应，读取完毕后 Recv 返回 io.EOF
+type Stream interface {
+	Recv() (StreamEvent, error)
+	Close() error
+}
+
+const (
+	FINISH_REASON_STOP       = string(openai.FinishReasonStop)
+	FINISH_REASON_LENGTH     = string(openai.FinishReasonLength)
+	FINISH_REASON_TOOL_CALLS = string(openai.FinishReasonToolCalls)
+	FINISH_REASON_FILTER     = string(openai.FinishReasonContentFilter)
+)
+
+// openaiStream 兼容 openai 接口的驱动共用
+type openaiStream struct {
+	stream *openai.ChatCompletionStream
+}
+
+func NewOpenAIStream(stream *openai.ChatCompletionStream) Stream {
+	return &openaiStream{stream: stream}
+}
+
+func (s *openaiStream) Recv() (StreamEvent, error) {
+	msg, err := s.stream.Recv()
+	if err != nil {
+		return StreamEvent{}, err
+	}
+
+	event := StreamEvent{
+		ID:    msg.ID,
+		Model: msg.Model,
+		Usage: msg.Usage,
+	}
+	// 未设置 n 时只会返回一个 choice
+	if len(msg.Choices) > 0 {
+		event.Content = msg.Choices[0].Delta.Content
+		event.FinishReason = string(msg.Choices[0].FinishReason)
+	}
+	return event, nil
+}
+
+func (s *openaiStream) Close() error {
+	return s.stream.Close()
+}
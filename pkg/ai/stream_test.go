@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+)
+
+type sliceStream struct {
+	events []StreamEvent
+	closed bool
+}
+
+func (s *sliceStream) Recv() (StreamEvent, error) {
+	if len(s.events) == 0 {
+		return StreamEvent{}, io.EOF
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func (s *sliceStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func Test_HandleAIStream(t *testing.T) {
+	stream := &sliceStream{events: []StreamEvent{
+		{ID: "1", Content: "your car is at "},
+		{ID: "1", Content: "$hidden[a"},
+		{ID: "1", Content: "b] floor"},
+		// 结束事件中带有最后一段内容
+		{ID: "1", Content: ".", FinishReason: FINISH_REASON_STOP},
+		{Model: "test", Usage: &openai.Usage{TotalTokens: 3}},
+	}}
+
+	respChan, err := HandleAIStream(context.Background(), stream, map[string]string{"$hidden[ab]": "B2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		content string
+		finish  string
+		usage   *openai.Usage
+		eof     bool
+	)
+	for msg := range respChan {
+		content += msg.Message
+		if msg.FinishReason != "" {
+			// 结束前的内容都已输出
+			assert.Equal(t, "your car is at B2 floor.", content)
+			finish = msg.FinishReason
+		}
+		if msg.Usage != nil {
+			assert.Equal(t, FINISH_REASON_STOP, finish)
+			usage = msg.Usage
+		}
+		if msg.Error == io.EOF {
+			eof = true
+		}
+	}
+	assert.Equal(t, "your car is at B2 floor.", content)
+	assert.Equal(t, 3, usage.TotalTokens)
+	assert.True(t, eof)
+	assert.True(t, stream.closed)
+}
@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+
+	"github.com/breeew/brew-api/pkg/chunker"
+)
+
+// TokenCounter 计算文本的 token 数
+type TokenCounter func(text string) int
+
+var tokenCounters sync.Map
+
+// NewTokenCounter 返回指定模型的 token 计数器
+// tiktoken 不认识的模型(qwen、deepseek 等)按 cl100k_base 计算，编码表无法加载时(如离线部署)退化为 chunker.CountTokens 估算
+func NewTokenCounter(model string) TokenCounter {
+	if v, ok := tokenCounters.Load(model); ok {
+		return v.(TokenCounter)
+	}
+
+	tkm, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		tkm, err = tiktoken.GetEncoding(tiktoken.MODEL_CL100K_BASE)
+	}
+
+	var counter TokenCounter
+	if err != nil {
+		slog.Warn("Failed to load tiktoken encoding, estimate tokens locally", slog.String("model", model), slog.String("error", err.Error()))
+		counter = chunker.CountTokens
+	} else {
+		counter = func(text string) int {
+			return len(tkm.Encode(text, nil, nil))
+		}
+	}
+
+	v, _ := tokenCounters.LoadOrStore(model, counter)
+	return v.(TokenCounter)
+}
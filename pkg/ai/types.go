@@ -3,13 +3,12 @@ package ai
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/pkoukk/tiktoken-go"
 	"github.com/sashabaranov/go-openai"
 
 	"github.com/breeew/brew-api/pkg/mark"
@@ -25,7 +24,7 @@ type ModelName struct {
 
 type Query interface {
 	Query(ctx context.Context, query []*types.MessageContext) (GenerateResponse, error)
-	QueryStream(ctx context.Context, query []*types.MessageContext) (*openai.ChatCompletionStream, error)
+	QueryStream(ctx context.Context, query []*types.MessageContext) (Stream, error)
 	Lang
 }
 
@@ -183,7 +182,7 @@ const GENERATE_PROMPT_TPL_CN = GENERATE_PROMPT_TPL_NONE_CONTENT_CN + `
 --------------------------------------
 你需要结合“参考内容”来回答用户的提问，
 注意，“参考内容”中可能有部分内容描述的是同一件事情，但是发生的时间不同，当你无法选择应该参考哪一天的内容时，可以结合用户提出的问题进行分析。
-如果你从“参考内容”中找到了我想要的答案，可以告诉我你参考了哪些内容的ID，并尽可能地将参考内容中相关的图片、音视频也一同告诉我(URL等)。
+“参考内容”中的每段内容前都有一个形如 [@1] 的引用标记，如果你从“参考内容”中找到了我想要的答案，请在用到该段内容的句子末尾原样写出它的引用标记，例如“……需要先安装 Helm[@1]。”，不要编造不存在的标记，也不要把引用标记改写成其他格式，并尽可能地将参考内容中相关的图片、音视频也一同告诉我(URL等)。
 以下是参考内容中可能出现的一些系统语法，你可以忽略这些标识，把它当成一个字符串整体：
 {symbol}
 Markdown中有些内容是通过HTML标签表示的，请不要额外处理这些HTML标签，例如<video>等，它们都是系统语法，请不要语义化这些内容。
@@ -199,7 +198,7 @@ Below are some "reference materials" that include historical records. Please do
 {relevant_passage}
 Please use the "reference materials" to answer my questions.
 Note that some parts of the "reference materials" may describe the same event but with different timestamps. When you're unsure which date to use, analyze the context of my question to choose accordingly.
-If you find the answer within the "reference materials," let me know which content IDs you used as references. Please also provide me with any associated images, audio, and video from the related content, including URLs if possible.
+Every passage in the "reference materials" starts with a citation marker such as [@1]. If you find the answer within the "reference materials," write the marker of each passage you used, exactly as given, at the end of the sentence that uses it, for example "...install Helm first[@1]." Do not invent markers or rewrite them in another format. Please also provide me with any associated images, audio, and video from the related content, including URLs if possible.
 Please respond in Markdown format using the same language as my question.
 Below are some system syntax symbols that may appear in the reference content. You can ignore these, treating them as strings without semantic interpretation: 
 {symbol}
@@ -312,7 +311,7 @@ func (s *QueryOptions) Query() (GenerateResponse, error) {
 	return s._driver.Query(s.ctx, s.query)
 }
 
-func (s *QueryOptions) QueryStream() (*openai.ChatCompletionStream, error) {
+func (s *QueryOptions) QueryStream() (Stream, error) {
 	if s.prompt == "" {
 		switch s._driver.Lang() {
 		case MODEL_BASE_LANGUAGE_CN:
@@ -348,7 +347,7 @@ func (s *QueryOptions) QueryStream() (*openai.ChatCompletionStream, error) {
 	return s._driver.QueryStream(s.ctx, s.query)
 }
 
-func HandleAIStream(ctx context.Context, resp *openai.ChatCompletionStream, marks map[string]string) (chan ResponseChoice, error) {
+func HandleAIStream(ctx context.Context, resp Stream, marks map[string]string) (chan ResponseChoice, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	respChan := make(chan ResponseChoice, 10)
 	ticker := time.NewTicker(time.Millisecond * 500)
@@ -407,7 +406,7 @@ func HandleAIStream(ctx context.Context, resp *openai.ChatCompletionStream, mark
 			default:
 			}
 
-			msg, err := resp.Recv()
+			event, err := resp.Recv()
 			if err != nil && err != io.EOF {
 				respChan <- ResponseChoice{
 					Error: err,
@@ -415,7 +414,7 @@ func HandleAIStream(ctx context.Context, resp *openai.ChatCompletionStream, mark
 				return
 			}
 
-			// slog.Debug("ai stream response", slog.Any("msg", msg))
+			// slog.Debug("ai stream response", slog.Any("event", event))
 			if err == io.EOF {
 				flushResponse()
 				respChan <- ResponseChoice{
@@ -424,31 +423,10 @@ func HandleAIStream(ctx context.Context, resp *openai.ChatCompletionStream, mark
 				return
 			}
 
-			// slog.Debug("message usage", slog.Any("msg", msg))
-			if msg.Usage != nil {
-				respChan <- ResponseChoice{
-					Usage: msg.Usage,
-					Model: msg.Model,
-				}
-			}
-
-			for _, v := range msg.Choices {
-				if v.FinishReason != "" {
-					if strs.Len() > 0 {
-						flushResponse()
-					}
-					respChan <- ResponseChoice{
-						Message:      v.Delta.Content,
-						FinishReason: string(v.FinishReason),
-					}
-				}
-
-				if v.Delta.Content == "" {
-					break
-				}
+			if event.Content != "" {
 				if needToMarks {
 					if !maybeMarks {
-						if strings.Contains(v.Delta.Content, "$") {
+						if strings.Contains(event.Content, "$") {
 							maybeMarks = true
 							if strs.Len() != 0 {
 								flushResponse()
@@ -459,8 +437,8 @@ func HandleAIStream(ctx context.Context, resp *openai.ChatCompletionStream, mark
 					}
 				}
 
-				strs.WriteString(v.Delta.Content)
-				if machedMarks && strings.Contains(v.Delta.Content, "]") {
+				strs.WriteString(event.Content)
+				if machedMarks && strings.Contains(event.Content, "]") {
 					text, replaced := mark.ResolveHidden(strs.String(), func(fakeValue string) string {
 						real := marks[fakeValue]
 						delete(marks, fakeValue)
@@ -475,10 +453,27 @@ func HandleAIStream(ctx context.Context, resp *openai.ChatCompletionStream, mark
 					}
 				}
 				once.Do(func() {
-					messageID = msg.ID
+					messageID = event.ID
 					// flushResponse() // 快速响应出去
 				})
 			}
+
+			// 结束事件中可能带有最后一段内容，先输出内容再通知结束
+			if event.FinishReason != "" {
+				flushResponse()
+				respChan <- ResponseChoice{
+					ID:           messageID,
+					FinishReason: event.FinishReason,
+				}
+			}
+
+			// slog.Debug("message usage", slog.Any("event", event))
+			if event.Usage != nil {
+				respChan <- ResponseChoice{
+					Usage: event.Usage,
+					Model: event.Model,
+				}
+			}
 		}
 	})
 	return respChan, nil
@@ -693,6 +688,23 @@ type RankDocItem struct {
 	Score float64
 }
 
+// FilterRankDocs 按得分降序排列，保留得分不低于 minScore 的前 topK 个结果，topK <= 0 时不限制数量
+func FilterRankDocs(items []RankDocItem, topK int, minScore float64) []RankDocItem {
+	res := make([]RankDocItem, 0, len(items))
+	for _, v := range items {
+		if v.Score >= minScore {
+			res = append(res, v)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	if topK > 0 && len(res) > topK {
+		res = res[:topK]
+	}
+	return res
+}
+
 // TODO i18n
 func GenerateTimeListAtNowCN() string {
 	now := time.Now()
@@ -880,44 +892,3 @@ type ResponseChoice struct {
 type ReaderResult struct {
 	Content string `json:"content"`
 }
-
-func NumTokens(messages []openai.ChatCompletionMessage, model string) (numTokens int, err error) {
-	var tokensPerMessage, tokensPerName int
-	switch model {
-	case "gpt-3.5-turbo-0613",
-		"gpt-3.5-turbo-16k-0613",
-		"gpt-4-0314",
-		"gpt-4-32k-0314",
-		"gpt-4-0613",
-		"gpt-4-32k-0613":
-		tokensPerMessage = 3
-		tokensPerName = 1
-	case "gpt-3.5-turbo-0301":
-		tokensPerMessage = 4 // every message follows <|start|>{role/name}\n{content}<|end|>\n
-		tokensPerName = -1   // if there's a name, the role is omitted
-	default:
-		if strings.Contains(model, "gpt-4") {
-			return NumTokens(messages, "gpt-4-0613")
-		} else {
-			return NumTokens(messages, "gpt-3.5-turbo-0613")
-		}
-	}
-
-	tkm, err := tiktoken.EncodingForModel(model)
-	if err != nil {
-		err = fmt.Errorf("encoding for model: %v", err)
-		return
-	}
-
-	for _, message := range messages {
-		numTokens += tokensPerMessage
-		numTokens += len(tkm.Encode(message.Content, nil, nil))
-		numTokens += len(tkm.Encode(message.Role, nil, nil))
-		numTokens += len(tkm.Encode(message.Name, nil, nil))
-		if message.Name != "" {
-			numTokens += tokensPerName
-		}
-	}
-	numTokens += 3 // every reply is primed with <|start|>assistant<|message|>
-	return numTokens, nil
-}
@@ -1,9 +1,88 @@
 package ai
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
 
 func Test_TimeTpl(t *testing.T) {
 	tpl := GenerateTimeListAtNowCN()
 
 	t.Log(tpl)
 }
+
+func Test_FilterRankDocs(t *testing.T) {
+	items := []RankDocItem{{ID: "a", Score: 0.2}, {ID: "b", Score: 0.9}, {ID: "c", Score: 0.5}, {ID: "d", Score: 0.05}}
+
+	res := FilterRankDocs(items, 2, 0.1)
+	if len(res) != 2 || res[0].ID != "b" || res[1].ID != "c" {
+		t.Fatal("unexpected filter result", res)
+	}
+
+	if res = FilterRankDocs(items, 0, 0); len(res) != 4 || res[3].ID != "d" {
+		t.Fatal("unexpected filter result", res)
+	}
+}
+
+func Test_ParseCitationMarks(t *testing.T) {
+	text := "部署使用 Helm[@1]，配置见 [@12][@x] 和 [^1]"
+	marks := ParseCitationMarks(text)
+	if len(marks) != 2 {
+		t.Fatal("unexpected citation marks", marks)
+	}
+	if marks[0] != (CitationMark{Marker: 1, Start: 9, End: 13}) || marks[1] != (CitationMark{Marker: 12, Start: 18, End: 23}) {
+		t.Fatal("unexpected citation marks", marks)
+	}
+	if string([]rune(text)[marks[1].Start:marks[1].End]) != CitationMarker(12) {
+		t.Fatal("unexpected citation span", marks[1])
+	}
+}
+
+func Test_ResolveCitations(t *testing.T) {
+	sources := []types.CitationSource{{Marker: 1, KnowledgeID: "k1", ChunkID: "c1"}, {Marker: 2, KnowledgeID: "k2"}}
+	res := ResolveCitations("a[@2] b[@3] c[@1]", sources)
+	if len(res) != 2 {
+		t.Fatal("unexpected citations", res)
+	}
+	if res[0] != (types.Citation{Marker: 2, KnowledgeID: "k2", Start: 1, End: 5}) ||
+		res[1] != (types.Citation{Marker: 1, KnowledgeID: "k1", ChunkID: "c1", Start: 13, End: 17}) {
+		t.Fatal("unexpected citations", res)
+	}
+	if ResolveCitations("a[@1]", nil) != nil {
+		t.Fatal("citations without sources")
+	}
+}
+
+func Test_ContextWindow(t *testing.T) {
+	cases := map[string]int{
+		"gpt-4o-mini":   128000,
+		"gpt-4-0613":    8192,
+		"gpt-4-turbo":   128000,
+		"Qwen-Plus":     131072,
+		"deepseek-chat": 65536,
+		"llama3.1:8b":   131072,
+		"llama3:8b":     8192,
+		"unknown-model": DEFAULT_CONTEXT_WINDOW,
+		"":              DEFAULT_CONTEXT_WINDOW,
+	}
+	for model, want := range cases {
+		if got := ContextWindow(model); got != want {
+			t.Fatalf("ContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func Test_NumMessageTokens(t *testing.T) {
+	count := func(text string) int {
+		return len(text)
+	}
+	msgs := []*types.MessageContext{
+		{Role: types.USER_ROLE_SYSTEM, Content: "prompt"},
+		{Role: types.USER_ROLE_USER, Content: "hi"},
+	}
+	want := 3 + (3 + len(types.USER_ROLE_SYSTEM.String()) + 6) + (3 + len(types.USER_ROLE_USER.String()) + 2)
+	if got := NumMessageTokens(msgs, count); got != want {
+		t.Fatalf("NumMessageTokens = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"strings"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// DEFAULT_CONTEXT_WINDOW 未知模型的上下文窗口，取常见模型中较小的值，宁可提前总结也不要请求失败
+const DEFAULT_CONTEXT_WINDOW = 8192
+
+// contextWindows 常见对话模型的上下文窗口(token)，按模型名前缀匹配，更具体的前缀需放在前面
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"gpt-4o", 128000},
+	{"gpt-4.1", 1047576},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo-instruct", 4096},
+	{"gpt-3.5-turbo", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4-mini", 200000},
+	{"qwen-long", 1000000},
+	{"qwen-turbo", 1000000},
+	{"qwen-plus", 131072},
+	{"qwen-max", 32768},
+	{"qwen2.5", 131072},
+	{"qwen3", 131072},
+	{"deepseek-chat", 65536},
+	{"deepseek-reasoner", 65536},
+	{"gemini-1.5", 1048576},
+	{"gemini-2", 1048576},
+	{"claude", 200000},
+	{"llama3.1", 131072},
+	{"llama3.2", 131072},
+	{"llama3.3", 131072},
+	{"llama3", 8192},
+}
+
+// ContextWindow 返回对话模型的上下文窗口大小，未知模型返回 DEFAULT_CONTEXT_WINDOW
+func ContextWindow(model string) int {
+	model = strings.ToLower(model)
+	for _, v := range contextWindows {
+		if strings.HasPrefix(model, v.prefix) {
+			return v.tokens
+		}
+	}
+	return DEFAULT_CONTEXT_WINDOW
+}
+
+// MessageTokenizer 驱动可选实现，按模型自己的分词方式计算对话消息的 token 数
+type MessageTokenizer interface {
+	CountMessageTokens(msgs []*types.MessageContext) int
+}
+
+// NumMessageTokens 按 openai 的消息格式估算对话消息的 token 数
+// 每条消息格式为 <|start|>{role}\n{content}<|end|>\n，回复前还有 <|start|>assistant<|message|>
+func NumMessageTokens(msgs []*types.MessageContext, count TokenCounter) int {
+	const (
+		tokensPerMessage = 3
+		tokensPerReply   = 3
+	)
+	num := tokensPerReply
+	for _, v := range msgs {
+		num += tokensPerMessage + count(v.Role.String()) + count(v.Content)
+	}
+	return num
+}
@@ -0,0 +1,243 @@
+// Package archive 空间导入导出使用的归档格式
+//
+// 归档为 zip 文件，包含：
+//   - manifest.json 归档的格式版本、空间信息及各表的记录数
+//   - {table}.jsonl 每行一条记录，加密字段以明文保存
+//   - blobs/{name} 知识点引用的文件
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+const (
+	FORMAT = "brew.space"
+	// VERSION 归档格式版本，新增字段保持兼容，不兼容的修改需要升级版本
+	VERSION = 1
+
+	MANIFEST_FILE = "manifest.json"
+	BLOB_DIR      = "blobs"
+
+	// MAX_ARCHIVE_SIZE 上传归档的最大长度
+	MAX_ARCHIVE_SIZE = 1 << 30
+	// MAX_TOTAL_SIZE 归档解压后的最大总长度
+	MAX_TOTAL_SIZE = 4 << 30
+	// MAX_TABLE_SIZE 单张表解压后的最大长度
+	MAX_TABLE_SIZE = 1 << 30
+	// MAX_RECORD_SIZE 表中单条记录的最大长度
+	MAX_RECORD_SIZE = 64 << 20
+	// MAX_FILE_SIZE manifest 及单个文件的最大长度
+	MAX_FILE_SIZE = 64 << 20
+)
+
+var (
+	ErrInvalidArchive     = errors.New("invalid space archive")
+	ErrUnsupportedVersion = errors.New("unsupported space archive version")
+	ErrTooLarge           = errors.New("space archive too large")
+)
+
+type Manifest struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	ExportedAt int64          `json:"exported_at"`
+	Space      Space          `json:"space"`
+	Counts     map[string]int `json:"counts"` // 各表的记录数
+}
+
+type Writer struct {
+	zw       *zip.Writer
+	manifest Manifest
+}
+
+func NewWriter(w io.Writer, space Space) *Writer {
+	return &Writer{
+		zw: zip.NewWriter(w),
+		manifest: Manifest{
+			Format:     FORMAT,
+			Version:    VERSION,
+			ExportedAt: time.Now().Unix(),
+			Space:      space,
+			Counts:     make(map[string]int),
+		},
+	}
+}
+
+// WriteTable 写入一张表，fn 中通过 write 逐条写入记录
+func (w *Writer) WriteTable(table string, fn func(write func(v any) error) error) error {
+	f, err := w.zw.Create(table + ".jsonl")
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	err = fn(func(v any) error {
+		w.manifest.Counts[table]++
+		return enc.Encode(v)
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (w *Writer) WriteBlob(name string, data []byte) error {
+	f, err := w.zw.Create(path.Join(BLOB_DIR, name))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// Close 写入 manifest 并结束归档
+func (w *Writer) Close() error {
+	f, err := w.zw.Create(MANIFEST_FILE)
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(f).Encode(w.manifest); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+type Reader struct {
+	zr       *zip.Reader
+	files    map[string]*zip.File
+	manifest Manifest
+}
+
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+
+	res := &Reader{
+		zr:    zr,
+		files: make(map[string]*zip.File, len(zr.File)),
+	}
+	// 读取时限制每个文件不超过其声明的长度，声明的总长度即为解压后的总长度
+	var total uint64
+	for _, f := range zr.File {
+		total += f.UncompressedSize64
+		if total > MAX_TOTAL_SIZE {
+			return nil, ErrTooLarge
+		}
+		res.files[f.Name] = f
+	}
+
+	raw, err := res.read(MANIFEST_FILE)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	if err = json.Unmarshal(raw, &res.manifest); err != nil || res.manifest.Format != FORMAT {
+		return nil, ErrInvalidArchive
+	}
+	if res.manifest.Version > VERSION {
+		return nil, ErrUnsupportedVersion
+	}
+	return res, nil
+}
+
+func (r *Reader) Manifest() Manifest {
+	return r.manifest
+}
+
+// limitReader 读取超过 n 字节时返回 ErrTooLarge，而不是像 io.LimitReader 一样截断
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// 恰好读完时需要确认后续没有数据
+		var b [1]byte
+		if n, _ := l.r.Read(b[:]); n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// open 打开归档中的文件，读取长度不超过 limit 及文件声明的长度
+func open(f *zip.File, limit int64) (io.Reader, io.Closer, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrTooLarge, f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &limitReader{r: rc, n: int64(f.UncompressedSize64)}, rc, nil
+}
+
+func (r *Reader) read(name string) ([]byte, error) {
+	f, ok := r.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	rd, closer, err := open(f, MAX_FILE_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return io.ReadAll(rd)
+}
+
+func (r *Reader) Blob(name string) ([]byte, error) {
+	return r.read(path.Join(BLOB_DIR, name))
+}
+
+// Each 逐条读取表中的记录，归档中不存在该表时视为空表
+func Each[T any](r *Reader, table string, fn func(item T) error) error {
+	f, ok := r.files[table+".jsonl"]
+	if !ok {
+		return nil
+	}
+	rd, closer, err := open(f, MAX_TABLE_SIZE)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	// 每行一条记录，限制单条记录的长度
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64<<10), MAX_RECORD_SIZE)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var item T
+		if err = json.Unmarshal(line, &item); err != nil {
+			return fmt.Errorf("%w: table %s: %w", ErrInvalidArchive, table, err)
+		}
+		if err = fn(item); err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = ErrTooLarge
+		}
+		return fmt.Errorf("%w: table %s: %w", ErrInvalidArchive, table, err)
+	}
+	return nil
+}
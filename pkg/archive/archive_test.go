@@ -0,0 +1,139 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, Space{ID: "space1", Title: "Brew", Settings: types.SpaceSettings{
+		Chunk: types.ChunkSettings{Strategy: "markdown", ChunkSize: 512},
+	}})
+
+	knowledges := []Knowledge{
+		{ID: "k1", Kind: types.KNOWLEDGE_KIND_TEXT, Title: "A", Content: `{"blocks":[{"type":"paragraph","data":{"text":"<b>a</b> & b"}}]}`, ContentType: types.KNOWLEDGE_CONTENT_TYPE_BLOCKS},
+		{ID: "k2", Kind: types.KNOWLEDGE_KIND_FILE, Title: "B", Content: "# B\n\nhello", ContentType: types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN, Source: "/brew/space1/file/b.pdf"},
+	}
+	err := w.WriteTable(TABLE_KNOWLEDGE, func(write func(v any) error) error {
+		for _, v := range knowledges {
+			if err := write(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, w.WriteTable(TABLE_VECTOR, func(write func(v any) error) error {
+		return write(Vector{ID: "c1", KnowledgeID: "k1", Embedding: []float32{0.1, 0.2}, Model: "m"})
+	}))
+	assert.NoError(t, w.WriteBlob("brew/space1/file/b.pdf", []byte("%PDF")))
+	assert.NoError(t, w.Close())
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	manifest := r.Manifest()
+	assert.Equal(t, VERSION, manifest.Version)
+	assert.Equal(t, "Brew", manifest.Space.Title)
+	assert.Equal(t, "markdown", manifest.Space.Settings.Chunk.Strategy)
+	assert.Equal(t, map[string]int{TABLE_KNOWLEDGE: 2, TABLE_VECTOR: 1}, manifest.Counts)
+
+	var got []Knowledge
+	assert.NoError(t, Each(r, TABLE_KNOWLEDGE, func(item Knowledge) error {
+		got = append(got, item)
+		return nil
+	}))
+	assert.Equal(t, knowledges, got)
+
+	var vectors []Vector
+	assert.NoError(t, Each(r, TABLE_VECTOR, func(item Vector) error {
+		vectors = append(vectors, item)
+		return nil
+	}))
+	assert.Equal(t, []float32{0.1, 0.2}, vectors[0].Embedding)
+
+	// 不存在的表视为空表
+	assert.NoError(t, Each(r, TABLE_JOURNAL, func(item Journal) error {
+		t.Fatal("unexpected journal")
+		return nil
+	}))
+
+	blob, err := r.Blob("brew/space1/file/b.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("%PDF"), blob)
+
+	_, err = r.Blob("missing")
+	assert.Error(t, err)
+}
+
+func Test_NewReader_Invalid(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("not a zip")), 9)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	build := func(manifest any) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		if manifest != nil {
+			f, _ := zw.Create(MANIFEST_FILE)
+			json.NewEncoder(f).Encode(manifest)
+		}
+		zw.Close()
+		return buf
+	}
+
+	buf := build(nil)
+	_, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	buf = build(Manifest{Format: "other", Version: VERSION})
+	_, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	buf = build(Manifest{Format: FORMAT, Version: VERSION + 1})
+	_, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func Test_Reader_Limits(t *testing.T) {
+	manifest, _ := json.Marshal(Manifest{Format: FORMAT, Version: VERSION})
+
+	// 声明的解压长度超出限制
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	f, _ := zw.Create(MANIFEST_FILE)
+	f.Write(manifest)
+	zw.CreateRaw(&zip.FileHeader{Name: "bomb.jsonl", Method: zip.Deflate, UncompressedSize64: MAX_TOTAL_SIZE})
+	zw.Close()
+	_, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	// 单条记录超出限制
+	buf = &bytes.Buffer{}
+	zw = zip.NewWriter(buf)
+	f, _ = zw.Create(MANIFEST_FILE)
+	f.Write(manifest)
+	f, _ = zw.Create("journals.jsonl")
+	f.Write([]byte(`{"date":"2024-01-01"}` + "\n" + `{"content":"`))
+	f.Write(bytes.Repeat([]byte("a"), MAX_RECORD_SIZE))
+	f.Write([]byte(`"}` + "\n"))
+	zw.Close()
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	err = Each(r, "journals", func(item Journal) error {
+		count++
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrTooLarge)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// 归档中各表的名称
+const (
+	TABLE_KNOWLEDGE    = "knowledge"
+	TABLE_CHUNK        = "knowledge_chunk"
+	TABLE_VECTOR       = "vector"
+	TABLE_LINK         = "knowledge_link"
+	TABLE_RESOURCE     = "resource"
+	TABLE_JOURNAL      = "journal"
+	TABLE_CHAT_SESSION = "chat_session"
+	TABLE_CHAT_MESSAGE = "chat_message"
+	TABLE_FILE         = "file"
+)
+
+// 记录中的 id 均为导出空间中的原始 id，导入时重新生成并通过这些 id 关联记录
+// 加密字段(知识点内容、切片、日记、聊天消息)均以明文保存
+
+type Space struct {
+	ID          string              `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Settings    types.SpaceSettings `json:"settings"`
+}
+
+type Knowledge struct {
+	ID          string                     `json:"id"`
+	Kind        types.KnowledgeKind        `json:"kind"`
+	Resource    string                     `json:"resource"`
+	Title       string                     `json:"title"`
+	Tags        []string                   `json:"tags"`
+	Content     string                     `json:"content"`
+	ContentType types.KnowledgeContentType `json:"content_type"`
+	Source      string                     `json:"source"`
+	Summary     string                     `json:"summary"`
+	MaybeDate   string                     `json:"maybe_date"`
+	Stage       types.KnowledgeStage       `json:"stage"`
+	CreatedAt   int64                      `json:"created_at"`
+	UpdatedAt   int64                      `json:"updated_at"`
+}
+
+type Chunk struct {
+	ID             string `json:"id"`
+	KnowledgeID    string `json:"knowledge_id"`
+	Chunk          string `json:"chunk"`
+	Seq            int    `json:"seq"`
+	OriginalLength int    `json:"original_length"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+// Link 知识点之间的链接，知识点内容中的 knowledge:// 链接同样使用原始 id
+type Link struct {
+	FromID    string `json:"from_id"`
+	ToID      string `json:"to_id"`
+	Text      string `json:"text"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Vector 切片的向量，ID 与切片 ID 一致
+type Vector struct {
+	ID             string    `json:"id"`
+	KnowledgeID    string    `json:"knowledge_id"`
+	Embedding      []float32 `json:"embedding"`
+	Model          string    `json:"model"`
+	OriginalLength int       `json:"original_length"`
+	CreatedAt      int64     `json:"created_at"`
+	UpdatedAt      int64     `json:"updated_at"`
+}
+
+type Resource struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Cycle       int    `json:"cycle"`
+	Tag         string `json:"tag"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type Journal struct {
+	UserID    string `json:"user_id"`
+	Date      string `json:"date"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type ChatSession struct {
+	ID               string                  `json:"id"`
+	UserID           string                  `json:"user_id"`
+	Title            string                  `json:"title"`
+	Type             types.ChatSessionType   `json:"session_type"`
+	Status           types.ChatSessionStatus `json:"status"`
+	CreatedAt        int64                   `json:"created_at"`
+	LatestAccessTime int64                   `json:"latest_access_time"`
+}
+
+type ChatMessage struct {
+	ID        string                `json:"id"`
+	SessionID string                `json:"session_id"`
+	Role      types.MessageUserRole `json:"role"`
+	Message   string                `json:"message"`
+	MsgType   types.MessageType     `json:"msg_type"`
+	SendTime  int64                 `json:"send_time"`
+	Complete  types.MessageProgress `json:"complete"`
+	Sequence  int64                 `json:"sequence"`
+	MsgBlock  int64                 `json:"msg_block"`
+	RelDocs   []string              `json:"rel_docs,omitempty"`  // 消息引用的知识点 id
+	Citations types.Citations       `json:"citations,omitempty"` // 回答中的引用
+}
+
+// File 知识点引用的文件，文件内容保存在 blobs/{Blob}
+type File struct {
+	Path       string `json:"path"`
+	FileSize   int64  `json:"file_size"`
+	ObjectType string `json:"object_type"`
+	Kind       string `json:"kind"`
+	Blob       string `json:"blob"`
+}
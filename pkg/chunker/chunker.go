@@ -0,0 +1,243 @@
+package chunker
+
+// 本地切片，不依赖 LLM，同样的输入总是得到同样的切片结果
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	STRATEGY_LLM      = "llm"      // 由 LLM 切片并生成标题、标签
+	STRATEGY_MARKDOWN = "markdown" // 按照 markdown 标题分节后再按句子切片，每个切片带有所属的标题路径
+	STRATEGY_SENTENCE = "sentence" // 按句子边界切片
+	STRATEGY_TOKEN    = "token"    // 按固定 token 窗口切片
+
+	DEFAULT_CHUNK_SIZE = 512
+	DEFAULT_OVERLAP    = 64
+)
+
+// IsValidStrategy 空字符串表示使用默认策略(llm)
+func IsValidStrategy(strategy string) bool {
+	switch strategy {
+	case "", STRATEGY_LLM, STRATEGY_MARKDOWN, STRATEGY_SENTENCE, STRATEGY_TOKEN:
+		return true
+	}
+	return false
+}
+
+// IsLocal 是否为本地切片策略
+func IsLocal(strategy string) bool {
+	return strategy != "" && strategy != STRATEGY_LLM && IsValidStrategy(strategy)
+}
+
+type Options struct {
+	ChunkSize int // 单个切片的最大 token 数
+	Overlap   int // 相邻切片重叠的 token 数
+}
+
+func (o Options) normalize() Options {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = DEFAULT_CHUNK_SIZE
+	}
+	if o.Overlap < 0 {
+		o.Overlap = 0
+	}
+	if o.Overlap >= o.ChunkSize {
+		o.Overlap = o.ChunkSize / 4
+	}
+	return o
+}
+
+// Split 使用指定的本地策略切片，未知策略按 markdown 处理
+func Split(strategy, text string, opts Options) []string {
+	switch strategy {
+	case STRATEGY_TOKEN:
+		return SplitTokens(text, opts)
+	case STRATEGY_SENTENCE:
+		return SplitSentences(text, opts)
+	default:
+		return SplitMarkdown(text, opts)
+	}
+}
+
+type span struct {
+	start, end int
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') && !isCJK(r)
+}
+
+// tokenSpans 估算 token 并返回每个 token 在原文中的位置
+// 连续的字母数字记为一个 token，中日韩文字与标点每个字符记为一个 token，空白不计
+func tokenSpans(text string) []span {
+	var (
+		res  []span
+		word = -1
+	)
+	for i, r := range text {
+		if isWordRune(r) {
+			if word < 0 {
+				word = i
+			}
+			continue
+		}
+		if word >= 0 {
+			res = append(res, span{word, i})
+			word = -1
+		}
+		if !unicode.IsSpace(r) {
+			res = append(res, span{i, i + len(string(r))})
+		}
+	}
+	if word >= 0 {
+		res = append(res, span{word, len(text)})
+	}
+	return res
+}
+
+// CountTokens 估算文本的 token 数
+func CountTokens(text string) int {
+	return len(tokenSpans(text))
+}
+
+// SplitTokens 按固定 token 窗口切片，相邻窗口重叠 Overlap 个 token
+func SplitTokens(text string, opts Options) []string {
+	opts = opts.normalize()
+	return splitTokenSpans(text, tokenSpans(text), opts)
+}
+
+func splitTokenSpans(text string, spans []span, opts Options) []string {
+	var res []string
+	step := opts.ChunkSize - opts.Overlap
+	for i := 0; i < len(spans); i += step {
+		end := min(i+opts.ChunkSize, len(spans))
+		if chunk := strings.TrimSpace(text[spans[i].start:spans[end-1].end]); chunk != "" {
+			res = append(res, chunk)
+		}
+		if end == len(spans) {
+			break
+		}
+	}
+	return res
+}
+
+type sentence struct {
+	span
+	tokens int
+}
+
+// sentenceTerminators 句子结束符，英文句号需要后跟空白才视为句子结束，避免切断小数、域名等
+const sentenceTerminators = "。！？；!?;…"
+
+// sentences 按句子边界及换行切分，返回每个句子在原文中的位置
+// markdown 代码块整体作为一个句子，避免被切断
+func sentences(text string) []sentence {
+	var (
+		res    []sentence
+		offset int
+		code   = -1 // 代码块起始位置
+	)
+	add := func(start, end int) {
+		if s := strings.TrimSpace(text[start:end]); s != "" {
+			res = append(res, sentence{span: span{start, end}, tokens: CountTokens(s)})
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		start := offset
+		offset += len(line)
+
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			if code < 0 {
+				code = start
+				continue
+			}
+			add(code, offset)
+			code = -1
+			continue
+		}
+		if code >= 0 {
+			continue
+		}
+
+		runes := []rune(line)
+		pos := start
+		for i, r := range runes {
+			size := len(string(r))
+			pos += size
+			if strings.ContainsRune(sentenceTerminators, r) ||
+				(r == '.' && (i+1 == len(runes) || unicode.IsSpace(runes[i+1]))) {
+				add(start, pos)
+				start = pos
+			}
+		}
+		add(start, offset)
+	}
+	if code >= 0 {
+		add(code, len(text))
+	}
+	return res
+}
+
+// SplitSentences 在不超过 ChunkSize 的前提下尽量多的合并完整的句子，相邻切片重叠不超过 Overlap 个 token 的完整句子
+// 超过 ChunkSize 的长句按 token 窗口切分
+func SplitSentences(text string, opts Options) []string {
+	opts = opts.normalize()
+	return splitSentences(text, sentences(text), opts)
+}
+
+func splitSentences(text string, list []sentence, opts Options) []string {
+	var (
+		res     []string
+		current []sentence
+		tokens  int
+		carried int // current 中来自上一个切片的重叠句子数
+	)
+
+	flush := func() {
+		if len(current) <= carried {
+			current, tokens, carried = current[:0], 0, 0
+			return
+		}
+		res = append(res, strings.TrimSpace(text[current[0].start:current[len(current)-1].end]))
+
+		// 保留末尾的句子作为下一个切片的开头
+		var keep, overlap int
+		for i := len(current) - 1; i > 0; i-- {
+			if overlap+current[i].tokens > opts.Overlap {
+				break
+			}
+			overlap += current[i].tokens
+			keep++
+		}
+		current = append(current[:0], current[len(current)-keep:]...)
+		tokens, carried = overlap, keep
+	}
+
+	for _, s := range list {
+		if s.tokens > opts.ChunkSize {
+			flush()
+			current, tokens, carried = current[:0], 0, 0
+			sub := text[s.start:s.end]
+			res = append(res, splitTokenSpans(sub, tokenSpans(sub), opts)...)
+			continue
+		}
+		if tokens+s.tokens > opts.ChunkSize {
+			flush()
+			// 重叠部分加上当前句子依然超长时放弃重叠
+			if tokens+s.tokens > opts.ChunkSize {
+				current, tokens, carried = current[:0], 0, 0
+			}
+		}
+		current = append(current, s)
+		tokens += s.tokens
+	}
+	flush()
+	return res
+}
@@ -0,0 +1,85 @@
+package chunker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CountTokens(t *testing.T) {
+	assert.Equal(t, 0, CountTokens("  \n "))
+	assert.Equal(t, 3, CountTokens("hello world!"))
+	assert.Equal(t, 4, CountTokens("向量检索"))
+	assert.Equal(t, 3, CountTokens("v1.2"))
+}
+
+func Test_SplitTokens(t *testing.T) {
+	text := "a b c d e f g h i j"
+	res := SplitTokens(text, Options{ChunkSize: 4, Overlap: 1})
+	assert.Equal(t, []string{"a b c d", "d e f g", "g h i j"}, res)
+
+	assert.Equal(t, []string{"a b c d e f g h i j"}, SplitTokens(text, Options{}))
+	assert.Empty(t, SplitTokens("", Options{ChunkSize: 4}))
+}
+
+func Test_SplitSentences(t *testing.T) {
+	text := "First one. Second one here. Third! 第四句。Fifth v1.2 is out."
+	res := SplitSentences(text, Options{ChunkSize: 7, Overlap: 0})
+	assert.Equal(t, []string{"First one. Second one here.", "Third! 第四句。", "Fifth v1.2 is out."}, res)
+
+	// overlap 按完整的句子保留
+	res = SplitSentences("a a. b b. c c. d d.", Options{ChunkSize: 6, Overlap: 3})
+	assert.Equal(t, []string{"a a. b b.", "b b. c c.", "c c. d d."}, res)
+
+	// 超长的句子按 token 窗口切分
+	res = SplitSentences("short. "+strings.Repeat("x ", 10), Options{ChunkSize: 4})
+	assert.Equal(t, []string{"short.", "x x x x", "x x x x", "x x"}, res)
+
+	// 同样的输入总是得到同样的结果
+	assert.Equal(t, SplitSentences(text, Options{ChunkSize: 5, Overlap: 2}), SplitSentences(text, Options{ChunkSize: 5, Overlap: 2}))
+}
+
+func Test_SplitMarkdown(t *testing.T) {
+	doc := `intro text.
+
+# Guide
+
+## Install
+
+Run the installer. Then restart.
+
+` + "```sh\n# not a heading\nmake\n```" + `
+
+## Usage
+
+Use it.
+`
+	res := SplitMarkdown(doc, Options{ChunkSize: 100})
+	assert.Equal(t, []string{
+		"intro text.",
+		"# Guide\n## Install\n\nRun the installer. Then restart.\n\n```sh\n# not a heading\nmake\n```",
+		"# Guide\n## Usage\n\nUse it.",
+	}, res)
+
+	res = SplitMarkdown(doc, Options{ChunkSize: 20})
+	for _, v := range res[1:] {
+		assert.True(t, strings.HasPrefix(v, "# Guide\n## "), v)
+	}
+	// 代码块不会被切断
+	assert.Contains(t, res, "# Guide\n## Install\n\nRun the installer. Then restart.")
+	assert.Contains(t, res, "# Guide\n## Install\n\n```sh\n# not a heading\nmake\n```")
+
+	assert.Equal(t, "Guide", Title("\n# Guide\ncontent"))
+	assert.Equal(t, "intro text.", Title(doc))
+	assert.Equal(t, "", Title("  "))
+}
+
+func Test_Split(t *testing.T) {
+	assert.True(t, IsLocal(STRATEGY_MARKDOWN))
+	assert.False(t, IsLocal(""))
+	assert.False(t, IsLocal(STRATEGY_LLM))
+	assert.False(t, IsValidStrategy("unknown"))
+
+	assert.Equal(t, []string{"a b"}, Split(STRATEGY_TOKEN, "a b", Options{}))
+}
@@ -0,0 +1,116 @@
+package chunker
+
+import (
+	"strings"
+)
+
+type section struct {
+	path []string // 所属的标题路径，包含当前节的标题
+	span
+}
+
+// headingLevel 返回 markdown 标题的级别，非标题返回 0
+func headingLevel(line string) int {
+	line = strings.TrimSpace(line)
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0
+	}
+	if strings.TrimSpace(line[level:]) == "" {
+		return 0
+	}
+	return level
+}
+
+// sections 按照 markdown 标题分节，代码块中的 # 不视为标题
+func sections(text string) []section {
+	var (
+		res     []section
+		path    []string
+		levels  []int
+		current = section{span: span{0, 0}}
+		inCode  bool
+		offset  int
+	)
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+
+		if level := headingLevel(line); !inCode && level > 0 {
+			current.end = offset
+			res = append(res, current)
+
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+				path = path[:len(path)-1]
+			}
+			levels = append(levels, level)
+			path = append(path, trimmed)
+
+			current = section{
+				path: append([]string(nil), path...),
+				span: span{offset + len(line), 0},
+			}
+		}
+		offset += len(line)
+	}
+	current.end = len(text)
+	res = append(res, current)
+	return res
+}
+
+// SplitMarkdown 按照标题分节，每节内按句子边界切片，并在切片前加上所属的标题路径
+// 切片不会跨越标题，保证每个切片只属于一个主题
+func SplitMarkdown(text string, opts Options) []string {
+	opts = opts.normalize()
+
+	var res []string
+	for _, sec := range sections(text) {
+		body := text[sec.start:sec.end]
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+
+		heading := strings.Join(sec.path, "\n")
+		sectionOpts := opts
+		if tokens := CountTokens(heading); tokens > 0 && tokens < opts.ChunkSize/2 {
+			sectionOpts.ChunkSize -= tokens
+			if sectionOpts.Overlap >= sectionOpts.ChunkSize {
+				sectionOpts.Overlap = sectionOpts.ChunkSize / 4
+			}
+		} else {
+			// 标题过长时不再附加到每个切片
+			heading = ""
+		}
+
+		for _, chunk := range splitSentences(body, sentences(body), sectionOpts) {
+			if heading != "" {
+				chunk = heading + "\n\n" + chunk
+			}
+			res = append(res, chunk)
+		}
+	}
+	return res
+}
+
+// Title 返回文档开头的标题，文档不以标题开头时返回第一行文本
+func Title(text string) string {
+	for _, sec := range sections(text) {
+		if len(sec.path) > 0 {
+			return strings.TrimSpace(strings.TrimLeft(sec.path[0], "#"))
+		}
+		for _, line := range strings.Split(text[sec.start:sec.end], "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				runes := []rune(line)
+				return string(runes[:min(len(runes), 64)])
+			}
+		}
+	}
+	return ""
+}
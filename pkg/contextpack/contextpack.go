@@ -0,0 +1,298 @@
+package contextpack
+
+// 按 token 预算组装 RAG 上下文：先按排序放入每个文档命中的片段，预算有剩余时再换成完整内容或补充相邻片段
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/breeew/brew-api/pkg/chunker"
+)
+
+const (
+	MODE_FULL      = "full"      // 完整内容
+	MODE_CHUNKS    = "chunks"    // 命中的片段及部分相邻片段
+	MODE_TRUNCATED = "truncated" // 内容被截断
+	MODE_DROPPED   = "dropped"   // 预算不足，未放入上下文
+
+	// MIN_PASSAGE_TOKENS 剩余预算低于该值时不再截断放入，过短的片段对回答没有帮助
+	MIN_PASSAGE_TOKENS = 64
+
+	chunkSeparator = "\n\n"
+	gapSeparator   = "\n\n...\n\n" // 不相邻的片段之间有被省略的内容
+	truncateMarker = "\n..."
+)
+
+// Document 待放入上下文的文档，Chunks 需按原文顺序排列，为空时只能使用完整内容
+type Document struct {
+	ID      string
+	Content string
+	Chunks  []string
+	Matched []int // 检索命中的片段下标
+}
+
+type Options struct {
+	Budget    int // 所有文档可使用的 token 总数，<=0 表示不限制
+	Neighbors int // 每个命中片段向两侧最多补充的相邻片段数
+	Count     func(text string) int
+}
+
+type Passage struct {
+	ID             string
+	Content        string
+	Parts          []Part // 组成 Content 的内容，按原文顺序排列
+	Mode           string
+	Tokens         int // 放入上下文的 token 数
+	OriginalTokens int // 完整内容的 token 数
+}
+
+// Part 放入上下文的一段内容，Chunk 为片段下标，-1 表示完整内容或截断后的完整内容
+type Part struct {
+	Chunk   int
+	Content string
+	Gap     bool // 与上一段内容之间有被省略的片段
+}
+
+// Render 拼接内容，label 不为空时在每段内容前加上其返回的标记
+func Render(parts []Part, label func(p Part) string) string {
+	var sb strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			if p.Gap {
+				sb.WriteString(gapSeparator)
+			} else {
+				sb.WriteString(chunkSeparator)
+			}
+		}
+		if label != nil {
+			sb.WriteString(label(p))
+		}
+		sb.WriteString(p.Content)
+	}
+	return sb.String()
+}
+
+type Result struct {
+	Used     int
+	Passages []Passage // 与输入的文档一一对应，包括被丢弃的文档
+}
+
+type state struct {
+	doc         Document
+	chunkTokens []int
+	fullTokens  int
+	selected    []bool
+	matched     []int
+	content     string // 非空时表示使用完整内容或截断后的内容
+	chunk       int    // content 对应的片段下标，-1 表示完整内容
+	mode        string
+	cost        int
+}
+
+// Pack 排序靠前的文档优先占用预算
+func Pack(docs []Document, opts Options) Result {
+	count := opts.Count
+	if count == nil {
+		count = chunker.CountTokens
+	}
+
+	states := make([]*state, 0, len(docs))
+	for _, d := range docs {
+		states = append(states, newState(d, count))
+	}
+
+	if opts.Budget <= 0 {
+		for _, s := range states {
+			s.useFull()
+		}
+		return result(states, count)
+	}
+
+	remaining := opts.Budget
+	sepTokens := count(chunkSeparator)
+
+	// 1. 放入命中的片段，没有片段信息的文档放入完整内容，放不下时截断
+	for _, s := range states {
+		if len(s.matched) == 0 {
+			switch {
+			case s.fullTokens <= remaining:
+				s.useFull()
+			case remaining >= MIN_PASSAGE_TOKENS:
+				s.truncate(-1, s.doc.Content, remaining, count)
+			default:
+				s.mode = MODE_DROPPED
+			}
+			remaining -= s.cost
+			continue
+		}
+
+		s.mode = MODE_CHUNKS
+		for _, idx := range s.matched {
+			cost := s.chunkTokens[idx] + sepTokens
+			if cost <= remaining {
+				s.selected[idx] = true
+				s.cost += cost
+				remaining -= cost
+				continue
+			}
+			if s.cost == 0 && remaining >= MIN_PASSAGE_TOKENS {
+				// 第一个命中片段都放不下时截断该片段
+				s.truncate(idx, s.doc.Chunks[idx], remaining, count)
+				remaining -= s.cost
+			}
+			break
+		}
+		if s.cost == 0 {
+			s.mode = MODE_DROPPED
+		}
+	}
+
+	// 2. 预算有剩余时，完整内容放得下的文档换成完整内容，否则补充相邻片段
+	for _, s := range states {
+		if s.mode != MODE_CHUNKS {
+			continue
+		}
+		if s.fullTokens-s.cost <= remaining {
+			remaining -= s.fullTokens - s.cost
+			s.useFull()
+			continue
+		}
+		for dist := 1; dist <= opts.Neighbors; dist++ {
+			for _, idx := range s.matched {
+				for _, n := range []int{idx - dist, idx + dist} {
+					if n < 0 || n >= len(s.selected) || s.selected[n] {
+						continue
+					}
+					if cost := s.chunkTokens[n] + sepTokens; cost <= remaining {
+						s.selected[n] = true
+						s.cost += cost
+						remaining -= cost
+					}
+				}
+			}
+		}
+	}
+
+	return result(states, count)
+}
+
+func newState(d Document, count func(string) int) *state {
+	s := &state{
+		doc:         d,
+		chunkTokens: make([]int, len(d.Chunks)),
+		selected:    make([]bool, len(d.Chunks)),
+		fullTokens:  count(d.Content),
+	}
+	for i, c := range d.Chunks {
+		s.chunkTokens[i] = count(c)
+	}
+
+	seen := make(map[int]bool, len(d.Matched))
+	for _, idx := range d.Matched {
+		if idx < 0 || idx >= len(d.Chunks) || seen[idx] {
+			continue
+		}
+		seen[idx] = true
+		s.matched = append(s.matched, idx)
+	}
+	sort.Ints(s.matched)
+	return s
+}
+
+func (s *state) useFull() {
+	s.mode = MODE_FULL
+	s.content = s.doc.Content
+	s.chunk = -1
+	s.cost = s.fullTokens
+}
+
+func (s *state) truncate(chunk int, text string, maxTokens int, count func(string) int) {
+	s.mode = MODE_TRUNCATED
+	s.chunk = chunk
+	s.content = Truncate(text, maxTokens, count)
+	if s.content == "" {
+		s.mode = MODE_DROPPED
+		return
+	}
+	s.cost = count(s.content)
+}
+
+// parts 按原文顺序返回放入上下文的内容
+func (s *state) parts() []Part {
+	switch s.mode {
+	case MODE_DROPPED:
+		return nil
+	case MODE_CHUNKS:
+	default:
+		return []Part{{Chunk: s.chunk, Content: s.content}}
+	}
+
+	var (
+		parts []Part
+		last  = -1
+	)
+	for i, ok := range s.selected {
+		if !ok {
+			continue
+		}
+		parts = append(parts, Part{
+			Chunk:   i,
+			Content: s.doc.Chunks[i],
+			Gap:     last >= 0 && i != last+1,
+		})
+		last = i
+	}
+	return parts
+}
+
+func result(states []*state, count func(string) int) Result {
+	var res Result
+	for _, s := range states {
+		p := Passage{
+			ID:             s.doc.ID,
+			Parts:          s.parts(),
+			Mode:           s.mode,
+			OriginalTokens: s.fullTokens,
+		}
+		p.Content = Render(p.Parts, nil)
+		if p.Content != "" {
+			p.Tokens = count(p.Content)
+		}
+		res.Used += p.Tokens
+		res.Passages = append(res.Passages, p)
+	}
+	return res
+}
+
+// Truncate 截断文本使其不超过 maxTokens，尽量在换行处截断
+func Truncate(text string, maxTokens int, count func(string) int) string {
+	if count(text) <= maxTokens {
+		return text
+	}
+	budget := maxTokens - count(truncateMarker)
+	if budget <= 0 {
+		return ""
+	}
+
+	runes := []rune(text)
+	// 二分查找不超过预算的最长前缀
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if count(string(runes[:mid])) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	prefix := string(runes[:lo])
+	if i := strings.LastIndexByte(prefix, '\n'); i > len(prefix)/2 {
+		prefix = prefix[:i]
+	}
+	prefix = strings.TrimRight(prefix, " \t\n")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + truncateMarker
+}
@@ -0,0 +1,92 @@
+package contextpack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// words 按空白分词计数，便于构造预算
+func words(text string) int {
+	return len(strings.Fields(text))
+}
+
+func repeat(word string, n int) string {
+	return strings.TrimSpace(strings.Repeat(word+" ", n))
+}
+
+func Test_PackUnlimited(t *testing.T) {
+	res := Pack([]Document{{ID: "a", Content: "a b c", Chunks: []string{"a", "b c"}, Matched: []int{1}}}, Options{Count: words})
+	assert.Equal(t, MODE_FULL, res.Passages[0].Mode)
+	assert.Equal(t, "a b c", res.Passages[0].Content)
+	assert.Equal(t, 3, res.Used)
+}
+
+func Test_PackPrefersMatchedChunks(t *testing.T) {
+	chunks := []string{repeat("c0", 100), repeat("c1", 100), repeat("c2", 100), repeat("c3", 100), repeat("c4", 100)}
+	big := Document{ID: "big", Content: strings.Join(chunks, "\n\n"), Chunks: chunks, Matched: []int{2, 2, 9}}
+	small := Document{ID: "small", Content: repeat("s", 50)}
+
+	// 完整内容放不下，命中片段及一个相邻片段放得下
+	res := Pack([]Document{big, small}, Options{Budget: 300, Neighbors: 1, Count: words})
+	assert.Equal(t, MODE_CHUNKS, res.Passages[0].Mode)
+	assert.Equal(t, 500, res.Passages[0].OriginalTokens)
+	assert.Equal(t, chunks[1]+"\n\n"+chunks[2], res.Passages[0].Content)
+	assert.Equal(t, MODE_FULL, res.Passages[1].Mode)
+	assert.Equal(t, 250, res.Used)
+
+	// 预算足够时使用完整内容
+	res = Pack([]Document{big, small}, Options{Budget: 600, Neighbors: 1, Count: words})
+	assert.Equal(t, MODE_FULL, res.Passages[0].Mode)
+	assert.Equal(t, MODE_FULL, res.Passages[1].Mode)
+}
+
+func Test_PackGapsBetweenChunks(t *testing.T) {
+	chunks := []string{"c0", "c1", "c2", "c3", "c4"}
+	doc := Document{ID: "a", Content: repeat("x", 100), Chunks: chunks, Matched: []int{0, 4}}
+	res := Pack([]Document{doc}, Options{Budget: 10, Count: words})
+	assert.Equal(t, MODE_CHUNKS, res.Passages[0].Mode)
+	assert.Equal(t, "c0"+gapSeparator+"c4", res.Passages[0].Content)
+}
+
+func Test_PackTruncatesAndDrops(t *testing.T) {
+	first := Document{ID: "first", Content: repeat("a", 150)}
+	second := Document{ID: "second", Content: repeat("b", 150)}
+	third := Document{ID: "third", Content: repeat("c", 150)}
+
+	res := Pack([]Document{first, second, third}, Options{Budget: 230, Count: words})
+	assert.Equal(t, MODE_FULL, res.Passages[0].Mode)
+	assert.Equal(t, MODE_TRUNCATED, res.Passages[1].Mode)
+	assert.LessOrEqual(t, res.Passages[1].Tokens, 80)
+	assert.True(t, strings.HasSuffix(res.Passages[1].Content, truncateMarker))
+	assert.Equal(t, MODE_DROPPED, res.Passages[2].Mode)
+	assert.Empty(t, res.Passages[2].Content)
+	assert.LessOrEqual(t, res.Used, 230)
+
+	// 剩余预算过少时不截断
+	res = Pack([]Document{first, second}, Options{Budget: 200, Count: words})
+	assert.Equal(t, MODE_DROPPED, res.Passages[1].Mode)
+}
+
+func Test_Truncate(t *testing.T) {
+	text := "line one\nline two\nline three"
+	assert.Equal(t, text, Truncate(text, 6, words))
+	assert.Equal(t, "line one\nline two"+truncateMarker, Truncate(text, 5, words))
+	assert.Equal(t, "", Truncate(text, 1, words))
+}
+
+func Test_PackParts(t *testing.T) {
+	chunks := []string{"c0", "c1", "c2", "c3", "c4"}
+	doc := Document{ID: "a", Content: repeat("x", 100), Chunks: chunks, Matched: []int{0, 1, 4}}
+	res := Pack([]Document{doc, {ID: "b", Content: "y"}}, Options{Budget: 10, Count: words})
+	assert.Equal(t, []Part{{Chunk: 0, Content: "c0"}, {Chunk: 1, Content: "c1"}, {Chunk: 4, Content: "c4", Gap: true}}, res.Passages[0].Parts)
+	assert.Equal(t, []Part{{Chunk: -1, Content: "y"}}, res.Passages[1].Parts)
+
+	n := 0
+	content := Render(res.Passages[0].Parts, func(p Part) string {
+		n++
+		return "[" + strings.Repeat("#", n) + "] "
+	})
+	assert.Equal(t, "[#] c0\n\n[##] c1"+gapSeparator+"[###] c4", content)
+}
@@ -0,0 +1,64 @@
+// Package dedup 知识点内容去重，完全相同的内容通过归一化后文本的哈希判断，相近的内容由向量相似度判断
+package dedup
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/breeew/brew-api/pkg/types"
+	"github.com/breeew/brew-api/pkg/utils"
+)
+
+// Normalize 忽略大小写及空白字符的差异，连续的空白字符视为一个空格
+func Normalize(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// PlainText 返回知识点内容的 markdown 形式，EditorJS 内容转换为 markdown 后再比较
+func PlainText(contentType types.KnowledgeContentType, content []byte) (string, error) {
+	if contentType == types.KNOWLEDGE_CONTENT_TYPE_BLOCKS {
+		return utils.ConvertEditorJSBlocksToMarkdown(json.RawMessage(content))
+	}
+	return string(content), nil
+}
+
+// Group 将两两重复的知识点合并为重复组，间接重复的知识点属于同一组
+// 组按照第一次出现的顺序返回，组内的知识点同样按照出现顺序排列
+func Group(pairs [][2]string) [][]string {
+	parent := make(map[string]string)
+	var order []string
+	var find func(id string) string
+	find = func(id string) string {
+		if _, exist := parent[id]; !exist {
+			parent[id] = id
+			order = append(order, id)
+		}
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+
+	for _, p := range pairs {
+		a, b := find(p[0]), find(p[1])
+		if a != b {
+			parent[b] = a
+		}
+	}
+
+	var (
+		index = make(map[string]int)
+		res   [][]string
+	)
+	for _, id := range order {
+		root := find(id)
+		i, exist := index[root]
+		if !exist {
+			i = len(res)
+			index[root] = i
+			res = append(res, nil)
+		}
+		res[i] = append(res[i], id)
+	}
+	return res
+}
@@ -0,0 +1,43 @@
+package dedup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func Test_Normalize(t *testing.T) {
+	assert.Equal(t, "hello world", Normalize("  Hello\n\tWORLD  "))
+	assert.Equal(t, Normalize("Hello world"), Normalize(" hello   World\n"))
+	assert.NotEqual(t, Normalize("hello world"), Normalize("hello, world"))
+	assert.Empty(t, Normalize(" \n\t "))
+}
+
+func Test_PlainText(t *testing.T) {
+	blocks := `{"time":1,"blocks":[{"id":"a","type":"paragraph","data":{"text":"Hello world"}}],"version":"2.30.7"}`
+	text, err := PlainText(types.KNOWLEDGE_CONTENT_TYPE_BLOCKS, []byte(blocks))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", Normalize(text))
+
+	text, err = PlainText(types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN, []byte("Hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello world", text)
+}
+
+func Test_Group(t *testing.T) {
+	groups := Group([][2]string{
+		{"a", "b"},
+		{"c", "d"},
+		{"b", "e"},
+		{"d", "a"},
+		{"x", "y"},
+	})
+	assert.Equal(t, [][]string{
+		{"a", "b", "c", "d", "e"},
+		{"x", "y"},
+	}, groups)
+
+	assert.Nil(t, Group(nil))
+}
@@ -0,0 +1,89 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSV 将 csv 转换为 markdown 表格，第一行作为表头
+func CSV(data []byte) (*Result, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if bytes.Count(data[:min(len(data), 4096)], []byte(";")) > bytes.Count(data[:min(len(data), 4096)], []byte(",")) {
+		r.Comma = ';'
+	}
+
+	var rows [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv file: %w", err)
+		}
+		rows = append(rows, record)
+	}
+
+	table := markdownTable(rows)
+	if table == "" {
+		return nil, ErrEmptyContent
+	}
+	return &Result{Content: table}, nil
+}
+
+// markdownTable 将二维表格转换为 markdown 表格，第一行作为表头，列数以最多的一行为准
+func markdownTable(rows [][]string) string {
+	var (
+		columns int
+		list    [][]string
+	)
+	for _, row := range rows {
+		empty := true
+		for _, v := range row {
+			if strings.TrimSpace(v) != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+		list = append(list, row)
+		columns = max(columns, len(row))
+	}
+	if len(list) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	writeRow := func(row []string) {
+		sb.WriteString("|")
+		for i := 0; i < columns; i++ {
+			var v string
+			if i < len(row) {
+				v = row[i]
+			}
+			sb.WriteString(" " + escapeTableCell(v) + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	writeRow(list[0])
+	sb.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+	for _, row := range list[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func escapeTableCell(v string) string {
+	v = strings.Join(strings.Fields(v), " ")
+	return strings.ReplaceAll(v, "|", "\\|")
+}
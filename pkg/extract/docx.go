@@ -0,0 +1,208 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const docxMaxXMLSize = 64 << 20
+
+func readZipFile(r *zip.Reader, name string) ([]byte, error) {
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		// 限制解压后的大小，避免压缩炸弹
+		return io.ReadAll(io.LimitReader(rc, docxMaxXMLSize))
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+// docxHeading 根据段落样式返回标题级别，正文返回 0
+func docxHeading(style string) int {
+	style = strings.ToLower(strings.ReplaceAll(style, " ", ""))
+	if style == "title" {
+		return 1
+	}
+	if strings.HasPrefix(style, "heading") {
+		if level, err := strconv.Atoi(strings.TrimPrefix(style, "heading")); err == nil && level > 0 {
+			return min(level, 6)
+		}
+	}
+	return 0
+}
+
+type docxParagraph struct {
+	heading int
+	list    bool
+	text    strings.Builder
+}
+
+// DOCX 提取 word 文档的正文，标题样式转换为 markdown 标题，表格转换为 markdown 表格
+func DOCX(data []byte) (*Result, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid docx file: %w", err)
+	}
+
+	document, err := readZipFile(r, "word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("invalid docx file: %w", err)
+	}
+
+	var (
+		decoder = xml.NewDecoder(bytes.NewReader(document))
+		blocks  []string
+		para    *docxParagraph
+		inText  bool
+		// 表格中的行与单元格，支持嵌套表格时仅处理最外层
+		tableDepth int
+		rows       [][]string
+		row        []string
+		cell       []string
+	)
+
+	flushParagraph := func() {
+		if para == nil {
+			return
+		}
+		text := strings.TrimSpace(para.text.String())
+		p := para
+		para = nil
+		if text == "" {
+			return
+		}
+		if tableDepth > 0 {
+			cell = append(cell, text)
+			return
+		}
+		switch {
+		case p.heading > 0:
+			text = strings.Repeat("#", p.heading) + " " + text
+		case p.list:
+			text = "- " + text
+		}
+		blocks = append(blocks, text)
+	}
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid docx document: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				flushParagraph()
+				para = &docxParagraph{}
+			case "pStyle":
+				if para != nil {
+					para.heading = docxHeading(xmlAttr(t, "val"))
+				}
+			case "numPr":
+				if para != nil {
+					para.list = true
+				}
+			case "t":
+				inText = true
+			case "tab":
+				if para != nil {
+					para.text.WriteByte('\t')
+				}
+			case "br", "cr":
+				if para != nil {
+					para.text.WriteByte('\n')
+				}
+			case "tbl":
+				flushParagraph()
+				tableDepth++
+				if tableDepth == 1 {
+					rows = nil
+				}
+			case "tr":
+				if tableDepth == 1 {
+					row = nil
+				}
+			case "tc":
+				if tableDepth == 1 {
+					cell = nil
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				flushParagraph()
+			case "t":
+				inText = false
+			case "tc":
+				if tableDepth == 1 {
+					row = append(row, strings.Join(cell, " "))
+				}
+			case "tr":
+				if tableDepth == 1 {
+					rows = append(rows, row)
+				}
+			case "tbl":
+				tableDepth--
+				if tableDepth == 0 {
+					if table := markdownTable(rows); table != "" {
+						blocks = append(blocks, table)
+					}
+				}
+			}
+		case xml.CharData:
+			if inText && para != nil {
+				para.text.Write(t)
+			}
+		}
+	}
+	flushParagraph()
+
+	if len(blocks) == 0 {
+		return nil, ErrEmptyContent
+	}
+
+	return &Result{
+		Title:   docxTitle(r),
+		Content: strings.Join(blocks, "\n\n"),
+	}, nil
+}
+
+// docxTitle 读取文档属性中的标题
+func docxTitle(r *zip.Reader) string {
+	data, err := readZipFile(r, "docProps/core.xml")
+	if err != nil {
+		return ""
+	}
+	var core struct {
+		Title string `xml:"title"`
+	}
+	if err = xml.Unmarshal(data, &core); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(core.Title)
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package extract
+
+// 从文件中提取文本，统一转换为 markdown 以便后续切片
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnsupported  = errors.New("unsupported file format")
+	ErrEmptyContent = errors.New("no text content found")
+)
+
+const (
+	FORMAT_PDF      = "pdf"
+	FORMAT_DOCX     = "docx"
+	FORMAT_HTML     = "html"
+	FORMAT_TEXT     = "text"
+	FORMAT_MARKDOWN = "markdown"
+	FORMAT_CSV      = "csv"
+)
+
+type Result struct {
+	Title   string // 文档自带的标题，没有时为空
+	Content string // markdown 格式的正文
+}
+
+// Format 根据文件名后缀判断文件格式，无法判断时根据 mime 类型判断
+func Format(fileName, mimeType string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".pdf":
+		return FORMAT_PDF
+	case ".docx":
+		return FORMAT_DOCX
+	case ".html", ".htm", ".xhtml":
+		return FORMAT_HTML
+	case ".md", ".markdown":
+		return FORMAT_MARKDOWN
+	case ".txt", ".text", ".log":
+		return FORMAT_TEXT
+	case ".csv":
+		return FORMAT_CSV
+	}
+
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	switch mimeType {
+	case "application/pdf":
+		return FORMAT_PDF
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return FORMAT_DOCX
+	case "text/html", "application/xhtml+xml":
+		return FORMAT_HTML
+	case "text/markdown":
+		return FORMAT_MARKDOWN
+	case "text/csv":
+		return FORMAT_CSV
+	case "text/plain":
+		return FORMAT_TEXT
+	}
+	return ""
+}
+
+// Extract 提取文件内容，mimeType 为空时根据文件内容判断
+func Extract(fileName, mimeType string, data []byte) (*Result, error) {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	var (
+		res *Result
+		err error
+	)
+	switch Format(fileName, mimeType) {
+	case FORMAT_PDF:
+		res, err = PDF(data)
+	case FORMAT_DOCX:
+		res, err = DOCX(data)
+	case FORMAT_HTML:
+		res, err = HTML(data)
+	case FORMAT_CSV:
+		res, err = CSV(data)
+	case FORMAT_TEXT, FORMAT_MARKDOWN:
+		res, err = Text(data)
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.Title == "" {
+		res.Title = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	}
+	return res, nil
+}
+
+// Text 纯文本及 markdown 原样保留，去掉无效的 utf8 字符
+func Text(data []byte) (*Result, error) {
+	content := string(data)
+	if !utf8.ValidString(content) {
+		content = strings.ToValidUTF8(content, "")
+	}
+	content = strings.TrimPrefix(content, "\ufeff")
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrEmptyContent
+	}
+	return &Result{Content: strings.TrimSpace(content)}, nil
+}
+
+// normalizeLines 去掉行尾空白并合并连续的空行
+func normalizeLines(text string) string {
+	var (
+		res   []string
+		blank bool
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			blank = len(res) > 0
+			continue
+		}
+		if blank {
+			res = append(res, "")
+			blank = false
+		}
+		res = append(res, line)
+	}
+	return strings.Join(res, "\n")
+}
@@ -0,0 +1,211 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flateStream(data string) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", buf.Len(), buf.String())
+}
+
+func rawStream(data string) string {
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(data), data)
+}
+
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Root 1 0 R /Info %d 0 R >>\n%%%%EOF\n", len(objects))
+	return buf.Bytes()
+}
+
+func Test_PDF(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar
+<0001> <5411>
+<0002> <91CF>
+endbfchar
+1 beginbfrange
+<0010> <0012> <0041>
+endbfrange
+endcmap`
+
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [8 0 R] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /Encoding /Identity-H /ToUnicode 9 0 R >>",
+		flateStream("BT /F1 12 Tf 72 720 Td (Hello ) Tj [(W) 20 (orld) -300 (again)] TJ 0 -14 Td (Line \\(two\\)) Tj ET"),
+		rawStream("BT /F2 12 Tf 1 0 0 1 72 700 Tm <00010002> Tj 1 0 0 1 72 680 Tm <001000110012> Tj ET"),
+		rawStream(cmap),
+		"<< /Title (Test Document) >>",
+	)
+
+	res, err := PDF(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Document", res.Title)
+	assert.Equal(t, "Hello World again\nLine (two)\n\n向量\nABC", res.Content)
+
+	_, err = PDF([]byte("not a pdf"))
+	assert.Error(t, err)
+
+	_, err = PDF(buildPDF("<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [] >>", "<< >>"))
+	assert.Equal(t, ErrEmptyContent, err)
+
+	encrypted := append(buildPDF("<< /Type /Catalog >>"), []byte("trailer\n<< /Encrypt 1 0 R >>\n")...)
+	_, err = PDF(encrypted)
+	assert.Equal(t, ErrEncrypted, err)
+}
+
+func Test_PDF_Malformed(t *testing.T) {
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+
+	// 长度为负数或超出文件
+	for _, length := range []string{"-99954", "99999999"} {
+		_, err := PDF(buildPDF(catalog, "<< /Length "+length+" >>\nstream\nBT (x) Tj ET\nendstream"))
+		assert.ErrorContains(t, err, "invalid pdf stream length")
+	}
+
+	// 对象流的 /First 为负数或超出流的长度
+	for _, first := range []string{"-3", "1000"} {
+		objStm := strings.Replace(rawStream("10 0 << /Title (x) >>"), "<<", "<< /Type /ObjStm /N 1 /First "+first, 1)
+		_, err := PDF(buildPDF(catalog, objStm))
+		assert.ErrorContains(t, err, "invalid pdf object stream first")
+	}
+
+	// 对象偏移为负数
+	objStm := strings.Replace(rawStream("10 -5 << /Title (x) >>"), "<<", "<< /Type /ObjStm /N 1 /First 6", 1)
+	_, err := PDF(buildPDF(catalog, objStm))
+	assert.ErrorContains(t, err, "invalid pdf object stream offset")
+}
+
+func Test_inflate_Limit(t *testing.T) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(make([]byte, pdfMaxStreamSize+1))
+	w.Close()
+
+	_, err := inflate(buf.Bytes())
+	assert.ErrorContains(t, err, "exceeds")
+
+	buf.Reset()
+	w = zlib.NewWriter(&buf)
+	w.Write([]byte("BT (ok) Tj ET"))
+	w.Close()
+	res, err := inflate(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "BT (ok) Tj ET", string(res))
+}
+
+func buildDOCX(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		f.Write([]byte(content))
+	}
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func Test_DOCX(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Overview</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">First </w:t></w:r><w:r><w:t>paragraph.</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>item</w:t></w:r></w:p>
+<w:p></w:p>
+<w:tbl>
+<w:tr><w:tc><w:p><w:r><w:t>Name</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Value</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>a|b</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>1</w:t></w:r></w:p></w:tc></w:tr>
+</w:tbl>
+</w:body>
+</w:document>`
+	core := `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Report</dc:title></cp:coreProperties>`
+
+	res, err := DOCX(buildDOCX(t, map[string]string{
+		"word/document.xml": document,
+		"docProps/core.xml": core,
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "Report", res.Title)
+	assert.Equal(t, "# Overview\n\nFirst paragraph.\n\n- item\n\n| Name | Value |\n| --- | --- |\n| a\\|b | 1 |", res.Content)
+
+	_, err = DOCX(buildDOCX(t, map[string]string{"other.xml": "<a/>"}))
+	assert.Error(t, err)
+}
+
+func Test_HTML(t *testing.T) {
+	doc := `<html><head><title> Page  Title </title><style>p{}</style></head>
+<body>
+<nav hidden>menu</nav>
+<h1>Heading</h1>
+<p>Some <b>bold</b>, <a href="https://example.com">link</a> and <code>code</code>.</p>
+<ul><li>one</li><li>two<ol><li>nested</li></ol></li></ul>
+<pre>line 1
+  line 2</pre>
+<table><tr><th>k</th><th>v</th></tr><tr><td>a</td><td>1</td></tr></table>
+<script>alert(1)</script>
+</body></html>`
+
+	res, err := HTML([]byte(doc))
+	assert.NoError(t, err)
+	assert.Equal(t, "Page Title", res.Title)
+	assert.Equal(t, strings.Join([]string{
+		"# Heading",
+		"Some **bold**, [link](https://example.com) and `code`.",
+		"- one\n- two\n  1. nested",
+		"```\nline 1\n  line 2\n```",
+		"| k | v |\n| --- | --- |\n| a | 1 |",
+	}, "\n\n"), res.Content)
+}
+
+func Test_CSV(t *testing.T) {
+	res, err := CSV([]byte("\xef\xbb\xbfname,age\nalice,30\n\nbob\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "| name | age |\n| --- | --- |\n| alice | 30 |\n| bob |  |", res.Content)
+
+	res, err = CSV([]byte("a;b\n1;2\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "| a | b |\n| --- | --- |\n| 1 | 2 |", res.Content)
+}
+
+func Test_Extract(t *testing.T) {
+	assert.Equal(t, FORMAT_PDF, Format("a.PDF", ""))
+	assert.Equal(t, FORMAT_DOCX, Format("a.docx", ""))
+	assert.Equal(t, FORMAT_HTML, Format("page", "text/html; charset=utf-8"))
+	assert.Equal(t, FORMAT_TEXT, Format("noext", "text/plain; charset=utf-8"))
+	assert.Equal(t, "", Format("a.png", "image/png"))
+
+	res, err := Extract("/brew/space/notes.md", "", []byte("# Notes\n\ncontent\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "notes", res.Title)
+	assert.Equal(t, "# Notes\n\ncontent", res.Content)
+
+	_, err = Extract("image.png", "", []byte("\x89PNG\r\n\x1a\n"))
+	assert.Equal(t, ErrUnsupported, err)
+
+	_, err = Extract("empty.txt", "", []byte("  \n"))
+	assert.Equal(t, ErrEmptyContent, err)
+}
@@ -0,0 +1,308 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTML 将 html 转换为 markdown，忽略脚本、样式等不可见的内容
+func HTML(data []byte) (*Result, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid html: %w", err)
+	}
+
+	content := HTMLToMarkdown(doc)
+	if content == "" {
+		return nil, ErrEmptyContent
+	}
+	return &Result{
+		Title:   HTMLTitle(doc),
+		Content: content,
+	}, nil
+}
+
+// HTMLTitle 返回 <title> 的内容
+func HTMLTitle(doc *html.Node) string {
+	if n := findElement(doc, atom.Title); n != nil {
+		return strings.Join(strings.Fields(textContent(n)), " ")
+	}
+	return ""
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if res := findElement(c, a); res != nil {
+			return res
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// htmlIgnored 不包含正文的元素
+func htmlIgnored(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Iframe, atom.Svg, atom.Canvas, atom.Form, atom.Button, atom.Select, atom.Input, atom.Textarea:
+		return true
+	}
+	for _, attr := range n.Attr {
+		if attr.Key == "hidden" || (attr.Key == "aria-hidden" && strings.EqualFold(attr.Val, "true")) {
+			return true
+		}
+	}
+	return false
+}
+
+type markdownWriter struct {
+	blocks []string
+	inline strings.Builder
+}
+
+// HTMLToMarkdown 将 html 节点转换为 markdown，块级元素之间空一行
+func HTMLToMarkdown(n *html.Node) string {
+	w := &markdownWriter{}
+	w.block(n)
+	w.flush("")
+	return normalizeLines(strings.Join(w.blocks, "\n\n"))
+}
+
+// flush 将当前行内内容作为一个块输出，prefix 为块的前缀，例如标题的 #
+func (w *markdownWriter) flush(prefix string) {
+	text := strings.Join(strings.Fields(w.inline.String()), " ")
+	w.inline.Reset()
+	if text == "" {
+		return
+	}
+	w.blocks = append(w.blocks, prefix+text)
+}
+
+func (w *markdownWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.block(c)
+	}
+}
+
+func (w *markdownWriter) block(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.inline.WriteString(n.Data)
+		return
+	case html.DocumentNode:
+		w.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if htmlIgnored(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.flush("")
+		level := int(n.Data[1] - '0')
+		w.inline.WriteString(inlineMarkdown(n))
+		w.flush(strings.Repeat("#", level) + " ")
+	case atom.P:
+		w.flush("")
+		w.inline.WriteString(inlineMarkdown(n))
+		w.flush("")
+	case atom.Br:
+		w.flush("")
+	case atom.Hr:
+		w.flush("")
+		w.blocks = append(w.blocks, "---")
+	case atom.Pre:
+		w.flush("")
+		code := strings.Trim(textContent(n), "\n")
+		if strings.TrimSpace(code) != "" {
+			w.blocks = append(w.blocks, "```\n"+code+"\n```")
+		}
+	case atom.Blockquote:
+		w.flush("")
+		sub := &markdownWriter{}
+		sub.children(n)
+		sub.flush("")
+		if len(sub.blocks) > 0 {
+			lines := strings.Split(strings.Join(sub.blocks, "\n\n"), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRight("> "+line, " ")
+			}
+			w.blocks = append(w.blocks, strings.Join(lines, "\n"))
+		}
+	case atom.Ul, atom.Ol:
+		w.flush("")
+		if list := markdownList(n, 0); list != "" {
+			w.blocks = append(w.blocks, list)
+		}
+	case atom.Table:
+		w.flush("")
+		if table := markdownTable(htmlTableRows(n)); table != "" {
+			w.blocks = append(w.blocks, table)
+		}
+	case atom.Img:
+		w.inline.WriteString(inlineMarkdown(n))
+	case atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer, atom.Aside, atom.Nav,
+		atom.Body, atom.Html, atom.Figure, atom.Figcaption, atom.Dl, atom.Dt, atom.Dd, atom.Details, atom.Summary, atom.Li:
+		w.flush("")
+		w.children(n)
+		w.flush("")
+	default:
+		w.inline.WriteString(inlineMarkdown(n))
+	}
+}
+
+// markdownList 转换列表，列表项的行内内容与标记在同一行，嵌套列表缩进后紧跟在所属的列表项之后
+func markdownList(n *html.Node, depth int) string {
+	var (
+		lines []string
+		index int
+	)
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		index++
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", index)
+		}
+
+		var (
+			sb     strings.Builder
+			nested []string
+		)
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.Ul || c.DataAtom == atom.Ol) {
+				if sub := markdownList(c, depth+1); sub != "" {
+					nested = append(nested, sub)
+				}
+				continue
+			}
+			sb.WriteString(inlineMarkdown(c))
+			sb.WriteString(" ")
+		}
+
+		if text := strings.Join(strings.Fields(sb.String()), " "); text != "" || len(nested) > 0 {
+			lines = append(lines, strings.Repeat("  ", depth)+marker+text)
+		}
+		lines = append(lines, nested...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func htmlTableRows(table *html.Node) [][]string {
+	var (
+		rows [][]string
+		walk func(*html.Node)
+	)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Table && n != table {
+			// 嵌套的表格按文本处理
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Tr {
+			var row []string
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && (c.DataAtom == atom.Td || c.DataAtom == atom.Th) {
+					row = append(row, inlineMarkdown(c))
+				}
+			}
+			rows = append(rows, row)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(table)
+	return rows
+}
+
+// inlineMarkdown 转换行内元素，块级元素的内容按文本处理
+func inlineMarkdown(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return n.Data
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if htmlIgnored(n) {
+		return ""
+	}
+
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(inlineMarkdown(c))
+		if c.Type == html.ElementNode && c.DataAtom == atom.Br {
+			sb.WriteString(" ")
+		}
+	}
+	inner := sb.String()
+	trimmed := strings.TrimSpace(inner)
+	if trimmed == "" && n.DataAtom != atom.Img {
+		return inner
+	}
+	// 保留原有的前后空白，避免与相邻的文本粘连
+	wrap := func(left, right string) string {
+		lead := inner[:len(inner)-len(strings.TrimLeft(inner, " \t\n\r"))]
+		trail := inner[len(strings.TrimRight(inner, " \t\n\r")):]
+		return lead + left + trimmed + right + trail
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		href := htmlAttr(n, "href")
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return inner
+		}
+		return wrap("[", "]("+href+")")
+	case atom.Strong, atom.B:
+		return wrap("**", "**")
+	case atom.Em, atom.I:
+		return wrap("*", "*")
+	case atom.Code:
+		return wrap("`", "`")
+	case atom.Img:
+		src := htmlAttr(n, "src")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return ""
+		}
+		return "![" + htmlAttr(n, "alt") + "](" + src + ")"
+	}
+	return inner
+}
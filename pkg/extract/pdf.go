@@ -0,0 +1,697 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var ErrEncrypted = errors.New("encrypted pdf is not supported")
+
+// pdfMaxStreamSize 单个流解压后的最大长度，避免压缩炸弹
+const pdfMaxStreamSize = 64 << 20
+
+type pdfObject struct {
+	value  any
+	stream []byte // 未解码的流数据，非流对象为 nil
+}
+
+type pdfDocument struct {
+	data    []byte
+	objects map[int]*pdfObject
+	trailer pdfDict
+}
+
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+
+func parsePDF(data []byte) (*pdfDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return nil, fmt.Errorf("invalid pdf header")
+	}
+
+	doc := &pdfDocument{
+		data:    data,
+		objects: make(map[int]*pdfObject),
+		trailer: pdfDict{},
+	}
+
+	// 按顺序扫描所有对象，增量更新的文档中后出现的对象覆盖先出现的
+	for pos := 0; pos < len(data); {
+		loc := pdfObjectHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		var num int
+		fmt.Sscanf(string(data[pos+loc[2]:pos+loc[3]]), "%d", &num)
+
+		l := newPDFLexer(data)
+		l.pos = pos + loc[1]
+		value, _ := l.next()
+		obj := &pdfObject{value: value}
+
+		l.skipSpace()
+		if dict, ok := value.(pdfDict); ok && bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+			start := l.pos + len("stream")
+			if start < len(data) && data[start] == '\r' {
+				start++
+			}
+			if start < len(data) && data[start] == '\n' {
+				start++
+			}
+			end := -1
+			if length, ok := dict["Length"].(float64); ok {
+				if length < 0 || length > float64(len(data)-start) {
+					return nil, fmt.Errorf("invalid pdf stream length %v", length)
+				}
+				if bytes.HasPrefix(bytes.TrimLeft(data[start+int(length):], " \t\r\n"), []byte("endstream")) {
+					end = start + int(length)
+				}
+			}
+			if end < 0 {
+				idx := bytes.Index(data[start:], []byte("endstream"))
+				if idx < 0 {
+					break
+				}
+				end = start + idx
+			}
+			obj.stream = data[start:end]
+			l.pos = end
+		}
+
+		doc.objects[num] = obj
+		pos = l.pos
+	}
+
+	// 压缩的对象流中的对象
+	for _, obj := range doc.objects {
+		dict, ok := obj.value.(pdfDict)
+		if !ok || dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		if err := doc.loadObjectStream(obj, dict); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(doc.objects) == 0 {
+		return nil, fmt.Errorf("no pdf object found")
+	}
+
+	doc.loadTrailer()
+	if doc.trailer["Encrypt"] != nil {
+		return nil, ErrEncrypted
+	}
+	return doc, nil
+}
+
+// loadObjectStream 解析对象流，流无法解码时忽略，/First 或对象偏移不合法时返回错误
+func (d *pdfDocument) loadObjectStream(obj *pdfObject, dict pdfDict) error {
+	content, err := d.decodeStream(obj)
+	if err != nil {
+		return nil
+	}
+	n, _ := dict["N"].(float64)
+	first, _ := dict["First"].(float64)
+	if first < 0 || first > float64(len(content)) {
+		return fmt.Errorf("invalid pdf object stream first %v", first)
+	}
+
+	header := newPDFLexer(content[:int(first)])
+	for i := 0; i < int(n); i++ {
+		num, ok1 := header.next()
+		offset, ok2 := header.next()
+		if !ok1 || !ok2 {
+			return nil
+		}
+		objNum, _ := num.(float64)
+		objOffset, _ := offset.(float64)
+		if objOffset < 0 {
+			return fmt.Errorf("invalid pdf object stream offset %v", objOffset)
+		}
+		if _, exist := d.objects[int(objNum)]; exist || first+objOffset >= float64(len(content)) {
+			continue
+		}
+
+		l := newPDFLexer(content)
+		l.pos = int(first + objOffset)
+		if value, ok := l.next(); ok {
+			d.objects[int(objNum)] = &pdfObject{value: value}
+		}
+	}
+	return nil
+}
+
+// loadTrailer 合并传统 trailer 与交叉引用流中的字典
+func (d *pdfDocument) loadTrailer() {
+	for _, obj := range d.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("XRef") {
+			for k, v := range dict {
+				d.trailer[k] = v
+			}
+		}
+	}
+	for pos := 0; ; {
+		idx := bytes.Index(d.data[pos:], []byte("trailer"))
+		if idx < 0 {
+			break
+		}
+		l := newPDFLexer(d.data)
+		l.pos = pos + idx + len("trailer")
+		if dict, ok := l.next(); ok {
+			if dict, ok := dict.(pdfDict); ok {
+				for k, v := range dict {
+					d.trailer[k] = v
+				}
+			}
+		}
+		pos = l.pos
+	}
+}
+
+func (d *pdfDocument) resolve(v any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		obj, ok := d.objects[ref.num]
+		if !ok {
+			return nil
+		}
+		v = obj.value
+	}
+	return nil
+}
+
+func (d *pdfDocument) dict(v any) pdfDict {
+	dict, _ := d.resolve(v).(pdfDict)
+	return dict
+}
+
+func (d *pdfDocument) decodeStream(obj *pdfObject) ([]byte, error) {
+	dict, _ := obj.value.(pdfDict)
+	var filters []pdfName
+	switch f := d.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = append(filters, f)
+	case pdfArray:
+		for _, v := range f {
+			if name, ok := d.resolve(v).(pdfName); ok {
+				filters = append(filters, name)
+			}
+		}
+	}
+
+	data := obj.stream
+	for _, f := range filters {
+		var err error
+		switch f {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+		case "ASCIIHexDecode", "AHx":
+			data = newPDFLexer(append(append([]byte(nil), data...), '>')).hexString()
+		case "ASCII85Decode", "A85":
+			data, err = decodeASCII85(data)
+		default:
+			return nil, fmt.Errorf("unsupported pdf filter %s", f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		// 部分文档缺少 zlib 头
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	defer r.Close()
+	res, err := io.ReadAll(io.LimitReader(r, pdfMaxStreamSize+1))
+	if len(res) > pdfMaxStreamSize {
+		return nil, fmt.Errorf("pdf stream exceeds %d bytes", pdfMaxStreamSize)
+	}
+	if err != nil && len(res) == 0 {
+		return nil, err
+	}
+	// 流被截断时保留已经解压的部分
+	return res, nil
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if idx := bytes.Index(data, []byte("~>")); idx >= 0 {
+		data = data[:idx]
+	}
+	res := make([]byte, len(data))
+	n, _, err := ascii85.Decode(res, data, true)
+	if err != nil {
+		return nil, err
+	}
+	return res[:n], nil
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages 按页面树的顺序返回所有页面，找不到页面树时按对象编号排序
+func (d *pdfDocument) pages() []pdfPage {
+	var res []pdfPage
+	if root := d.dict(d.trailer["Root"]); root != nil {
+		visited := make(map[pdfRef]bool)
+		var walk func(node any, resources pdfDict)
+		walk = func(node any, resources pdfDict) {
+			if ref, ok := node.(pdfRef); ok {
+				if visited[ref] {
+					return
+				}
+				visited[ref] = true
+			}
+			dict := d.dict(node)
+			if dict == nil {
+				return
+			}
+			if r := d.dict(dict["Resources"]); r != nil {
+				resources = r
+			}
+			if dict["Type"] == pdfName("Pages") || dict["Kids"] != nil {
+				kids, _ := d.resolve(dict["Kids"]).(pdfArray)
+				for _, kid := range kids {
+					walk(kid, resources)
+				}
+				return
+			}
+			res = append(res, pdfPage{dict: dict, resources: resources})
+		}
+		walk(root["Pages"], nil)
+	}
+	if len(res) > 0 {
+		return res
+	}
+
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if dict, ok := d.objects[num].value.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			res = append(res, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+		}
+	}
+	return res
+}
+
+func (d *pdfDocument) pageContent(page pdfPage) []byte {
+	var refs []any
+	switch c := page.dict["Contents"].(type) {
+	case pdfArray:
+		refs = c
+	default:
+		if arr, ok := d.resolve(c).(pdfArray); ok {
+			refs = arr
+		} else {
+			refs = append(refs, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, ref := range refs {
+		r, ok := ref.(pdfRef)
+		if !ok {
+			continue
+		}
+		obj, ok := d.objects[r.num]
+		if !ok || obj.stream == nil {
+			continue
+		}
+		data, err := d.decodeStream(obj)
+		if err != nil {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// pdfFont 字体的编码信息，优先使用 ToUnicode 映射
+type pdfFont struct {
+	cmap    map[string]string
+	codeLen int
+	// identity 为 true 时表示双字节编码且没有 ToUnicode 映射，无法还原文本
+	identity bool
+}
+
+func (d *pdfDocument) fonts(resources pdfDict) map[pdfName]*pdfFont {
+	res := make(map[pdfName]*pdfFont)
+	for name, v := range d.dict(resources["Font"]) {
+		dict := d.dict(v)
+		if dict == nil {
+			continue
+		}
+		font := &pdfFont{codeLen: 1}
+		if ref, ok := dict["ToUnicode"].(pdfRef); ok {
+			if obj, ok := d.objects[ref.num]; ok && obj.stream != nil {
+				if data, err := d.decodeStream(obj); err == nil {
+					font.cmap, font.codeLen = parseToUnicode(data)
+				}
+			}
+		}
+		if font.cmap == nil {
+			if enc, ok := d.resolve(dict["Encoding"]).(pdfName); ok && strings.HasPrefix(string(enc), "Identity") {
+				font.identity = true
+			}
+		}
+		res[name] = font
+	}
+	return res
+}
+
+func parseToUnicode(data []byte) (map[string]string, int) {
+	var (
+		cmap    = make(map[string]string)
+		codeLen = 0
+		l       = newPDFLexer(data)
+		stack   []any
+	)
+	for {
+		v, ok := l.next()
+		if !ok {
+			break
+		}
+		op, isOp := v.(pdfOperator)
+		if !isOp {
+			stack = append(stack, v)
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			if len(stack) > 0 {
+				if lo, ok := stack[0].(pdfString); ok && codeLen == 0 {
+					codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(stack); i += 2 {
+				src, ok1 := stack[i].(pdfString)
+				dst, ok2 := stack[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap[string(src)] = decodeUTF16(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(stack); i += 3 {
+				lo, ok1 := stack[i].(pdfString)
+				hi, ok2 := stack[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) == 0 || len(lo) != len(hi) {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start > 0xffff {
+					continue
+				}
+				for code := start; code <= end; code++ {
+					src := codeBytes(code, len(lo))
+					switch dst := stack[i+2].(type) {
+					case pdfString:
+						if len(dst) == 0 {
+							continue
+						}
+						next := append([]byte(nil), dst...)
+						next[len(next)-1] += byte(code - start)
+						cmap[string(src)] = decodeUTF16(next)
+					case pdfArray:
+						if idx := code - start; idx < len(dst) {
+							if s, ok := dst[idx].(pdfString); ok {
+								cmap[string(src)] = decodeUTF16(s)
+							}
+						}
+					}
+				}
+			}
+		}
+		stack = stack[:0]
+	}
+
+	if codeLen == 0 {
+		codeLen = 1
+		for k := range cmap {
+			codeLen = len(k)
+			break
+		}
+	}
+	if len(cmap) == 0 {
+		return nil, codeLen
+	}
+	return cmap, codeLen
+}
+
+func codeValue(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+func codeBytes(v, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
+
+// decodePDFText 解码文档信息中的文本字符串，带 BOM 时为 UTF-16BE，否则为 PDFDocEncoding
+func decodePDFText(b []byte) string {
+	if bytes.HasPrefix(b, []byte{0xfe, 0xff}) {
+		return decodeUTF16(b[2:])
+	}
+	s, _ := charmap.Windows1252.NewDecoder().Bytes(b)
+	return string(s)
+}
+
+func (f *pdfFont) decode(b []byte) string {
+	if f == nil {
+		s, _ := charmap.Windows1252.NewDecoder().Bytes(b)
+		return string(s)
+	}
+	if f.cmap == nil {
+		if f.identity {
+			return ""
+		}
+		s, _ := charmap.Windows1252.NewDecoder().Bytes(b)
+		return string(s)
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(b); {
+		n := min(f.codeLen, len(b)-i)
+		if s, ok := f.cmap[string(b[i:i+n])]; ok {
+			sb.WriteString(s)
+		} else if f.codeLen == 1 {
+			s, _ := charmap.Windows1252.NewDecoder().Bytes(b[i : i+1])
+			sb.Write(s)
+		}
+		i += n
+	}
+	return sb.String()
+}
+
+// pdfTextWriter 根据文本定位操作符还原换行与空格
+type pdfTextWriter struct {
+	sb    strings.Builder
+	lastY float64
+	hasY  bool
+}
+
+func (w *pdfTextWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	w.sb.WriteString(s)
+}
+
+func (w *pdfTextWriter) lastByte() byte {
+	s := w.sb.String()
+	if s == "" {
+		return '\n'
+	}
+	return s[len(s)-1]
+}
+
+func (w *pdfTextWriter) space() {
+	if c := w.lastByte(); c != ' ' && c != '\n' {
+		w.sb.WriteByte(' ')
+	}
+}
+
+func (w *pdfTextWriter) newline() {
+	if w.sb.Len() > 0 {
+		w.sb.WriteByte('\n')
+	}
+}
+
+// moveTo 纵坐标变化视为换行，同一行内的移动视为空格
+func (w *pdfTextWriter) moveTo(y float64) {
+	if w.hasY && y != w.lastY {
+		w.newline()
+	} else {
+		w.space()
+	}
+	w.lastY, w.hasY = y, true
+}
+
+func (d *pdfDocument) pageText(page pdfPage) string {
+	var (
+		content = d.pageContent(page)
+		fonts   = d.fonts(page.resources)
+		font    *pdfFont
+		w       pdfTextWriter
+		stack   []any
+		l       = newPDFLexer(content)
+		lineY   float64
+	)
+	for {
+		v, ok := l.next()
+		if !ok {
+			break
+		}
+		op, isOp := v.(pdfOperator)
+		if !isOp {
+			stack = append(stack, v)
+			continue
+		}
+
+		num := func(i int) float64 {
+			if i < len(stack) {
+				f, _ := stack[i].(float64)
+				return f
+			}
+			return 0
+		}
+
+		switch op {
+		case "BT":
+			lineY = 0
+		case "Tf":
+			if len(stack) > 0 {
+				if name, ok := stack[0].(pdfName); ok {
+					font = fonts[name]
+				}
+			}
+		case "Td", "TD":
+			if len(stack) >= 2 {
+				lineY += num(1)
+				if num(1) != 0 {
+					w.newline()
+				} else if num(0) != 0 {
+					w.space()
+				}
+				w.lastY, w.hasY = lineY, true
+			}
+		case "Tm":
+			if len(stack) >= 6 {
+				lineY = num(5)
+				w.moveTo(lineY)
+			}
+		case "T*":
+			w.newline()
+		case "Tj":
+			if len(stack) > 0 {
+				if s, ok := stack[len(stack)-1].(pdfString); ok {
+					w.write(font.decode(s))
+				}
+			}
+		case "'", "\"":
+			w.newline()
+			if len(stack) > 0 {
+				if s, ok := stack[len(stack)-1].(pdfString); ok {
+					w.write(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(stack) > 0 {
+				arr, _ := stack[len(stack)-1].(pdfArray)
+				for _, item := range arr {
+					switch item := item.(type) {
+					case pdfString:
+						w.write(font.decode(item))
+					case float64:
+						// 较大的负间距通常是单词之间的空格
+						if item < -200 {
+							w.space()
+						}
+					}
+				}
+			}
+		case "ET":
+			w.space()
+		case "BI":
+			// 跳过内嵌图片数据
+			if idx := bytes.Index(content[l.pos:], []byte("EI")); idx >= 0 {
+				l.pos += idx + 2
+			}
+		}
+		stack = stack[:0]
+	}
+	return w.sb.String()
+}
+
+func (d *pdfDocument) title() string {
+	info := d.dict(d.trailer["Info"])
+	if s, ok := d.resolve(info["Title"]).(pdfString); ok {
+		return strings.TrimSpace(decodePDFText(s))
+	}
+	return ""
+}
+
+// PDF 提取 pdf 中的文本，每页之间空一行
+// 不支持扫描件等没有文本层的文档，也不支持加密的文档
+func PDF(data []byte) (*Result, error) {
+	doc, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []string
+	for _, page := range doc.pages() {
+		if text := normalizeLines(doc.pageText(page)); text != "" {
+			pages = append(pages, text)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, ErrEmptyContent
+	}
+
+	return &Result{
+		Title:   doc.title(),
+		Content: strings.Join(pages, "\n\n"),
+	}, nil
+}
@@ -0,0 +1,288 @@
+package extract
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// pdf 对象的最小实现，仅用于提取文本，不支持加密的文档
+
+type (
+	pdfName   string
+	pdfString []byte
+	pdfDict   map[pdfName]any
+	pdfArray  []any
+	pdfRef    struct{ num int }
+	// pdfOperator 内容流中的操作符，例如 Tj、BT
+	pdfOperator string
+)
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func newPDFLexer(data []byte) *pdfLexer {
+	return &pdfLexer{data: data}
+}
+
+func isPDFSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	}
+	return false
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *pdfLexer) regular() []byte {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+// next 读取下一个值，数组与字典会被完整读取，ok 为 false 表示已经读完
+func (l *pdfLexer) next() (any, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+
+	switch c := l.data[l.pos]; c {
+	case '/':
+		l.pos++
+		return pdfName(decodeName(l.regular())), true
+	case '(':
+		l.pos++
+		return l.literalString(), true
+	case '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return l.dict(), true
+		}
+		l.pos++
+		return l.hexString(), true
+	case '[':
+		l.pos++
+		var arr pdfArray
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return arr, true
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return arr, true
+			}
+			v, ok := l.next()
+			if !ok {
+				return arr, true
+			}
+			arr = append(arr, l.maybeRef(v))
+		}
+	case ']', '>', ')', '{', '}':
+		// 不匹配的分隔符直接跳过
+		l.pos++
+		return pdfOperator(string(c)), true
+	}
+
+	word := l.regular()
+	if len(word) == 0 {
+		l.pos++
+		return l.next()
+	}
+	switch string(word) {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	case "null":
+		return nil, true
+	}
+	if f, err := strconv.ParseFloat(string(word), 64); err == nil {
+		return f, true
+	}
+	return pdfOperator(word), true
+}
+
+// maybeRef 将 "num gen R" 合并为引用
+func (l *pdfLexer) maybeRef(v any) any {
+	num, ok := v.(float64)
+	if !ok {
+		return v
+	}
+	pos := l.pos
+	gen, ok := l.next()
+	if _, isNum := gen.(float64); ok && isNum {
+		if op, ok := l.next(); ok && op == pdfOperator("R") {
+			return pdfRef{num: int(num)}
+		}
+	}
+	l.pos = pos
+	return v
+}
+
+func (l *pdfLexer) dict() pdfDict {
+	d := pdfDict{}
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return d
+		}
+		if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+			l.pos += 2
+			return d
+		}
+		key, ok := l.next()
+		if !ok {
+			return d
+		}
+		name, isName := key.(pdfName)
+		if !isName {
+			continue
+		}
+		v, ok := l.next()
+		if !ok {
+			return d
+		}
+		d[name] = l.maybeRef(v)
+	}
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	var (
+		buf   []byte
+		depth = 1
+	)
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return buf
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return buf
+			}
+			c = l.data[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if c >= '0' && c <= '7' {
+					n := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					buf = append(buf, byte(n))
+				} else {
+					buf = append(buf, c)
+				}
+			}
+			continue
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	var (
+		buf  []byte
+		half = -1
+	)
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			break
+		}
+		v := unhex(c)
+		if v < 0 {
+			continue
+		}
+		if half < 0 {
+			half = v
+			continue
+		}
+		buf = append(buf, byte(half<<4|v))
+		half = -1
+	}
+	if half >= 0 {
+		buf = append(buf, byte(half<<4))
+	}
+	return buf
+}
+
+func unhex(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// decodeName 处理名称中的 #xx 转义
+func decodeName(b []byte) string {
+	if bytes.IndexByte(b, '#') < 0 {
+		return string(b)
+	}
+	var res []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) && unhex(b[i+1]) >= 0 && unhex(b[i+2]) >= 0 {
+			res = append(res, byte(unhex(b[i+1])<<4|unhex(b[i+2])))
+			i += 2
+			continue
+		}
+		res = append(res, b[i])
+	}
+	return string(res)
+}
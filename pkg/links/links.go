@@ -0,0 +1,150 @@
+// Package links 解析知识点内容中指向其他知识点的 knowledge:// 链接
+package links
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+// Link 内容中指向其他知识点的链接
+type Link struct {
+	ID   string // 被链接的知识点ID
+	Text string // 链接文字
+}
+
+var (
+	// knowledgeURL knowledge://{id}，id 之后可以带有 #、? 等后缀
+	knowledgeURL = regexp.MustCompile(`knowledge://([0-9A-Za-z]{1,32})`)
+	// markdownLink [text](knowledge://id)、<knowledge://id>
+	markdownLink = regexp.MustCompile(`\[([^\]\n]*)\]\(\s*<?knowledge://([0-9A-Za-z]{1,32})[^)\s]*>?(?:\s+"[^"\n]*")?\s*\)|<knowledge://([0-9A-Za-z]{1,32})[^>\s]*>`)
+	// htmlLink EditorJS 行内链接 <a href="knowledge://id">text</a>
+	htmlLink = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']knowledge://([0-9A-Za-z]{1,32})[^"']*["'][^>]*>(.*?)</a>`)
+	htmlTag  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ParseURL 解析 knowledge:// 链接中的知识点ID
+func ParseURL(u string) (string, bool) {
+	u = strings.TrimSpace(u)
+	if !strings.HasPrefix(u, types.KNOWLEDGE_LINK_PREFIX) {
+		return "", false
+	}
+	m := knowledgeURL.FindStringSubmatch(u)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// Extract 按照内容格式提取链接，同一知识点只保留第一次出现的链接
+func Extract(contentType types.KnowledgeContentType, content []byte) []Link {
+	var list []Link
+	if contentType == types.KNOWLEDGE_CONTENT_TYPE_BLOCKS {
+		list = extractBlocks(content)
+	} else {
+		list = extractMarkdown(string(content))
+	}
+
+	var (
+		exist = make(map[string]bool, len(list))
+		res   []Link
+	)
+	for _, v := range list {
+		if exist[v.ID] {
+			continue
+		}
+		exist[v.ID] = true
+		res = append(res, v)
+	}
+	return res
+}
+
+func extractMarkdown(s string) []Link {
+	var list []Link
+	for _, m := range markdownLink.FindAllStringSubmatch(s, -1) {
+		if m[2] != "" {
+			list = append(list, Link{ID: m[2], Text: strings.TrimSpace(m[1])})
+		} else {
+			list = append(list, Link{ID: m[3]})
+		}
+	}
+	return append(list, extractHTML(s)...)
+}
+
+func extractHTML(s string) []Link {
+	var list []Link
+	for _, m := range htmlLink.FindAllStringSubmatch(s, -1) {
+		list = append(list, Link{ID: m[1], Text: strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(m[2], "")))})
+	}
+	return list
+}
+
+// extractBlocks 遍历 EditorJS 各个 block 的数据，行内文本中的链接为 html，
+// link 工具(linkTool)的数据为 {"link": "knowledge://id", "meta": {"title": "..."}}
+func extractBlocks(content []byte) []Link {
+	var doc struct {
+		Blocks []struct {
+			Type string `json:"type"`
+			Data any    `json:"data"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil
+	}
+
+	var list []Link
+	for _, b := range doc.Blocks {
+		walk(b.Data, func(v map[string]any) {
+			link, _ := v["link"].(string)
+			id, ok := ParseURL(link)
+			if !ok {
+				return
+			}
+			item := Link{ID: id}
+			if meta, ok := v["meta"].(map[string]any); ok {
+				item.Text, _ = meta["title"].(string)
+			}
+			list = append(list, item)
+		}, func(s string) {
+			list = append(list, extractHTML(s)...)
+		})
+	}
+	return list
+}
+
+// walk 按 key 的顺序遍历对象，保证同一内容提取的链接顺序一致
+func walk(v any, onObject func(map[string]any), onString func(string)) {
+	switch v := v.(type) {
+	case map[string]any:
+		onObject(v)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walk(v[k], onObject, onString)
+		}
+	case []any:
+		for _, item := range v {
+			walk(item, onObject, onString)
+		}
+	case string:
+		onString(v)
+	}
+}
+
+// ReplaceIDs 替换内容中 knowledge:// 链接的知识点ID，replace 返回空字符串时保持不变
+func ReplaceIDs(content string, replace func(id string) string) string {
+	return knowledgeURL.ReplaceAllStringFunc(content, func(m string) string {
+		id := strings.TrimPrefix(m, types.KNOWLEDGE_LINK_PREFIX)
+		if newID := replace(id); newID != "" {
+			return types.KnowledgeLinkURL(newID)
+		}
+		return m
+	})
+}
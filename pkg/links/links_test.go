@@ -0,0 +1,70 @@
+package links
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/breeew/brew-api/pkg/types"
+)
+
+func Test_ParseURL(t *testing.T) {
+	id, ok := ParseURL("knowledge://abc123#heading")
+	assert.True(t, ok)
+	assert.Equal(t, "abc123", id)
+
+	_, ok = ParseURL("https://example.com/knowledge://abc")
+	assert.False(t, ok)
+	_, ok = ParseURL("knowledge://")
+	assert.False(t, ok)
+}
+
+func Test_Extract_Markdown(t *testing.T) {
+	content := "See [Brew](knowledge://k1) and [again](knowledge://k1 \"title\").\n" +
+		"Autolink <knowledge://k2>, web [link](https://example.com), html <a href=\"knowledge://k3\"><b>Three</b> &amp; more</a>"
+
+	assert.Equal(t, []Link{
+		{ID: "k1", Text: "Brew"},
+		{ID: "k2"},
+		{ID: "k3", Text: "Three & more"},
+	}, Extract(types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN, []byte(content)))
+
+	assert.Nil(t, Extract(types.KNOWLEDGE_CONTENT_TYPE_MARKDOWN, []byte("no links")))
+}
+
+func Test_Extract_Blocks(t *testing.T) {
+	content := `{"blocks":[
+		{"type":"paragraph","data":{"text":"Go to <a href=\"knowledge://k1\">One</a>"}},
+		{"type":"linkTool","data":{"link":"knowledge://k2","meta":{"title":"Two"}}},
+		{"type":"list","data":{"items":[{"content":"<a href='knowledge://k3'>Three</a>","items":[]}]}},
+		{"type":"linkTool","data":{"link":"https://example.com","meta":{"title":"Web"}}}
+	]}`
+
+	assert.Equal(t, []Link{
+		{ID: "k1", Text: "One"},
+		{ID: "k2", Text: "Two"},
+		{ID: "k3", Text: "Three"},
+	}, Extract(types.KNOWLEDGE_CONTENT_TYPE_BLOCKS, []byte(content)))
+
+	assert.Nil(t, Extract(types.KNOWLEDGE_CONTENT_TYPE_BLOCKS, []byte("invalid")))
+
+	// 同一个 block 中的多个链接按 key 的顺序返回
+	content = `{"blocks":[{"type":"table","data":{"c":"<a href=\"knowledge://k3\">C</a>","a":"<a href=\"knowledge://k1\">A</a>","b":"<a href=\"knowledge://k2\">B</a>"}}]}`
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, []Link{
+			{ID: "k1", Text: "A"},
+			{ID: "k2", Text: "B"},
+			{ID: "k3", Text: "C"},
+		}, Extract(types.KNOWLEDGE_CONTENT_TYPE_BLOCKS, []byte(content)))
+	}
+}
+
+func Test_ReplaceIDs(t *testing.T) {
+	got := ReplaceIDs("[a](knowledge://old1) [b](knowledge://old2#x)", func(id string) string {
+		if id == "old1" {
+			return "new1"
+		}
+		return ""
+	})
+	assert.Equal(t, "[a](knowledge://new1) [b](knowledge://old2#x)", got)
+}
@@ -0,0 +1,287 @@
+// Package notes 解析 Obsidian vault、Notion 导出或普通 markdown 目录打包的 zip 文件
+package notes
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// MAX_NOTE_SIZE 单篇笔记的最大长度，超出的笔记将被忽略
+	MAX_NOTE_SIZE = 5 << 20
+	// MAX_ATTACHMENT_SIZE 单个附件的最大长度，与上传文件的限制一致
+	MAX_ATTACHMENT_SIZE = 30 << 20
+	// MAX_VAULT_SIZE 上传的 zip 及其中笔记、附件解压后的最大总长度
+	MAX_VAULT_SIZE = 512 << 20
+)
+
+var ErrTooLarge = errors.New("vault too large")
+
+type Note struct {
+	Path    string   // zip 内的路径
+	Folder  string   // 所在目录，已去除 Notion 的 id 后缀，根目录为空
+	Title   string   // front-matter 中的 title，没有时使用文件名
+	Tags    []string // front-matter 中的 tags
+	Aliases []string // front-matter 中的 aliases，用于解析 wikilink
+	Content string   // 去除 front-matter 后的正文
+}
+
+type Attachment struct {
+	Path string
+	file *zip.File
+}
+
+// Read 读取附件内容，附件只在被引用时读取
+func (a *Attachment) Read() ([]byte, error) {
+	return readFile(a.file)
+}
+
+func (a *Attachment) IsImage() bool {
+	switch strings.ToLower(path.Ext(a.Path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".bmp":
+		return true
+	}
+	return false
+}
+
+type Vault struct {
+	Notes       []*Note
+	attachments map[string]*Attachment // path -> attachment
+	notePaths   map[string]*Note       // path -> note
+	noteNames   map[string]*Note       // 小写的笔记名称、别名及不含扩展名的路径 -> note
+	fileNames   map[string]*Attachment // 小写的附件文件名 -> attachment
+}
+
+// Open 读取 zip 中的 markdown 笔记及附件，忽略隐藏目录(例如 .obsidian)
+func Open(r io.ReaderAt, size int64) (*Vault, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip file: %w", err)
+	}
+
+	v := &Vault{
+		attachments: make(map[string]*Attachment),
+		notePaths:   make(map[string]*Note),
+		noteNames:   make(map[string]*Note),
+		fileNames:   make(map[string]*Attachment),
+	}
+	var total uint64
+	for _, f := range zr.File {
+		name := path.Clean(strings.TrimPrefix(strings.ReplaceAll(f.Name, "\\", "/"), "/"))
+		if f.FileInfo().IsDir() || isHidden(name) {
+			continue
+		}
+		// 被忽略的过大文件不计入总长度
+		if f.UncompressedSize64 <= MAX_ATTACHMENT_SIZE {
+			if total += f.UncompressedSize64; total > MAX_VAULT_SIZE {
+				return nil, ErrTooLarge
+			}
+		}
+
+		switch strings.ToLower(path.Ext(name)) {
+		case ".md", ".markdown":
+			if f.UncompressedSize64 > MAX_NOTE_SIZE {
+				continue
+			}
+			raw, err := readFile(f)
+			if err != nil {
+				return nil, err
+			}
+			v.addNote(name, raw)
+		default:
+			if f.UncompressedSize64 > MAX_ATTACHMENT_SIZE {
+				continue
+			}
+			v.attachments[name] = &Attachment{Path: name, file: f}
+		}
+	}
+
+	// 同名文件优先匹配路径较短的，与 Obsidian 的解析方式一致
+	sort.Slice(v.Notes, func(i, j int) bool {
+		if len(v.Notes[i].Path) != len(v.Notes[j].Path) {
+			return len(v.Notes[i].Path) < len(v.Notes[j].Path)
+		}
+		return v.Notes[i].Path < v.Notes[j].Path
+	})
+	for _, n := range v.Notes {
+		keys := append([]string{noteName(n.Path), strings.TrimSuffix(n.Path, path.Ext(n.Path)), n.Title}, n.Aliases...)
+		for _, key := range keys {
+			key = strings.ToLower(key)
+			if _, exist := v.noteNames[key]; !exist && key != "" {
+				v.noteNames[key] = n
+			}
+		}
+	}
+	attachments := make([]string, 0, len(v.attachments))
+	for p := range v.attachments {
+		attachments = append(attachments, p)
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		if len(attachments[i]) != len(attachments[j]) {
+			return len(attachments[i]) < len(attachments[j])
+		}
+		return attachments[i] < attachments[j]
+	})
+	for _, p := range attachments {
+		key := strings.ToLower(path.Base(p))
+		if _, exist := v.fileNames[key]; !exist {
+			v.fileNames[key] = v.attachments[p]
+		}
+	}
+	return v, nil
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	// 读取长度不超过声明的长度
+	return io.ReadAll(io.LimitReader(rc, int64(f.UncompressedSize64)))
+}
+
+func isHidden(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// notionID Notion 导出的文件及目录名称以空格加 32 位 id 结尾
+var notionID = regexp.MustCompile(`\s+[0-9a-f]{32}$`)
+
+func stripNotionID(name string) string {
+	return notionID.ReplaceAllString(name, "")
+}
+
+// noteName 去除扩展名及 Notion id 后的文件名
+func noteName(p string) string {
+	base := path.Base(p)
+	return stripNotionID(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+func (v *Vault) addNote(p string, raw []byte) {
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))
+	meta, content := splitFrontMatter(string(raw))
+
+	n := &Note{
+		Path:    p,
+		Title:   noteName(p),
+		Content: strings.TrimSpace(content),
+	}
+	if dir := path.Dir(p); dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			parts[i] = stripNotionID(parts[i])
+		}
+		n.Folder = strings.Join(parts, "/")
+	}
+	if title, ok := meta["title"].(string); ok && strings.TrimSpace(title) != "" {
+		n.Title = strings.TrimSpace(title)
+	}
+	n.Tags = stringList(meta["tags"], true)
+	if len(n.Tags) == 0 {
+		n.Tags = stringList(meta["tag"], true)
+	}
+	n.Aliases = stringList(meta["aliases"], false)
+
+	v.Notes = append(v.Notes, n)
+	v.notePaths[p] = n
+}
+
+// splitFrontMatter 拆分 yaml front-matter 与正文，front-matter 解析失败时作为正文保留
+func splitFrontMatter(s string) (map[string]any, string) {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if !strings.HasPrefix(s, "---\n") {
+		return nil, s
+	}
+	end := strings.Index(s[4:], "\n---")
+	if end < 0 {
+		return nil, s
+	}
+	rest := s[4+end+4:]
+	if rest != "" && rest[0] != '\n' {
+		return nil, s
+	}
+
+	meta := make(map[string]any)
+	if err := yaml.Unmarshal([]byte(s[4:4+end]), &meta); err != nil {
+		return nil, s
+	}
+	return meta, rest
+}
+
+// stringList 将 front-matter 中的列表或以逗号、空格分隔的字符串转换为字符串列表
+func stringList(v any, isTag bool) []string {
+	var list []string
+	switch v := v.(type) {
+	case string:
+		if isTag {
+			list = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+		} else {
+			list = strings.Split(v, ",")
+		}
+	case []any:
+		for _, item := range v {
+			if item != nil {
+				list = append(list, fmt.Sprint(item))
+			}
+		}
+	}
+
+	var res []string
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if isTag {
+			item = strings.TrimPrefix(item, "#")
+		}
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// ResolveNote 按照相对路径、名称或别名查找笔记，from 为链接所在的笔记
+func (v *Vault) ResolveNote(from *Note, target string) *Note {
+	for _, p := range []string{path.Join(path.Dir(from.Path), target), path.Clean(target)} {
+		if n := v.notePaths[p]; n != nil {
+			return n
+		}
+		if n := v.notePaths[p+".md"]; n != nil {
+			return n
+		}
+	}
+
+	name := target
+	if ext := strings.ToLower(path.Ext(name)); ext == ".md" || ext == ".markdown" {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	if n := v.noteNames[strings.ToLower(name)]; n != nil {
+		return n
+	}
+	return v.noteNames[strings.ToLower(stripNotionID(path.Base(name)))]
+}
+
+// ResolveAttachment 按照相对路径或文件名查找附件
+func (v *Vault) ResolveAttachment(from *Note, target string) *Attachment {
+	if a := v.attachments[path.Join(path.Dir(from.Path), target)]; a != nil {
+		return a
+	}
+	if a := v.attachments[path.Clean(target)]; a != nil {
+		return a
+	}
+	return v.fileNames[strings.ToLower(path.Base(target))]
+}
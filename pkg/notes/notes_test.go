@@ -0,0 +1,117 @@
+package notes
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildVault(t *testing.T, files map[string]string) *Vault {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		assert.NoError(t, err)
+		f.Write([]byte(content))
+	}
+	assert.NoError(t, zw.Close())
+
+	v, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	return v
+}
+
+func findNote(v *Vault, p string) *Note {
+	for _, n := range v.Notes {
+		if n.Path == p {
+			return n
+		}
+	}
+	return nil
+}
+
+func Test_Open(t *testing.T) {
+	v := buildVault(t, map[string]string{
+		"Home.md":          "---\ntitle: Welcome\ntags: [daily, \"#work\"]\naliases: index\n---\n\nHello",
+		"Projects/Brew.md": "---\ntags: go, ai\n---\nBrew notes",
+		"Export 0123456789abcdef0123456789abcdef/Page 0123456789abcdef0123456789abcdef.md": "# Page",
+		"Broken.md":              "---\ntitle: [unclosed\n---\nbody",
+		".obsidian/workspace.md": "ignored",
+		"__MACOSX/Home.md":       "ignored",
+		"assets/cat.png":         "png",
+	})
+
+	assert.Len(t, v.Notes, 4)
+
+	home := findNote(v, "Home.md")
+	assert.Equal(t, "Welcome", home.Title)
+	assert.Equal(t, []string{"daily", "work"}, home.Tags)
+	assert.Equal(t, []string{"index"}, home.Aliases)
+	assert.Equal(t, "Hello", home.Content)
+	assert.Equal(t, "", home.Folder)
+
+	brew := findNote(v, "Projects/Brew.md")
+	assert.Equal(t, "Brew", brew.Title)
+	assert.Equal(t, []string{"go", "ai"}, brew.Tags)
+	assert.Equal(t, "Projects", brew.Folder)
+
+	page := findNote(v, "Export 0123456789abcdef0123456789abcdef/Page 0123456789abcdef0123456789abcdef.md")
+	assert.Equal(t, "Page", page.Title)
+	assert.Equal(t, "Export", page.Folder)
+
+	// front-matter 解析失败时作为正文保留
+	broken := findNote(v, "Broken.md")
+	assert.Equal(t, "Broken", broken.Title)
+	assert.Contains(t, broken.Content, "title: [unclosed")
+
+	assert.Equal(t, home, v.ResolveNote(brew, "index"))
+	assert.Equal(t, brew, v.ResolveNote(home, "Projects/Brew.md"))
+	assert.Equal(t, page, v.ResolveNote(home, "page"))
+	assert.NotNil(t, v.ResolveAttachment(home, "cat.png"))
+}
+
+func Test_Open_Invalid(t *testing.T) {
+	_, err := Open(bytes.NewReader([]byte("not a zip")), 9)
+	assert.Error(t, err)
+
+	// 附件声明的解压长度合计超出限制
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for i := 0; i <= MAX_VAULT_SIZE/MAX_ATTACHMENT_SIZE; i++ {
+		zw.CreateRaw(&zip.FileHeader{Name: fmt.Sprintf("img%d.png", i), Method: zip.Deflate, UncompressedSize64: MAX_ATTACHMENT_SIZE})
+	}
+	assert.NoError(t, zw.Close())
+	_, err = Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func Test_Rewrite(t *testing.T) {
+	v := buildVault(t, map[string]string{
+		"Home.md":          "See [[Brew]], [[Brew|the project]] and [[Brew#Roadmap]].\n[Relative](Projects/Brew.md) [Missing](Nope.md) [Web](https://example.com)\n![[cat.png|300]] ![alt](assets/cat.png) [[Unknown]]\n`[[Brew]]`\n```\n[[Brew]]\n```",
+		"Projects/Brew.md": "Back to [[Home]]",
+		"assets/cat.png":   "png",
+	})
+
+	rw := Rewriter{
+		Link: func(target *Note) string {
+			return "knowledge://" + target.Title
+		},
+		Image: func(a *Attachment) string {
+			data, err := a.Read()
+			assert.NoError(t, err)
+			assert.Equal(t, "png", string(data))
+			return "https://static/" + a.Path
+		},
+	}
+
+	got := v.Rewrite(findNote(v, "Home.md"), rw)
+	assert.Equal(t, "See [Brew](knowledge://Brew), [the project](knowledge://Brew) and [Brew](knowledge://Brew).\n"+
+		"[Relative](knowledge://Brew) Missing [Web](https://example.com)\n"+
+		"![](https://static/assets/cat.png) ![alt](https://static/assets/cat.png) Unknown\n"+
+		"`[[Brew]]`\n```\n[[Brew]]\n```", got)
+
+	assert.Equal(t, "Back to [Home](knowledge://Home)", v.Rewrite(findNote(v, "Projects/Brew.md"), rw))
+}
@@ -0,0 +1,142 @@
+package notes
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// wikiLink [[note]]、[[note|alias]]、[[note#heading]] 及嵌入 ![[image.png]]
+	wikiLink = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+?)\]\]`)
+	// markdownLink [text](path) 及 ![alt](path)，路径中包含空格时使用 <path>
+	markdownLink = regexp.MustCompile(`(!?)\[([^\]\n]*)\]\(\s*(<[^>\n]+>|[^)\s]+)(\s+"[^"\n]*")?\s*\)`)
+	// imageSize Obsidian 中 ![[image.png|300]] 的 300 表示图片宽度
+	imageSize = regexp.MustCompile(`^\d+(x\d+)?$`)
+)
+
+// Rewriter 返回笔记与图片在导入后的地址，返回空字符串时视为无法解析
+type Rewriter struct {
+	Link  func(target *Note) string
+	Image func(a *Attachment) string
+}
+
+// Rewrite 将正文中的 wikilink、笔记之间的相对链接替换为 markdown 链接，并替换图片的地址
+// 无法解析的 wikilink 替换为纯文本，代码块中的内容保持不变
+func (v *Vault) Rewrite(n *Note, rw Rewriter) string {
+	lines := strings.Split(n.Content, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+
+		// 奇数段位于行内代码中
+		parts := strings.Split(line, "`")
+		for j := 0; j < len(parts); j += 2 {
+			parts[j] = v.rewriteText(n, parts[j], rw)
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (v *Vault) rewriteText(n *Note, s string, rw Rewriter) string {
+	// 先替换 markdown 链接，避免替换后的 wikilink 被再次解析
+	s = markdownLink.ReplaceAllStringFunc(s, func(m string) string {
+		sub := markdownLink.FindStringSubmatch(m)
+		target := strings.TrimSuffix(strings.TrimPrefix(sub[3], "<"), ">")
+		if isExternal(target) {
+			return m
+		}
+		if unescaped, err := url.PathUnescape(target); err == nil {
+			target = unescaped
+		}
+		if i := strings.IndexAny(target, "#?"); i >= 0 {
+			target = target[:i]
+		}
+		if target == "" {
+			return m
+		}
+
+		if sub[1] == "!" {
+			if a := v.ResolveAttachment(n, target); a != nil && a.IsImage() {
+				if u := rw.Image(a); u != "" {
+					return "![" + sub[2] + "](" + escapeURL(u) + ")"
+				}
+			}
+			return m
+		}
+
+		ext := strings.ToLower(path.Ext(target))
+		if ext != ".md" && ext != ".markdown" {
+			return m
+		}
+		if target := v.ResolveNote(n, target); target != nil {
+			if u := rw.Link(target); u != "" {
+				return "[" + sub[2] + "](" + escapeURL(u) + ")"
+			}
+		}
+		return sub[2]
+	})
+
+	return wikiLink.ReplaceAllStringFunc(s, func(m string) string {
+		sub := wikiLink.FindStringSubmatch(m)
+		embed := sub[1] == "!"
+		target, alias, _ := strings.Cut(sub[2], "|")
+		target, heading, _ := strings.Cut(strings.TrimSpace(target), "#")
+		alias = strings.TrimSpace(alias)
+
+		text := alias
+		if text == "" {
+			text = target
+			if text == "" {
+				text = heading
+			}
+		}
+		if target == "" {
+			return text
+		}
+
+		if a := v.ResolveAttachment(n, target); a != nil && embed && a.IsImage() {
+			if imageSize.MatchString(alias) {
+				alias = ""
+			}
+			if u := rw.Image(a); u != "" {
+				return "![" + alias + "](" + escapeURL(u) + ")"
+			}
+			return text
+		}
+		if target := v.ResolveNote(n, target); target != nil {
+			if u := rw.Link(target); u != "" {
+				if alias == "" && heading == "" {
+					text = target.Title
+				}
+				return "[" + text + "](" + escapeURL(u) + ")"
+			}
+		}
+		return text
+	})
+}
+
+func isExternal(target string) bool {
+	if strings.HasPrefix(target, "#") || strings.HasPrefix(target, "mailto:") {
+		return true
+	}
+	u, err := url.Parse(target)
+	return err == nil && u.Scheme != ""
+}
+
+// escapeURL markdown 链接地址中不能包含空格与括号
+func escapeURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u)
+}
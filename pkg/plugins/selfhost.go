@@ -14,17 +14,19 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/breeew/brew-api/app/core"
+	"github.com/breeew/brew-api/app/core/srv"
 	v1 "github.com/breeew/brew-api/app/logic/v1"
 	"github.com/breeew/brew-api/pkg/ai"
 	"github.com/breeew/brew-api/pkg/mark"
 	"github.com/breeew/brew-api/pkg/safe"
+	"github.com/breeew/brew-api/pkg/search"
 	"github.com/breeew/brew-api/pkg/types"
 	"github.com/breeew/brew-api/pkg/utils"
 )
 
 func NewSingleLock() *SingleLock {
 	return &SingleLock{
-		locks: make(map[string]bool),
+		locks: make(map[string]context.Context),
 	}
 }
 
@@ -33,22 +35,26 @@ type SelfHostCustomConfig struct {
 	EncryptKey    string              `toml:"encrypt_key"`
 }
 
+// SingleLock 进程内的互斥锁，锁在加锁时传入的 ctx 结束后释放
 type SingleLock struct {
 	mu    sync.Mutex
-	locks map[string]bool
+	locks map[string]context.Context
 }
 
+// TryLock ctx 结束即视为已释放，调用方 cancel 之后可以立即重新加锁，不需要等待清理协程
 func (s *SingleLock) TryLock(ctx context.Context, key string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.locks[key] {
+	if holder, exist := s.locks[key]; exist && holder.Err() == nil {
 		return false, nil
 	}
+	s.locks[key] = ctx
 	go safe.Run(func() {
-		select {
-		case <-ctx.Done():
-			s.mu.Lock()
-			defer s.mu.Unlock()
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// 锁可能已被其他 ctx 重新持有
+		if s.locks[key] == ctx {
 			delete(s.locks, key)
 		}
 	})
@@ -260,6 +266,19 @@ func (s *SelfHostPlugin) DecryptData(data []byte) ([]byte, error) {
 	return utils.DecryptCFB(data, []byte(s.customConfig.EncryptKey))
 }
 
+// EncryptKeywords 将分词结果转换为全文索引使用的 lexeme，写入与查询时需要保持一致
+func (s *SelfHostPlugin) EncryptKeywords(tokens []string) []string {
+	return search.HashTokens(tokens, []byte(s.customConfig.EncryptKey))
+}
+
+// HashContent 与 EncryptKeywords 使用相同的密钥，避免内容哈希泄露明文
+func (s *SelfHostPlugin) HashContent(text string) string {
+	if text == "" {
+		return ""
+	}
+	return utils.HMACSHA256(text, []byte(s.customConfig.EncryptKey))
+}
+
 func (s *SelfHostPlugin) DeleteSpace(ctx context.Context, spaceID string) error {
 	return nil
 }
@@ -303,6 +322,49 @@ func (s *SelfHostPlugin) AppendKnowledgeContentToDocs(docs []*types.PassageInfo,
 	return docs, nil
 }
 
-func (s *SelfHostPlugin) Rerank(query string, knowledges []*types.Knowledge) ([]*types.Knowledge, *ai.Usage, error) {
-	return knowledges, nil, nil
+// RERANK_DOC_MAX_RUNES 提交给重排模型的单个知识点的最大长度
+const RERANK_DOC_MAX_RUNES = 4000
+
+// Rerank 使用 usage.rerank 指定的驱动重排知识点，保留得分不低于 ai.rerank.min_score 的前 ai.rerank.top_k 个
+func (s *SelfHostPlugin) Rerank(query string, knowledges []*types.Knowledge) ([]*types.Knowledge, []ai.RankDocItem, *ai.Usage, error) {
+	if len(knowledges) == 0 {
+		return knowledges, nil, nil, nil
+	}
+
+	// 重排服务可能是第三方服务，与提交给对话模型的内容一样需要先遮盖敏感信息
+	docs := lo.Map(knowledges, func(item *types.Knowledge, _ int) *ai.RerankDoc {
+		content := []rune(mark.NewSensitiveWork().Do(item.Title + "\n" + string(item.Content)))
+		if len(content) > RERANK_DOC_MAX_RUNES {
+			content = content[:RERANK_DOC_MAX_RUNES]
+		}
+		return &ai.RerankDoc{
+			ID:      item.ID,
+			Content: string(content),
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	ranked, usage, err := s.core.Srv().AI().Rerank(ctx, query, docs)
+	if err == srv.ERROR_UNSUPPORTED_FEATURE {
+		// 未配置重排驱动，保持检索顺序
+		return knowledges, nil, nil, nil
+	}
+	if err != nil {
+		return knowledges, nil, nil, err
+	}
+
+	cfg := s.core.Cfg().AI.Rerank
+	ranked = ai.FilterRankDocs(ranked, cfg.TopK, cfg.MinScore)
+
+	knowledgeMap := lo.SliceToMap(knowledges, func(item *types.Knowledge) (string, *types.Knowledge) {
+		return item.ID, item
+	})
+	res := make([]*types.Knowledge, 0, len(ranked))
+	for _, v := range ranked {
+		if k, exist := knowledgeMap[v.ID]; exist {
+			res = append(res, k)
+		}
+	}
+	return res, ranked, usage, nil
 }
@@ -0,0 +1,44 @@
+package plugins
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SingleLock(t *testing.T) {
+	l := NewSingleLock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ok, err := l.TryLock(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// 持有期间同一个 key 无法重复加锁，其他 key 不受影响
+	ok, _ = l.TryLock(context.Background(), "a")
+	assert.False(t, ok)
+	other, cancelOther := context.WithCancel(context.Background())
+	defer cancelOther()
+	ok, _ = l.TryLock(other, "b")
+	assert.True(t, ok)
+
+	// cancel 后立即可以重新加锁
+	cancel()
+	again, cancelAgain := context.WithCancel(context.Background())
+	ok, _ = l.TryLock(again, "a")
+	assert.True(t, ok)
+
+	// 旧 ctx 的清理不会释放新持有的锁
+	time.Sleep(10 * time.Millisecond)
+	ok, _ = l.TryLock(context.Background(), "a")
+	assert.False(t, ok)
+
+	cancelAgain()
+	assert.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return len(l.locks) == 1
+	}, time.Second, time.Millisecond)
+}
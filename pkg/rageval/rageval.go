@@ -0,0 +1,246 @@
+// Package rageval 离线评估 RAG 的检索与生成效果
+// 数据集为 JSONL，每行一个问题，包含期望召回的知识点ID 和/或参考答案
+package rageval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/breeew/brew-api/pkg/search"
+)
+
+var DefaultKs = []int{1, 3, 5, 10}
+
+type Case struct {
+	ID              string   `json:"id"`
+	Question        string   `json:"question"`
+	ExpectedIDs     []string `json:"expected_ids"`
+	ReferenceAnswer string   `json:"reference_answer"`
+}
+
+// LoadCases 读取 JSONL 格式的数据集，忽略空行，id 为空时使用行号
+func LoadCases(r io.Reader) ([]Case, error) {
+	var (
+		cases   []Case
+		line    int
+		scanner = bufio.NewScanner(r)
+	)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var c Case
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.TrimSpace(c.Question) == "" {
+			return nil, fmt.Errorf("line %d: question is empty", line)
+		}
+		if len(c.ExpectedIDs) == 0 && c.ReferenceAnswer == "" {
+			return nil, fmt.Errorf("line %d: expected_ids or reference_answer is required", line)
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("%d", line)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// RecallAtK 前 k 个检索结果中命中的期望知识点占全部期望知识点的比例
+func RecallAtK(retrieved, expected []string, k int) float64 {
+	if len(expected) == 0 {
+		return 0
+	}
+	if k < len(retrieved) {
+		retrieved = retrieved[:k]
+	}
+	hit := 0
+	for _, id := range expected {
+		for _, v := range retrieved {
+			if v == id {
+				hit++
+				break
+			}
+		}
+	}
+	return float64(hit) / float64(len(expected))
+}
+
+// ReciprocalRank 第一个命中的期望知识点排名的倒数，未命中时为 0
+func ReciprocalRank(retrieved, expected []string) float64 {
+	for i, v := range retrieved {
+		for _, id := range expected {
+			if v == id {
+				return 1 / float64(i+1)
+			}
+		}
+	}
+	return 0
+}
+
+// TokenF1 按关键词检索的分词规则计算回答与参考答案的词项 F1
+func TokenF1(answer, reference string) float64 {
+	a, b := search.Tokenize(answer), search.Tokenize(reference)
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	count := make(map[string]int, len(b))
+	for _, v := range b {
+		count[v]++
+	}
+	common := 0
+	for _, v := range a {
+		if count[v] > 0 {
+			count[v]--
+			common++
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+	precision := float64(common) / float64(len(a))
+	recall := float64(common) / float64(len(b))
+	return 2 * precision * recall / (precision + recall)
+}
+
+// Cosine 向量的余弦相似度，用于比较回答与参考答案的 embedding
+func Cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// CaseResult 单个问题的评估结果，没有期望知识点时不计算检索指标，没有生成回答时不计算回答指标
+type CaseResult struct {
+	ID               string          `json:"id"`
+	Question         string          `json:"question"`
+	ExpectedIDs      []string        `json:"expected_ids,omitempty"`
+	Retrieved        []string        `json:"retrieved"`
+	Recall           map[int]float64 `json:"recall,omitempty"`
+	ReciprocalRank   *float64        `json:"reciprocal_rank,omitempty"`
+	Answer           string          `json:"answer,omitempty"`
+	AnswerSimilarity *float64        `json:"answer_similarity,omitempty"`
+	AnswerF1         *float64        `json:"answer_f1,omitempty"`
+	Error            string          `json:"error,omitempty"`
+}
+
+// Score 根据检索结果计算检索指标
+func (r *CaseResult) Score(ks []int) {
+	if len(r.ExpectedIDs) == 0 {
+		return
+	}
+	r.Recall = make(map[int]float64, len(ks))
+	for _, k := range ks {
+		r.Recall[k] = RecallAtK(r.Retrieved, r.ExpectedIDs, k)
+	}
+	rr := ReciprocalRank(r.Retrieved, r.ExpectedIDs)
+	r.ReciprocalRank = &rr
+}
+
+// Report 数据集的评估报告，各项指标为参与该项评估的问题的平均值
+type Report struct {
+	Ks               []int           `json:"ks"`
+	Total            int             `json:"total"`
+	Failed           int             `json:"failed"`
+	Recall           map[int]float64 `json:"recall"`
+	MRR              float64         `json:"mrr"`
+	RetrievalCases   int             `json:"retrieval_cases"`
+	AnswerSimilarity float64         `json:"answer_similarity"`
+	AnswerF1         float64         `json:"answer_f1"`
+	AnswerCases      int             `json:"answer_cases"`
+	Cases            []CaseResult    `json:"cases"`
+}
+
+func Summarize(ks []int, results []CaseResult) Report {
+	report := Report{
+		Ks:     ks,
+		Total:  len(results),
+		Recall: make(map[int]float64, len(ks)),
+		Cases:  results,
+	}
+
+	var similarityCases int
+	for _, v := range results {
+		if v.Error != "" {
+			report.Failed++
+			continue
+		}
+		if v.ReciprocalRank != nil {
+			report.RetrievalCases++
+			report.MRR += *v.ReciprocalRank
+			for _, k := range ks {
+				report.Recall[k] += v.Recall[k]
+			}
+		}
+		if v.AnswerF1 != nil {
+			report.AnswerCases++
+			report.AnswerF1 += *v.AnswerF1
+		}
+		if v.AnswerSimilarity != nil {
+			similarityCases++
+			report.AnswerSimilarity += *v.AnswerSimilarity
+		}
+	}
+
+	if report.RetrievalCases > 0 {
+		report.MRR /= float64(report.RetrievalCases)
+		for _, k := range ks {
+			report.Recall[k] /= float64(report.RetrievalCases)
+		}
+	}
+	if report.AnswerCases > 0 {
+		report.AnswerF1 /= float64(report.AnswerCases)
+	}
+	if similarityCases > 0 {
+		report.AnswerSimilarity /= float64(similarityCases)
+	}
+	return report
+}
+
+// WriteText 输出便于在终端阅读的汇总结果
+func (r Report) WriteText(w io.Writer) {
+	ks := append([]int(nil), r.Ks...)
+	sort.Ints(ks)
+
+	fmt.Fprintf(w, "cases: %d, failed: %d\n", r.Total, r.Failed)
+	if r.RetrievalCases > 0 {
+		fmt.Fprintf(w, "retrieval (%d cases):\n", r.RetrievalCases)
+		for _, k := range ks {
+			fmt.Fprintf(w, "  recall@%d: %.4f\n", k, r.Recall[k])
+		}
+		fmt.Fprintf(w, "  mrr: %.4f\n", r.MRR)
+	}
+	if r.AnswerCases > 0 {
+		fmt.Fprintf(w, "answer (%d cases):\n", r.AnswerCases)
+		fmt.Fprintf(w, "  similarity: %.4f\n", r.AnswerSimilarity)
+		fmt.Fprintf(w, "  f1: %.4f\n", r.AnswerF1)
+	}
+	for _, v := range r.Cases {
+		if v.Error != "" {
+			fmt.Fprintf(w, "failed %s: %s\n", v.ID, v.Error)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package rageval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadCases(t *testing.T) {
+	cases, err := LoadCases(strings.NewReader(`
+{"id":"q1","question":"what is k8s","expected_ids":["k1","k2"]}
+
+{"question":"who wrote it","reference_answer":"alice"}
+`))
+	assert.NoError(t, err)
+	assert.Len(t, cases, 2)
+	assert.Equal(t, "q1", cases[0].ID)
+	assert.Equal(t, "4", cases[1].ID)
+
+	_, err = LoadCases(strings.NewReader(`{"question":"no expectation"}`))
+	assert.Error(t, err)
+	_, err = LoadCases(strings.NewReader(`{"question":`))
+	assert.Error(t, err)
+}
+
+func Test_RetrievalMetrics(t *testing.T) {
+	retrieved := []string{"a", "b", "c", "d"}
+	assert.Equal(t, 0.0, RecallAtK(retrieved, []string{"c", "x"}, 1))
+	assert.Equal(t, 0.5, RecallAtK(retrieved, []string{"c", "x"}, 3))
+	assert.Equal(t, 1.0, RecallAtK(retrieved, []string{"a", "d"}, 10))
+	assert.Equal(t, 0.0, RecallAtK(retrieved, nil, 10))
+
+	assert.Equal(t, 1.0/3, ReciprocalRank(retrieved, []string{"x", "c"}))
+	assert.Equal(t, 0.0, ReciprocalRank(retrieved, []string{"x"}))
+}
+
+func Test_AnswerMetrics(t *testing.T) {
+	assert.Equal(t, 1.0, TokenF1("Deploy with Helm", "deploy with helm"))
+	assert.Equal(t, 0.0, TokenF1("nothing", "deploy"))
+	assert.InDelta(t, 6.0/7, TokenF1("deploy with helm chart", "deploy with helm"), 1e-9)
+
+	assert.InDelta(t, 1, Cosine([]float32{1, 2}, []float32{2, 4}), 1e-9)
+	assert.Equal(t, 0.0, Cosine([]float32{1}, []float32{1, 2}))
+}
+
+func Test_Summarize(t *testing.T) {
+	ks := []int{1, 3}
+	results := []CaseResult{
+		{ID: "1", ExpectedIDs: []string{"a"}, Retrieved: []string{"a", "b"}},
+		{ID: "2", ExpectedIDs: []string{"b"}, Retrieved: []string{"a", "c", "b"}},
+		{ID: "3", Retrieved: []string{"a"}},
+		{ID: "4", ExpectedIDs: []string{"a"}, Error: "timeout"},
+	}
+	for i := range results {
+		results[i].Score(ks)
+	}
+	f1 := 0.5
+	results[2].AnswerF1 = &f1
+
+	report := Summarize(ks, results)
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 2, report.RetrievalCases)
+	assert.Equal(t, 0.5, report.Recall[1])
+	assert.Equal(t, 1.0, report.Recall[3])
+	assert.InDelta(t, (1+1.0/3)/2, report.MRR, 1e-9)
+	assert.Equal(t, 1, report.AnswerCases)
+	assert.Equal(t, 0.5, report.AnswerF1)
+
+	var buf bytes.Buffer
+	report.WriteText(&buf)
+	assert.Contains(t, buf.String(), "recall@3: 1.0000")
+	assert.Contains(t, buf.String(), "failed 4: timeout")
+}
@@ -0,0 +1,51 @@
+package search
+
+import "sort"
+
+// DEFAULT_RRF_K RRF 的平滑常数，见 Cormack et al. 2009
+const DEFAULT_RRF_K = 60
+
+// RankList 一路检索的结果，IDs 按相关性从高到低排列
+type RankList struct {
+	Weight float64
+	IDs    []string
+}
+
+type FusionItem struct {
+	ID    string
+	Score float64
+}
+
+// ReciprocalRankFusion 使用加权 RRF 合并多路检索结果
+// score(d) = Σ weight_i / (k + rank_i(d))，rank 从 1 开始
+// 同分时按照首次出现的顺序排列，保证结果稳定
+func ReciprocalRankFusion(k int, lists ...RankList) []FusionItem {
+	if k <= 0 {
+		k = DEFAULT_RRF_K
+	}
+
+	var (
+		items []FusionItem
+		index = make(map[string]int)
+	)
+
+	for _, list := range lists {
+		if list.Weight <= 0 {
+			continue
+		}
+		for rank, id := range list.IDs {
+			i, ok := index[id]
+			if !ok {
+				i = len(items)
+				index[id] = i
+				items = append(items, FusionItem{ID: id})
+			}
+			items[i].Score += list.Weight / float64(k+rank+1)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Score > items[j].Score
+	})
+	return items
+}
@@ -0,0 +1,71 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Tokenize(t *testing.T) {
+	tokens := Tokenize("Deploy failed on db01.example.com with ERR-1234, see ticket #5678.")
+	assert.Contains(t, tokens, "deploy")
+	assert.Contains(t, tokens, "db01.example.com")
+	assert.Contains(t, tokens, "db01")
+	assert.Contains(t, tokens, "err-1234")
+	assert.Contains(t, tokens, "1234")
+	assert.Contains(t, tokens, "5678")
+	assert.NotContains(t, tokens, "#5678")
+	assert.NotContains(t, tokens, "com,")
+
+	tokens = Tokenize("向量数据库 pgvector")
+	assert.Equal(t, []string{"向量", "量数", "数据", "据库", "pgvector"}, tokens)
+
+	assert.Equal(t, []string{"好"}, Tokenize("好"))
+	assert.Empty(t, Tokenize("  , . ! "))
+}
+
+func Test_HashTokens(t *testing.T) {
+	a := HashTokens([]string{"err-1234", "err-1234", "host"}, []byte("key"))
+	assert.Len(t, a, 3)
+	assert.Equal(t, a[0], a[1])
+	assert.NotEqual(t, a[0], a[2])
+	assert.Regexp(t, "^t[0-9a-f]{16}$", a[0])
+
+	b := HashTokens([]string{"err-1234"}, []byte("other"))
+	assert.NotEqual(t, a[0], b[0])
+
+	assert.Equal(t, []string{"x", "y"}, Unique([]string{"x", "y", "x"}))
+}
+
+func Test_ReciprocalRankFusion(t *testing.T) {
+	res := ReciprocalRankFusion(60,
+		RankList{Weight: 1, IDs: []string{"a", "b", "c"}},
+		RankList{Weight: 1, IDs: []string{"c", "d"}},
+	)
+
+	assert.Equal(t, "c", res[0].ID)
+	assert.InDelta(t, 1.0/63+1.0/61, res[0].Score, 1e-9)
+	assert.Equal(t, []string{"c", "a", "b", "d"}, ids(res))
+
+	// keyword weight disabled
+	res = ReciprocalRankFusion(0,
+		RankList{Weight: 1, IDs: []string{"a", "b"}},
+		RankList{Weight: 0, IDs: []string{"z"}},
+	)
+	assert.Equal(t, []string{"a", "b"}, ids(res))
+
+	// heavier keyword weight lifts exact matches
+	res = ReciprocalRankFusion(60,
+		RankList{Weight: 1, IDs: []string{"a", "b"}},
+		RankList{Weight: 3, IDs: []string{"b"}},
+	)
+	assert.Equal(t, "b", res[0].ID)
+}
+
+func ids(items []FusionItem) []string {
+	var res []string
+	for _, v := range items {
+		res = append(res, v.ID)
+	}
+	return res
+}
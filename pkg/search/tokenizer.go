@@ -0,0 +1,106 @@
+package search
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// identifierSeparators 标识符内部允许出现的连接符，例如 ERR-1234、db01.example.com、user_id
+const identifierSeparators = "-_.:/@#"
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isCJK(r)
+}
+
+// Tokenize 将文本切分为用于关键词检索的词项
+// - 英文/数字按单词切分并转小写，错误码、主机名等标识符会同时保留完整形式与拆分后的片段
+// - 中日韩文字按二元组(bigram)切分
+// 返回结果保留重复词项，用于计算词频
+func Tokenize(text string) []string {
+	var (
+		tokens []string
+		word   []rune
+		cjk    []rune
+	)
+
+	flushWord := func() {
+		w := strings.Trim(string(word), identifierSeparators)
+		word = word[:0]
+		if w == "" {
+			return
+		}
+		w = strings.ToLower(w)
+		parts := strings.FieldsFunc(w, func(r rune) bool {
+			return strings.ContainsRune(identifierSeparators, r)
+		})
+		if len(parts) > 1 {
+			tokens = append(tokens, w)
+		}
+		for _, p := range parts {
+			if len([]rune(p)) > 1 || unicode.IsDigit([]rune(p)[0]) {
+				tokens = append(tokens, p)
+			}
+		}
+	}
+
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			tokens = append(tokens, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case isWordRune(r) || (len(word) > 0 && strings.ContainsRune(identifierSeparators, r)):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return tokens
+}
+
+// HashTokens 使用 HMAC 将词项转换为不可逆的 lexeme，避免加密存储的知识内容以明文形式出现在全文索引中
+// lexeme 以字母开头且只包含 [0-9a-z]，可以安全的用于 to_tsvector / to_tsquery
+func HashTokens(tokens []string, key []byte) []string {
+	res := make([]string, 0, len(tokens))
+	for _, v := range tokens {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(v))
+		res = append(res, "t"+hex.EncodeToString(h.Sum(nil))[:16])
+	}
+	return res
+}
+
+// Unique 去重并保持原有顺序
+func Unique(tokens []string) []string {
+	seen := make(map[string]struct{}, len(tokens))
+	res := make([]string, 0, len(tokens))
+	for _, v := range tokens {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		res = append(res, v)
+	}
+	return res
+}
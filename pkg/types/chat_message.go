@@ -24,8 +24,10 @@ const (
 )
 
 type RAGDocs struct {
-	Refs []QueryResult
-	Docs []*PassageInfo
+	Refs    []QueryResult
+	Docs    []*PassageInfo
+	Packing *ContextPacking  // 知识内容按 token 预算裁剪的记录
+	Sources []CitationSource // 参考内容中引用标记对应的知识点及片段
 }
 
 type PassageInfo struct {
@@ -155,6 +157,7 @@ type MessageExt struct {
 	RelDocs          []string     `json:"rel_docs"`
 	Evaluate         EvaluateType `json:"evaluate"`
 	IsEvaluateEnable bool         `json:"is_evaluate_enable"`
+	Citations        Citations    `json:"citations"`
 }
 
 type StreamMessage struct {
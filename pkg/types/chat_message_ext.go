@@ -1,6 +1,10 @@
 package types
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
 	"github.com/lib/pq"
 )
 
@@ -10,7 +14,83 @@ type ChatMessageExt struct {
 	SpaceID          string               `db:"space_id"`
 	Evaluate         EvaluateType         `db:"evaluate"`
 	GenerationStatus GenerationStatusType `db:"generation_status"`
-	RelDocs          pq.StringArray       `db:"rel_docs"` // relevance docs
+	RelDocs          pq.StringArray       `db:"rel_docs"`        // relevance docs
+	ContextPacking   *ContextPacking      `db:"context_packing"` // 知识内容按 token 预算裁剪的记录
+	Citations        Citations            `db:"citations"`       // 回答中的引用
 	CreatedAt        int64                `db:"created_at"`
 	UpdatedAt        int64                `db:"updated_at"`
 }
+
+// ContextPacking 组装 RAG 上下文时的 token 预算及被裁剪的知识点
+type ContextPacking struct {
+	Model     string               `json:"model"`
+	Budget    int                  `json:"budget"`
+	Used      int                  `json:"used"`
+	Truncated []ContextPackingItem `json:"truncated"` // 未使用完整内容的知识点，包括被丢弃的
+}
+
+type ContextPackingItem struct {
+	KnowledgeID    string `json:"knowledge_id"`
+	Mode           string `json:"mode"` // chunks: 命中片段及相邻片段，truncated: 截断，dropped: 未放入上下文
+	Tokens         int    `json:"tokens"`
+	OriginalTokens int    `json:"original_tokens"`
+}
+
+// Value implements the driver.Valuer interface.
+func (s ContextPacking) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *ContextPacking) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	case nil:
+		return nil
+	}
+
+	return fmt.Errorf("pq: cannot convert %T to ContextPacking", src)
+}
+
+// CitationSource 参考内容中引用标记对应的来源，ChunkID 为空表示引用整篇知识点
+type CitationSource struct {
+	Marker      int    `json:"marker"`
+	KnowledgeID string `json:"knowledge_id"`
+	ChunkID     string `json:"chunk_id,omitempty"`
+}
+
+// Citation 回答中的一处引用，Start/End 为引用标记在回答中的字符(rune)位置，客户端据此渲染脚注
+type Citation struct {
+	Marker      int    `json:"marker"`
+	KnowledgeID string `json:"knowledge_id"`
+	ChunkID     string `json:"chunk_id,omitempty"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+}
+
+type Citations []Citation
+
+// Value implements the driver.Valuer interface.
+func (s Citations) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *Citations) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	case nil:
+		return nil
+	}
+
+	return fmt.Errorf("pq: cannot convert %T to Citations", src)
+}
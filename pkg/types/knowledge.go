@@ -11,8 +11,15 @@ import (
 
 const (
 	DEFAULT_RESOURCE = "knowledge"
+
+	// KNOWLEDGE_LINK_PREFIX 知识点之间的链接地址前缀，markdown 中使用 [标题](knowledge://{knowledge_id}) 引用其他知识点
+	KNOWLEDGE_LINK_PREFIX = "knowledge://"
 )
 
+func KnowledgeLinkURL(id string) string {
+	return KNOWLEDGE_LINK_PREFIX + id
+}
+
 // export const cards = pgTable('cards', {
 //   id: uuid('id').primaryKey().notNull().defaultRandom(),
 //   spaceID: uuid('spaceID')
@@ -39,8 +46,9 @@ const (
 	KNOWLEDGE_KIND_TEXT    KnowledgeKind = "text"
 	KNOWLEDGE_KIND_IMAGE                 = "image"
 	KNOWLEDGE_KIND_VIDEO                 = "video"
-	KNOWLEDGE_KIND_URL                   = "url"
+	KNOWLEDGE_KIND_URL                   = "url" // 从网页抓取的知识点，Source 为网页地址
 	KNOWLEDGE_KIND_CHUNK                 = "chunk"
+	KNOWLEDGE_KIND_FILE                  = "file" // 从文件中提取的知识点，Source 为原始文件路径
 	KNOWLEDGE_KIND_UNKNOWN               = "unknown"
 )
 
@@ -52,6 +60,10 @@ func KindNewFromString(s string) KnowledgeKind {
 		return KNOWLEDGE_KIND_IMAGE
 	case string(KNOWLEDGE_KIND_VIDEO):
 		return KNOWLEDGE_KIND_VIDEO
+	case KNOWLEDGE_KIND_FILE:
+		return KNOWLEDGE_KIND_FILE
+	case KNOWLEDGE_KIND_URL:
+		return KNOWLEDGE_KIND_URL
 	default:
 		return KNOWLEDGE_KIND_UNKNOWN
 	}
@@ -107,6 +119,7 @@ type KnowledgeResponse struct {
 	Content     string               `json:"content" db:"content"`
 	Blocks      json.RawMessage      `json:"blocks" db:"-"`
 	ContentType KnowledgeContentType `json:"content_type" db:"content_type"`
+	Source      string               `json:"source" db:"source"`
 	UserID      string               `json:"user_id" db:"user_id"`
 	Stage       KnowledgeStage       `json:"stage" db:"stage"`
 	CreatedAt   int64                `json:"created_at" db:"created_at"`
@@ -122,6 +135,8 @@ type Knowledge struct {
 	Tags        pq.StringArray       `json:"tags" db:"tags"`
 	Content     KnowledgeContent     `json:"content" db:"content"`
 	ContentType KnowledgeContentType `json:"content_type" db:"content_type"`
+	Source      string               `json:"source" db:"source"`             // 知识点的原始来源，例如文件路径
+	ContentHash string               `json:"content_hash" db:"content_hash"` // 归一化后明文内容的哈希，用于判断重复内容
 	UserID      string               `json:"user_id" db:"user_id"`
 	Summary     string               `json:"summary" db:"summary"`
 	MaybeDate   string               `json:"maybe_date" db:"maybe_date"`
@@ -201,16 +216,20 @@ func StringToKnowledgeContentType(str string) KnowledgeContentType {
 }
 
 type GetKnowledgeOptions struct {
-	ID         string
-	IDs        []string
-	Kind       []KnowledgeKind
-	SpaceID    string
-	UserID     string
-	Resource   *ResourceQuery
-	Stage      KnowledgeStage
-	RetryTimes int
-	Keywords   string
-	TimeRange  *struct {
+	ID          string
+	IDs         []string
+	Kind        []KnowledgeKind
+	SpaceID     string
+	UserID      string
+	Resource    *ResourceQuery
+	Stage       KnowledgeStage
+	RetryTimes  int
+	Keywords    string
+	AfterID     string // id 大于该值，配合按 id 排序实现游标分页
+	ContentHash string
+	Tags        []string // 同时包含所有标签
+	Filter      *KnowledgeFilter
+	TimeRange   *struct {
 		St int64
 		Et int64
 	}
@@ -240,6 +259,18 @@ func (opts GetKnowledgeOptions) Apply(query *sq.SelectBuilder) {
 	if opts.RetryTimes > 0 {
 		*query = query.Where(sq.Eq{"retry_times": opts.RetryTimes})
 	}
+	if opts.AfterID != "" {
+		*query = query.Where(sq.Gt{"id": opts.AfterID})
+	}
+	if opts.ContentHash != "" {
+		*query = query.Where(sq.Eq{"content_hash": opts.ContentHash})
+	}
+	if len(opts.Tags) > 0 {
+		*query = query.Where(sq.Expr("tags @> ?", pq.Array(opts.Tags)))
+	}
+	if !opts.Filter.IsEmpty() {
+		*query = query.Where(opts.Filter.ToQuery())
+	}
 
 	if opts.Keywords != "" {
 		or := sq.Or{}
@@ -1,13 +1,49 @@
 package types
 
+import sq "github.com/Masterminds/squirrel"
+
 // KnowledgeChunk 表的结构体
 type KnowledgeChunk struct {
-	ID             string `json:"id" db:"id"`                           // 主键，字符串类型
-	KnowledgeID    string `json:"knowledge_id" db:"knowledge_id"`       // 知识点ID
-	SpaceID        string `json:"space_id" db:"space_id"`               // 空间ID
-	UserID         string `json:"user_id" db:"user_id"`                 // 用户ID
-	Chunk          string `json:"chunk" db:"chunk"`                     // 知识片段
-	OriginalLength int    `json:"original_length" db:"original_length"` // 原文长度
-	UpdatedAt      int64  `json:"updated_at" db:"updated_at"`           // 更新时间
-	CreatedAt      int64  `json:"created_at" db:"created_at"`           // 创建时间
+	ID             string   `json:"id" db:"id"`                           // 主键，字符串类型
+	KnowledgeID    string   `json:"knowledge_id" db:"knowledge_id"`       // 知识点ID
+	SpaceID        string   `json:"space_id" db:"space_id"`               // 空间ID
+	UserID         string   `json:"user_id" db:"user_id"`                 // 用户ID
+	Chunk          string   `json:"chunk" db:"chunk"`                     // 知识片段
+	Seq            int      `json:"seq" db:"seq"`                         // 片段在原文中的顺序，只保证可排序，不保证连续
+	Keywords       []string `json:"-" db:"-"`                             // 关键词 lexeme，写入时生成全文索引，见 search.HashTokens
+	OriginalLength int      `json:"original_length" db:"original_length"` // 原文长度
+	UpdatedAt      int64    `json:"updated_at" db:"updated_at"`           // 更新时间
+	CreatedAt      int64    `json:"created_at" db:"created_at"`           // 创建时间
+}
+
+// ChunkSearchResult 关键词检索结果
+type ChunkSearchResult struct {
+	ID             string  `json:"id" db:"id"`
+	KnowledgeID    string  `json:"knowledge_id" db:"knowledge_id"`
+	OriginalLength int     `json:"original_length" db:"original_length"`
+	Rank           float32 `json:"rank" db:"rank"`
+}
+
+type SearchChunksOptions struct {
+	SpaceID  string
+	UserID   string
+	Resource *ResourceQuery
+	// Knowledge 只匹配所属知识点满足筛选条件的切片
+	Knowledge *KnowledgeFilter
+}
+
+// Apply 查询时 bw_knowledge_chunk 别名为 c，bw_knowledge 别名为 k
+func (opts SearchChunksOptions) Apply(query *sq.SelectBuilder) {
+	if opts.SpaceID != "" {
+		*query = query.Where(sq.Eq{"c.space_id": opts.SpaceID})
+	}
+	if opts.UserID != "" {
+		*query = query.Where(sq.Eq{"c.user_id": opts.UserID})
+	}
+	if opts.Resource != nil {
+		*query = query.Where(opts.Resource.ToQuery())
+	}
+	if !opts.Knowledge.IsEmpty() {
+		*query = query.Where(opts.Knowledge.KnowledgeIDQuery("c.knowledge_id", opts.SpaceID))
+	}
 }
@@ -0,0 +1,23 @@
+package types
+
+const (
+	// DEFAULT_CRAWL_INTERVAL url 类知识点默认的重新抓取间隔(秒)
+	DEFAULT_CRAWL_INTERVAL int64 = 24 * 3600
+	MIN_CRAWL_INTERVAL     int64 = 3600
+	MAX_CRAWL_INTERVAL     int64 = 30 * 24 * 3600
+)
+
+// KnowledgeCrawl url 类知识点的抓取计划，内容摘要变化时重新切片并生成历史版本
+type KnowledgeCrawl struct {
+	KnowledgeID   string `json:"knowledge_id" db:"knowledge_id"`       // 知识点ID
+	SpaceID       string `json:"space_id" db:"space_id"`               // 空间ID
+	URL           string `json:"url" db:"url"`                         // 抓取地址
+	ContentHash   string `json:"content_hash" db:"content_hash"`       // 最近一次抓取到的内容摘要
+	Interval      int64  `json:"interval" db:"crawl_interval"`         // 抓取间隔(秒)，0 表示不再自动抓取
+	LastCrawledAt int64  `json:"last_crawled_at" db:"last_crawled_at"` // 最近一次成功抓取的时间
+	NextCrawlAt   int64  `json:"next_crawl_at" db:"next_crawl_at"`     // 下一次抓取的时间
+	FailedTimes   int    `json:"failed_times" db:"failed_times"`       // 连续失败次数
+	LastError     string `json:"last_error" db:"last_error"`           // 最近一次失败的原因
+	CreatedAt     int64  `json:"created_at" db:"created_at"`           // 创建时间
+	UpdatedAt     int64  `json:"updated_at" db:"updated_at"`           // 更新时间
+}
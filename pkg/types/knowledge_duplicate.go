@@ -0,0 +1,48 @@
+package types
+
+import "github.com/lib/pq"
+
+const (
+	// KNOWLEDGE_DUPLICATE_MIN_SCORE 向量相似度不低于该值时视为相近的重复内容
+	KNOWLEDGE_DUPLICATE_MIN_SCORE = 0.95
+	// KNOWLEDGE_DUPLICATE_CHECK_RUNES 新增内容时用于检索相近内容的最大字符数
+	KNOWLEDGE_DUPLICATE_CHECK_RUNES = 2000
+
+	KNOWLEDGE_DUPLICATE_REASON_HASH    = "hash"    // 归一化后的内容完全相同
+	KNOWLEDGE_DUPLICATE_REASON_SIMILAR = "similar" // 向量相似度超过 KNOWLEDGE_DUPLICATE_MIN_SCORE
+)
+
+// KnowledgeDuplicate 定时去重任务发现的一对重复知识点，内容相同时 KnowledgeID 为其中最早创建的知识点
+type KnowledgeDuplicate struct {
+	SpaceID     string  `json:"space_id" db:"space_id"`         // 空间ID
+	KnowledgeID string  `json:"knowledge_id" db:"knowledge_id"` // 知识点ID
+	DuplicateID string  `json:"duplicate_id" db:"duplicate_id"` // 与之重复的知识点ID
+	Reason      string  `json:"reason" db:"reason"`             // 判断为重复的依据 hash/similar
+	Score       float32 `json:"score" db:"score"`               // 相似度，内容相同时为 1
+	CreatedAt   int64   `json:"created_at" db:"created_at"`     // 报告生成时间
+}
+
+// DuplicateKnowledge 新增内容时发现的已存在的重复知识点
+type DuplicateKnowledge struct {
+	Knowledge *KnowledgeLite `json:"knowledge"`
+	Reason    string         `json:"reason"`
+	Score     float32        `json:"score"`
+}
+
+// KnowledgeDuplicateGroup 去重报告中互相重复的一组知识点
+type KnowledgeDuplicateGroup struct {
+	Knowledges []*KnowledgeLite     `json:"knowledges"`
+	Pairs      []KnowledgeDuplicate `json:"pairs"`
+}
+
+// KnowledgeDuplicateReport 空间的去重报告
+type KnowledgeDuplicateReport struct {
+	Groups    []KnowledgeDuplicateGroup `json:"groups"`
+	CreatedAt int64                     `json:"created_at"` // 报告生成时间，尚未生成报告时为 0
+}
+
+// KnowledgeHashDuplicate 内容哈希相同的知识点，按创建时间排序
+type KnowledgeHashDuplicate struct {
+	ContentHash string         `db:"content_hash"`
+	IDs         pq.StringArray `db:"ids"`
+}
@@ -0,0 +1,143 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// MaybeDate 的存储格式，为 "2006-01-02 15:04"，筛选时也接受只有日期的格式
+const (
+	MAYBE_DATE_LAYOUT      = "2006-01-02 15:04"
+	MAYBE_DATE_DATE_LAYOUT = "2006-01-02"
+)
+
+// KnowledgeFilter 知识点的结构化筛选条件，各条件之间为 AND 关系，空条件不参与筛选
+type KnowledgeFilter struct {
+	Tags      []string            `json:"tags"`       // 同时包含所有标签
+	Kinds     []KnowledgeKind     `json:"kinds"`      // 知识类型，满足其一即可
+	UserIDs   []string            `json:"user_ids"`   // 作者，满足其一即可
+	MaybeDate *KnowledgeDateRange `json:"maybe_date"` // AI 分析出的事件发生时间
+	CreatedAt *KnowledgeTimeRange `json:"created_at"`
+	UpdatedAt *KnowledgeTimeRange `json:"updated_at"`
+}
+
+// KnowledgeDateRange 闭区间，格式为 2006-01-02 或 2006-01-02 15:04，为空表示不限制
+// To 只有日期时包含当天
+type KnowledgeDateRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// KnowledgeTimeRange 闭区间，UNIX 时间戳，为 0 表示不限制
+type KnowledgeTimeRange struct {
+	St int64 `json:"st"`
+	Et int64 `json:"et"`
+}
+
+func (f *KnowledgeFilter) IsEmpty() bool {
+	return f == nil || (len(f.Tags) == 0 && len(f.Kinds) == 0 && len(f.UserIDs) == 0 &&
+		f.MaybeDate == nil && f.CreatedAt == nil && f.UpdatedAt == nil)
+}
+
+func parseMaybeDate(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(MAYBE_DATE_LAYOUT, s); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(MAYBE_DATE_DATE_LAYOUT, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid maybe_date %q, expected %s or %s", s, MAYBE_DATE_DATE_LAYOUT, MAYBE_DATE_LAYOUT)
+	}
+	return t, true, nil
+}
+
+func (r *KnowledgeTimeRange) validate(name string) error {
+	if r != nil && r.St > 0 && r.Et > 0 && r.St > r.Et {
+		return fmt.Errorf("invalid %s range, st is after et", name)
+	}
+	return nil
+}
+
+func (f *KnowledgeFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	if f.MaybeDate != nil {
+		var from, to time.Time
+		var err error
+		if f.MaybeDate.From != "" {
+			if from, _, err = parseMaybeDate(f.MaybeDate.From); err != nil {
+				return err
+			}
+		}
+		if f.MaybeDate.To != "" {
+			if to, _, err = parseMaybeDate(f.MaybeDate.To); err != nil {
+				return err
+			}
+		}
+		if !from.IsZero() && !to.IsZero() && from.After(to) {
+			return errors.New("invalid maybe_date range, from is after to")
+		}
+	}
+	if err := f.CreatedAt.validate("created_at"); err != nil {
+		return err
+	}
+	return f.UpdatedAt.validate("updated_at")
+}
+
+func (r *KnowledgeTimeRange) toQuery(column string, and sq.And) sq.And {
+	if r == nil {
+		return and
+	}
+	if r.St > 0 {
+		and = append(and, sq.GtOrEq{column: r.St})
+	}
+	if r.Et > 0 {
+		and = append(and, sq.LtOrEq{column: r.Et})
+	}
+	return and
+}
+
+// ToQuery 转换为 bw_knowledge 上的查询条件，调用前需要先 Validate
+// maybe_date 为定长的字符串，直接按字典序比较
+func (f *KnowledgeFilter) ToQuery() sq.Sqlizer {
+	and := sq.And{}
+	if f.IsEmpty() {
+		return and
+	}
+	if len(f.Tags) > 0 {
+		and = append(and, sq.Expr("tags @> ?", pq.Array(f.Tags)))
+	}
+	if len(f.Kinds) > 0 {
+		and = append(and, sq.Eq{"kind": f.Kinds})
+	}
+	if len(f.UserIDs) > 0 {
+		and = append(and, sq.Eq{"user_id": f.UserIDs})
+	}
+	if f.MaybeDate != nil {
+		if f.MaybeDate.From != "" {
+			and = append(and, sq.GtOrEq{"maybe_date": f.MaybeDate.From})
+		}
+		if f.MaybeDate.To != "" {
+			if to, dateOnly, err := parseMaybeDate(f.MaybeDate.To); err == nil && dateOnly {
+				and = append(and, sq.Lt{"maybe_date": to.AddDate(0, 0, 1).Format(MAYBE_DATE_DATE_LAYOUT)})
+			} else {
+				and = append(and, sq.LtOrEq{"maybe_date": f.MaybeDate.To})
+			}
+		}
+	}
+	and = f.CreatedAt.toQuery("created_at", and)
+	return f.UpdatedAt.toQuery("updated_at", and)
+}
+
+// KnowledgeIDQuery 生成 column IN (空间中满足筛选条件的知识点ID) 的子查询，用于向量、切片等关联知识点的表
+func (f *KnowledgeFilter) KnowledgeIDQuery(column, spaceID string) sq.Sqlizer {
+	where := sq.And{f.ToQuery()}
+	if spaceID != "" {
+		where = append(sq.And{sq.Eq{"space_id": spaceID}}, where...)
+	}
+	return sq.Expr(fmt.Sprintf("%s IN (SELECT id FROM %s WHERE ?)", column, TABLE_KNOWLEDGE.Name()), where)
+}
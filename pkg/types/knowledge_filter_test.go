@@ -0,0 +1,55 @@
+package types
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KnowledgeFilterValidate(t *testing.T) {
+	var empty *KnowledgeFilter
+	assert.True(t, empty.IsEmpty())
+	assert.NoError(t, empty.Validate())
+	assert.True(t, (&KnowledgeFilter{}).IsEmpty())
+
+	assert.NoError(t, (&KnowledgeFilter{MaybeDate: &KnowledgeDateRange{From: "2024-07-01", To: "2024-09-30 18:00"}}).Validate())
+	assert.Error(t, (&KnowledgeFilter{MaybeDate: &KnowledgeDateRange{From: "2024/07/01"}}).Validate())
+	assert.Error(t, (&KnowledgeFilter{MaybeDate: &KnowledgeDateRange{From: "2024-10-01", To: "2024-09-30"}}).Validate())
+	assert.Error(t, (&KnowledgeFilter{CreatedAt: &KnowledgeTimeRange{St: 10, Et: 5}}).Validate())
+	assert.NoError(t, (&KnowledgeFilter{UpdatedAt: &KnowledgeTimeRange{St: 10}}).Validate())
+}
+
+func Test_KnowledgeFilterToQuery(t *testing.T) {
+	f := &KnowledgeFilter{
+		Tags:      []string{"k8s"},
+		Kinds:     []KnowledgeKind{KNOWLEDGE_KIND_TEXT},
+		UserIDs:   []string{"u1", "u2"},
+		MaybeDate: &KnowledgeDateRange{From: "2024-07-01", To: "2024-09-30"},
+		CreatedAt: &KnowledgeTimeRange{St: 100},
+	}
+	sql, args, err := f.ToQuery().ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "(tags @> ? AND kind IN (?) AND user_id IN (?,?) AND maybe_date >= ? AND maybe_date < ? AND created_at >= ?)", sql)
+	// 只有日期的结束时间包含当天
+	assert.Equal(t, []interface{}{pq.Array([]string{"k8s"}), KNOWLEDGE_KIND_TEXT, "u1", "u2", "2024-07-01", "2024-10-01", int64(100)}, args)
+
+	f = &KnowledgeFilter{MaybeDate: &KnowledgeDateRange{To: "2024-09-30 18:00"}}
+	_, args, err = f.ToQuery().ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"2024-09-30 18:00"}, args)
+}
+
+func Test_KnowledgeFilterKnowledgeIDQuery(t *testing.T) {
+	f := &KnowledgeFilter{Tags: []string{"k8s"}}
+	query := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select("id").From(TABLE_VECTORS.Name()).
+		Where(sq.Eq{"space_id": "s1"}).
+		Where(f.KnowledgeIDQuery("knowledge_id", "s1"))
+
+	sql, args, err := query.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM bw_vectors WHERE space_id = $1 AND knowledge_id IN (SELECT id FROM bw_knowledge WHERE (space_id = $2 AND (tags @> $3)))", sql)
+	assert.Len(t, args, 3)
+}
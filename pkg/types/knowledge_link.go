@@ -0,0 +1,55 @@
+package types
+
+import (
+	sq "github.com/Masterminds/squirrel"
+)
+
+const (
+	// MAX_KNOWLEDGE_GRAPH_DEPTH 知识图谱中以某个知识点为中心展开的最大层数
+	MAX_KNOWLEDGE_GRAPH_DEPTH = 3
+	// MAX_KNOWLEDGE_GRAPH_EDGES 知识图谱单次返回的最大边数
+	MAX_KNOWLEDGE_GRAPH_EDGES = 1000
+)
+
+// KnowledgeLink 知识点之间的链接，由知识点内容中指向其他知识点的链接解析得到
+type KnowledgeLink struct {
+	SpaceID   string `json:"space_id" db:"space_id"`     // 空间ID
+	FromID    string `json:"from_id" db:"from_id"`       // 链接所在的知识点
+	ToID      string `json:"to_id" db:"to_id"`           // 被链接的知识点
+	Text      string `json:"text" db:"text"`             // 链接文字
+	CreatedAt int64  `json:"created_at" db:"created_at"` // 创建时间
+}
+
+type GetKnowledgeLinksOptions struct {
+	SpaceID string
+	FromIDs []string
+	ToIDs   []string
+}
+
+// Apply 同时设置 FromIDs 与 ToIDs 时返回任意一端在列表中的链接
+func (opts GetKnowledgeLinksOptions) Apply(query *sq.SelectBuilder) {
+	if opts.SpaceID != "" {
+		*query = query.Where(sq.Eq{"space_id": opts.SpaceID})
+	}
+	switch {
+	case len(opts.FromIDs) > 0 && len(opts.ToIDs) > 0:
+		*query = query.Where(sq.Or{sq.Eq{"from_id": opts.FromIDs}, sq.Eq{"to_id": opts.ToIDs}})
+	case len(opts.FromIDs) > 0:
+		*query = query.Where(sq.Eq{"from_id": opts.FromIDs})
+	case len(opts.ToIDs) > 0:
+		*query = query.Where(sq.Eq{"to_id": opts.ToIDs})
+	}
+}
+
+// KnowledgeBacklink 链接到某个知识点的其他知识点
+type KnowledgeBacklink struct {
+	Knowledge *KnowledgeLite `json:"knowledge"`
+	Text      string         `json:"text"` // 链接文字
+}
+
+// KnowledgeGraph 知识点及其链接组成的图
+type KnowledgeGraph struct {
+	Nodes     []*KnowledgeLite `json:"nodes"`
+	Edges     []KnowledgeLink  `json:"edges"`
+	Truncated bool             `json:"truncated"` // 边数超出 MAX_KNOWLEDGE_GRAPH_EDGES 时为 true
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// KnowledgeProgress 大文档分段处理的进度，处理失败后从已完成的分段继续，处理完成后删除
+type KnowledgeProgress struct {
+	KnowledgeID string                   `json:"knowledge_id" db:"knowledge_id"` // 知识点ID
+	SpaceID     string                   `json:"space_id" db:"space_id"`         // 空间ID
+	Stage       KnowledgeStage           `json:"stage" db:"stage"`               // 正在处理的阶段
+	ContentHash string                   `json:"content_hash" db:"content_hash"` // 内容摘要，内容变化后进度失效
+	Total       int                      `json:"total" db:"total"`               // 分段总数
+	Finished    int                      `json:"finished" db:"finished"`         // 已完成的分段数，分段按顺序处理
+	Summary     KnowledgeProgressSummary `json:"summary" db:"summary"`           // 已完成分段汇总的标题、标签等
+	CreatedAt   int64                    `json:"created_at" db:"created_at"`     // 创建时间
+	UpdatedAt   int64                    `json:"updated_at" db:"updated_at"`     // 更新时间
+}
+
+// Percent 处理进度百分比
+func (p KnowledgeProgress) Percent() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return p.Finished * 100 / p.Total
+}
+
+type KnowledgeProgressSummary struct {
+	Title    string   `json:"title"`
+	Tags     []string `json:"tags"`
+	DateTime string   `json:"date_time"`
+}
+
+// Value implements the driver.Valuer interface.
+func (s KnowledgeProgressSummary) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *KnowledgeProgressSummary) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	case nil:
+		return nil
+	}
+
+	return fmt.Errorf("pq: cannot convert %T to KnowledgeProgressSummary", src)
+}
@@ -0,0 +1,23 @@
+package types
+
+const (
+	// RELATED_KNOWLEDGE_LIMIT 每个知识点保存的相关知识点数量
+	RELATED_KNOWLEDGE_LIMIT = 10
+	// RELATED_KNOWLEDGE_MIN_SCORE 相关知识点的最低相似度
+	RELATED_KNOWLEDGE_MIN_SCORE = 0.5
+)
+
+// KnowledgeRelated 语义相近的知识点，相似度为两个知识点切片向量之间最大的余弦相似度
+type KnowledgeRelated struct {
+	SpaceID     string  `json:"space_id" db:"space_id"`         // 空间ID
+	KnowledgeID string  `json:"knowledge_id" db:"knowledge_id"` // 知识点ID
+	RelatedID   string  `json:"related_id" db:"related_id"`     // 相关的知识点ID
+	Score       float32 `json:"score" db:"score"`               // 余弦相似度
+	CreatedAt   int64   `json:"created_at" db:"created_at"`     // 计算时间
+}
+
+// RelatedKnowledge 相关知识点及其相似度
+type RelatedKnowledge struct {
+	Knowledge *KnowledgeLite `json:"knowledge"`
+	Score     float32        `json:"score"`
+}
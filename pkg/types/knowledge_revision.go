@@ -0,0 +1,32 @@
+package types
+
+// KnowledgeRevisionOrigin 产生历史版本的来源
+type KnowledgeRevisionOrigin string
+
+const (
+	KNOWLEDGE_REVISION_ORIGIN_CREATE  KnowledgeRevisionOrigin = "create"  // 首次修改前知识点的原始内容
+	KNOWLEDGE_REVISION_ORIGIN_EDIT    KnowledgeRevisionOrigin = "edit"    // 用户修改
+	KNOWLEDGE_REVISION_ORIGIN_RESTORE KnowledgeRevisionOrigin = "restore" // 恢复到历史版本
+	KNOWLEDGE_REVISION_ORIGIN_CRAWL   KnowledgeRevisionOrigin = "crawl"   // url 类知识点抓取到新的内容
+)
+
+// KnowledgeRevision 知识点的历史版本，每个版本保存完整的内容快照
+type KnowledgeRevision struct {
+	ID          string                  `json:"id" db:"id"`                     // 版本ID
+	KnowledgeID string                  `json:"knowledge_id" db:"knowledge_id"` // 知识点ID
+	SpaceID     string                  `json:"space_id" db:"space_id"`         // 空间ID
+	UserID      string                  `json:"user_id" db:"user_id"`           // 产生该版本的用户
+	Origin      KnowledgeRevisionOrigin `json:"origin" db:"origin"`             // 版本来源
+	Title       string                  `json:"title" db:"title"`               // 版本对应的标题
+	Content     KnowledgeContent        `json:"content,omitempty" db:"content"` // 加密后的内容
+	ContentType KnowledgeContentType    `json:"content_type" db:"content_type"` // 内容格式
+	ContentHash string                  `json:"content_hash" db:"content_hash"` // 明文内容的摘要
+	CreatedAt   int64                   `json:"created_at" db:"created_at"`     // 创建时间
+}
+
+// KnowledgeRevisionDiff 两个版本之间的差异，From、To 不包含内容
+type KnowledgeRevisionDiff struct {
+	From KnowledgeRevision `json:"from"`
+	To   KnowledgeRevision `json:"to"`
+	Diff string            `json:"diff"` // markdown 格式的 unified diff，内容相同时为空
+}
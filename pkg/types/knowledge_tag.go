@@ -0,0 +1,28 @@
+package types
+
+import "github.com/lib/pq"
+
+const (
+	// MAX_TAG_LENGTH 标签的最大字符数
+	MAX_TAG_LENGTH = 64
+	// TAG_MERGE_MIN_SCORE 标签向量的余弦相似度不低于该值时建议合并
+	TAG_MERGE_MIN_SCORE = 0.9
+	// TAG_MERGE_MAX_TAGS 计算合并建议时最多使用的标签数量，按使用次数倒序选取
+	TAG_MERGE_MAX_TAGS = 500
+)
+
+// KnowledgeTag 空间中的标签及使用该标签的知识点数量
+type KnowledgeTag struct {
+	Tag   string `json:"tag" db:"tag"`
+	Count int64  `json:"count" db:"count"`
+}
+
+// KnowledgeTagMerge 定时任务生成的标签合并建议，Tags 中的标签建议统一为 Target
+type KnowledgeTagMerge struct {
+	ID        string         `json:"id" db:"id"`
+	SpaceID   string         `json:"space_id" db:"space_id"`
+	Tags      pq.StringArray `json:"tags" db:"tags"`     // 建议合并的标签，包含 Target
+	Target    string         `json:"target" db:"target"` // 建议保留的标签，为组内使用次数最多的标签
+	Score     float32        `json:"score" db:"score"`   // 组内相连标签之间最低的相似度
+	CreatedAt int64          `json:"created_at" db:"created_at"`
+}
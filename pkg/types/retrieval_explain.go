@@ -0,0 +1,39 @@
+package types
+
+// RetrievalExplain 一次检索的各个步骤的中间结果，用于排查回答不准确的原因，不会请求对话模型
+type RetrievalExplain struct {
+	Query           string              `json:"query"`
+	EnhancedQueries []string            `json:"enhanced_queries"` // EnhanceQuery 改写出的查询，与原始查询拼接后用于向量检索
+	EmbeddingModel  string              `json:"embedding_model"`
+	CosLimit        float32             `json:"cos_limit"` // 余弦距离超过该值且原文较长的向量命中会被丢弃
+	VectorHits      []ExplainVectorHit  `json:"vector_hits"`
+	KeywordHits     []ChunkSearchResult `json:"keyword_hits"`
+	Fused           []QueryResult       `json:"fused"`      // 向量与关键词检索 RRF 融合后的切片
+	LinkedIDs       []string            `json:"linked_ids"` // 通过知识点链接扩展的知识点
+	Rerank          []ExplainRerankItem `json:"rerank"`     // 重排后的知识点，按最终顺序排列
+	Packing         *ContextPacking     `json:"packing"`    // 知识内容按 token 预算裁剪的结果
+	Passages        []*PassageInfo      `json:"passages"`   // 最终提供给模型的参考内容
+	Prompt          string              `json:"prompt"`     // 发送给对话模型的系统提示词
+	Warnings        []string            `json:"warnings"`   // 降级处理的步骤，如改写查询、关键词检索或重排失败
+}
+
+// ExplainVectorHit 向量检索命中的切片，Dropped 表示被 cosLimit 规则丢弃
+type ExplainVectorHit struct {
+	QueryResult
+	Dropped    bool   `json:"dropped"`
+	DropReason string `json:"drop_reason,omitempty"`
+}
+
+// ExplainRerankItem 重排结果，Score 为重排模型给出的相关性得分，重排模型未返回得分时为 0
+type ExplainRerankItem struct {
+	KnowledgeID string  `json:"knowledge_id"`
+	Title       string  `json:"title"`
+	Score       float64 `json:"score"`
+}
+
+// Warn 记录降级处理的步骤，e 为 nil 时忽略
+func (e *RetrievalExplain) Warn(step string, err error) {
+	if e != nil && err != nil {
+		e.Warnings = append(e.Warnings, step+": "+err.Error())
+	}
+}
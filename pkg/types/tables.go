@@ -11,22 +11,29 @@ func (s TableName) Name() string {
 const TABLE_PREFIX = "bw_"
 
 const (
-	TABLE_KNOWLEDGE        = TableName("knowledge")
-	TABLE_KNOWLEDGE_CHUNK  = TableName("knowledge_chunk")
-	TABLE_VECTORS          = TableName("vectors")
-	TABLE_ACCESS_TOKEN     = TableName("access_token")
-	TABLE_USER_SPACE       = TableName("user_space")
-	TABLE_SPACE            = TableName("space")
-	TABLE_RESOURCE         = TableName("resource")
-	TABLE_USER             = TableName("user")
-	TABLE_CHAT_SESSION     = TableName("chat_session")
-	TABLE_CHAT_SESSION_PIN = TableName("chat_session_pin")
-	TABLE_CHAT_MESSAGE     = TableName("chat_message")
-	TABLE_CHAT_SUMMARY     = TableName("chat_summary")
-	TABLE_CHAT_MESSAGE_EXT = TableName("chat_message_ext")
-	TABLE_FILE_MANAGEMENT  = TableName("file_management")
-	TABLE_AI_TOKEN_USAGE   = TableName("ai_token_usage")
-	TABLE_SHARE_TOKEN      = TableName("share_token")
-	TABLE_JOURNAL          = TableName("journal")
-	TABLE_BUTLER           = TableName("butler")
+	TABLE_KNOWLEDGE           = TableName("knowledge")
+	TABLE_KNOWLEDGE_CHUNK     = TableName("knowledge_chunk")
+	TABLE_KNOWLEDGE_PROGRESS  = TableName("knowledge_progress")
+	TABLE_KNOWLEDGE_REVISION  = TableName("knowledge_revision")
+	TABLE_KNOWLEDGE_CRAWL     = TableName("knowledge_crawl")
+	TABLE_KNOWLEDGE_LINK      = TableName("knowledge_link")
+	TABLE_KNOWLEDGE_RELATED   = TableName("knowledge_related")
+	TABLE_KNOWLEDGE_DUPLICATE = TableName("knowledge_duplicate")
+	TABLE_KNOWLEDGE_TAG_MERGE = TableName("knowledge_tag_merge")
+	TABLE_VECTORS             = TableName("vectors")
+	TABLE_ACCESS_TOKEN        = TableName("access_token")
+	TABLE_USER_SPACE          = TableName("user_space")
+	TABLE_SPACE               = TableName("space")
+	TABLE_RESOURCE            = TableName("resource")
+	TABLE_USER                = TableName("user")
+	TABLE_CHAT_SESSION        = TableName("chat_session")
+	TABLE_CHAT_SESSION_PIN    = TableName("chat_session_pin")
+	TABLE_CHAT_MESSAGE        = TableName("chat_message")
+	TABLE_CHAT_SUMMARY        = TableName("chat_summary")
+	TABLE_CHAT_MESSAGE_EXT    = TableName("chat_message_ext")
+	TABLE_FILE_MANAGEMENT     = TableName("file_management")
+	TABLE_AI_TOKEN_USAGE      = TableName("ai_token_usage")
+	TABLE_SHARE_TOKEN         = TableName("share_token")
+	TABLE_JOURNAL             = TableName("journal")
+	TABLE_BUTLER              = TableName("butler")
 )
@@ -1,6 +1,12 @@
 package types
 
-import sq "github.com/Masterminds/squirrel"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
 
 // UserSpace 数据表结构
 type UserSpace struct {
@@ -26,17 +32,122 @@ func (opts ListUserSpaceOptions) Apply(query *sq.SelectBuilder) {
 }
 
 type Space struct {
-	SpaceID     string `json:"space_id" db:"space_id"` // 空间ID
-	Title       string `json:"title" db:"title"`
-	Description string `json:"description" db:"description"`
-	CreatedAt   int64  `json:"created_at" db:"created_at"` // 创建时间，存储为时间戳
+	SpaceID     string        `json:"space_id" db:"space_id"` // 空间ID
+	Title       string        `json:"title" db:"title"`
+	Description string        `json:"description" db:"description"`
+	Settings    SpaceSettings `json:"settings" db:"settings"`     // 空间配置
+	CreatedAt   int64         `json:"created_at" db:"created_at"` // 创建时间，存储为时间戳
+}
+
+// SpaceSettings 空间级别的配置，以 jsonb 格式存储
+type SpaceSettings struct {
+	Retrieval     RetrievalSettings        `json:"retrieval"`
+	Embedding     EmbeddingSettings        `json:"embedding"`
+	Chunk         ChunkSettings            `json:"chunk"`
+	ResourceChunk map[string]ChunkSettings `json:"resource_chunk,omitempty"` // 按 resource 覆盖空间的切片配置
+	Tags          TagSettings              `json:"tags"`
+}
+
+// TagSettings 标签管理配置
+type TagSettings struct {
+	SuggestMerges bool `json:"suggest_merges"` // 定期根据标签向量的相似度生成合并建议，会消耗 embedding 配额
+}
+
+// ChunkSettings 知识点切片配置
+type ChunkSettings struct {
+	Strategy  string `json:"strategy"`   // llm(默认) / markdown / sentence / token
+	ChunkSize int    `json:"chunk_size"` // 单个切片的最大 token 数，仅本地切片生效
+	Overlap   int    `json:"overlap"`    // 相邻切片重叠的 token 数，仅本地切片生效
+}
+
+// GetChunkSettings 获取 resource 的切片配置，resource 未单独配置时使用空间的配置
+func (s SpaceSettings) GetChunkSettings(resource string) ChunkSettings {
+	if v, ok := s.ResourceChunk[resource]; ok {
+		return v
+	}
+	return s.Chunk
+}
+
+// EmbeddingSettings 空间当前用于检索的 embedding 模型，Driver 为空时使用全局配置 ai.usage
+type EmbeddingSettings struct {
+	Driver    string              `json:"driver"`
+	Model     string              `json:"model"`
+	Dimension int                 `json:"dimension"`
+	Migration *EmbeddingMigration `json:"migration,omitempty"` // 正在进行中的模型迁移
+}
+
+const (
+	EMBEDDING_MIGRATION_STATUS_RUNNING = "running"
+	EMBEDDING_MIGRATION_STATUS_FAILED  = "failed"
+)
+
+// EmbeddingMigration 空间 embedding 模型迁移进度，迁移完成前旧模型的向量继续提供检索
+type EmbeddingMigration struct {
+	Driver    string `json:"driver"`
+	Model     string `json:"model"`
+	Dimension int    `json:"dimension"`
+	Status    string `json:"status"`
+	Cursor    string `json:"cursor"`    // 最后一个迁移完成的 knowledge id，用于中断后继续迁移
+	Processed int    `json:"processed"` // 已迁移的知识点数量
+	Error     string `json:"error,omitempty"`
+	StartedAt int64  `json:"started_at"`
+}
+
+func (s EmbeddingSettings) IsMigrating() bool {
+	return s.Migration != nil && s.Migration.Status == EMBEDDING_MIGRATION_STATUS_RUNNING
+}
+
+// RetrievalSettings 混合检索配置，零值表示使用默认值
+type RetrievalSettings struct {
+	VectorWeight   float64 `json:"vector_weight"`   // 向量检索在 RRF 融合中的权重，default: 1
+	KeywordWeight  float64 `json:"keyword_weight"`  // 关键词检索在 RRF 融合中的权重，default: 1
+	RRFK           int     `json:"rrf_k"`           // RRF 平滑常数，default: 60
+	DisableKeyword bool    `json:"disable_keyword"` // 关闭关键词检索，仅使用向量检索
+	LinkExpansion  int     `json:"link_expansion"`  // 将排名前 n 的知识点所链接的知识点(一跳)加入参考内容，0 表示不扩展
+}
+
+func (s RetrievalSettings) GetVectorWeight() float64 {
+	if s.VectorWeight <= 0 {
+		return 1
+	}
+	return s.VectorWeight
+}
+
+func (s RetrievalSettings) GetKeywordWeight() float64 {
+	if s.DisableKeyword {
+		return 0
+	}
+	if s.KeywordWeight <= 0 {
+		return 1
+	}
+	return s.KeywordWeight
+}
+
+// Value implements the driver.Valuer interface.
+func (s SpaceSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *SpaceSettings) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	case nil:
+		return nil
+	}
+
+	return fmt.Errorf("pq: cannot convert %T to SpaceSettings", src)
 }
 
 type UserSpaceDetail struct {
-	UserID      string `json:"user_id"`
-	SpaceID     string `json:"space_id"`
-	Role        string `json:"role"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	CreatedAt   int64  `json:"created_at"`
+	UserID      string        `json:"user_id"`
+	SpaceID     string        `json:"space_id"`
+	Role        string        `json:"role"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Settings    SpaceSettings `json:"settings"`
+	CreatedAt   int64         `json:"created_at"`
 }
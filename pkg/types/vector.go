@@ -2,19 +2,20 @@ package types
 
 import (
 	sq "github.com/Masterminds/squirrel"
-	"github.com/pgvector/pgvector-go"
 )
 
 type Vector struct {
-	ID             string          `json:"id" db:"id"`                           // 主键，关联knowledge_chunk_id
-	KnowledgeID    string          `json:"knowledge_id" db:"knowledge_id"`       // 关联 knowledge_id
-	SpaceID        string          `json:"space_id" db:"space_id"`               // 空间ID，用于标识所属空间
-	Resource       string          `json:"resource" db:"resource"`               // 关联 knowledge resource
-	UserID         string          `json:"user_id" db:"user_id"`                 // 用户ID，用于标识向量所属用户
-	Embedding      pgvector.Vector `json:"embedding" db:"embedding"`             // 文本向量，存储经过编码后的文本向量表示
-	OriginalLength int             `json:"original_length" db:"original_length"` // 原文长度
-	CreatedAt      int64           `json:"created_at" db:"created_at"`           // 创建时间，UNIX时间戳
-	UpdatedAt      int64           `json:"updated_at" db:"updated_at"`           // 更新时间，UNIX时间戳
+	ID             string    `json:"id" db:"id"`                           // 主键，关联knowledge_chunk_id
+	KnowledgeID    string    `json:"knowledge_id" db:"knowledge_id"`       // 关联 knowledge_id
+	SpaceID        string    `json:"space_id" db:"space_id"`               // 空间ID，用于标识所属空间
+	Resource       string    `json:"resource" db:"resource"`               // 关联 knowledge resource
+	UserID         string    `json:"user_id" db:"user_id"`                 // 用户ID，用于标识向量所属用户
+	Embedding      []float32 `json:"embedding" db:"-"`                     // 文本向量，存储经过编码后的文本向量表示，由具体的向量存储驱动负责序列化
+	Model          string    `json:"model" db:"model"`                     // 生成向量的 embedding 模型
+	Dimension      int       `json:"dimension" db:"dimension"`             // 向量维度
+	OriginalLength int       `json:"original_length" db:"original_length"` // 原文长度
+	CreatedAt      int64     `json:"created_at" db:"created_at"`           // 创建时间，UNIX时间戳
+	UpdatedAt      int64     `json:"updated_at" db:"updated_at"`           // 更新时间，UNIX时间戳
 }
 
 type QueryResult struct {
@@ -22,6 +23,7 @@ type QueryResult struct {
 	KnowledgeID    string  `json:"knowledge_id" db:"knowledge_id"`
 	Cos            float32 `json:"cos" db:"cos"`
 	OriginalLength int     `json:"original_length" db:"original_length"`
+	Score          float64 `json:"score" db:"-"` // 混合检索 RRF 融合后的得分
 }
 
 type GetVectorsOptions struct {
@@ -30,6 +32,11 @@ type GetVectorsOptions struct {
 	UserID      string
 	KnowledgeID string
 	Resource    *ResourceQuery
+	// Model 仅匹配该模型生成的向量，未记录模型的历史数据(model 为空)视为同维度的任意模型
+	Model     string
+	Dimension int
+	// Knowledge 只匹配所属知识点满足筛选条件的向量
+	Knowledge *KnowledgeFilter
 }
 
 func (opts GetVectorsOptions) Apply(query *sq.SelectBuilder) {
@@ -37,7 +44,7 @@ func (opts GetVectorsOptions) Apply(query *sq.SelectBuilder) {
 		*query = query.Where(sq.Eq{"id": opts.ID})
 	}
 	if opts.KnowledgeID != "" {
-		*query = query.Where(sq.Eq{"knowledge_id": opts.ID})
+		*query = query.Where(sq.Eq{"knowledge_id": opts.KnowledgeID})
 	}
 	if opts.SpaceID != "" {
 		*query = query.Where(sq.Eq{"space_id": opts.SpaceID})
@@ -48,4 +55,13 @@ func (opts GetVectorsOptions) Apply(query *sq.SelectBuilder) {
 	if opts.Resource != nil {
 		*query = query.Where(opts.Resource.ToQuery())
 	}
+	if opts.Model != "" {
+		*query = query.Where(sq.Eq{"model": []string{opts.Model, ""}})
+	}
+	if opts.Dimension > 0 {
+		*query = query.Where(sq.Eq{"dimension": opts.Dimension})
+	}
+	if !opts.Knowledge.IsEmpty() {
+		*query = query.Where(opts.Knowledge.KnowledgeIDQuery("knowledge_id", opts.SpaceID))
+	}
 }
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// MarkdownDiff 按行比较两段文本，返回包含 unified diff 的 markdown 代码块，内容相同时返回空字符串
+func MarkdownDiff(fromName, toName, from, to string) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(strings.TrimRight(from, "\n")),
+		B:        difflib.SplitLines(strings.TrimRight(to, "\n")),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+	if err != nil || diff == "" {
+		return "", err
+	}
+
+	// 围栏需要长于内容中连续的反引号，避免代码块被内容提前结束
+	fence := "```"
+	for strings.Contains(diff, fence) {
+		fence += "`"
+	}
+	return fence + "diff\n" + strings.TrimRight(diff, "\n") + "\n" + fence, nil
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownDiff(t *testing.T) {
+	diff, err := MarkdownDiff("v1", "v2", "# Title\n\nline a\nline b\n", "# Title\n\nline a\nline c")
+	assert.NoError(t, err)
+	assert.Equal(t, "```diff\n--- v1\n+++ v2\n@@ -1,4 +1,4 @@\n # Title\n \n line a\n-line b\n+line c\n```", diff)
+
+	diff, err = MarkdownDiff("v1", "v2", "same\n", "same")
+	assert.NoError(t, err)
+	assert.Equal(t, "", diff)
+
+	diff, err = MarkdownDiff("v1", "v2", "```go\n```", "```js\n```")
+	assert.NoError(t, err)
+	assert.Equal(t, "````diff\n--- v1\n+++ v2\n@@ -1,2 +1,2 @@\n-```go\n+```js\n ```\n````", diff)
+}
@@ -3,8 +3,10 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/md5"
 	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -85,6 +87,17 @@ func MD5(s string) string {
 	return hex.EncodeToString(cipherStr)
 }
 
+func SHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func HMACSHA256(s string, key []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func BindArgsWithGin(c *gin.Context, req interface{}) error {
 	err := c.ShouldBindWith(req, binding.Default(c.Request.Method, c.ContentType()))
 	if err != nil {
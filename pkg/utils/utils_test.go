@@ -40,3 +40,12 @@ func Test_Crypt(t *testing.T) {
 
 	assert.Equal(t, plaintext, decrypted)
 }
+
+func Test_HMACSHA256(t *testing.T) {
+	key := []byte("test-key")
+	assert.Equal(t, HMACSHA256("hello world", key), HMACSHA256("hello world", key))
+	assert.NotEqual(t, HMACSHA256("hello world", key), HMACSHA256("hello world", []byte("other-key")))
+	// 带密钥的哈希不等于明文的 sha256
+	assert.NotEqual(t, SHA256("hello world"), HMACSHA256("hello world", key))
+	assert.Len(t, HMACSHA256("hello world", key), 64)
+}